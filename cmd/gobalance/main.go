@@ -2,32 +2,70 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/Nash0810/gobalance/internal/admin"
 	"github.com/Nash0810/gobalance/internal/backend"
 	"github.com/Nash0810/gobalance/internal/balancer"
+	"github.com/Nash0810/gobalance/internal/cache"
 	"github.com/Nash0810/gobalance/internal/config"
 	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/lifecycle"
 	"github.com/Nash0810/gobalance/internal/logging"
 	"github.com/Nash0810/gobalance/internal/metrics"
 	"github.com/Nash0810/gobalance/internal/retry"
 )
 
+// defaultConfigPath is used when no config file is given on the command line
+const defaultConfigPath = "configs/config.yaml"
+
+// backgroundStopTimeout is how long shutdown waits for background goroutines to return
+const backgroundStopTimeout = 5 * time.Second
+
+// options holds the parsed command-line flags
+type options struct {
+	configPath  string // Config file to load
+	checkConfig bool   // Validate the config and exit without serving
+}
+
 func main() {
+	opts, err := parseFlags(os.Args[1:])
+	if err != nil {
+		os.Exit(2) // flag package already printed usage
+	}
+
+	// Dry run: validate the config file and exit
+	if opts.checkConfig {
+		if err := checkConfig(opts.configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "config %s invalid: %v\n", opts.configPath, err)
+			os.Exit(1)
+		}
+		fmt.Printf("config %s OK\n", opts.configPath)
+		return
+	}
+
 	// Create logger
 	logger := logging.NewLogger("gobalance")
-	logger.Info("starting_load_balancer")
+	logger.Info("starting_load_balancer", "config", opts.configPath)
 
-	// Load configuration
-	cfg, err := config.LoadConfig("configs/config.yaml")
+	// Load configuration, rejecting exactly what --check-config rejects
+	cfg, err := loadConfig(opts.configPath)
 	if err != nil {
 		logger.Error("failed_to_load_config", "error", err.Error())
 		log.Fatal(err)
@@ -41,103 +79,306 @@ func main() {
 	}
 
 	// Create metrics collector
-	collector := metrics.NewCollector()
+	collector := metrics.NewCollectorWithOptions(prometheus.DefaultRegisterer, metrics.CollectorOptions{
+		DropMethodLabel: cfg.Metrics.DropMethodLabel,
+		DurationBuckets: cfg.Metrics.DurationBuckets,
+	})
 
-	// Create backend pool
+	// Create backend pool (transports are shared between backends with identical settings)
+	transports := backend.NewTransportCache()
 	pool := backend.NewPool()
+	pool.SetDrainTimeout(time.Duration(cfg.DrainTimeoutSeconds) * time.Second)
 	for _, pb := range parsedBackends {
-		b := backend.NewBackend(pb.URL)
-		b.SetWeight(pb.Weight) // Set weight from config
+		b := buildBackend(pb, cfg, transports)
 		pool.AddBackend(b)
 		logger.Info("backend_added",
 			"url", b.URL.String(),
 			"weight", b.Weight)
 	}
 
-	if pool.Size() == 0 {
+	if err := requireBackends(cfg, pool); err != nil {
 		logger.Error("no_backends_configured")
-		log.Fatal("No backends configured")
+		log.Fatal(err)
+	}
+	if pool.Size() == 0 && cfg.AllowEmptyBackends {
+		// Discovery may not be ready yet: requests get 503 until a reload adds backends
+		logger.Warn("starting_without_backends")
 	}
 
 	// Create strategy based on config
-	var strategy balancer.Strategy
-	switch cfg.Strategy {
-	case "round-robin":
-		strategy = balancer.NewRoundRobinStrategy()
-	case "weighted-round-robin":
-		strategy = balancer.NewWeightedRoundRobinStrategy()
-	case "least-connections":
-		strategy = balancer.NewLeastConnectionsStrategy()
-	default:
+	strategy, known := newPoolStrategy(cfg, cfg.Strategy)
+	if !known {
 		logger.Warn("unknown_strategy_using_roundrobin",
 			"strategy", cfg.Strategy)
-		strategy = balancer.NewRoundRobinStrategy()
 	}
 	logger.Info("strategy_selected",
 		"strategy", strategy.Name())
 
-	// Create context for cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Build backend groups for virtual-host routing; each may name its own strategy
+	groupPools := make(map[string]*backend.Pool)
+	groupStrategies := make(map[string]balancer.Strategy)
+	for _, g := range cfg.Groups {
+		groupBackends, err := g.ParseBackends()
+		if err != nil {
+			logger.Error("failed_to_parse_group_backends", "group", g.Name, "error", err.Error())
+			log.Fatal(err)
+		}
+		groupPool := backend.NewPool()
+		groupPool.SetDrainTimeout(time.Duration(cfg.DrainTimeoutSeconds) * time.Second)
+		for _, pb := range groupBackends {
+			groupPool.AddBackend(buildBackend(pb, cfg, transports))
+		}
+		groupPools[g.Name] = groupPool
+		groupStrategy := g.Strategy
+		if groupStrategy == "" {
+			groupStrategy = cfg.Strategy
+		}
+		groupStrategies[g.Name], _ = newPoolStrategy(cfg, groupStrategy)
+		logger.Info("backend_group_added",
+			"group", g.Name,
+			"backends", groupPool.Size(),
+			"strategy", groupStrategies[g.Name].Name())
+	}
+
+	// Seed backends known to be down before the restart; they rejoin once health checks pass
+	var stateFile *backend.StateFile
+	if cfg.StateFile.Path != "" {
+		stateFile = backend.NewStateFile(cfg.StateFile.Path, time.Duration(cfg.StateFile.MaxAgeSeconds)*time.Second)
+		seeded, err := stateFile.Seed(allBackends(pool, groupPools))
+		if err != nil {
+			logger.Warn("backend_state_load_failed", "path", cfg.StateFile.Path, "error", err.Error())
+		} else {
+			logger.Info("backend_states_seeded", "path", cfg.StateFile.Path, "unhealthy", seeded)
+		}
+	}
+
+	// Background goroutines share a context and are waited for on shutdown
+	background := lifecycle.NewGroup(context.Background())
+	pool.SetBackground(background)
+	for _, groupPool := range groupPools {
+		groupPool.SetBackground(background)
+	}
+
+	if stateFile != nil {
+		interval := time.Duration(cfg.StateFile.IntervalSeconds) * time.Second
+		background.Go("state_file", func(ctx context.Context) {
+			persistStates(ctx, stateFile, func() []*backend.Backend { return allBackends(pool, groupPools) }, interval, logger)
+		})
+	}
 
-	// Start active health checker
+	// Keep a minimum of each pool healthy; one guard for all checkers so
+	// ejections are decided one at a time
+	var ejectionGuard *health.EjectionGuard
+	if cfg.HealthCheck.MinHealthy > 0 || cfg.HealthCheck.MinHealthyFraction > 0 {
+		ejectionGuard = health.NewEjectionGuard(cfg.HealthCheck.MinHealthy, cfg.HealthCheck.MinHealthyFraction)
+		logger.Info("min_healthy_configured",
+			"min_healthy", cfg.HealthCheck.MinHealthy,
+			"min_healthy_fraction", cfg.HealthCheck.MinHealthyFraction)
+	}
+
+	// Create active health checker (started once the balancer exists)
 	activeChecker := health.NewActiveChecker(pool, cfg.HealthCheck, collector, logger)
-	go activeChecker.Start(ctx)
+	activeChecker.SetEjectionGuard(ejectionGuard)
 
 	// Create passive tracker
 	passiveTracker := health.NewPassiveTracker(5) // 5 failures threshold
+	passiveTracker.SetRecoveryThreshold(cfg.HealthCheck.PassiveRecovery)
+	passiveTracker.SetRecoveryCooldown(time.Duration(cfg.HealthCheck.PassiveCooldownMs) * time.Millisecond)
+	passiveTracker.SetEjectionGuard(ejectionGuard)
 
-	// Create retry policy
-	retryPolicy := retry.NewPolicy(cfg.Retry.MaxAttempts, cfg.Retry.BudgetPercent)
-	if cfg.Retry.Enabled {
+	// Create retry policy (nil when disabled so request bodies stream unbuffered)
+	retryPolicy := newRetryPolicy(cfg.Retry)
+	if retryPolicy != nil {
 		logger.Info("retry_enabled",
 			"max_attempts", cfg.Retry.MaxAttempts,
-			"budget_percent", cfg.Retry.BudgetPercent)
+			"budget_percent", cfg.Retry.BudgetPercent,
+			"max_per_backend", retryPolicy.MaxPerBackend(),
+			"to_healthiest", cfg.Retry.ToHealthiest)
 	}
 
 	// Log request timeout configuration (FIX #8)
 	logger.Info("request_timeout_configured",
-		"timeout_seconds", cfg.RequestTimeout)
+		"timeout_seconds", cfg.RequestTimeout,
+		"connect_timeout_ms", cfg.ConnectTimeoutMs)
 
 	// Create balancer with metrics, logging, and timeout
 	requestTimeout := time.Duration(cfg.RequestTimeout) * time.Second
 	lb := balancer.NewBalancer(pool, strategy, passiveTracker, retryPolicy, requestTimeout, collector, logger)
+	lb.SetMaxInMemoryBodyBytes(cfg.MaxInMemoryBodyBytes)
+	lb.SetBodyReadErrorStatus(cfg.BodyReadErrorStatus)
+	lb.SetRetryToHealthiest(cfg.Retry.ToHealthiest)
+	lb.SetClientCertHeader(cfg.TLS.ClientHeader)
+	lb.SetCircuitBreakerTimeoutWeight(cfg.CircuitBreaker.TimeoutWeight)
+	if cfg.ServerTimingKey != "" {
+		lb.SetServerTimingKey([]byte(cfg.ServerTimingKey))
+	}
+	lb.SetServerTiming(cfg.ServerTiming)
 
-	// Start metrics exporter
-	exporter := metrics.NewExporter(collector, pool, retryPolicy.GetBudget())
-	go exporter.Start(ctx)
+	// Start active health checker, optionally feeding the circuit breakers
+	if cfg.HealthCheck.FeedCircuitBreaker {
+		activeChecker.SetCircuitBreakerSource(lb.CircuitBreaker)
+		logger.Info("health_checks_feed_circuit_breaker")
+	}
+	background.Go("active_checker", activeChecker.Start)
+
+	// Route virtual hosts to their groups; each group gets its own health checker
+	for name, groupPool := range groupPools {
+		groupChecker := health.NewActiveChecker(groupPool, cfg.HealthCheck, collector, logger)
+		groupChecker.SetEjectionGuard(ejectionGuard)
+		if cfg.HealthCheck.FeedCircuitBreaker {
+			groupChecker.SetCircuitBreakerSource(lb.CircuitBreaker)
+		}
+		background.Go("active_checker_"+name, groupChecker.Start)
+	}
+	// hostRoutes resolves host routing entries to their group's pool and strategy
+	hostRoutes := func(kind string, routes []config.VirtualHostConfig) []balancer.VirtualHost {
+		var vhosts []balancer.VirtualHost
+		for _, vh := range routes {
+			groupPool, exists := groupPools[vh.Group]
+			if !exists {
+				logger.Error(kind+"_unknown_group", "host", vh.Host, "group", vh.Group)
+				log.Fatalf("%s %s: unknown group %s", kind, vh.Host, vh.Group)
+			}
+			vhosts = append(vhosts, balancer.VirtualHost{
+				Pattern:  vh.Host,
+				Pool:     groupPool,
+				Strategy: groupStrategies[vh.Group],
+			})
+			logger.Info(kind+"_configured", "host", vh.Host, "group", vh.Group)
+		}
+		return vhosts
+	}
+	if len(cfg.VirtualHosts) > 0 {
+		lb.SetVirtualHosts(hostRoutes("virtual_host", cfg.VirtualHosts))
+	}
+	if len(cfg.SNIRoutes) > 0 {
+		lb.SetSNIRoutes(hostRoutes("sni_route", cfg.SNIRoutes))
+	}
+	// Requests matching no route go to the default group, or get 404 when
+	// only routes are configured
+	if cfg.DefaultGroup != "" {
+		lb.SetDefaultRoute(&balancer.VirtualHost{
+			Pattern:  "*",
+			Pool:     groupPools[cfg.DefaultGroup],
+			Strategy: groupStrategies[cfg.DefaultGroup],
+		})
+		logger.Info("default_group_configured", "group", cfg.DefaultGroup)
+	} else if len(cfg.Backends) == 0 && !cfg.AllowEmptyBackends {
+		lb.SetDefaultRoute(nil)
+		logger.Info("unmatched_requests_rejected", "status", http.StatusNotFound)
+	}
 
-	// Start config watcher for hot reload
-	configWatcher, err := config.NewWatcher("configs/config.yaml", logger, func(newCfg *config.Config) error {
-		logger.Info("applying_config_reload")
+	// Configure which response statuses count against backend health
+	if len(cfg.FailurePolicy.IgnoreStatus) > 0 || len(cfg.FailurePolicy.FailStatus) > 0 {
+		lb.SetFailurePredicate(balancer.StatusFailurePredicate(cfg.FailurePolicy.IgnoreStatus, cfg.FailurePolicy.FailStatus))
+		logger.Info("failure_policy_configured",
+			"ignore_status", cfg.FailurePolicy.IgnoreStatus,
+			"fail_status", cfg.FailurePolicy.FailStatus)
+	}
 
-		// Parse new backends
-		newBackends, err := newCfg.ParseBackends()
-		if err != nil {
-			return err
+	// Limit in-flight requests, queueing the excess in arrival order
+	if cfg.Admission.Enabled {
+		admissionWait := time.Duration(cfg.Admission.QueueTimeoutMs) * time.Millisecond
+		lb.SetAdmissionQueue(balancer.NewAdmissionQueue(cfg.Admission.MaxInFlight, cfg.Admission.MaxQueue), admissionWait)
+		logger.Info("admission_queue_enabled",
+			"max_in_flight", cfg.Admission.MaxInFlight,
+			"max_queue", cfg.Admission.MaxQueue,
+			"queue_timeout_ms", cfg.Admission.QueueTimeoutMs)
+		if cfg.Admission.TenantHeader != "" {
+			lb.SetTenantWeights(&balancer.TenantWeights{
+				Header:  cfg.Admission.TenantHeader,
+				Weights: cfg.Admission.TenantWeights,
+				Default: cfg.Admission.DefaultTenantWeight,
+			})
+			logger.Info("tenant_admission_weights_enabled",
+				"header", cfg.Admission.TenantHeader,
+				"tenants", len(cfg.Admission.TenantWeights))
 		}
+	}
 
-		// Create new backend instances
-		var backends []*backend.Backend
-		for _, pb := range newBackends {
-			b := backend.NewBackend(pb.URL)
-			b.SetWeight(pb.Weight)
-			backends = append(backends, b)
-			logger.Info("new_backend_configured",
-				"url", b.URL.String(),
-				"weight", b.Weight)
-		}
+	// Rank requests by a priority header; budgets apply even without the queue
+	if cfg.Admission.PriorityHeader != "" {
+		lb.SetRequestPriorities(requestPriorities(cfg.Admission))
+		logger.Info("request_priorities_enabled",
+			"header", cfg.Admission.PriorityHeader,
+			"budgets", len(cfg.Admission.Priorities),
+			"trusted", len(cfg.Admission.PriorityTrusted))
+	}
 
-		// Replace backends in pool (preserves health state of existing backends)
-		pool.ReplaceBackends(backends)
+	// Hold requests during a total outage until a backend returns
+	if cfg.FailOpen.Enabled {
+		lb.SetFailOpen(time.Duration(cfg.FailOpen.MaxWaitMs)*time.Millisecond, cfg.FailOpen.MaxHeld)
+		logger.Info("fail_open_enabled",
+			"max_wait_ms", cfg.FailOpen.MaxWaitMs,
+			"max_held", cfg.FailOpen.MaxHeld)
+	}
 
-		logger.Info("backends_reloaded", "count", len(backends))
-		return nil
-	})
+	// Reject filtered methods and paths at the edge
+	ac := cfg.AccessControl
+	if len(ac.AllowMethods)+len(ac.DenyMethods)+len(ac.AllowPaths)+len(ac.DenyPaths) > 0 {
+		lb.SetAccessControl(&balancer.AccessControl{
+			AllowMethods: ac.AllowMethods,
+			DenyMethods:  ac.DenyMethods,
+			AllowPaths:   ac.AllowPaths,
+			DenyPaths:    ac.DenyPaths,
+		})
+		logger.Info("access_control_enabled",
+			"allow_methods", ac.AllowMethods,
+			"deny_methods", ac.DenyMethods,
+			"allow_paths", ac.AllowPaths,
+			"deny_paths", ac.DenyPaths)
+	}
+
+	// Pin clients to a backend with a cookie
+	if cfg.StickySessions.Enabled {
+		lb.SetStickySessions(&balancer.StickySessions{
+			CookieName:    cfg.StickySessions.CookieName,
+			TTL:           time.Duration(cfg.StickySessions.TTLSeconds) * time.Second,
+			OnBackendDown: cfg.StickySessions.OnBackendDown,
+		})
+		logger.Info("sticky_sessions_enabled",
+			"cookie", cfg.StickySessions.CookieName,
+			"ttl_seconds", cfg.StickySessions.TTLSeconds,
+			"on_backend_down", cfg.StickySessions.OnBackendDown)
+	}
+
+	// Enable response caching for cacheable GETs
+	if cfg.Cache.Enabled {
+		responseCache := cache.NewCache(cfg.Cache.MaxEntries, cfg.Cache.MaxEntryBytes)
+		responseCache.SetStaleIfError(time.Duration(cfg.Cache.StaleIfErrorSeconds) * time.Second)
+		lb.SetCache(responseCache)
+		logger.Info("response_cache_enabled",
+			"max_entries", cfg.Cache.MaxEntries,
+			"max_entry_bytes", cfg.Cache.MaxEntryBytes,
+			"stale_if_error_seconds", cfg.Cache.StaleIfErrorSeconds)
+	}
+
+	// Start metrics exporter
+	var retryBudget *retry.Budget
+	if retryPolicy != nil {
+		retryBudget = retryPolicy.GetBudget()
+	}
+	// The top-level pool gets no gauges when no request can reach it
+	var exportedPool *backend.Pool
+	if servesTopLevelPool(cfg) {
+		exportedPool = pool
+	}
+	exporter := metrics.NewExporter(collector, exportedPool, retryBudget)
+	background.Go("exporter", exporter.Start)
+	for name, groupPool := range groupPools {
+		groupExporter := metrics.NewExporter(collector, groupPool, nil)
+		groupExporter.SetPoolName(name)
+		background.Go("exporter_"+name, groupExporter.Start)
+	}
+
+	// Start config watcher for hot reload (shares the reload path with /admin/reload)
+	configReloader := newReloader(opts.configPath, pool, transports, logger)
+	configReloader.setGroupPools(groupPools)
+	configWatcher, err := config.NewWatcher(opts.configPath, logger, configReloader.applyConfig)
 	if err != nil {
 		logger.Error("failed_to_create_config_watcher", "error", err.Error())
 	} else {
-		go configWatcher.Start(ctx)
+		background.Go("config_watcher", configWatcher.Start)
 	}
 
 	// Create HTTP server
@@ -149,20 +390,37 @@ func main() {
 	// Metrics endpoint
 	mux.Handle("/metrics", promhttp.Handler())
 
-	// Health endpoint for load balancer itself
-	mux.HandleFunc("/lb-health", func(w http.ResponseWriter, r *http.Request) {
-		backends := pool.GetHealthyBackends()
-		if len(backends) == 0 {
-			http.Error(w, "No healthy backends", http.StatusServiceUnavailable)
-			return
+	// Admin API (backend status, on-demand reload, retry budget, backend
+	// controls) on its own listener, off the traffic port. Without admin_port
+	// there is none, and /admin/ paths are proxied like any other.
+	var adminServer *http.Server
+	if cfg.AdminPort != 0 {
+		adminHandler := admin.NewHandler(pool, logger)
+		adminHandler.SetReloadFunc(configReloader.reload)
+		adminHandler.SetRetryPolicy(retryPolicy)
+		adminHandler.SetCircuitBreakers(lb.CircuitBreaker)
+		adminHandler.SetGroupPools(groupPools)
+		adminHandler.EnableBackendControls()
+		if cfg.EnablePprof {
+			adminHandler.EnablePprof()
 		}
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"status":"ok","healthy_backends":%d}`, len(backends))
-	})
+		adminServer = &http.Server{
+			Addr:    net.JoinHostPort(cfg.AdminBindAddress, strconv.Itoa(cfg.AdminPort)),
+			Handler: adminHandler,
+		}
+	}
+
+	// Readiness flips to 503 as soon as shutdown starts
+	ready := newReadiness(collector.Draining)
+	mux.Handle("/readyz", ready)
+
+	// Health endpoint for load balancer itself
+	mux.Handle("/lb-health", lbHealth(routedPools(cfg, pool, groupPools)))
 
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", cfg.Port),
-		Handler: mux,
+	server := newServer(cfg, mux)
+	if server.TLSConfig, err = serverTLSConfig(cfg.TLS); err != nil {
+		logger.Error("failed_to_load_client_ca", "error", err.Error())
+		log.Fatal(err)
 	}
 
 	// Handle graceful shutdown
@@ -172,27 +430,329 @@ func main() {
 	// Start server in background
 	go func() {
 		logger.Info("server_starting",
-			"addr", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			"addr", server.Addr,
+			"tls", cfg.TLS.Enabled())
+		if err := listenAndServe(server, cfg.TLS); err != nil && err != http.ErrServerClosed {
 			logger.Error("server_error", "error", err.Error())
 			log.Fatal(err)
 		}
 	}()
+	if adminServer != nil {
+		go func() {
+			logger.Info("admin_server_starting",
+				"addr", adminServer.Addr,
+				"pprof", cfg.EnablePprof)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("admin_server_error", "error", err.Error())
+				log.Fatal(err)
+			}
+		}()
+	}
 
 	// Wait for shutdown signal
 	<-sigChan
 	logger.Info("shutdown_signal_received")
+	beginShutdown(ready, time.Duration(cfg.ShutdownDelaySeconds)*time.Second, logger)
 
 	// Graceful shutdown with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout(cfg))
 	defer shutdownCancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error("shutdown_error", "error", err.Error())
 	}
+	if err := awaitInFlight(shutdownCtx, lb.InFlight); err != nil {
+		logger.Error("shutdown_requests_abandoned", "error", err.Error())
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			logger.Error("admin_shutdown_error", "error", err.Error())
+		}
+	}
 
-	// Cancel background contexts
-	cancel()
+	// Stop background goroutines and wait for them to return
+	if err := background.Stop(backgroundStopTimeout); err != nil {
+		logger.Error("background_shutdown_timeout", "error", err.Error())
+	}
+
+	if stateFile != nil {
+		if err := stateFile.Save(allBackends(pool, groupPools)); err != nil {
+			logger.Error("backend_state_save_failed", "error", err.Error())
+		}
+	}
 
 	logger.Info("shutdown_complete")
 }
+
+// newPoolStrategy creates the named strategy for one pool, preferring
+// backends in the local zone when one is set
+func newPoolStrategy(cfg *config.Config, name string) (balancer.Strategy, bool) {
+	strategy, known := newStrategy(name, cfg)
+	if cfg.LocalZone == "" {
+		return strategy, known
+	}
+	spillover, _ := newStrategy(name, cfg)
+	locality := balancer.NewLocalityStrategy(cfg.LocalZone, strategy, spillover)
+	locality.SetMaxActiveRequests(int64(cfg.LocalZoneMaxActive))
+	return locality, known
+}
+
+// newStrategy creates a strategy by config name, falling back to round-robin.
+// Returns false if the name wasn't recognized.
+func newStrategy(name string, cfg *config.Config) (balancer.Strategy, bool) {
+	switch name {
+	case "round-robin":
+		return balancer.NewRoundRobinStrategy(), true
+	case "weighted-round-robin":
+		wrr := balancer.NewWeightedRoundRobinStrategy()
+		wrr.SetFailurePenalty(time.Duration(cfg.FailurePenaltyMs) * time.Millisecond)
+		return wrr, true
+	case "least-connections":
+		return balancer.NewLeastConnectionsStrategy(), true
+	case "weighted-random":
+		return balancer.NewWeightedRandomStrategy(), true
+	case "latency-p99":
+		return balancer.NewLatencyP99Strategy(), true
+	case "weighted-latency":
+		return balancer.NewWeightedLatencyStrategy(), true
+	case "composite":
+		return balancer.NewCompositeScoreStrategy(compositeCoefficients(cfg.CompositeScore)), true
+	default:
+		return balancer.NewRoundRobinStrategy(), false
+	}
+}
+
+// compositeCoefficients converts the composite score config, taking the
+// default for each coefficient that isn't set
+func compositeCoefficients(cfg config.CompositeScoreConfig) balancer.CompositeScoreCoefficients {
+	coefficients := balancer.DefaultCompositeScoreCoefficients
+	if cfg.Connections != nil {
+		coefficients.Connections = *cfg.Connections
+	}
+	if cfg.ErrorRate != nil {
+		coefficients.ErrorRate = *cfg.ErrorRate
+	}
+	if cfg.LatencyMs != nil {
+		coefficients.LatencyMs = *cfg.LatencyMs
+	}
+	return coefficients
+}
+
+// newRetryPolicy creates the retry policy, or nil if retries are disabled.
+// The balancer only buffers request bodies when it has a policy.
+func newRetryPolicy(cfg config.RetryConfig) *retry.Policy {
+	if !cfg.Enabled {
+		return nil
+	}
+	policy := retry.NewPolicy(cfg.MaxAttempts, cfg.BudgetPercent)
+	policy.SetMaxPerBackend(cfg.MaxPerBackend)
+	policy.SetRetryOn(cfg.RetryOn)
+	return policy
+}
+
+// buildBackend creates a backend from its parsed config
+func buildBackend(pb *config.ParsedBackend, cfg *config.Config, transports *backend.TransportCache) *backend.Backend {
+	b := backend.NewBackend(pb.URL)
+	b.SetWeight(pb.Weight) // Set weight from config
+	b.SetTransport(transports.Get(transportConfig(pb, cfg)))
+	b.SetFlushInterval(flushInterval(cfg.FlushIntervalMs))
+	b.SetMaxResponseHeaderBytes(cfg.MaxBackendHeaderBytes)
+	if pb.HealthURL != nil {
+		b.SetHealthURL(pb.HealthURL)
+	}
+	b.Tags = pb.Tags
+	b.SetRetryable(pb.Retryable)
+	return b
+}
+
+// parseFlags parses command-line arguments. The config path may instead be
+// given positionally, e.g. "gobalance --check-config configs/config.yaml",
+// but not both ways at once.
+func parseFlags(args []string) (*options, error) {
+	opts := &options{}
+	fs := flag.NewFlagSet("gobalance", flag.ContinueOnError)
+	fs.StringVar(&opts.configPath, "config", defaultConfigPath, "config file, comma-separated files, or directory, merged in order")
+	fs.BoolVar(&opts.checkConfig, "check-config", false, "validate the config file and exit")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 1 {
+		return nil, fmt.Errorf("expected at most one config path, got %d", fs.NArg())
+	}
+	if fs.NArg() == 1 {
+		explicit := false
+		fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "config" })
+		if explicit {
+			return nil, fmt.Errorf("config path given both as --config and as an argument")
+		}
+		opts.configPath = fs.Arg(0)
+	}
+	if opts.configPath == "" {
+		return nil, fmt.Errorf("config path must not be empty")
+	}
+	return opts, nil
+}
+
+// servesTopLevelPool reports whether any request can reach the top-level
+// backends: not when a default group takes unmatched requests, nor when only
+// host routes are configured and unmatched requests get 404
+func servesTopLevelPool(cfg *config.Config) bool {
+	return cfg.DefaultGroup == "" && (len(cfg.Backends) > 0 || cfg.AllowEmptyBackends)
+}
+
+// routedPools returns the pools requests can be routed to: the top-level pool
+// if it serves requests, then every group named by a route or as the default group
+func routedPools(cfg *config.Config, pool *backend.Pool, groupPools map[string]*backend.Pool) []*backend.Pool {
+	var pools []*backend.Pool
+	if servesTopLevelPool(cfg) {
+		pools = append(pools, pool)
+	}
+	routed := map[string]bool{cfg.DefaultGroup: cfg.DefaultGroup != ""}
+	for _, vh := range slices.Concat(cfg.VirtualHosts, cfg.SNIRoutes) {
+		routed[vh.Group] = true
+	}
+	for _, g := range cfg.Groups {
+		if routed[g.Name] {
+			pools = append(pools, groupPools[g.Name])
+		}
+	}
+	return pools
+}
+
+// lbHealth reports the balancer healthy while any of pools has a healthy backend
+func lbHealth(pools []*backend.Pool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		healthy := 0
+		for _, p := range pools {
+			healthy += len(p.GetHealthyBackends())
+		}
+		if healthy == 0 {
+			http.Error(w, "No healthy backends", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"ok","healthy_backends":%d}`, healthy)
+	}
+}
+
+// requireBackends fails when the pool is empty, unless the config allows
+// starting without backends or routes all traffic to groups
+func requireBackends(cfg *config.Config, pool *backend.Pool) error {
+	if pool.Size() == 0 && cfg.RequiresBackends() {
+		return fmt.Errorf("no backends configured")
+	}
+	return nil
+}
+
+// checkConfig loads and validates a config file without starting the server
+func checkConfig(path string) error {
+	_, err := loadConfig(path)
+	return err
+}
+
+// loadConfig loads a config file and validates it. Startup and the
+// --check-config dry run both use it, so they accept the same files.
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// transportConfig maps a backend's protocol, keepalive and connect timeout
+// settings to a transport configuration. The idle connection limit is raised
+// to warm_up.connections so warmed connections aren't closed straight away.
+func transportConfig(pb *config.ParsedBackend, cfg *config.Config) backend.TransportConfig {
+	protocol := pb.Protocol
+	if protocol == "" {
+		protocol = backend.ProtocolHTTP1
+	}
+	return backend.TransportConfig{
+		Protocol:            protocol,
+		DisableKeepAlives:   pb.KeepAlive.Disabled,
+		IdleConnTimeout:     time.Duration(pb.KeepAlive.IdleTimeoutSeconds) * time.Second,
+		MaxIdleConnsPerHost: max(pb.KeepAlive.MaxIdleConnsPerHost, cfg.WarmUp.Connections),
+		LocalAddr:           pb.LocalAddr,
+		ConnectTimeout:      time.Duration(cfg.ConnectTimeoutMs) * time.Millisecond,
+	}
+}
+
+// flushInterval converts the configured flush interval (-1 = immediate) to a duration
+func flushInterval(ms int) time.Duration {
+	if ms < 0 {
+		return -1
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// newServer builds the traffic server. MaxHeaderBytes caps how much of a
+// request's headers net/http reads before answering 431, so a client sending
+// enormous headers cannot make the proxy buffer them before the handler runs.
+func newServer(cfg *config.Config, handler http.Handler) *http.Server {
+	maxHeaderBytes := cfg.MaxHeaderBytes
+	if maxHeaderBytes == 0 {
+		maxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	return &http.Server{
+		Addr:           fmt.Sprintf(":%d", cfg.Port),
+		Handler:        handler,
+		MaxHeaderBytes: maxHeaderBytes,
+	}
+}
+
+// requestPriorities converts the admission priority settings for the balancer
+func requestPriorities(cfg config.AdmissionConfig) *balancer.RequestPriorities {
+	budgets := make(map[balancer.Priority]balancer.PriorityBudget, len(cfg.Priorities))
+	for name, p := range cfg.Priorities {
+		priority, _ := balancer.ParsePriority(name)
+		budgets[priority] = balancer.PriorityBudget{
+			Timeout:     time.Duration(p.TimeoutMs) * time.Millisecond,
+			MaxAttempts: p.MaxAttempts,
+		}
+	}
+	var trusted []netip.Prefix
+	for _, cidr := range cfg.PriorityTrusted {
+		if prefix, err := netip.ParsePrefix(cidr); err == nil { // Validated at load
+			trusted = append(trusted, prefix.Masked())
+		}
+	}
+	return &balancer.RequestPriorities{Header: cfg.PriorityHeader, Budgets: budgets, TrustedSources: trusted}
+}
+
+// serverTLSConfig returns the traffic port's TLS settings beyond the
+// certificate: the CAs and mode for verifying client certificates, or nil
+// when client certificates are not requested
+func serverTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	var clientAuth tls.ClientAuthType
+	switch cfg.ClientAuthMode() {
+	case config.ClientAuthOptional:
+		clientAuth = tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		clientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("client_ca_file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("client_ca_file %s holds no PEM certificates", cfg.ClientCAFile)
+	}
+	return &tls.Config{ClientCAs: pool, ClientAuth: clientAuth}, nil
+}
+
+// listenAndServe serves plain HTTP, or terminates TLS when a certificate is
+// configured. The negotiated server name (SNI) reaches the balancer in r.TLS.
+func listenAndServe(server *http.Server, cfg config.TLSConfig) error {
+	if cfg.Enabled() {
+		return server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,842 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/admin"
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/balancer"
+	"github.com/Nash0810/gobalance/internal/config"
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/lifecycle"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/Nash0810/gobalance/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// writeConfig writes a config file into a temp directory and returns its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+// TestCheckConfig tests the dry-run config check accepts good configs and rejects bad ones
+func TestCheckConfig(t *testing.T) {
+	good := writeConfig(t, `
+port: 9090
+strategy: "round-robin"
+backends:
+  - url: "http://localhost:8081"
+    weight: 3
+`)
+	if err := checkConfig(good); err != nil {
+		t.Errorf("Expected good config to pass, got %v", err)
+	}
+
+	bad := writeConfig(t, `
+port: 9090
+strategy: "fastest"
+backends:
+  - url: "http://localhost:8081"
+    weight: 500
+`)
+	if err := checkConfig(bad); err == nil {
+		t.Error("Expected bad config to fail validation")
+	}
+
+	if err := checkConfig(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected missing config file to fail")
+	}
+}
+
+// TestStartupRejectsInvalidConfig tests startup refuses the configs the
+// --check-config dry run rejects, instead of serving with them
+func TestStartupRejectsInvalidConfig(t *testing.T) {
+	if path := os.Getenv("GOBALANCE_TEST_STARTUP_CONFIG"); path != "" {
+		os.Args = []string{"gobalance", "--config", path}
+		main()
+		os.Exit(0) // main only returns on shutdown
+	}
+
+	for _, tt := range []struct {
+		name   string
+		config string
+	}{
+		{"bad duration buckets", "metrics:\n  duration_buckets: [0.5, 0.1]\n"},
+		{"unknown retry class", "retry:\n  enabled: true\n  retry_on: [dns]\n"},
+		{"unknown check type", "health_check:\n  check_type: tcp\n"},
+		{"state file without health checks", "state_file:\n  path: /tmp/states.json\n"},
+	} {
+		path := writeConfig(t, "backends:\n  - url: \"http://localhost:8081\"\n"+tt.config)
+		if err := checkConfig(path); err == nil {
+			t.Fatalf("%s: expected the dry run to reject the config", tt.name)
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestStartupRejectsInvalidConfig$")
+		cmd.Env = append(os.Environ(), "GOBALANCE_TEST_STARTUP_CONFIG="+path)
+		done := make(chan error, 1)
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start: %v", err)
+		}
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%s: expected startup to fail", tt.name)
+			}
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			t.Errorf("%s: expected startup to fail, but the server kept running", tt.name)
+		}
+	}
+}
+
+// TestParseFlagsCheckConfig tests --check-config accepts the path as a flag or positionally
+func TestParseFlagsCheckConfig(t *testing.T) {
+	opts, err := parseFlags([]string{"--check-config", "/etc/gobalance/config.yaml"})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if !opts.checkConfig || opts.configPath != "/etc/gobalance/config.yaml" {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+
+	opts, err = parseFlags([]string{"--check-config", "--config", "other.yaml"})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if opts.configPath != "other.yaml" {
+		t.Errorf("Expected config path other.yaml, got %s", opts.configPath)
+	}
+
+	if _, err := parseFlags([]string{"--bogus"}); err == nil {
+		t.Error("Expected unknown flag to fail")
+	}
+}
+
+// TestParseFlagsConfigPath tests the config path defaults and can be overridden
+func TestParseFlagsConfigPath(t *testing.T) {
+	opts, err := parseFlags(nil)
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if opts.configPath != defaultConfigPath || opts.checkConfig {
+		t.Errorf("Expected defaults, got %+v", opts)
+	}
+
+	opts, err = parseFlags([]string{"--config", "/etc/gobalance/config.yaml"})
+	if err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if opts.configPath != "/etc/gobalance/config.yaml" {
+		t.Errorf("Expected /etc/gobalance/config.yaml, got %s", opts.configPath)
+	}
+
+	if _, err := parseFlags([]string{"--config", ""}); err == nil {
+		t.Error("Expected empty config path to fail")
+	}
+	if _, err := parseFlags([]string{"--config", "a.yaml", "b.yaml"}); err == nil {
+		t.Error("Expected a positional path alongside --config to fail")
+	}
+	if _, err := parseFlags([]string{"a.yaml", "b.yaml"}); err == nil {
+		t.Error("Expected two positional paths to fail")
+	}
+}
+
+// TestAdminReload tests POST /admin/reload applies a valid config and rejects an invalid one
+func TestAdminReload(t *testing.T) {
+	path := writeConfig(t, `
+backends:
+  - url: "http://localhost:8081"
+`)
+	logger := logging.NewLogger("test")
+	pool := backend.NewPool()
+	rl := newReloader(path, pool, backend.NewTransportCache(), logger)
+	handler := admin.NewHandler(pool, logger)
+	handler.SetReloadFunc(rl.reload)
+
+	postReload := func() (*httptest.ResponseRecorder, map[string]any) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/admin/reload", nil))
+		var result map[string]any
+		json.NewDecoder(w.Body).Decode(&result)
+		return w, result
+	}
+
+	w, result := postReload()
+	if w.Code != http.StatusOK || result["status"] != "ok" {
+		t.Fatalf("Expected successful reload, got %d %v", w.Code, result)
+	}
+	if pool.Size() != 1 {
+		t.Fatalf("Expected 1 backend after reload, got %d", pool.Size())
+	}
+
+	// Invalid config on disk: rejected, pool untouched
+	if err := os.WriteFile(path, []byte(`
+backends:
+  - url: "http://localhost:8081"
+  - url: "http://localhost:8082"
+    weight: 500
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, result = postReload()
+	if w.Code != http.StatusUnprocessableEntity || result["status"] != "error" || result["error"] == "" {
+		t.Errorf("Expected validation error, got %d %v", w.Code, result)
+	}
+	if pool.Size() != 1 {
+		t.Errorf("Invalid reload should leave the pool unchanged, got %d backends", pool.Size())
+	}
+}
+
+// TestReloadSkipsBadBackend tests a reload applies the valid backends and logs the bad one
+func TestReloadSkipsBadBackend(t *testing.T) {
+	path := writeConfig(t, `
+backends:
+  - url: "http://localhost:8081"
+  - url: "http://[::1"
+  - url: "http://localhost:8083"
+`)
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	pool := backend.NewPool()
+	rl := newReloader(path, pool, backend.NewTransportCache(), logging.NewLogger("test"))
+	if err := rl.reload(); err != nil {
+		t.Fatalf("Expected lenient reload to succeed, got %v", err)
+	}
+
+	if pool.Size() != 2 {
+		t.Fatalf("Expected the 2 valid backends to be applied, got %d", pool.Size())
+	}
+	for _, b := range pool.GetBackends() {
+		if b.URL.Host != "localhost:8081" && b.URL.Host != "localhost:8083" {
+			t.Errorf("Unexpected backend %s", b.URL)
+		}
+	}
+	if !strings.Contains(logs.String(), "backend_skipped_on_reload") || !strings.Contains(logs.String(), "[::1") {
+		t.Errorf("Expected the skipped backend to be logged, got:\n%s", logs.String())
+	}
+}
+
+// TestReloadWarmsUpNewBackend tests a backend added on reload gets its warm-up
+// probes before it serves its first real request
+func TestReloadWarmsUpNewBackend(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	path := writeConfig(t, "backends:\n  - url: \"http://localhost:8081\"\n")
+	pool := backend.NewPool()
+	rl := newReloader(path, pool, backend.NewTransportCache(), logging.NewLogger("test"))
+	if err := rl.reload(); err != nil {
+		t.Fatalf("Initial reload failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`
+warm_up:
+  connections: 2
+health_check:
+  path: "/ready"
+backends:
+  - url: "http://localhost:8081"
+  - url: "`+server.URL+`"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	var added *backend.Backend
+	for _, b := range pool.GetBackends() {
+		if b.URL.String() == server.URL {
+			added = b
+		}
+	}
+	if added == nil {
+		t.Fatal("New backend missing after reload")
+	}
+	added.ReverseProxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/app", nil))
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"HEAD /ready", "HEAD /ready", "GET /app"}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected %v, got %v", want, requests)
+	}
+}
+
+// TestReloadWarmsUpTrafficURL tests warm-up opens connections to the traffic
+// URL even when health is served on a separate health_url
+func TestReloadWarmsUpTrafficURL(t *testing.T) {
+	var trafficHits, healthHits atomic.Int32
+	traffic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { trafficHits.Add(1) }))
+	defer traffic.Close()
+	healthServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { healthHits.Add(1) }))
+	defer healthServer.Close()
+
+	path := writeConfig(t, "backends:\n  - url: \"http://localhost:8081\"\n")
+	pool := backend.NewPool()
+	rl := newReloader(path, pool, backend.NewTransportCache(), logging.NewLogger("test"))
+	if err := rl.reload(); err != nil {
+		t.Fatalf("Initial reload failed: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`
+warm_up:
+  connections: 3
+backends:
+  - url: "http://localhost:8081"
+  - url: "`+traffic.URL+`"
+    health_url: "`+healthServer.URL+`/health"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if n := trafficHits.Load(); n != 3 {
+		t.Errorf("Expected 3 warm-up probes on the traffic URL, got %d", n)
+	}
+	if n := healthHits.Load(); n != 0 {
+		t.Errorf("Expected no warm-up probes on the health URL, got %d", n)
+	}
+}
+
+// TestTransportConfigWarmUpIdleConns tests the idle connection limit is raised
+// to hold every warmed connection, and never lowered
+func TestTransportConfigWarmUpIdleConns(t *testing.T) {
+	tests := []struct {
+		maxIdle, warmUp, want int
+	}{
+		{0, 0, 0},
+		{0, 8, 8},
+		{32, 8, 32},
+	}
+	for _, tt := range tests {
+		pb := &config.ParsedBackend{KeepAlive: config.KeepAliveConfig{MaxIdleConnsPerHost: tt.maxIdle}}
+		cfg := &config.Config{WarmUp: config.WarmUpConfig{Connections: tt.warmUp}}
+		if got := transportConfig(pb, cfg).MaxIdleConnsPerHost; got != tt.want {
+			t.Errorf("max_idle_conns_per_host %d, warm_up %d: expected %d, got %d", tt.maxIdle, tt.warmUp, tt.want, got)
+		}
+	}
+}
+
+// TestStartWithoutBackends tests an empty backend list is fatal unless
+// allow_empty_backends is set, in which case requests get 503 until a reload adds a backend
+func TestStartWithoutBackends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if _, err := config.LoadConfig(writeConfig(t, "port: 8080\n")); err == nil {
+		t.Error("Expected an empty backend list rejected by default")
+	}
+	if err := requireBackends(&config.Config{}, backend.NewPool()); err == nil {
+		t.Error("Expected an empty pool rejected by default")
+	}
+
+	path := writeConfig(t, "port: 8080\nallow_empty_backends: true\n")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected an empty backend list allowed, got %v", err)
+	}
+	pool := backend.NewPool()
+	if err := requireBackends(cfg, pool); err != nil {
+		t.Fatalf("Expected startup allowed without backends, got %v", err)
+	}
+
+	logger := logging.NewLogger("test")
+	collector := metrics.NewCollectorWithOptions(prometheus.NewRegistry(), metrics.CollectorOptions{})
+	lb := balancer.NewBalancer(pool, balancer.NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil, time.Second, collector, logger)
+	serve := func() int {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w.Code
+	}
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 without backends, got %d", code)
+	}
+
+	// Discovery catches up: the config gains a backend and is reloaded
+	if err := os.WriteFile(path, []byte("port: 8080\nallow_empty_backends: true\nbackends:\n  - url: \""+server.URL+"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newReloader(path, pool, backend.NewTransportCache(), logger).reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("Expected traffic served once a backend was added, got %d", code)
+	}
+}
+
+// TestLBHealthGroups tests /lb-health counts the routed groups' backends when
+// the top-level pool is empty by design
+func TestLBHealthGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{"default group", "default_group: api\n"},
+		{"host routes only", "virtual_hosts:\n  - host: api.example.com\n    group: api\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.LoadConfig(writeConfig(t, "groups:\n  - name: api\n    backends:\n      - url: \"http://localhost:9001\"\n"+
+				"  - name: unused\n    backends:\n      - url: \"http://localhost:9002\"\n"+tt.config))
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			u, _ := url.Parse("http://localhost:9001")
+			groupBackend := backend.NewBackend(u)
+			groupPools := map[string]*backend.Pool{"api": backend.NewPool(), "unused": backend.NewPool()}
+			groupPools["api"].AddBackend(groupBackend)
+			u, _ = url.Parse("http://localhost:9002")
+			groupPools["unused"].AddBackend(backend.NewBackend(u))
+
+			handler := lbHealth(routedPools(cfg, backend.NewPool(), groupPools))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/lb-health", nil))
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"healthy_backends":1`) {
+				t.Fatalf("Expected the api group's backend counted, got %d %s", w.Code, w.Body.String())
+			}
+
+			groupBackend.SetState(backend.Unhealthy)
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/lb-health", nil))
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected 503 once no routed backend is healthy, got %d", w.Code)
+			}
+		})
+	}
+}
+
+// TestCompositeCoefficientsPartial tests unset coefficients keep their defaults
+// while set ones, including an explicit 0, override them
+func TestCompositeCoefficientsPartial(t *testing.T) {
+	path := writeConfig(t, `
+port: 8080
+strategy: composite
+backends:
+  - url: "http://localhost:8081"
+composite_score:
+  latency_ms: 2
+  error_rate: 0
+`)
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := balancer.CompositeScoreCoefficients{
+		Connections: balancer.DefaultCompositeScoreCoefficients.Connections,
+		ErrorRate:   0,
+		LatencyMs:   2,
+	}
+	if got := compositeCoefficients(cfg.CompositeScore); got != want {
+		t.Errorf("Expected coefficients %+v, got %+v", want, got)
+	}
+	if got := compositeCoefficients(config.CompositeScoreConfig{}); got != balancer.DefaultCompositeScoreCoefficients {
+		t.Errorf("Expected the defaults with nothing set, got %+v", got)
+	}
+}
+
+// TestNewRetryPolicyDisabled tests a disabled retry config yields no policy (no body buffering)
+func TestNewRetryPolicyDisabled(t *testing.T) {
+	if p := newRetryPolicy(config.RetryConfig{Enabled: false, MaxAttempts: 3, BudgetPercent: 20}); p != nil {
+		t.Error("Disabled retries should yield a nil policy")
+	}
+	if p := newRetryPolicy(config.RetryConfig{Enabled: true, MaxAttempts: 3, BudgetPercent: 20}); p == nil {
+		t.Error("Enabled retries should yield a policy")
+	}
+}
+
+// TestReadyzDuringShutdown tests /readyz reports 503 while the server is still
+// accepting connections, and the draining gauge is set
+func TestReadyzDuringShutdown(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_draining"})
+	ready := newReadiness(gauge)
+	server := httptest.NewServer(ready)
+	defer server.Close()
+
+	readyzStatus := func() int {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("Server stopped accepting before readiness flipped: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := readyzStatus(); code != http.StatusOK {
+		t.Fatalf("Expected 200 before shutdown, got %d", code)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		beginShutdown(ready, 200*time.Millisecond, logging.NewLogger("test"))
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for readyzStatus() != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("/readyz never reported draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-done:
+		t.Fatal("Shutdown delay ended before /readyz was observed draining")
+	default:
+	}
+
+	var m dto.Metric
+	gauge.Write(&m)
+	if m.GetGauge().GetValue() != 1 {
+		t.Errorf("Expected draining gauge 1, got %v", m.GetGauge().GetValue())
+	}
+	<-done
+}
+
+// TestBackgroundGoroutinesStop tests every background goroutine main starts
+// returns once the lifecycle group is stopped
+func TestBackgroundGoroutinesStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	path := writeConfig(t, "port: 8080\nbackends:\n  - url: \""+server.URL+"\"\n")
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	logger := logging.NewLogger("test")
+	pool := backend.NewPool()
+	parsed, _ := cfg.ParseBackends()
+	transports := backend.NewTransportCache()
+	for _, pb := range parsed {
+		pool.AddBackend(buildBackend(pb, cfg, transports))
+	}
+
+	collector := metrics.NewCollector()
+	checker := health.NewActiveChecker(pool, config.HealthCheckConfig{
+		Enabled: true, Interval: 1, Timeout: 1, HealthyThreshold: 1, UnhealthyThreshold: 1, Path: "/",
+	}, collector, logger)
+	watcher, err := config.NewWatcher(path, logger, newReloader(path, pool, transports, logger).applyConfig)
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	stateFile := backend.NewStateFile(filepath.Join(t.TempDir(), "states.json"), 0)
+
+	background := lifecycle.NewGroup(context.Background())
+	background.Go("active_checker", checker.Start)
+	background.Go("exporter", metrics.NewExporter(collector, pool, nil).Start)
+	background.Go("config_watcher", watcher.Start)
+	background.Go("state_file", func(ctx context.Context) {
+		persistStates(ctx, stateFile, pool.GetBackends, 10*time.Millisecond, logger)
+	})
+	time.Sleep(50 * time.Millisecond) // Let each loop get going
+
+	if err := background.Stop(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// The exposed WaitGroup is done too
+	done := make(chan struct{})
+	go func() {
+		background.WaitGroup().Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("WaitGroup not done after Stop returned")
+	}
+}
+
+// TestShutdownTimeout tests the configured shutdown timeout is used, with 30s when unset
+func TestShutdownTimeout(t *testing.T) {
+	if got := shutdownTimeout(&config.Config{}); got != 30*time.Second {
+		t.Errorf("Expected the 30s default when unset, got %v", got)
+	}
+	if got := shutdownTimeout(&config.Config{ShutdownTimeoutSeconds: 5}); got != 5*time.Second {
+		t.Errorf("Expected the configured 5s, got %v", got)
+	}
+}
+
+// TestNewServerMaxHeaderBytes tests the header limit is applied to the server,
+// with the net/http default when unset, and oversized headers get 431
+func TestNewServerMaxHeaderBytes(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if got := newServer(&config.Config{}, handler).MaxHeaderBytes; got != http.DefaultMaxHeaderBytes {
+		t.Errorf("Expected the %d default when unset, got %d", http.DefaultMaxHeaderBytes, got)
+	}
+
+	server := newServer(&config.Config{MaxHeaderBytes: 1024}, handler)
+	if server.MaxHeaderBytes != 1024 {
+		t.Fatalf("Expected the configured 1024, got %d", server.MaxHeaderBytes)
+	}
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config = server
+	ts.Start()
+	defer ts.Close()
+
+	send := func(size int) int {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		req.Header.Set("X-Padding", strings.Repeat("a", size))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := send(512); code != http.StatusOK {
+		t.Errorf("Expected headers under the limit accepted, got %d", code)
+	}
+	// net/http allows 4 KiB of slack over MaxHeaderBytes
+	if code := send(64 << 10); code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("Expected 431 for oversized headers, got %d", code)
+	}
+}
+
+// testCert is a certificate and key signed by a test CA
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pair tls.Certificate
+}
+
+// newTestCert issues a certificate from template, signed by parent (self-signed when nil)
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, pair: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}}
+}
+
+// newTestCA creates a self-signed CA certificate
+func newTestCA(t *testing.T, name string) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+// writePEM writes tc's certificate (and key, if keyPath is set) as PEM files
+func (tc *testCert) writePEM(t *testing.T, certPath, keyPath string) {
+	t.Helper()
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tc.cert.Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if keyPath == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(tc.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestServerClientCertificates tests client certificate verification on the
+// traffic port: verified clients are served and their subject reaches the
+// backend, clients without a certificate are refused during the handshake
+func TestServerClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	serverCert := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "gobalance"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	clientCert := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client-1", Organization: []string{"tenant-a"}},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	tlsCfg := config.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+		ClientHeader: "X-Client-Cert",
+	}
+	serverCert.writePEM(t, tlsCfg.CertFile, tlsCfg.KeyFile)
+	ca.writePEM(t, tlsCfg.ClientCAFile, "")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Client-Cert"))
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	pool := backend.NewPool()
+	pool.AddBackend(backend.NewBackend(u))
+	collector := metrics.NewCollectorWithOptions(prometheus.NewRegistry(), metrics.CollectorOptions{})
+	lb := balancer.NewBalancer(pool, balancer.NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil, time.Second, collector, logging.NewLogger("test"))
+	lb.SetClientCertHeader(tlsCfg.ClientHeader)
+
+	server := newServer(&config.Config{TLS: tlsCfg}, lb)
+	var err error
+	if server.TLSConfig, err = serverTLSConfig(tlsCfg); err != nil {
+		t.Fatalf("serverTLSConfig failed: %v", err)
+	}
+	server.ErrorLog = log.New(io.Discard, "", 0) // Rejected handshakes are logged
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(ln, tlsCfg.CertFile, tlsCfg.KeyFile)
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		req, _ := http.NewRequest("GET", "https://"+ln.Addr().String()+"/", nil)
+		req.Header.Set("X-Client-Cert", "CN=spoofed")
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		return string(body), nil
+	}
+
+	subject, err := get(clientCert.pair)
+	if err != nil {
+		t.Fatalf("Expected a client with a valid certificate served, got %v", err)
+	}
+	if want := clientCert.cert.Subject.String(); subject != want {
+		t.Errorf("Expected backend to see subject %q, got %q", want, subject)
+	}
+	if _, err := get(); err == nil {
+		t.Error("Expected a client without a certificate rejected at the TLS layer")
+	}
+
+	rogueCA := newTestCA(t, "rogue-ca")
+	rogue := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client-1"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, rogueCA)
+	if _, err := get(rogue.pair); err == nil {
+		t.Error("Expected a certificate from an unknown CA rejected")
+	}
+}
+
+// TestServerTLSConfigOptional tests optional client auth verifies certificates
+// only when sent, and that no TLS config is built without client auth
+func TestServerTLSConfigOptional(t *testing.T) {
+	if cfg, err := serverTLSConfig(config.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}); err != nil || cfg != nil {
+		t.Errorf("Expected no TLS config without client auth, got %v, %v", cfg, err)
+	}
+
+	dir := t.TempDir()
+	ca := newTestCA(t, "test-ca")
+	caPath := filepath.Join(dir, "ca.pem")
+	ca.writePEM(t, caPath, "")
+	cfg, err := serverTLSConfig(config.TLSConfig{ClientCAFile: caPath, ClientAuth: config.ClientAuthOptional})
+	if err != nil {
+		t.Fatalf("serverTLSConfig failed: %v", err)
+	}
+	if cfg.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("Expected VerifyClientCertIfGiven, got %v", cfg.ClientAuth)
+	}
+
+	notPEM := filepath.Join(dir, "not.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o644)
+	if _, err := serverTLSConfig(config.TLSConfig{ClientCAFile: notPEM}); err == nil {
+		t.Error("Expected an error for a client CA file without certificates")
+	}
+}
+
+// TestAwaitInFlight tests shutdown waits for in-flight requests but gives up at the timeout
+func TestAwaitInFlight(t *testing.T) {
+	var inFlight atomic.Int64
+	inFlight.Store(1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		inFlight.Store(0)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := awaitInFlight(ctx, inFlight.Load); err != nil {
+		t.Errorf("Expected requests to finish before the timeout, got %v", err)
+	}
+
+	inFlight.Store(2)
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := awaitInFlight(ctx, inFlight.Load)
+	if err == nil || !strings.Contains(err.Error(), "2 requests") {
+		t.Errorf("Expected a timeout naming the 2 stuck requests, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected to give up at the timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/config"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultShutdownTimeout bounds graceful shutdown when shutdown_timeout_seconds is unset
+const defaultShutdownTimeout = 30 * time.Second
+
+// inFlightPollInterval is how often shutdown checks whether proxied requests have finished
+const inFlightPollInterval = 50 * time.Millisecond
+
+// readiness backs /readyz, which reports 503 as soon as shutdown starts so
+// orchestrators stop routing new traffic while in-flight requests drain
+type readiness struct {
+	draining atomic.Bool
+	gauge    prometheus.Gauge // Set to 1 while draining (nil = not exported)
+}
+
+// newReadiness creates a readiness endpoint that starts out ready
+func newReadiness(gauge prometheus.Gauge) *readiness {
+	return &readiness{gauge: gauge}
+}
+
+// startDraining marks the load balancer as shutting down
+func (rd *readiness) startDraining() {
+	rd.draining.Store(true)
+	if rd.gauge != nil {
+		rd.gauge.Set(1)
+	}
+}
+
+// ServeHTTP implements http.Handler interface
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if rd.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, `{"status":"draining"}`)
+		return
+	}
+	fmt.Fprint(w, `{"status":"ready"}`)
+}
+
+// beginShutdown reports not-ready and keeps serving for delay, giving
+// orchestrators time to stop sending traffic before the listener closes
+func beginShutdown(rd *readiness, delay time.Duration, logger *logging.Logger) {
+	rd.startDraining()
+	if delay <= 0 {
+		return
+	}
+	logger.Info("shutdown_delay_started", "delay_seconds", delay.Seconds())
+	time.Sleep(delay)
+}
+
+// shutdownTimeout returns how long graceful shutdown may take, using the default when unset
+func shutdownTimeout(cfg *config.Config) time.Duration {
+	if cfg.ShutdownTimeoutSeconds <= 0 {
+		return defaultShutdownTimeout
+	}
+	return time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+}
+
+// awaitInFlight waits until inFlight reports no requests, covering upgraded
+// connections that Server.Shutdown doesn't track. Returns ctx's error if it ends first.
+func awaitInFlight(ctx context.Context, inFlight func() int64) error {
+	ticker := time.NewTicker(inFlightPollInterval)
+	defer ticker.Stop()
+	for inFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d requests still in flight: %w", inFlight(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
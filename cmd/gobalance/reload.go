@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/config"
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/logging"
+)
+
+// defaultWarmUpTimeout bounds warm-up when warm_up.timeout_ms is unset
+const defaultWarmUpTimeout = time.Second
+
+// reloader applies config changes to the running balancer. File-watch and
+// admin-triggered reloads share it so they never interleave.
+type reloader struct {
+	configPath string
+	pool       *backend.Pool
+	groupPools map[string]*backend.Pool // Virtual-host groups by name
+	transports *backend.TransportCache
+	logger     *logging.Logger
+	mux        sync.Mutex // Serializes reloads
+}
+
+// newReloader creates a reloader for the config file at configPath
+func newReloader(configPath string, pool *backend.Pool, transports *backend.TransportCache, logger *logging.Logger) *reloader {
+	return &reloader{
+		configPath: configPath,
+		pool:       pool,
+		transports: transports,
+		logger:     logger,
+	}
+}
+
+// setGroupPools registers the backend groups updated on reload
+func (rl *reloader) setGroupPools(groupPools map[string]*backend.Pool) {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	rl.groupPools = groupPools
+}
+
+// reload re-reads the config file and applies it
+func (rl *reloader) reload() error {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	cfg, err := config.LoadConfig(rl.configPath)
+	if err != nil {
+		return err
+	}
+	return rl.applyConfigLocked(cfg)
+}
+
+// applyConfig validates and applies an already loaded config
+func (rl *reloader) applyConfig(cfg *config.Config) error {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+	return rl.applyConfigLocked(cfg)
+}
+
+// applyConfigLocked implements applyConfig (caller holds lock)
+func (rl *reloader) applyConfigLocked(cfg *config.Config) error {
+	rl.logger.Info("applying_config_reload")
+
+	if err := cfg.ValidateLenient(); err != nil {
+		return err
+	}
+
+	// Parse new backends, skipping entries that don't parse
+	newBackends, skipped := cfg.ParseBackendsLenient()
+	rl.logSkipped("", skipped)
+
+	// Create new backend instances
+	var backends []*backend.Backend
+	for _, pb := range newBackends {
+		b := buildBackend(pb, cfg, rl.transports)
+		backends = append(backends, b)
+		rl.logger.Info("new_backend_configured",
+			"url", b.URL.String(),
+			"weight", b.Weight)
+	}
+
+	// Replace backends in pool (preserves health state of existing backends)
+	drainTimeout := time.Duration(cfg.DrainTimeoutSeconds) * time.Second
+	rl.pool.SetDrainTimeout(drainTimeout)
+	rl.warmUp(cfg, rl.pool, backends)
+	rl.pool.ReplaceBackends(backends)
+
+	rl.logger.Info("backends_reloaded", "count", len(backends))
+
+	// Update existing groups; adding or removing groups needs a restart
+	seen := make(map[string]bool)
+	for _, g := range cfg.Groups {
+		seen[g.Name] = true
+		groupPool, exists := rl.groupPools[g.Name]
+		if !exists {
+			rl.logger.Warn("group_added_requires_restart", "group", g.Name)
+			continue
+		}
+		groupBackends, skipped := g.ParseBackendsLenient()
+		rl.logSkipped(g.Name, skipped)
+		var members []*backend.Backend
+		for _, pb := range groupBackends {
+			members = append(members, buildBackend(pb, cfg, rl.transports))
+		}
+		groupPool.SetDrainTimeout(drainTimeout)
+		rl.warmUp(cfg, groupPool, members)
+		groupPool.ReplaceBackends(members)
+		rl.logger.Info("group_reloaded", "group", g.Name, "count", len(members))
+	}
+	for name := range rl.groupPools {
+		if !seen[name] {
+			rl.logger.Warn("group_removed_requires_restart", "group", name)
+		}
+	}
+	return nil
+}
+
+// warmUp opens connections to the backends not yet in pool before they enter
+// rotation, so their first requests don't pay for connection setup. Backends
+// already in the pool keep their add time and connections, so they're skipped.
+func (rl *reloader) warmUp(cfg *config.Config, pool *backend.Pool, backends []*backend.Backend) {
+	if cfg.WarmUp.Connections < 1 {
+		return
+	}
+	existing := make(map[string]bool)
+	for _, b := range pool.GetBackends() {
+		existing[b.URL.String()] = true
+	}
+
+	timeout := time.Duration(cfg.WarmUp.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultWarmUpTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, b := range backends {
+		if existing[b.URL.String()] {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The traffic URL, not health_url: only its connections serve requests
+			warmed := b.WarmUp(ctx, health.TrafficProbeURL(b, cfg.HealthCheck.Path), cfg.WarmUp.Connections)
+			rl.logger.Info("backend_warmed_up",
+				"url", b.URL.String(),
+				"connections", warmed)
+		}()
+	}
+	wg.Wait()
+}
+
+// logSkipped warns about backend entries left out of a reload (group "" = default pool)
+func (rl *reloader) logSkipped(group string, skipped []error) {
+	for _, err := range skipped {
+		if group == "" {
+			rl.logger.Warn("backend_skipped_on_reload", "error", err)
+		} else {
+			rl.logger.Warn("backend_skipped_on_reload", "group", group, "error", err)
+		}
+	}
+}
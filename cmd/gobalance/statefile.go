@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/logging"
+)
+
+// allBackends returns the backends of the default pool and every group pool
+func allBackends(pool *backend.Pool, groupPools map[string]*backend.Pool) []*backend.Backend {
+	backends := pool.GetBackends()
+	for _, groupPool := range groupPools {
+		backends = append(backends, groupPool.GetBackends()...)
+	}
+	return backends
+}
+
+// persistStates writes backend states to sf every interval in which one changed,
+// until ctx is done
+func persistStates(ctx context.Context, sf *backend.StateFile, backends func() []*backend.Backend, interval time.Duration, logger *logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last map[string]backend.HealthState
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := backends()
+			states := make(map[string]backend.HealthState, len(current))
+			for _, b := range current {
+				states[b.URL.String()] = b.GetState()
+			}
+			if maps.Equal(states, last) {
+				continue
+			}
+			if err := sf.Save(current); err != nil {
+				logger.Error("backend_state_save_failed", "error", err.Error())
+				continue
+			}
+			last = states
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"slices"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/Nash0810/gobalance/internal/retry"
+)
+
+// Handler serves the operator admin API (/admin/...)
+type Handler struct {
+	pool   *backend.Pool
+	groups map[string]*backend.Pool // Virtual-host group pools by name
+	logger *logging.Logger
+	mux    *http.ServeMux
+	reload func() error  // Re-reads and applies the config file (nil = reload unavailable)
+	retry  *retry.Policy // Retry policy reported by /admin/retry (nil = retries disabled)
+
+	breakers func(*backend.Backend) *health.CircuitBreaker // Circuit breaker per backend (nil = circuit controls unavailable)
+}
+
+// BackendStatus is the admin view of a single backend
+type BackendStatus struct {
+	URL                  string     `json:"url"`
+	Group                string     `json:"group,omitempty"` // Virtual-host group ("" = top-level pool)
+	Weight               int        `json:"weight"`
+	State                string     `json:"state"`
+	Alive                bool       `json:"alive"`
+	Paused               bool       `json:"paused"` // Kept out of selection by an operator
+	ActiveRequests       int64      `json:"active_requests"`
+	StateChangedAt       time.Time  `json:"state_changed_at"`
+	StateDurationSeconds float64    `json:"state_duration_seconds"`
+	LastFailureReason    string     `json:"last_failure_reason,omitempty"`
+	LastCheck            *time.Time `json:"last_check,omitempty"`        // Most recent health check (absent = never checked)
+	LastCheckResult      string     `json:"last_check_result,omitempty"` // "success" or "failure"
+	LastSuccess          *time.Time `json:"last_success,omitempty"`
+	LastFailure          *time.Time `json:"last_failure,omitempty"`
+	ErrorRate            float64    `json:"error_rate"` // Failed fraction of requests over the last minute
+	Successes            int64      `json:"successes"`  // Proxied requests that succeeded since the backend was added
+	Failures             int64      `json:"failures"`   // Proxied requests that failed since the backend was added
+}
+
+// CircuitStatus is the admin view of a backend's circuit breaker
+type CircuitStatus struct {
+	URL    string `json:"url"`
+	State  string `json:"state"`
+	Forced bool   `json:"forced"` // Pinned by an operator until reset
+}
+
+// RetryStatus is the admin view of the retry policy and its budget
+type RetryStatus struct {
+	MaxAttempts    int   `json:"max_attempts"`
+	MaxPerBackend  int   `json:"max_per_backend"`
+	BudgetPercent  int   `json:"budget_percent"`
+	Tokens         int64 `json:"tokens"`        // Retries available right now
+	MaxTokens      int64 `json:"max_tokens"`    // Capacity, adapted to the measured rate
+	MeasuredRate   int64 `json:"measured_rate"` // Requests per second over the last budget window
+	RetriesAllowed int64 `json:"retries_allowed"`
+	RetriesDenied  int64 `json:"retries_denied"` // Refused because the budget was exhausted
+}
+
+// NewHandler creates the admin API handler
+func NewHandler(pool *backend.Pool, logger *logging.Logger) *Handler {
+	h := &Handler{
+		pool:   pool,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	h.mux.HandleFunc("GET /admin/backends", h.handleBackends)
+	h.mux.HandleFunc("GET /admin/ui", h.handleUI)
+	h.mux.HandleFunc("GET /admin/retry", h.handleRetry)
+	return h
+}
+
+// SetGroupPools adds the backends of virtual-host groups to the listing and
+// lets the backend controls find them
+func (h *Handler) SetGroupPools(groups map[string]*backend.Pool) {
+	h.groups = groups
+}
+
+// SetReloadFunc serves POST /admin/reload, which calls fn to re-read and
+// apply the config. Like the backend controls it changes what is served, so
+// only call this for a handler bound to the admin port.
+func (h *Handler) SetReloadFunc(fn func() error) {
+	if h.reload == nil {
+		h.mux.HandleFunc("POST /admin/reload", h.handleReload)
+	}
+	h.reload = fn
+}
+
+// SetRetryPolicy enables GET /admin/retry, reporting p's limits and budget
+func (h *Handler) SetRetryPolicy(p *retry.Policy) {
+	h.retry = p
+}
+
+// SetCircuitBreakers enables the /admin/backends/circuit controls, which force
+// the breaker fn returns for a backend open or closed, or reset it
+func (h *Handler) SetCircuitBreakers(fn func(*backend.Backend) *health.CircuitBreaker) {
+	h.breakers = fn
+}
+
+// EnableBackendControls serves POST /admin/backends/pause and resume and the
+// /admin/backends/circuit controls. Anyone who can reach them can take
+// backends out of rotation, so only call this for a handler bound to the admin port.
+func (h *Handler) EnableBackendControls() {
+	h.mux.HandleFunc("POST /admin/backends/pause", h.handlePause(true))
+	h.mux.HandleFunc("POST /admin/backends/resume", h.handlePause(false))
+	h.mux.HandleFunc("POST /admin/backends/circuit/open", h.handleCircuit("open"))
+	h.mux.HandleFunc("POST /admin/backends/circuit/close", h.handleCircuit("close"))
+	h.mux.HandleFunc("POST /admin/backends/circuit/reset", h.handleCircuit("reset"))
+}
+
+// EnablePprof serves the net/http/pprof runtime profiles under /debug/pprof/.
+// Only call this for a handler bound to the admin port.
+func (h *Handler) EnablePprof() {
+	h.mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	h.mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	h.mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	h.mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	h.mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handleBackends returns the status of every backend in the top-level pool,
+// then in each group
+func (h *Handler) handleBackends(w http.ResponseWriter, r *http.Request) {
+	statuses := []BackendStatus{}
+	for _, b := range h.pool.GetBackends() {
+		statuses = append(statuses, backendStatus(b))
+	}
+	for _, name := range h.groupNames() {
+		for _, b := range h.groups[name].GetBackends() {
+			status := backendStatus(b)
+			status.Group = name
+			statuses = append(statuses, status)
+		}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// groupNames returns the group names in order
+func (h *Handler) groupNames() []string {
+	names := make([]string, 0, len(h.groups))
+	for name := range h.groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// pools returns the top-level pool followed by the group pools in name order
+func (h *Handler) pools() []*backend.Pool {
+	pools := []*backend.Pool{h.pool}
+	for _, name := range h.groupNames() {
+		pools = append(pools, h.groups[name])
+	}
+	return pools
+}
+
+// findBackends returns every backend with the URL named by ?url=, across the
+// top-level pool and the groups (a URL may serve several groups), writing an
+// error response if there is none
+func (h *Handler) findBackends(w http.ResponseWriter, r *http.Request) []*backend.Backend {
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"status": "error", "error": "url parameter required"})
+		return nil
+	}
+	var found []*backend.Backend
+	for _, pool := range h.pools() {
+		for _, b := range pool.GetBackends() {
+			if b.URL.String() == rawURL {
+				found = append(found, b)
+			}
+		}
+	}
+	if len(found) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "error": "no backend with url " + rawURL})
+	}
+	return found
+}
+
+// handlePause returns a handler pausing (or resuming) new traffic to the
+// backend named by ?url=, in every pool it belongs to
+func (h *Handler) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		backends := h.findBackends(w, r)
+		if len(backends) == 0 {
+			return
+		}
+		for _, b := range backends {
+			b.SetPaused(paused)
+		}
+		h.logger.Info("admin_backend_paused",
+			"backend", backends[0].URL.String(),
+			"paused", paused,
+			"pools", len(backends),
+			"remote_addr", r.RemoteAddr)
+		writeJSON(w, http.StatusOK, backendStatus(backends[0]))
+	}
+}
+
+// handleCircuit returns a handler forcing the circuit of the backend named by
+// ?url= open or closed, or resetting it to automatic control
+func (h *Handler) handleCircuit(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.breakers == nil {
+			writeJSON(w, http.StatusNotImplemented, map[string]string{"status": "error", "error": "circuit breakers not configured"})
+			return
+		}
+		backends := h.findBackends(w, r)
+		if len(backends) == 0 {
+			return
+		}
+		b := backends[0] // Breakers are per backend host, shared across pools
+		cb := h.breakers(b)
+		switch action {
+		case "open":
+			cb.ForceOpen()
+		case "close":
+			cb.ForceClose()
+		default:
+			cb.Reset()
+		}
+		h.logger.Info("admin_circuit_"+action,
+			"backend", b.URL.String(),
+			"remote_addr", r.RemoteAddr)
+		writeJSON(w, http.StatusOK, CircuitStatus{
+			URL:    b.URL.String(),
+			State:  cb.GetState().String(),
+			Forced: cb.IsForced(),
+		})
+	}
+}
+
+// handleReload re-reads the config file and reports whether it was applied
+func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
+	if h.reload == nil {
+		writeJSON(w, http.StatusNotImplemented, map[string]string{"status": "error", "error": "reload not configured"})
+		return
+	}
+
+	h.logger.Info("admin_reload_requested", "remote_addr", r.RemoteAddr)
+	if err := h.reload(); err != nil {
+		h.logger.Error("admin_reload_failed", "error", err.Error())
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"status": "error", "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "backends": h.pool.Size()})
+}
+
+// handleRetry reports the retry policy and the current state of its budget
+func (h *Handler) handleRetry(w http.ResponseWriter, r *http.Request) {
+	if h.retry == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"status": "error", "error": "retries not enabled"})
+		return
+	}
+	budget := h.retry.GetBudget()
+	writeJSON(w, http.StatusOK, RetryStatus{
+		MaxAttempts:    h.retry.MaxAttempts(),
+		MaxPerBackend:  h.retry.MaxPerBackend(),
+		BudgetPercent:  budget.Percent(),
+		Tokens:         budget.GetAvailable(),
+		MaxTokens:      budget.MaxTokens(),
+		MeasuredRate:   budget.MeasuredRate(),
+		RetriesAllowed: budget.RetriesAllowed(),
+		RetriesDenied:  budget.RetriesDenied(),
+	})
+}
+
+// backendStatus builds the admin view of a backend
+func backendStatus(b *backend.Backend) BackendStatus {
+	changedAt := b.StateChangedAt()
+	healthMetrics := b.GetHealthMetrics()
+	return BackendStatus{
+		URL:                  b.URL.String(),
+		Weight:               b.Weight,
+		State:                b.GetState().String(),
+		Alive:                b.IsAlive(),
+		Paused:               b.IsPaused(),
+		ActiveRequests:       b.GetActiveRequests(),
+		StateChangedAt:       changedAt,
+		StateDurationSeconds: time.Since(changedAt).Seconds(),
+		LastFailureReason:    healthMetrics.LastFailureReason,
+		LastCheck:            optionalTime(healthMetrics.LastCheck),
+		LastCheckResult:      checkResult(healthMetrics),
+		LastSuccess:          optionalTime(healthMetrics.LastSuccess),
+		LastFailure:          optionalTime(healthMetrics.LastFailure),
+		ErrorRate:            b.GetErrorRate(backend.DefaultErrorRateWindow),
+		Successes:            b.SuccessCount(),
+		Failures:             b.FailureCount(),
+	}
+}
+
+// optionalTime returns nil for the zero time so unset timestamps are left out of JSON
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// checkResult reports the outcome of the last health check ("" if never checked)
+func checkResult(m backend.HealthMetrics) string {
+	switch {
+	case m.LastCheck.IsZero():
+		return ""
+	case m.LastCheck.Equal(m.LastSuccess):
+		return "success"
+	default:
+		return "failure"
+	}
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
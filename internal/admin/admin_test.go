@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/Nash0810/gobalance/internal/retry"
+)
+
+// newTestPool creates a pool with one backend per URL
+func newTestPool(t *testing.T, urls ...string) *backend.Pool {
+	t.Helper()
+	pool := backend.NewPool()
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("Bad URL %s: %v", raw, err)
+		}
+		pool.AddBackend(backend.NewBackend(u))
+	}
+	return pool
+}
+
+// getBackends fetches and decodes /admin/backends
+func getBackends(t *testing.T, h http.Handler) []BackendStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var statuses []BackendStatus
+	if err := json.NewDecoder(w.Body).Decode(&statuses); err != nil {
+		t.Fatalf("Decoding status failed: %v", err)
+	}
+	return statuses
+}
+
+// TestBackendsStatusStateDuration tests the status endpoint reports the state transition time
+func TestBackendsStatusStateDuration(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+
+	before := time.Now()
+	pool.GetBackends()[0].SetState(backend.Unhealthy)
+
+	statuses := getBackends(t, h)
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 backend, got %d", len(statuses))
+	}
+	status := statuses[0]
+	if status.State != "UNHEALTHY" || status.Alive {
+		t.Errorf("Unexpected state %s (alive=%v)", status.State, status.Alive)
+	}
+	if status.StateChangedAt.Before(before) {
+		t.Errorf("state_changed_at %v predates the transition at %v", status.StateChangedAt, before)
+	}
+	if status.StateDurationSeconds < 0 || status.StateDurationSeconds > 5 {
+		t.Errorf("Unexpected state duration %v", status.StateDurationSeconds)
+	}
+}
+
+// TestBackendsStatusLastCheck tests health check timestamps and the last result
+// are serialized, and left out for a backend never checked
+func TestBackendsStatusLastCheck(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends", nil))
+	if body := w.Body.String(); strings.Contains(body, "last_check") || strings.Contains(body, "last_success") {
+		t.Errorf("Expected no check timestamps before any check, got %s", body)
+	}
+
+	b := pool.GetBackends()[0]
+	b.RecordHealthCheckSuccess()
+	first := getBackends(t, h)[0]
+	if first.LastCheck == nil || first.LastSuccess == nil || first.LastFailure != nil {
+		t.Fatalf("Expected last_check and last_success only, got %+v", first)
+	}
+	if !first.LastCheck.Equal(*first.LastSuccess) || first.LastCheckResult != "success" {
+		t.Errorf("Expected the check to be the success, got %v/%v (%s)", first.LastCheck, first.LastSuccess, first.LastCheckResult)
+	}
+
+	time.Sleep(time.Millisecond)
+	b.RecordHealthCheckFailure()
+	second := getBackends(t, h)[0]
+	if second.LastCheck == nil || !second.LastCheck.After(*first.LastCheck) {
+		t.Errorf("Expected last_check to advance past %v, got %v", first.LastCheck, second.LastCheck)
+	}
+	if second.LastFailure == nil || !second.LastCheck.Equal(*second.LastFailure) || second.LastCheckResult != "failure" {
+		t.Errorf("Expected the check to be the failure, got %+v", second)
+	}
+	if !second.LastSuccess.Equal(*first.LastSuccess) {
+		t.Errorf("last_success should not move on a failure, got %v", second.LastSuccess)
+	}
+}
+
+// TestBackendsStatusRequestTotals tests the status endpoint reports each backend's request totals
+func TestBackendsStatusRequestTotals(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081", "http://localhost:8082")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+
+	backends := pool.GetBackends()
+	for i := 0; i < 3; i++ {
+		backends[0].RecordRequestSuccess()
+	}
+	backends[0].RecordRequestFailure()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/backends", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"successes":3,"failures":1`) {
+		t.Errorf("Expected totals in the JSON, got %s", body)
+	}
+	statuses := getBackends(t, h)
+	if statuses[0].Successes != 3 || statuses[0].Failures != 1 || statuses[1].Successes != 0 || statuses[1].Failures != 0 {
+		t.Errorf("Unexpected totals %+v", statuses)
+	}
+}
+
+// TestBackendsStatusGroups tests group backends are listed after the
+// top-level ones, labelled with their group
+func TestBackendsStatusGroups(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+	h.SetGroupPools(map[string]*backend.Pool{
+		"web": newTestPool(t, "http://localhost:8083"),
+		"api": newTestPool(t, "http://localhost:8082"),
+	})
+
+	statuses := getBackends(t, h)
+	want := []struct{ url, group string }{
+		{"http://localhost:8081", ""},
+		{"http://localhost:8082", "api"},
+		{"http://localhost:8083", "web"},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("Expected %d backends, got %+v", len(want), statuses)
+	}
+	for i, w := range want {
+		if statuses[i].URL != w.url || statuses[i].Group != w.group {
+			t.Errorf("Backend %d: expected %s in group %q, got %s in %q", i, w.url, w.group, statuses[i].URL, statuses[i].Group)
+		}
+	}
+}
+
+// TestPprofEndpoint tests the pprof index is only served once enabled
+func TestPprofEndpoint(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 with pprof disabled, got %d", w.Code)
+	}
+
+	h.EnablePprof()
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with pprof enabled, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "goroutine") {
+		t.Error("Expected the pprof index to list the goroutine profile")
+	}
+}
+
+// TestUIPage tests the status page is served as HTML and loads the backends endpoint
+func TestUIPage(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/ui", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected an HTML content type, got %q", ct)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `"/admin/backends"`) {
+		t.Error("Page should fetch /admin/backends")
+	}
+	if !strings.Contains(body, "setInterval(refresh") {
+		t.Error("Page should refresh itself")
+	}
+}
+
+// getRetry fetches and decodes /admin/retry
+func getRetry(t *testing.T, h http.Handler) RetryStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/retry", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var status RetryStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatalf("Decoding retry status failed: %v", err)
+	}
+	return status
+}
+
+// TestRetryStatus tests /admin/retry reports the policy and the budget spent by retries
+func TestRetryStatus(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/admin/retry", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without a retry policy, got %d", w.Code)
+	}
+
+	policy := retry.NewPolicy(3, 1) // 1% of the 1000 req/s baseline: 10 tokens
+	policy.SetMaxPerBackend(2)
+	h.SetRetryPolicy(policy)
+
+	status := getRetry(t, h)
+	if status.MaxAttempts != 3 || status.MaxPerBackend != 2 || status.BudgetPercent != 1 {
+		t.Errorf("Unexpected policy %+v", status)
+	}
+	if status.Tokens != 10 || status.MaxTokens != 10 || status.RetriesAllowed != 0 {
+		t.Errorf("Expected a full budget of 10 tokens, got %+v", status)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 12; i++ {
+		policy.ShouldRetry(req, errors.New("status 503"), 1)
+	}
+
+	status = getRetry(t, h)
+	if status.RetriesAllowed+status.RetriesDenied != 12 || status.RetriesAllowed < 10 || status.RetriesDenied < 1 {
+		t.Errorf("Expected 12 retries with the budget exhausted, got %d allowed and %d denied",
+			status.RetriesAllowed, status.RetriesDenied)
+	}
+	if status.Tokens > 1 {
+		t.Errorf("Expected the budget to be spent, %d tokens left", status.Tokens)
+	}
+}
+
+// TestReloadRegistration tests POST /admin/reload is only served once a
+// reload function is set
+func TestReloadRegistration(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+	post := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/reload", nil))
+		return w.Code
+	}
+
+	if code := post(); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 without a reload function, got %d", code)
+	}
+	reloads := 0
+	h.SetReloadFunc(func() error { reloads++; return nil })
+	if code := post(); code != http.StatusOK || reloads != 1 {
+		t.Errorf("Expected a served reload, got %d after %d reloads", code, reloads)
+	}
+}
+
+// TestPauseResume tests pausing and resuming a backend through the admin API,
+// which is only possible once backend controls are enabled
+func TestPauseResume(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081", "http://localhost:8082")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+
+	post := func(path string) (int, BackendStatus) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		var status BackendStatus
+		json.NewDecoder(w.Body).Decode(&status)
+		return w.Code, status
+	}
+
+	if code, _ := post("/admin/backends/pause?url=" + url.QueryEscape("http://localhost:8081")); code != http.StatusNotFound {
+		t.Fatalf("Expected 404 with backend controls disabled, got %d", code)
+	}
+	if len(pool.GetRoutableBackends()) != 2 {
+		t.Fatal("Expected no backend paused while controls are disabled")
+	}
+
+	h.EnableBackendControls()
+	code, status := post("/admin/backends/pause?url=" + url.QueryEscape("http://localhost:8081"))
+	if code != http.StatusOK || !status.Paused || status.State != "HEALTHY" {
+		t.Fatalf("Expected a paused healthy backend, got %d %+v", code, status)
+	}
+	if routable := pool.GetRoutableBackends(); len(routable) != 1 || routable[0].URL.Host != "localhost:8082" {
+		t.Errorf("Expected only the unpaused backend routable, got %v", routable)
+	}
+	if statuses := getBackends(t, h); !statuses[0].Paused || statuses[1].Paused {
+		t.Errorf("Expected /admin/backends to report the pause, got %+v", statuses)
+	}
+
+	code, status = post("/admin/backends/resume?url=" + url.QueryEscape("http://localhost:8081"))
+	if code != http.StatusOK || status.Paused {
+		t.Fatalf("Expected the backend resumed, got %d %+v", code, status)
+	}
+	if len(pool.GetRoutableBackends()) != 2 {
+		t.Errorf("Expected both backends routable after resume")
+	}
+
+	if code, _ := post("/admin/backends/pause"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a url, got %d", code)
+	}
+	if code, _ := post("/admin/backends/pause?url=http://localhost:9999"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown backend, got %d", code)
+	}
+}
+
+// TestPauseGroupBackend tests backends of virtual-host groups can be paused,
+// in every pool serving the URL
+func TestPauseGroupBackend(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081")
+	api := newTestPool(t, "http://localhost:8082", "http://localhost:8083")
+	web := newTestPool(t, "http://localhost:8083")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+	h.SetGroupPools(map[string]*backend.Pool{"api": api, "web": web})
+	h.EnableBackendControls()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/backends/pause?url="+url.QueryEscape("http://localhost:8083"), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected a group backend paused, got %d", w.Code)
+	}
+	if routable := api.GetRoutableBackends(); len(routable) != 1 || routable[0].URL.Host != "localhost:8082" {
+		t.Errorf("Expected only localhost:8082 routable in the api group, got %v", routable)
+	}
+	if len(web.GetRoutableBackends()) != 0 {
+		t.Error("Expected the backend paused in the web group too")
+	}
+	if len(pool.GetRoutableBackends()) != 1 {
+		t.Error("Expected the top-level pool untouched")
+	}
+}
+
+// TestCircuitControls tests forcing a backend's circuit open and closed through
+// the admin API, and resetting it, once backend controls are enabled
+func TestCircuitControls(t *testing.T) {
+	pool := newTestPool(t, "http://localhost:8081")
+	h := NewHandler(pool, logging.NewLogger("admin"))
+	target := "?url=" + url.QueryEscape("http://localhost:8081")
+
+	post := func(path string) (int, CircuitStatus) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		var status CircuitStatus
+		json.NewDecoder(w.Body).Decode(&status)
+		return w.Code, status
+	}
+
+	if code, _ := post("/admin/backends/circuit/open" + target); code != http.StatusNotFound {
+		t.Errorf("Expected 404 with backend controls disabled, got %d", code)
+	}
+
+	h.EnableBackendControls()
+	if code, _ := post("/admin/backends/circuit/open" + target); code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without circuit breakers, got %d", code)
+	}
+
+	cb := health.NewCircuitBreaker("localhost:8081")
+	h.SetCircuitBreakers(func(*backend.Backend) *health.CircuitBreaker { return cb })
+
+	code, status := post("/admin/backends/circuit/open" + target)
+	if code != http.StatusOK || status.State != "OPEN" || !status.Forced {
+		t.Fatalf("Expected a forced open circuit, got %d %+v", code, status)
+	}
+	cb.RecordSuccess()
+	cb.RecordSuccess()
+	if cb.AllowRequest() {
+		t.Error("Forced open circuit should reject requests despite successes")
+	}
+
+	code, status = post("/admin/backends/circuit/close" + target)
+	if code != http.StatusOK || status.State != "CLOSED" || !status.Forced {
+		t.Fatalf("Expected a forced closed circuit, got %d %+v", code, status)
+	}
+
+	code, status = post("/admin/backends/circuit/reset" + target)
+	if code != http.StatusOK || status.State != "CLOSED" || status.Forced {
+		t.Fatalf("Expected an automatic closed circuit, got %d %+v", code, status)
+	}
+
+	if code, _ := post("/admin/backends/circuit/open"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a url, got %d", code)
+	}
+	if code, _ := post("/admin/backends/circuit/open?url=http://localhost:9999"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown backend, got %d", code)
+	}
+}
+
+// TestCircuitControlsGroupBackend tests a group backend's circuit can be forced open
+func TestCircuitControlsGroupBackend(t *testing.T) {
+	h := NewHandler(newTestPool(t, "http://localhost:8081"), logging.NewLogger("admin"))
+	h.SetGroupPools(map[string]*backend.Pool{"api": newTestPool(t, "http://localhost:8082")})
+	h.EnableBackendControls()
+	cb := health.NewCircuitBreaker("localhost:8082")
+	h.SetCircuitBreakers(func(b *backend.Backend) *health.CircuitBreaker {
+		if b.URL.Host != "localhost:8082" {
+			t.Errorf("Expected the group backend's breaker, got %s", b.URL.Host)
+		}
+		return cb
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/backends/circuit/open?url="+url.QueryEscape("http://localhost:8082"), nil))
+	if w.Code != http.StatusOK || cb.GetState() != health.StateOpen {
+		t.Errorf("Expected the group backend's circuit forced open, got %d %v", w.Code, cb.GetState())
+	}
+}
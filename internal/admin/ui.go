@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"net/http"
+)
+
+// uiPage is a self-contained page rendering /admin/backends as a table,
+// refreshed every 2 seconds
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GoBalance backends</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+.HEALTHY { background: #d9f2d9; }
+.DRAINING { background: #fff2cc; }
+.UNHEALTHY, .DOWN { background: #f8d7da; }
+#error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>GoBalance backends</h1>
+<p id="updated"></p>
+<p id="error"></p>
+<table>
+<thead><tr><th>URL</th><th>Group</th><th>State</th><th>Weight</th><th>Active</th><th>Error rate</th><th>In state for</th><th>Last check</th><th>Last failure</th></tr></thead>
+<tbody id="backends"></tbody>
+</table>
+<script>
+const statusURL = "/admin/backends";
+
+function cell(row, text) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  row.appendChild(td);
+}
+
+async function refresh() {
+  try {
+    const resp = await fetch(statusURL, {cache: "no-store"});
+    if (!resp.ok) throw new Error("status " + resp.status);
+    const backends = await resp.json();
+    const body = document.getElementById("backends");
+    body.replaceChildren();
+    for (const b of backends) {
+      const row = document.createElement("tr");
+      row.className = b.state;
+      cell(row, b.url);
+      cell(row, b.group || "");
+      cell(row, b.paused ? b.state + " (paused)" : b.state);
+      cell(row, b.weight);
+      cell(row, b.active_requests);
+      cell(row, (b.error_rate * 100).toFixed(1) + "%");
+      cell(row, Math.round(b.state_duration_seconds) + "s");
+      cell(row, b.last_check ? new Date(b.last_check).toLocaleTimeString() + " (" + b.last_check_result + ")" : "never");
+      cell(row, b.last_failure_reason || "");
+      body.appendChild(row);
+    }
+    document.getElementById("error").textContent = "";
+    document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+  } catch (err) {
+    document.getElementById("error").textContent = "Failed to load " + statusURL + ": " + err.message;
+  }
+}
+
+refresh();
+setInterval(refresh, 2000);
+</script>
+</body>
+</html>
+`
+
+// handleUI serves the backend status page
+func (h *Handler) handleUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(uiPage))
+}
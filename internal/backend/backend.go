@@ -1,6 +1,11 @@
 package backend
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"sync"
@@ -11,26 +16,127 @@ import (
 // Backend represents a single backend server
 type Backend struct {
 	URL            *url.URL               // Backend URL
+	healthURL      *url.URL               // Optional separate health check URL
+	retryable      *bool                  // Retry override for failed requests (nil = follow the retry policy)
+	maxHeaderBytes int                    // Responses with a larger header block are rejected (0 = no limit)
 	alive          bool                   // Health status (protected by mutex)
+	paused         bool                   // Operator-paused: kept out of selection without touching health (protected by mutex)
 	state          HealthState            // Current health state
+	stateChangedAt time.Time              // When the health state last changed
+	addedAt        time.Time              // When the backend joined the pool (kept across reloads)
 	metrics        HealthMetrics          // Health check metrics
-	mux            sync.RWMutex           // Protects 'alive', 'state', 'metrics'
+	requests       *requestWindow         // Rolling proxied request outcomes
+	latencies      *latencySamples        // Recent successful response latencies
+	mux            sync.RWMutex           // Protects 'alive', 'paused', 'state', 'metrics'
 	ReverseProxy   *httputil.ReverseProxy // HTTP proxy
-	ActiveRequests int64                  // Active request count (atomic)
+	activeRequests *atomic.Int64          // Active request count, shared with the backend this one replaced on reload
+	totals         *requestTotals         // Lifetime proxied request outcomes, shared like activeRequests
 	Weight         int                    // Weight for weighted strategies (1-100)
+	Tags           map[string]string      // Free-form labels from config, e.g. zone (read-only once pooled)
+}
+
+// requestTotals counts proxied request outcomes since the backend was first added
+type requestTotals struct {
+	successes atomic.Int64
+	failures  atomic.Int64
+}
+
+// ProxyErrorRecorder is implemented by response writers that want to know why a
+// proxy attempt failed (connection refused, timeout, ...)
+type ProxyErrorRecorder interface {
+	RecordProxyError(err error)
 }
 
 // NewBackend creates a new backend instance
 func NewBackend(u *url.URL) *Backend {
-	return &Backend{
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.ErrorHandler = proxyErrorHandler
+
+	b := &Backend{
 		URL:            u,
 		alive:          true,
 		state:          Healthy,
+		stateChangedAt: time.Now(),
+		addedAt:        time.Now(),
 		metrics:        HealthMetrics{},
-		ReverseProxy:   httputil.NewSingleHostReverseProxy(u),
-		ActiveRequests: 0,
+		requests:       newRequestWindow(),
+		latencies:      newLatencySamples(),
+		ReverseProxy:   proxy,
+		activeRequests: &atomic.Int64{},
+		totals:         &requestTotals{},
 		Weight:         1, // Default weight
 	}
+	proxy.ModifyResponse = b.modifyResponse
+	return b
+}
+
+// ErrResponseHeaderTooLarge rejects a backend response whose header block
+// exceeds the configured limit; the client gets 502 instead
+var ErrResponseHeaderTooLarge = errors.New("backend response header too large")
+
+// SetMaxResponseHeaderBytes rejects responses whose header block is larger
+// than n bytes with 502, so a misbehaving backend's headers never reach
+// clients or downstream proxies (0 = no limit)
+func (b *Backend) SetMaxResponseHeaderBytes(n int) {
+	b.maxHeaderBytes = n
+}
+
+// ResponseBodyHook replaces a backend response body before it is copied to the
+// client. It gets the response (headers may be changed) and its body, which it
+// must close or wrap, and returns the body to send. Returning an error fails
+// the attempt with 502.
+type ResponseBodyHook func(resp *http.Response, body io.ReadCloser) (io.ReadCloser, error)
+
+// responseBodyHookKey carries a ResponseBodyHook in a request context
+type responseBodyHookKey struct{}
+
+// WithResponseBodyHook returns a context under which responses proxied for
+// the request pass through hook
+func WithResponseBodyHook(ctx context.Context, hook ResponseBodyHook) context.Context {
+	return context.WithValue(ctx, responseBodyHookKey{}, hook)
+}
+
+// modifyResponse enforces the response header limit, then applies the body
+// hook carried by the request, if any
+func (b *Backend) modifyResponse(resp *http.Response) error {
+	if n := b.maxHeaderBytes; n > 0 {
+		if size := headerSize(resp.Header); size > n {
+			return fmt.Errorf("%w: %d bytes, limit %d", ErrResponseHeaderTooLarge, size, n)
+		}
+	}
+
+	hook, ok := resp.Request.Context().Value(responseBodyHookKey{}).(ResponseBodyHook)
+	if !ok || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	body, err := hook(resp, resp.Body)
+	if err != nil {
+		return err // The proxy closes the original body
+	}
+	// The hook may change the length, so the body goes out chunked
+	resp.Body = body
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	return nil
+}
+
+// headerSize returns the size of a header block on the wire ("Key: value\r\n" per value)
+func headerSize(h http.Header) int {
+	size := 0
+	for key, values := range h {
+		for _, v := range values {
+			size += len(key) + len(v) + 4
+		}
+	}
+	return size
+}
+
+// proxyErrorHandler reports transport errors to the response writer and responds 502
+func proxyErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if recorder, ok := w.(ProxyErrorRecorder); ok {
+		recorder.RecordProxyError(err)
+	}
+	w.WriteHeader(http.StatusBadGateway)
 }
 
 // IsAlive returns the backend's health status (thread-safe)
@@ -40,11 +146,44 @@ func (b *Backend) IsAlive() bool {
 	return b.alive
 }
 
+// healthEpoch changes whenever any backend's alive or paused flag does, telling
+// pools their cached healthy and routable snapshots may be stale
+var healthEpoch atomic.Uint64
+
 // SetAlive sets the backend's health status (thread-safe)
 func (b *Backend) SetAlive(alive bool) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
-	b.alive = alive
+	if b.alive != alive {
+		b.alive = alive
+		healthEpoch.Add(1)
+	}
+}
+
+// IsPaused reports whether an operator paused the backend (thread-safe)
+func (b *Backend) IsPaused() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.paused
+}
+
+// SetPaused pauses or resumes new traffic to the backend without changing its
+// health state, so health checks neither clear the pause nor see a failure (thread-safe)
+func (b *Backend) SetPaused(paused bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.paused != paused {
+		b.paused = paused
+		healthEpoch.Add(1)
+	}
+}
+
+// IsRoutable reports whether the backend may be selected for new requests:
+// healthy and not paused (thread-safe)
+func (b *Backend) IsRoutable() bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.alive && !b.paused
 }
 
 // GetState returns the current health state (thread-safe)
@@ -58,10 +197,23 @@ func (b *Backend) GetState() HealthState {
 func (b *Backend) SetState(state HealthState) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
+	if b.state != state {
+		b.stateChangedAt = time.Now()
+	}
 	b.state = state
 
 	// Update alive flag based on state
-	b.alive = (state == Healthy)
+	if alive := state == Healthy; b.alive != alive {
+		b.alive = alive
+		healthEpoch.Add(1)
+	}
+}
+
+// StateChangedAt returns when the health state last changed (thread-safe)
+func (b *Backend) StateChangedAt() time.Time {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.stateChangedAt
 }
 
 // RecordHealthCheckSuccess records a successful health check
@@ -69,10 +221,11 @@ func (b *Backend) RecordHealthCheckSuccess() {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
+	now := time.Now()
 	b.metrics.ConsecutiveSuccesses++
 	b.metrics.ConsecutiveFailures = 0
-	b.metrics.LastCheck = time.Now()
-	b.metrics.LastSuccess = time.Now()
+	b.metrics.LastCheck = now
+	b.metrics.LastSuccess = now
 }
 
 // RecordHealthCheckFailure records a failed health check
@@ -80,10 +233,32 @@ func (b *Backend) RecordHealthCheckFailure() {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
+	now := time.Now()
 	b.metrics.ConsecutiveFailures++
 	b.metrics.ConsecutiveSuccesses = 0
-	b.metrics.LastCheck = time.Now()
-	b.metrics.LastFailure = time.Now()
+	b.metrics.LastCheck = now
+	b.metrics.LastFailure = now
+}
+
+// RecordPassiveFailure records a failed proxied request the way a failed
+// check is recorded, stamping it as the latest passive failure. LastCheck is
+// left to the active checker
+func (b *Backend) RecordPassiveFailure() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	now := time.Now()
+	b.metrics.ConsecutiveFailures++
+	b.metrics.ConsecutiveSuccesses = 0
+	b.metrics.LastFailure = now
+	b.metrics.LastPassiveFailure = now
+}
+
+// SetHealthCheckFailureReason records why the last health check failed
+func (b *Backend) SetHealthCheckFailureReason(reason string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.metrics.LastFailureReason = reason
 }
 
 // GetHealthMetrics returns a copy of health metrics (thread-safe)
@@ -93,19 +268,70 @@ func (b *Backend) GetHealthMetrics() HealthMetrics {
 	return b.metrics
 }
 
+// RecordLatency records how long a successful response took
+func (b *Backend) RecordLatency(d time.Duration) {
+	b.latencies.record(d)
+}
+
+// LatencyP99 returns the p99 of recent response latencies, or false until
+// enough responses have been sampled
+func (b *Backend) LatencyP99() (time.Duration, bool) {
+	return b.latencies.percentile(99)
+}
+
+// LatencyEWMA returns the exponentially weighted moving average of response
+// latencies, or false before the first response has been recorded
+func (b *Backend) LatencyEWMA() (time.Duration, bool) {
+	return b.latencies.average()
+}
+
+// RecordRequestSuccess records a successfully proxied request
+func (b *Backend) RecordRequestSuccess() {
+	b.requests.record(true)
+	b.totals.successes.Add(1)
+}
+
+// RecordRequestFailure records a failed proxied request
+func (b *Backend) RecordRequestFailure() {
+	b.requests.record(false)
+	b.totals.failures.Add(1)
+}
+
+// SuccessCount returns how many proxied requests to the backend have succeeded
+func (b *Backend) SuccessCount() int64 {
+	return b.totals.successes.Load()
+}
+
+// FailureCount returns how many proxied requests to the backend have failed
+func (b *Backend) FailureCount() int64 {
+	return b.totals.failures.Load()
+}
+
+// GetErrorRate returns the fraction of proxied requests that failed within the window (up to 5 minutes)
+func (b *Backend) GetErrorRate(window time.Duration) float64 {
+	return b.requests.errorRate(window)
+}
+
 // IncrementActiveRequests atomically increments active request count
 func (b *Backend) IncrementActiveRequests() {
-	atomic.AddInt64(&b.ActiveRequests, 1)
+	b.activeRequests.Add(1)
 }
 
 // DecrementActiveRequests atomically decrements active request count
 func (b *Backend) DecrementActiveRequests() {
-	atomic.AddInt64(&b.ActiveRequests, -1)
+	b.activeRequests.Add(-1)
 }
 
 // GetActiveRequests atomically reads active request count
 func (b *Backend) GetActiveRequests() int64 {
-	return atomic.LoadInt64(&b.ActiveRequests)
+	return b.activeRequests.Load()
+}
+
+// ActiveRequests atomically reads active request count. It replaces the former
+// exported ActiveRequests field, which could not be shared across reloads;
+// callers that read the field directly must call this instead
+func (b *Backend) ActiveRequests() int64 {
+	return b.activeRequests.Load()
 }
 
 // SetWeight sets the backend weight
@@ -119,6 +345,79 @@ func (b *Backend) SetWeight(weight int) {
 	b.Weight = weight
 }
 
+// SetHealthURL makes health checks probe u instead of the traffic URL
+func (b *Backend) SetHealthURL(u *url.URL) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.healthURL = u
+}
+
+// HealthURL returns the separate health check URL, or nil to derive it from the traffic URL
+func (b *Backend) HealthURL() *url.URL {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.healthURL
+}
+
+// SetRetryable overrides the retry policy for requests that fail on this
+// backend: false never retries them, true retries them whatever their method.
+// nil follows the global policy.
+func (b *Backend) SetRetryable(retryable *bool) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.retryable = retryable
+}
+
+// Retryable returns the backend's retry override, or nil to follow the retry policy
+func (b *Backend) Retryable() *bool {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.retryable
+}
+
+// SetTransport sets the transport used to proxy requests to this backend
+func (b *Backend) SetTransport(t http.RoundTripper) {
+	b.ReverseProxy.Transport = t
+}
+
+// SetFlushInterval sets how often streamed responses are flushed to the client
+// (negative = flush after every write, 0 = only for streaming responses)
+func (b *Backend) SetFlushInterval(d time.Duration) {
+	b.ReverseProxy.FlushInterval = d
+}
+
+// AddedAt returns when the backend was first added (thread-safe)
+func (b *Backend) AddedAt() time.Time {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.addedAt
+}
+
+// copyAddedAt keeps the original add time so a reload doesn't restart the health check grace period
+func (b *Backend) copyAddedAt(old *Backend) {
+	addedAt := old.AddedAt()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.addedAt = addedAt
+}
+
+// copyStateChangedAt carries the state transition time over from the backend being replaced
+func (b *Backend) copyStateChangedAt(old *Backend) {
+	changedAt := old.StateChangedAt()
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.stateChangedAt = changedAt
+}
+
+// shareLoad makes b use old's active request counter, request totals and
+// latency samples, so requests still in flight on old are counted against b
+// and finish correctly (caller must call this before b is reachable by other goroutines)
+func (b *Backend) shareLoad(old *Backend) {
+	b.activeRequests = old.activeRequests
+	b.totals = old.totals
+	b.latencies = old.latencies
+}
+
 // CopyHealthMetrics copies health metrics from another backend (for config reload)
 func (b *Backend) CopyHealthMetrics(m HealthMetrics) {
 	b.mux.Lock()
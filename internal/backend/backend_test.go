@@ -1,9 +1,21 @@
 package backend
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/clock"
+	"github.com/Nash0810/gobalance/internal/lifecycle"
 )
 
 // TestBackendHealthState tests the health state transitions
@@ -63,6 +75,10 @@ func TestBackendActiveRequests(t *testing.T) {
 		}
 	}
 
+	if b.ActiveRequests() != 10 {
+		t.Errorf("ActiveRequests() = %d, want 10", b.ActiveRequests())
+	}
+
 	// Decrement and verify
 	for i := 9; i >= 0; i-- {
 		b.DecrementActiveRequests()
@@ -95,6 +111,42 @@ func TestBackendActiveRequestsConcurrency(t *testing.T) {
 	}
 }
 
+// TestBackendRequestTotals tests success and failure totals count concurrent
+// requests exactly and survive a reload
+func TestBackendRequestTotals(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+	pool := NewPool()
+	pool.AddBackend(b)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if i%4 == 0 {
+					b.RecordRequestFailure()
+				} else {
+					b.RecordRequestSuccess()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if b.SuccessCount() != 7500 || b.FailureCount() != 2500 {
+		t.Fatalf("Expected 7500 successes and 2500 failures, got %d and %d", b.SuccessCount(), b.FailureCount())
+	}
+
+	reloaded := NewBackend(u)
+	pool.ReplaceBackends([]*Backend{reloaded})
+	reloaded.RecordRequestSuccess()
+	if reloaded.SuccessCount() != 7501 || reloaded.FailureCount() != 2500 {
+		t.Errorf("Expected totals carried across reload, got %d and %d", reloaded.SuccessCount(), reloaded.FailureCount())
+	}
+}
+
 // TestBackendHealthMetrics tests health check metrics
 func TestBackendHealthMetrics(t *testing.T) {
 	u, _ := url.Parse("http://localhost:8081")
@@ -130,6 +182,29 @@ func TestBackendHealthMetrics(t *testing.T) {
 	}
 }
 
+// TestBackendPassiveFailureLastCheck tests a failed request counts as a failure
+// without moving the last active check time
+func TestBackendPassiveFailureLastCheck(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	b.RecordHealthCheckSuccess()
+	checked := b.GetHealthMetrics().LastCheck
+	time.Sleep(time.Millisecond)
+	b.RecordPassiveFailure()
+
+	m := b.GetHealthMetrics()
+	if !m.LastCheck.Equal(checked) {
+		t.Errorf("Expected last check to stay at %v, got %v", checked, m.LastCheck)
+	}
+	if !m.LastFailure.After(checked) || !m.LastFailure.Equal(m.LastPassiveFailure) {
+		t.Errorf("Expected the passive failure to be recorded after %v, got %+v", checked, m)
+	}
+	if m.ConsecutiveFailures != 1 {
+		t.Errorf("Expected 1 failure, got %d", m.ConsecutiveFailures)
+	}
+}
+
 // TestBackendWeight tests weight configuration
 func TestBackendWeight(t *testing.T) {
 	u, _ := url.Parse("http://localhost:8081")
@@ -347,3 +422,527 @@ func TestPoolConcurrency(t *testing.T) {
 		t.Errorf("Expected 1000 active requests, got %d", firstBackend.GetActiveRequests())
 	}
 }
+
+// TestPoolVersion tests that mutations bump the pool version and reads don't
+func TestPoolVersion(t *testing.T) {
+	pool := NewPool()
+	v0 := pool.Version()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+
+	pool.AddBackend(NewBackend(u1))
+	v1 := pool.Version()
+	if v1 == v0 {
+		t.Error("AddBackend should bump the version")
+	}
+
+	// Reads must not change the version
+	pool.GetBackends()
+	pool.GetHealthyBackends()
+	pool.Size()
+	if pool.Version() != v1 {
+		t.Error("Reads should not bump the version")
+	}
+
+	pool.ReplaceBackends([]*Backend{NewBackend(u1), NewBackend(u2)})
+	v2 := pool.Version()
+	if v2 == v1 {
+		t.Error("ReplaceBackends should bump the version")
+	}
+
+	if removed := pool.RemoveBackend(u2.String()); removed == nil {
+		t.Fatal("RemoveBackend should return the removed backend")
+	}
+	if pool.Version() == v2 {
+		t.Error("RemoveBackend should bump the version")
+	}
+	if pool.Size() != 1 {
+		t.Errorf("Expected 1 backend after removal, got %d", pool.Size())
+	}
+
+	// Removing an unknown backend is a no-op
+	v3 := pool.Version()
+	if pool.RemoveBackend("http://localhost:9999") != nil {
+		t.Error("RemoveBackend of unknown URL should return nil")
+	}
+	if pool.Version() != v3 {
+		t.Error("No-op RemoveBackend should not bump the version")
+	}
+}
+
+// TestNewTransportProtocols tests transports are built for the configured protocol
+func TestNewTransportProtocols(t *testing.T) {
+	h1 := NewTransport(TransportConfig{Protocol: ProtocolHTTP1})
+	if !h1.Protocols.HTTP1() || h1.Protocols.HTTP2() || h1.Protocols.UnencryptedHTTP2() {
+		t.Errorf("HTTP/1 transport should only speak HTTP/1, got %v", h1.Protocols)
+	}
+
+	h2c := NewTransport(TransportConfig{Protocol: ProtocolH2C})
+	if !h2c.Protocols.UnencryptedHTTP2() || h2c.Protocols.HTTP1() {
+		t.Errorf("h2c transport should only speak cleartext HTTP/2, got %v", h2c.Protocols)
+	}
+
+	h2 := NewTransport(TransportConfig{Protocol: ProtocolH2})
+	if !h2.Protocols.HTTP2() {
+		t.Errorf("h2 transport should speak HTTP/2 over TLS, got %v", h2.Protocols)
+	}
+
+	tuned := NewTransport(TransportConfig{DisableKeepAlives: true, MaxIdleConnsPerHost: 50})
+	if !tuned.DisableKeepAlives || tuned.MaxIdleConnsPerHost != 50 {
+		t.Error("Keepalive settings not applied")
+	}
+}
+
+// TestTransportCache tests transports are shared by configuration signature
+func TestTransportCache(t *testing.T) {
+	cache := NewTransportCache()
+
+	a := cache.Get(TransportConfig{Protocol: ProtocolHTTP1})
+	b := cache.Get(TransportConfig{Protocol: ProtocolHTTP1})
+	c := cache.Get(TransportConfig{Protocol: ProtocolH2C})
+
+	if a != b {
+		t.Error("Identical configs should share a transport")
+	}
+	if a == c {
+		t.Error("Different configs should use different transports")
+	}
+}
+
+// TestSetFlushInterval tests the flush interval is applied to the reverse proxy
+func TestSetFlushInterval(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	b.SetFlushInterval(-1)
+	if b.ReverseProxy.FlushInterval != -1 {
+		t.Errorf("Expected flush interval -1, got %v", b.ReverseProxy.FlushInterval)
+	}
+}
+
+// TestStateChangedAt tests the transition timestamp moves only on real state changes
+func TestStateChangedAt(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	created := b.StateChangedAt()
+	if created.IsZero() {
+		t.Fatal("New backend should record its initial state time")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	b.SetState(Healthy) // Same state: no transition
+	if !b.StateChangedAt().Equal(created) {
+		t.Error("Timestamp should not change without a transition")
+	}
+
+	b.SetState(Unhealthy)
+	changed := b.StateChangedAt()
+	if !changed.After(created) {
+		t.Error("Timestamp should advance on a transition")
+	}
+
+	// Reload keeps the original transition time
+	replacement := NewBackend(u)
+	pool := NewPool()
+	pool.AddBackend(b)
+	pool.ReplaceBackends([]*Backend{replacement})
+	if !replacement.StateChangedAt().Equal(changed) {
+		t.Error("ReplaceBackends should preserve the state transition time")
+	}
+}
+
+// TestGetErrorRate tests the rolling error rate over a window, including expiry
+func TestGetErrorRate(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	fake := clock.NewFake(time.Unix(1_000_000, 0))
+	b.requests.clock = fake
+
+	if rate := b.GetErrorRate(time.Minute); rate != 0 {
+		t.Errorf("Expected 0 with no requests, got %v", rate)
+	}
+
+	// 30s ago: 10 failures. Now: 30 successes, 10 failures.
+	fake.Advance(-30 * time.Second)
+	for i := 0; i < 10; i++ {
+		b.RecordRequestFailure()
+	}
+	fake.Advance(30 * time.Second)
+	for i := 0; i < 30; i++ {
+		b.RecordRequestSuccess()
+	}
+	for i := 0; i < 10; i++ {
+		b.RecordRequestFailure()
+	}
+
+	if rate := b.GetErrorRate(time.Minute); math.Abs(rate-20.0/50.0) > 0.001 {
+		t.Errorf("Expected 1-minute error rate 0.4, got %v", rate)
+	}
+	if rate := b.GetErrorRate(10 * time.Second); math.Abs(rate-10.0/40.0) > 0.001 {
+		t.Errorf("Expected 10-second error rate 0.25, got %v", rate)
+	}
+
+	// Older failures drop out of the window
+	fake.Advance(45 * time.Second)
+	if rate := b.GetErrorRate(time.Minute); math.Abs(rate-0.25) > 0.001 {
+		t.Errorf("Expected 0.25 once older failures expire, got %v", rate)
+	}
+	fake.Advance(time.Minute)
+	if rate := b.GetErrorRate(time.Minute); rate != 0 {
+		t.Errorf("Expected 0 once all requests expire, got %v", rate)
+	}
+}
+
+// TestPoolReplaceBackendsDrainTimeout tests a removed backend with a hung request
+// drains as non-routable and is forcibly removed once the timeout expires
+func TestPoolReplaceBackendsDrainTimeout(t *testing.T) {
+	pool := NewPool()
+	pool.SetDrainTimeout(150 * time.Millisecond)
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	u3, _ := url.Parse("http://localhost:8083")
+	hung := NewBackend(u1)
+	idle := NewBackend(u2)
+	pool.AddBackend(hung)
+	pool.AddBackend(idle)
+
+	hung.IncrementActiveRequests() // Never finishes
+
+	pool.ReplaceBackends([]*Backend{NewBackend(u3)})
+
+	// The idle backend goes immediately; the hung one drains
+	if pool.Size() != 2 {
+		t.Fatalf("Expected new backend plus draining backend, got %d", pool.Size())
+	}
+	if hung.GetState() != Draining || hung.IsAlive() {
+		t.Errorf("Removed backend should be Draining and not alive, got %v", hung.GetState())
+	}
+	for _, b := range pool.GetHealthyBackends() {
+		if b == hung {
+			t.Error("Draining backend must not be routable")
+		}
+	}
+
+	// A reload during the drain doesn't resurrect its state
+	newB1 := NewBackend(u1)
+	pool.ReplaceBackends([]*Backend{NewBackend(u3), newB1})
+	if newB1.GetState() != Healthy {
+		t.Errorf("Re-added backend should start fresh, got %v", newB1.GetState())
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	for _, b := range pool.GetBackends() {
+		if b == hung {
+			t.Fatal("Draining backend should be removed after the timeout")
+		}
+	}
+	if pool.Size() != 2 {
+		t.Errorf("Expected the 2 configured backends to remain, got %d", pool.Size())
+	}
+}
+
+// TestPoolReplaceBackendsDrainCompletes tests a draining backend is removed as soon as its requests finish
+func TestPoolReplaceBackendsDrainCompletes(t *testing.T) {
+	pool := NewPool()
+	pool.SetDrainTimeout(10 * time.Second)
+
+	u1, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u1)
+	pool.AddBackend(b)
+	b.IncrementActiveRequests()
+
+	pool.ReplaceBackends(nil)
+	if pool.Size() != 1 {
+		t.Fatalf("Expected backend to be draining, got pool size %d", pool.Size())
+	}
+
+	b.DecrementActiveRequests()
+	deadline := time.Now().Add(time.Second)
+	for pool.Size() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pool.Size() != 0 {
+		t.Error("Drained backend should be removed once its requests finish")
+	}
+}
+
+// TestPoolDrainStopsOnShutdown tests drains run in the background group, so
+// shutdown waits for them and removes backends still draining
+func TestPoolDrainStopsOnShutdown(t *testing.T) {
+	background := lifecycle.NewGroup(context.Background())
+	pool := NewPool()
+	pool.SetDrainTimeout(time.Minute)
+	pool.SetBackground(background)
+
+	u1, _ := url.Parse("http://localhost:8081")
+	hung := NewBackend(u1)
+	pool.AddBackend(hung)
+	hung.IncrementActiveRequests() // Never finishes
+
+	pool.ReplaceBackends(nil)
+	if running := background.Running(); len(running) != 1 || running[0] != "pool_drain" {
+		t.Fatalf("Expected the drain tracked by the group, got %v", running)
+	}
+
+	if err := background.Stop(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Size() != 0 {
+		t.Errorf("Expected the draining backend removed on shutdown, got pool size %d", pool.Size())
+	}
+}
+
+// TestPoolReplaceBackendsKeepsLoad tests a surviving backend keeps its active request
+// count and latency through a reload, and requests that started before it finish cleanly
+func TestPoolReplaceBackendsKeepsLoad(t *testing.T) {
+	pool := NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	old := NewBackend(u1)
+	pool.AddBackend(old)
+	old.IncrementActiveRequests()
+	old.IncrementActiveRequests()
+	old.RecordLatency(40 * time.Millisecond)
+
+	reloaded := NewBackend(u1)
+	reloaded.SetWeight(5)
+	pool.ReplaceBackends([]*Backend{reloaded})
+
+	current := pool.GetBackends()[0]
+	if current != reloaded || current.Weight != 5 {
+		t.Fatal("Expected the reloaded backend with its new weight")
+	}
+	if got := current.GetActiveRequests(); got != 2 {
+		t.Errorf("Expected 2 active requests after reload, got %d", got)
+	}
+	if avg, ok := current.LatencyEWMA(); !ok || avg != 40*time.Millisecond {
+		t.Errorf("Expected latency to survive reload, got %v (ok=%v)", avg, ok)
+	}
+
+	// In-flight requests finish on the instance they started on
+	current.IncrementActiveRequests()
+	old.DecrementActiveRequests()
+	old.DecrementActiveRequests()
+	if got := current.GetActiveRequests(); got != 1 {
+		t.Errorf("Expected 1 active request once the pre-reload ones finish, got %d", got)
+	}
+}
+
+// TestLatencyP99 tests p99 needs enough samples and tracks only recent ones
+func TestLatencyP99(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	for i := 0; i < MinLatencySamples-1; i++ {
+		b.RecordLatency(time.Millisecond)
+	}
+	if _, ok := b.LatencyP99(); ok {
+		t.Fatal("p99 should not be reported before enough samples")
+	}
+
+	for i := 0; i < 98; i++ {
+		b.RecordLatency(time.Millisecond)
+	}
+	b.RecordLatency(time.Second)
+	b.RecordLatency(time.Second)
+	if p99, ok := b.LatencyP99(); !ok || p99 != time.Second {
+		t.Errorf("Expected p99 of 1s, got %v (ok=%v)", p99, ok)
+	}
+
+	// The spikes age out of the ring
+	for i := 0; i < latencySampleSize; i++ {
+		b.RecordLatency(time.Millisecond)
+	}
+	if p99, _ := b.LatencyP99(); p99 != time.Millisecond {
+		t.Errorf("Expected old spikes to age out, got p99 %v", p99)
+	}
+}
+
+// TestLatencyEWMA tests the moving average starts at the first sample and moves toward new ones
+func TestLatencyEWMA(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := NewBackend(u)
+
+	if _, ok := b.LatencyEWMA(); ok {
+		t.Fatal("EWMA should not be reported before the first sample")
+	}
+
+	b.RecordLatency(100 * time.Millisecond)
+	if avg, ok := b.LatencyEWMA(); !ok || avg != 100*time.Millisecond {
+		t.Fatalf("Expected EWMA of 100ms after one sample, got %v (ok=%v)", avg, ok)
+	}
+
+	b.RecordLatency(200 * time.Millisecond)
+	if avg, _ := b.LatencyEWMA(); avg != 120*time.Millisecond {
+		t.Errorf("Expected EWMA of 120ms, got %v", avg)
+	}
+}
+
+// TestNewTransportLocalAddr tests backend connections originate from the configured source IP
+func TestNewTransportLocalAddr(t *testing.T) {
+	dialer := NewDialer("127.0.0.1")
+	if addr, ok := dialer.LocalAddr.(*net.TCPAddr); !ok || !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("Expected dialer bound to 127.0.0.1, got %v", dialer.LocalAddr)
+	}
+	if NewDialer("").LocalAddr != nil {
+		t.Error("Dialer without local_addr should let the OS choose")
+	}
+
+	var remote string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+	}))
+	defer server.Close()
+
+	transport := NewTransport(TransportConfig{Protocol: ProtocolHTTP1, LocalAddr: "127.0.0.1"})
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if host, _, _ := net.SplitHostPort(remote); host != "127.0.0.1" {
+		t.Errorf("Expected connection from 127.0.0.1, got %s", remote)
+	}
+}
+
+// TestStateFileRestart tests a backend marked unhealthy before a restart is seeded
+// unhealthy afterwards, and that missing, stale or corrupt files seed nothing
+func TestStateFileRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "states.json")
+	newBackends := func() []*Backend {
+		u1, _ := url.Parse("http://localhost:8081")
+		u2, _ := url.Parse("http://localhost:8082")
+		return []*Backend{NewBackend(u1), NewBackend(u2)}
+	}
+
+	sf := NewStateFile(path, time.Minute)
+	if seeded, err := sf.Seed(newBackends()); err != nil || seeded != 0 {
+		t.Fatalf("Missing file should seed nothing, got %d (err=%v)", seeded, err)
+	}
+
+	before := newBackends()
+	before[1].SetState(Unhealthy)
+	if err := sf.Save(before); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// "Restart": fresh backends start healthy until seeded
+	after := newBackends()
+	seeded, err := sf.Seed(after)
+	if err != nil || seeded != 1 {
+		t.Fatalf("Expected 1 backend seeded, got %d (err=%v)", seeded, err)
+	}
+	if after[0].GetState() != Healthy || !after[0].IsAlive() {
+		t.Errorf("Healthy backend should stay healthy, got %s", after[0].GetState())
+	}
+	if after[1].GetState() != Unhealthy || after[1].IsAlive() {
+		t.Errorf("Expected backend seeded unhealthy pending re-probe, got %s", after[1].GetState())
+	}
+
+	// Too old to trust
+	stale := NewStateFile(path, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if seeded, err := stale.Seed(newBackends()); err != nil || seeded != 0 {
+		t.Errorf("Stale file should seed nothing, got %d (err=%v)", seeded, err)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fresh := newBackends()
+	if _, err := sf.Seed(fresh); err == nil {
+		t.Error("Expected an error for a corrupt state file")
+	}
+	if fresh[0].GetState() != Healthy || fresh[1].GetState() != Healthy {
+		t.Error("Corrupt state file should leave backends healthy")
+	}
+}
+
+// TestPoolHealthSnapshot tests healthy and routable lists are cached between
+// changes and rebuilt when membership or any backend's health changes
+func TestPoolHealthSnapshot(t *testing.T) {
+	pool := benchmarkPool(4) // Backend 0 is down
+	backends := pool.GetBackends()
+
+	if got := len(pool.GetRoutableBackends()); got != 3 {
+		t.Fatalf("Expected 3 routable backends, got %d", got)
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		pool.GetRoutableBackends()
+		pool.GetHealthyBackends()
+	}); allocs != 0 {
+		t.Errorf("Expected repeated calls without changes not to allocate, got %v allocs", allocs)
+	}
+
+	changes := []struct {
+		name         string
+		change       func()
+		wantHealthy  int
+		wantRoutable int
+	}{
+		{"state change", func() { backends[1].SetState(Unhealthy) }, 2, 2},
+		{"recovery", func() { backends[0].SetState(Healthy) }, 3, 3},
+		{"pause", func() { backends[2].SetPaused(true) }, 3, 2},
+		{"alive flag", func() { backends[3].SetAlive(false) }, 2, 1},
+		{"membership", func() {
+			u, _ := url.Parse("http://localhost:9100")
+			pool.AddBackend(NewBackend(u))
+		}, 3, 2},
+		{"removal", func() { pool.RemoveBackend("http://localhost:9000") }, 2, 1},
+	}
+	for _, tt := range changes {
+		tt.change()
+		if got := len(pool.GetHealthyBackends()); got != tt.wantHealthy {
+			t.Errorf("After %s: expected %d healthy backends, got %d", tt.name, tt.wantHealthy, got)
+		}
+		if got := len(pool.GetRoutableBackends()); got != tt.wantRoutable {
+			t.Errorf("After %s: expected %d routable backends, got %d", tt.name, tt.wantRoutable, got)
+		}
+	}
+
+	// Appending to a result must not write into the cached list
+	routable := pool.GetRoutableBackends()
+	_ = append(routable, backends[2])
+	if got := len(pool.GetRoutableBackends()); got != len(routable) {
+		t.Errorf("Expected the cached list unchanged by an append, got %d backends", got)
+	}
+}
+
+// benchmarkPool returns a pool of n backends with every fourth one down
+func benchmarkPool(n int) *Pool {
+	pool := NewPool()
+	for i := 0; i < n; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 9000+i))
+		b := NewBackend(u)
+		if i%4 == 0 {
+			b.SetState(Unhealthy)
+		}
+		pool.AddBackend(b)
+	}
+	return pool
+}
+
+// BenchmarkGetRoutableBackends measures the per-request cost strategies pay
+// to list selectable backends
+func BenchmarkGetRoutableBackends(b *testing.B) {
+	pool := benchmarkPool(32)
+	b.ReportAllocs()
+	for b.Loop() {
+		pool.GetRoutableBackends()
+	}
+}
+
+// BenchmarkGetHealthyBackends measures listing healthy backends
+func BenchmarkGetHealthyBackends(b *testing.B) {
+	pool := benchmarkPool(32)
+	b.ReportAllocs()
+	for b.Loop() {
+		pool.GetHealthyBackends()
+	}
+}
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/clock"
+)
+
+// DefaultErrorRateWindow is the window used when reporting backend error rates
+const DefaultErrorRateWindow = time.Minute
+
+// maxErrorRateWindow is the longest window an error rate can be computed over
+const maxErrorRateWindow = 5 * time.Minute
+
+// requestBucket counts request outcomes during one second
+type requestBucket struct {
+	second    int64 // Unix second this bucket covers
+	successes int64
+	failures  int64
+}
+
+// requestWindow keeps per-second request outcome counts for the last few minutes
+type requestWindow struct {
+	buckets []requestBucket // Ring indexed by unix second
+	mux     sync.Mutex
+	clock   clock.Clock // Time source (a fake in tests)
+}
+
+// newRequestWindow creates an empty request window
+func newRequestWindow() *requestWindow {
+	return &requestWindow{
+		buckets: make([]requestBucket, int(maxErrorRateWindow/time.Second)),
+		clock:   clock.Real,
+	}
+}
+
+// record counts one request outcome in the current second's bucket
+func (rw *requestWindow) record(success bool) {
+	rw.mux.Lock()
+	defer rw.mux.Unlock()
+
+	second := rw.clock.Now().Unix()
+	bucket := &rw.buckets[second%int64(len(rw.buckets))]
+	if bucket.second != second {
+		*bucket = requestBucket{second: second} // Reuse a bucket from a previous lap
+	}
+	if success {
+		bucket.successes++
+	} else {
+		bucket.failures++
+	}
+}
+
+// errorRate returns failures / total over the window (0 if there were no requests)
+func (rw *requestWindow) errorRate(window time.Duration) float64 {
+	if window > maxErrorRateWindow {
+		window = maxErrorRateWindow
+	}
+
+	rw.mux.Lock()
+	defer rw.mux.Unlock()
+
+	now := rw.clock.Now().Unix()
+	oldest := now - int64(window/time.Second)
+	var successes, failures int64
+	for _, bucket := range rw.buckets {
+		if bucket.second > oldest && bucket.second <= now {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+
+	total := successes + failures
+	if total == 0 {
+		return 0
+	}
+	return float64(failures) / float64(total)
+}
@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencySampleSize is how many recent response latencies are kept per backend
+const latencySampleSize = 256
+
+// MinLatencySamples is how many samples a backend needs before its p99 is reported
+const MinLatencySamples = 20
+
+// latencyEWMAAlpha is the weight of the newest sample in the moving average
+const latencyEWMAAlpha = 0.2
+
+// latencySamples is a ring of the most recent response latencies
+type latencySamples struct {
+	samples []time.Duration
+	next    int           // Index the next sample overwrites once the ring is full
+	ewma    time.Duration // Exponentially weighted moving average of all samples
+	mux     sync.Mutex
+}
+
+// newLatencySamples creates an empty latency ring
+func newLatencySamples() *latencySamples {
+	return &latencySamples{samples: make([]time.Duration, 0, latencySampleSize)}
+}
+
+// record adds a sample, replacing the oldest once the ring is full
+func (ls *latencySamples) record(d time.Duration) {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+
+	if len(ls.samples) == 0 {
+		ls.ewma = d
+	} else {
+		ls.ewma = time.Duration(latencyEWMAAlpha*float64(d) + (1-latencyEWMAAlpha)*float64(ls.ewma))
+	}
+
+	if len(ls.samples) < latencySampleSize {
+		ls.samples = append(ls.samples, d)
+		return
+	}
+	ls.samples[ls.next] = d
+	ls.next = (ls.next + 1) % latencySampleSize
+}
+
+// percentile returns the p-th percentile (0-100) of the samples, or false if
+// there are fewer than MinLatencySamples
+func (ls *latencySamples) percentile(p float64) (time.Duration, bool) {
+	ls.mux.Lock()
+	sorted := slices.Clone(ls.samples)
+	ls.mux.Unlock()
+
+	if len(sorted) < MinLatencySamples {
+		return 0, false
+	}
+	slices.Sort(sorted)
+	// Nearest-rank: the smallest sample at or above p% of all samples
+	rank := int(math.Ceil(float64(len(sorted)) * p / 100))
+	return sorted[max(rank-1, 0)], true
+}
+
+// average returns the moving average latency, or false before the first sample
+func (ls *latencySamples) average() (time.Duration, bool) {
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	return ls.ewma, len(ls.samples) > 0
+}
@@ -1,27 +1,98 @@
 package backend
 
 import (
+	"context"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/lifecycle"
 )
 
+// drainPollInterval is how often a draining backend's active requests are checked
+const drainPollInterval = 50 * time.Millisecond
+
 // Pool manages a collection of backends
 type Pool struct {
-	backends []*Backend
-	mux      sync.RWMutex
+	backends     []*Backend
+	draining     map[*Backend]bool // Removed backends still finishing requests
+	drainTimeout time.Duration     // Max wait for removed backends to drain (0 = remove immediately)
+	background   *lifecycle.Group  // Runs drain goroutines (nil = unmanaged goroutines)
+	mux          sync.RWMutex
+	version      uint64                         // Changed on every membership change, unique across pools (atomic)
+	snapshot     atomic.Pointer[healthSnapshot] // Cached healthy and routable backends
+}
+
+// healthSnapshot lists a pool's healthy and routable backends as of a pool
+// version and health epoch. The slices are shared and must not be modified.
+type healthSnapshot struct {
+	version  uint64
+	epoch    uint64
+	healthy  []*Backend
+	routable []*Backend
 }
 
+// poolVersions hands out pool versions, so no two pools ever share one and a
+// strategy handed a different pool always rebuilds its cached state
+var poolVersions atomic.Uint64
+
 // NewPool creates a new backend pool
 func NewPool() *Pool {
 	return &Pool{
 		backends: make([]*Backend, 0),
+		draining: make(map[*Backend]bool),
+		version:  poolVersions.Add(1),
 	}
 }
 
+// bumpVersion records a membership change
+func (p *Pool) bumpVersion() {
+	atomic.StoreUint64(&p.version, poolVersions.Add(1))
+}
+
+// SetDrainTimeout sets how long ReplaceBackends keeps a removed backend with
+// in-flight requests around before forcibly removing it
+func (p *Pool) SetDrainTimeout(d time.Duration) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.drainTimeout = d
+}
+
+// SetBackground runs the goroutines draining removed backends in g, so they
+// are stopped and waited for on shutdown
+func (p *Pool) SetBackground(g *lifecycle.Group) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.background = g
+}
+
 // AddBackend adds a backend to the pool
 func (p *Pool) AddBackend(b *Backend) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
 	p.backends = append(p.backends, b)
+	p.bumpVersion()
+}
+
+// RemoveBackend removes the backend with the given URL from the pool.
+// Returns the removed backend, or nil if no backend matched.
+func (p *Pool) RemoveBackend(rawURL string) *Backend {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	for i, b := range p.backends {
+		if b.URL.String() == rawURL {
+			// Build a new slice so copies handed out by GetBackends stay valid
+			backends := make([]*Backend, 0, len(p.backends)-1)
+			backends = append(backends, p.backends[:i]...)
+			backends = append(backends, p.backends[i+1:]...)
+			p.backends = backends
+			p.bumpVersion()
+			return b
+		}
+	}
+	return nil
 }
 
 // GetBackends returns all backends (copy of slice)
@@ -35,18 +106,47 @@ func (p *Pool) GetBackends() []*Backend {
 	return backends
 }
 
-// GetHealthyBackends returns only healthy backends
+// GetHealthyBackends returns only healthy backends. The slice is shared
+// between callers and must not be modified.
 func (p *Pool) GetHealthyBackends() []*Backend {
+	return p.healthSnapshot().healthy
+}
+
+// GetRoutableBackends returns the backends new requests may be sent to:
+// healthy ones that are not paused. The slice is shared between callers and
+// must not be modified.
+func (p *Pool) GetRoutableBackends() []*Backend {
+	return p.healthSnapshot().routable
+}
+
+// healthSnapshot returns the cached healthy and routable backends, rebuilding
+// them when membership or any backend's health changed since. Strategies call
+// this on every request, so between changes it must not allocate.
+func (p *Pool) healthSnapshot() *healthSnapshot {
+	// Load the epoch before reading any flags: a change racing with the
+	// rebuild bumps it again, so a stale snapshot never looks current
+	epoch := healthEpoch.Load()
+	if s := p.snapshot.Load(); s != nil && s.epoch == epoch && s.version == p.Version() {
+		return s
+	}
+
 	p.mux.RLock()
 	defer p.mux.RUnlock()
 
-	var healthy []*Backend
+	s := &healthSnapshot{version: p.Version(), epoch: epoch}
 	for _, b := range p.backends {
 		if b.IsAlive() {
-			healthy = append(healthy, b)
+			s.healthy = append(s.healthy, b)
+		}
+		if b.IsRoutable() {
+			s.routable = append(s.routable, b)
 		}
 	}
-	return healthy
+	// Callers appending to a result must not write into the shared array
+	s.healthy = slices.Clip(s.healthy)
+	s.routable = slices.Clip(s.routable)
+	p.snapshot.Store(s)
+	return s
 }
 
 // Size returns the total number of backends
@@ -58,6 +158,9 @@ func (p *Pool) Size() int {
 
 // ReplaceBackends replaces all backends while preserving health state
 // If a backend with the same URL exists, copy its health state to the new backend
+// and share its active request count and latency samples.
+// Removed backends with in-flight requests are marked Draining and stay in the
+// pool (not routable) until their requests finish or the drain timeout expires.
 func (p *Pool) ReplaceBackends(newBackends []*Backend) {
 	p.mux.Lock()
 	defer p.mux.Unlock()
@@ -65,7 +168,9 @@ func (p *Pool) ReplaceBackends(newBackends []*Backend) {
 	// Create a map of old backends by URL for quick lookup
 	oldBackendMap := make(map[string]*Backend)
 	for _, b := range p.backends {
-		oldBackendMap[b.URL.String()] = b
+		if !p.draining[b] {
+			oldBackendMap[b.URL.String()] = b
+		}
 	}
 
 	// For each new backend, check if it existed before
@@ -73,7 +178,11 @@ func (p *Pool) ReplaceBackends(newBackends []*Backend) {
 		if oldBackend, exists := oldBackendMap[newBackend.URL.String()]; exists {
 			// Preserve health state from old backend
 			newBackend.SetAlive(oldBackend.IsAlive())
+			newBackend.SetPaused(oldBackend.IsPaused())
 			newBackend.SetState(oldBackend.GetState())
+			newBackend.copyStateChangedAt(oldBackend)
+			newBackend.copyAddedAt(oldBackend)
+			newBackend.shareLoad(oldBackend)
 
 			// Copy health metrics (consecutive successes/failures)
 			oldMetrics := oldBackend.GetHealthMetrics()
@@ -82,6 +191,71 @@ func (p *Pool) ReplaceBackends(newBackends []*Backend) {
 		// If backend is new, it keeps its default state (HEALTHY)
 	}
 
+	// Keep removed backends around while they drain
+	kept := make(map[string]bool, len(newBackends))
+	for _, b := range newBackends {
+		kept[b.URL.String()] = true
+	}
+	backends := append([]*Backend(nil), newBackends...)
+	for b := range p.draining {
+		backends = append(backends, b)
+	}
+	for url, oldBackend := range oldBackendMap {
+		if kept[url] || p.drainTimeout <= 0 || oldBackend.GetActiveRequests() == 0 {
+			continue
+		}
+		oldBackend.SetState(Draining)
+		p.draining[oldBackend] = true
+		backends = append(backends, oldBackend)
+		timeout := p.drainTimeout
+		drain := func(ctx context.Context) { p.drain(ctx, oldBackend, timeout) }
+		if p.background != nil {
+			p.background.Go("pool_drain", drain)
+		} else {
+			go drain(context.Background())
+		}
+	}
+
 	// Replace the backends slice
-	p.backends = newBackends
+	p.backends = backends
+	p.bumpVersion()
+}
+
+// drain waits up to timeout for b's active requests to reach zero, then
+// removes it. Shutdown (ctx done) removes it at once.
+func (p *Pool) drain(ctx context.Context, b *Backend, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for b.GetActiveRequests() > 0 && time.Now().Before(deadline) && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+	p.removeDrained(b)
+}
+
+// removeDrained drops a draining backend from the pool
+func (p *Pool) removeDrained(b *Backend) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	delete(p.draining, b)
+	backends := make([]*Backend, 0, len(p.backends))
+	for _, existing := range p.backends {
+		if existing != b {
+			backends = append(backends, existing)
+		}
+	}
+	p.backends = backends
+	p.bumpVersion()
+}
+
+// Version returns a value that changes whenever pool membership changes and
+// is never shared with another pool, letting strategies cache derived
+// structures between changes
+func (p *Pool) Version() uint64 {
+	return atomic.LoadUint64(&p.version)
 }
@@ -44,4 +44,6 @@ type HealthMetrics struct {
 	LastCheck            time.Time // Time of last health check
 	LastSuccess          time.Time // Time of last successful check
 	LastFailure          time.Time // Time of last failed check
+	LastFailureReason    string    // Why the last check failed (timeout, bad_status, ...)
+	LastPassiveFailure   time.Time // Time of last failed request counted by the passive tracker
 }
@@ -0,0 +1,89 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateFile persists backend health states so a restart doesn't send the first
+// requests to backends that were known to be down
+type StateFile struct {
+	path   string
+	maxAge time.Duration // Saved states older than this are ignored (0 = never stale)
+}
+
+// savedStates is the on-disk format of a state file
+type savedStates struct {
+	SavedAt  time.Time         `json:"saved_at"`
+	Backends map[string]string `json:"backends"` // Backend URL → state name
+}
+
+// NewStateFile creates a state file at path whose contents expire after maxAge
+func NewStateFile(path string, maxAge time.Duration) *StateFile {
+	return &StateFile{path: path, maxAge: maxAge}
+}
+
+// Save writes the states of backends, replacing the file atomically.
+// Draining backends are left out: they are on their way out of the pool.
+func (sf *StateFile) Save(backends []*Backend) error {
+	saved := savedStates{SavedAt: time.Now(), Backends: make(map[string]string, len(backends))}
+	for _, b := range backends {
+		if state := b.GetState(); state != Draining {
+			saved.Backends[b.URL.String()] = state.String()
+		}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(sf.path), filepath.Base(sf.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), sf.path)
+}
+
+// Seed marks backends that were saved as not healthy as Unhealthy, so they only
+// get traffic once active health checks pass again. A missing or stale file
+// seeds nothing. Returns how many backends were seeded.
+func (sf *StateFile) Seed(backends []*Backend) (int, error) {
+	data, err := os.ReadFile(sf.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var saved savedStates
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, fmt.Errorf("parse state file %s: %w", sf.path, err)
+	}
+	if sf.maxAge > 0 && time.Since(saved.SavedAt) > sf.maxAge {
+		return 0, nil
+	}
+
+	seeded := 0
+	for _, b := range backends {
+		state, exists := saved.Backends[b.URL.String()]
+		if !exists || state == Healthy.String() {
+			continue
+		}
+		b.SetState(Unhealthy)
+		seeded++
+	}
+	return seeded, nil
+}
@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Supported backend protocols
+const (
+	ProtocolHTTP1 = "http1" // HTTP/1.1 (default)
+	ProtocolH2    = "h2"    // HTTP/2 over TLS
+	ProtocolH2C   = "h2c"   // HTTP/2 over cleartext TCP (prior knowledge)
+)
+
+// TransportConfig describes how connections to a backend are made.
+// It is comparable so it can be used as a cache key.
+type TransportConfig struct {
+	Protocol            string        // ProtocolHTTP1, ProtocolH2 or ProtocolH2C
+	DisableKeepAlives   bool          // Close connections after each request
+	IdleConnTimeout     time.Duration // How long idle connections are kept (0 = default)
+	MaxIdleConnsPerHost int           // Idle connections kept per backend (0 = default)
+	LocalAddr           string        // Source IP for backend connections ("" = chosen by the OS)
+	ConnectTimeout      time.Duration // Longest a dial may take (0 = 30s)
+}
+
+// NewDialer builds the dialer for backend connections, bound to localAddr if set.
+// An unparseable address is ignored (config validation rejects it up front).
+func NewDialer(localAddr string) *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second, // Same as http.DefaultTransport
+		KeepAlive: 30 * time.Second,
+	}
+	if ip := net.ParseIP(localAddr); ip != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return dialer
+}
+
+// NewTransport builds an HTTP transport for the given configuration
+func NewTransport(cfg TransportConfig) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	protocols := new(http.Protocols)
+	switch cfg.Protocol {
+	case ProtocolH2:
+		protocols.SetHTTP2(true)
+		t.ForceAttemptHTTP2 = true
+	case ProtocolH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+		t.ForceAttemptHTTP2 = false
+	}
+	t.Protocols = protocols
+
+	if cfg.LocalAddr != "" || cfg.ConnectTimeout > 0 {
+		dialer := NewDialer(cfg.LocalAddr)
+		if cfg.ConnectTimeout > 0 {
+			// Bounds only connection setup, so an unreachable backend fails over
+			// quickly while the request timeout still governs the whole exchange
+			dialer.Timeout = cfg.ConnectTimeout
+		}
+		t.DialContext = dialer.DialContext
+	}
+
+	t.DisableKeepAlives = cfg.DisableKeepAlives
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+
+	return t
+}
+
+// TransportCache shares transports between backends with identical settings
+type TransportCache struct {
+	transports map[TransportConfig]*http.Transport
+	mux        sync.Mutex
+}
+
+// NewTransportCache creates an empty transport cache
+func NewTransportCache() *TransportCache {
+	return &TransportCache{
+		transports: make(map[TransportConfig]*http.Transport),
+	}
+}
+
+// Get returns the transport for cfg, creating it on first use
+func (tc *TransportCache) Get(cfg TransportConfig) *http.Transport {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	if t, exists := tc.transports[cfg]; exists {
+		return t
+	}
+
+	t := NewTransport(cfg)
+	tc.transports[cfg] = t
+	return t
+}
@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// WarmUp opens up to conns connections to the backend ahead of its first real
+// request by sending concurrent HEAD requests to target through the backend's
+// transport. The connections stay idle in the transport for reuse. Returns the
+// number of probes that got a response.
+func (b *Backend) WarmUp(ctx context.Context, target string, conns int) int {
+	transport := b.ReverseProxy.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	var warmed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+			if err != nil {
+				return
+			}
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				return
+			}
+			io.Copy(io.Discard, resp.Body) // Drain so the connection is kept
+			resp.Body.Close()
+			warmed.Add(1)
+		}()
+	}
+	wg.Wait()
+	return int(warmed.Load())
+}
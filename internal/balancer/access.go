@@ -0,0 +1,75 @@
+package balancer
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// AccessControl rejects requests by method or path before they reach a backend.
+// Deny lists win over allow lists; an empty allow list allows everything.
+//
+// Path patterns use path.Match syntax and also cover everything below them, so
+// "/.git" rejects "/.git/config" and "/admin/*" rejects "/admin/users/1".
+type AccessControl struct {
+	AllowMethods []string // Only these methods are proxied (empty = all)
+	DenyMethods  []string // Methods rejected with 405
+	AllowPaths   []string // Only paths matching these are proxied (empty = all)
+	DenyPaths    []string // Paths rejected with 403
+}
+
+// SetAccessControl enables method and path filtering (nil disables it)
+func (lb *Balancer) SetAccessControl(ac *AccessControl) {
+	lb.access = ac
+}
+
+// check returns the status to reject r with, or 0 if r may proceed
+func (ac *AccessControl) check(r *http.Request) int {
+	if !ac.methodAllowed(r.Method) {
+		return http.StatusMethodNotAllowed
+	}
+	// Clean first so "/static/../.git" can't slip past a deny pattern
+	p := path.Clean("/" + r.URL.Path)
+	if matchesAnyPath(ac.DenyPaths, p) || (len(ac.AllowPaths) > 0 && !matchesAnyPath(ac.AllowPaths, p)) {
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// methodAllowed reports whether method passes the method lists
+func (ac *AccessControl) methodAllowed(method string) bool {
+	for _, m := range ac.DenyMethods {
+		if strings.EqualFold(m, method) {
+			return false
+		}
+	}
+	if len(ac.AllowMethods) == 0 {
+		return true
+	}
+	for _, m := range ac.AllowMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeader lists the allowed methods for a 405 response ("" if any method not denied is allowed)
+func (ac *AccessControl) allowHeader() string {
+	return strings.ToUpper(strings.Join(ac.AllowMethods, ", "))
+}
+
+// matchesAnyPath reports whether p, or one of its parent directories, matches a pattern
+func matchesAnyPath(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		for dir := p; ; dir = path.Dir(dir) {
+			if ok, _ := path.Match(pattern, dir); ok {
+				return true
+			}
+			if dir == "/" {
+				break
+			}
+		}
+	}
+	return false
+}
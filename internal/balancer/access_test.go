@@ -0,0 +1,83 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// TestAccessControl tests denied methods get 405, denied paths get 403 and
+// neither reaches the backend, while allowed traffic is proxied
+func TestAccessControl(t *testing.T) {
+	hits := atomic.Int32{}
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}), 1)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetAccessControl(&AccessControl{
+		DenyMethods: []string{"TRACE"},
+		DenyPaths:   []string{"/.git", "/*.php"},
+	})
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"TRACE", "/", http.StatusMethodNotAllowed},
+		{"GET", "/.git", http.StatusForbidden},
+		{"GET", "/.git/config", http.StatusForbidden},
+		{"GET", "/static/../.git/HEAD", http.StatusForbidden},
+		{"POST", "/index.php", http.StatusForbidden},
+		{"GET", "/", http.StatusOK},
+		{"GET", "/.github/workflows", http.StatusOK},
+		{"DELETE", "/api/items/1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		hits.Store(0)
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+		if reached := hits.Load() > 0; reached != (tt.want == http.StatusOK) {
+			t.Errorf("%s %s: backend reached = %v", tt.method, tt.path, reached)
+		}
+	}
+}
+
+// TestAccessControlAllowLists tests only allow-listed methods and paths are proxied
+func TestAccessControlAllowLists(t *testing.T) {
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), 1)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetAccessControl(&AccessControl{
+		AllowMethods: []string{"get", "HEAD"},
+		AllowPaths:   []string{"/api", "/health"},
+		DenyPaths:    []string{"/api/internal"},
+	})
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/api/users", http.StatusOK},
+		{"HEAD", "/health", http.StatusOK},
+		{"GET", "/api/internal/debug", http.StatusForbidden},
+		{"GET", "/other", http.StatusForbidden},
+		{"PUT", "/api/users", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+		if tt.want == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("Expected Allow: GET, HEAD on 405, got %q", w.Header().Get("Allow"))
+		}
+	}
+}
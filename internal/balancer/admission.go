@@ -0,0 +1,283 @@
+package balancer
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// ErrQueueFull is returned when a request can't be admitted or queued
+var ErrQueueFull = errors.New("admission queue full")
+
+// ErrPreempted is returned to a queued request evicted to make room for a
+// request of a higher priority or a higher-weighted tenant
+var ErrPreempted = errors.New("admission preempted by higher-priority request")
+
+// AdmissionQueue caps the number of in-flight requests and queues the excess.
+// Waiters are grouped by priority and weight: within a class, slots go strictly
+// in arrival order (FIFO). Higher priorities are always served first; within a
+// priority, freed slots are shared in proportion to weight using smooth weighted
+// round robin, so heavier tenants are admitted faster without starving lighter
+// ones. When the queue is full, a request of higher priority (or equal priority
+// and heavier weight) evicts the newest waiter of the lowest class queued.
+// Freed slots are handed straight to a waiter so newcomers can't barge ahead.
+type AdmissionQueue struct {
+	maxInFlight int            // Concurrent requests allowed through
+	maxQueue    int            // Requests allowed to wait for a slot (0 = shed immediately)
+	inFlight    int            // Requests currently holding a slot
+	queued      int            // Requests waiting across all classes
+	classes     []*waiterClass // Waiters by priority then weight, highest first
+	mux         sync.Mutex
+}
+
+// waiterClass is the FIFO of waiters sharing a priority and weight
+type waiterClass struct {
+	priority      Priority
+	weight        int
+	currentWeight int        // Smooth weighted round robin state
+	waiters       *list.List // Oldest at the front
+}
+
+// waiter is a request waiting for a slot
+type waiter struct {
+	ready chan struct{} // Closed when the waiter has been handed a slot or evicted
+	err   error         // Why the waiter was evicted (nil = handed a slot)
+}
+
+// NewAdmissionQueue creates a new admission queue
+func NewAdmissionQueue(maxInFlight int, maxQueue int) *AdmissionQueue {
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+	if maxQueue < 0 {
+		maxQueue = 0
+	}
+	return &AdmissionQueue{
+		maxInFlight: maxInFlight,
+		maxQueue:    maxQueue,
+	}
+}
+
+// Acquire waits for a slot at normal priority and weight 1. See AcquirePriority.
+func (q *AdmissionQueue) Acquire(ctx context.Context) error {
+	return q.AcquirePriority(ctx, PriorityNormal, 1)
+}
+
+// AcquireWeighted waits for a slot at normal priority on behalf of a tenant
+// with the given weight. See AcquirePriority.
+func (q *AdmissionQueue) AcquireWeighted(ctx context.Context, weight int) error {
+	return q.AcquirePriority(ctx, PriorityNormal, weight)
+}
+
+// AcquirePriority waits for a slot for a request of the given priority on
+// behalf of a tenant with the given weight (< 1 counts as 1). Returns
+// ErrQueueFull if the queue is full of requests ranking at least as high,
+// ErrPreempted if a higher-ranking request evicted it while queued, or the
+// context error if ctx ends first. Every successful acquire must be paired with Release.
+func (q *AdmissionQueue) AcquirePriority(ctx context.Context, priority Priority, weight int) error {
+	if weight < 1 {
+		weight = 1
+	}
+
+	q.mux.Lock()
+	if q.inFlight < q.maxInFlight && q.queued == 0 {
+		q.inFlight++
+		q.mux.Unlock()
+		return nil
+	}
+	if q.queued >= q.maxQueue && !q.evictLowerLocked(priority, weight) {
+		q.mux.Unlock()
+		return ErrQueueFull
+	}
+	class := q.classLocked(priority, weight)
+	w := &waiter{ready: make(chan struct{})}
+	elem := class.waiters.PushBack(w)
+	q.queued++
+	q.mux.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.err
+	case <-ctx.Done():
+		q.mux.Lock()
+		defer q.mux.Unlock()
+		select {
+		case <-w.ready:
+			if w.err != nil {
+				return w.err // Evicted as we gave up
+			}
+			// Handed a slot as we gave up: pass it on
+			q.releaseLocked()
+		default:
+			class.waiters.Remove(elem)
+			q.queued--
+		}
+		return ctx.Err()
+	}
+}
+
+// ranksBelow reports whether the class is served after requests of the given
+// priority and weight: it has a lower priority, or the same priority and a lighter weight
+func (c *waiterClass) ranksBelow(priority Priority, weight int) bool {
+	if c.priority != priority {
+		return c.priority < priority
+	}
+	return c.weight < weight
+}
+
+// classLocked returns the class for priority and weight, creating it if needed (caller holds lock)
+func (q *AdmissionQueue) classLocked(priority Priority, weight int) *waiterClass {
+	i := 0
+	for ; i < len(q.classes); i++ {
+		if q.classes[i].priority == priority && q.classes[i].weight == weight {
+			return q.classes[i]
+		}
+		if q.classes[i].ranksBelow(priority, weight) {
+			break
+		}
+	}
+	class := &waiterClass{priority: priority, weight: weight, waiters: list.New()}
+	q.classes = slices.Insert(q.classes, i, class)
+	return class
+}
+
+// evictLowerLocked evicts the newest waiter of the lowest non-empty class
+// ranking below priority and weight. Returns false if there is none (caller holds lock).
+func (q *AdmissionQueue) evictLowerLocked(priority Priority, weight int) bool {
+	for i := len(q.classes) - 1; i >= 0 && q.classes[i].ranksBelow(priority, weight); i-- {
+		if back := q.classes[i].waiters.Back(); back != nil {
+			q.classes[i].waiters.Remove(back)
+			q.queued--
+			w := back.Value.(*waiter)
+			w.err = ErrPreempted
+			close(w.ready)
+			return true
+		}
+	}
+	return false
+}
+
+// Release frees a slot, handing it to the next waiter if there is one
+func (q *AdmissionQueue) Release() {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	q.releaseLocked()
+}
+
+// releaseLocked implements Release (caller holds lock)
+func (q *AdmissionQueue) releaseLocked() {
+	if class := q.nextClassLocked(); class != nil {
+		front := class.waiters.Front()
+		class.waiters.Remove(front)
+		q.queued--
+		close(front.Value.(*waiter).ready) // Slot transfers without touching inFlight
+		return
+	}
+	q.inFlight--
+}
+
+// nextClassLocked picks the class to hand the next slot to with smooth
+// weighted round robin over the highest-priority classes with waiters (nil =
+// none waiting)
+func (q *AdmissionQueue) nextClassLocked() *waiterClass {
+	var selected *waiterClass
+	totalWeight := 0
+	for _, class := range q.classes {
+		if class.waiters.Len() == 0 {
+			class.currentWeight = 0 // Idle classes don't bank credit
+			continue
+		}
+		if selected != nil && class.priority < selected.priority {
+			continue // Classes are ordered by priority: only the top one with waiters competes
+		}
+		class.currentWeight += class.weight
+		totalWeight += class.weight
+		if selected == nil || class.currentWeight > selected.currentWeight {
+			selected = class
+		}
+	}
+	if selected != nil {
+		selected.currentWeight -= totalWeight
+	}
+	return selected
+}
+
+// InFlight returns the number of requests holding a slot
+func (q *AdmissionQueue) InFlight() int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return q.inFlight
+}
+
+// QueueLen returns the number of requests waiting for a slot
+func (q *AdmissionQueue) QueueLen() int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return q.queued
+}
+
+// TenantWeights maps a request header to the weight its tenant gets in the
+// admission queue. Under saturation heavier tenants are admitted first and
+// lighter ones are shed first.
+type TenantWeights struct {
+	Header  string         // Request header naming the tenant, e.g. X-Tenant
+	Weights map[string]int // Weight per tenant
+	Default int            // Weight of tenants not listed (< 1 = 1)
+}
+
+// SetTenantWeights enables weighted fair admission (nil = every request weighs
+// the same and is admitted in arrival order). Shed requests then get 429
+// rather than 503, since a tenant over its share is being throttled.
+func (lb *Balancer) SetTenantWeights(tw *TenantWeights) {
+	lb.tenants = tw
+}
+
+// weight returns the admission weight of r's tenant
+func (tw *TenantWeights) weight(r *http.Request) int {
+	if w, ok := tw.Weights[r.Header.Get(tw.Header)]; ok {
+		return w
+	}
+	return tw.Default
+}
+
+// admit waits for an admission slot at the request's priority, shedding the
+// request with 503 (429 with tenant weights) if none frees up in time. Returns
+// false if the request was rejected.
+func (lb *Balancer) admit(w http.ResponseWriter, r *http.Request, requestID string, priority Priority) bool {
+	ctx := r.Context()
+	if lb.admissionWait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lb.admissionWait)
+		defer cancel()
+	}
+
+	weight := 1
+	if lb.tenants != nil {
+		weight = lb.tenants.weight(r)
+	}
+	err := lb.admission.AcquirePriority(ctx, priority, weight)
+	if err == nil {
+		return true
+	}
+
+	lb.logger.Warn("request_shed",
+		"request_id", requestID,
+		"error", err.Error(),
+		"weight", weight,
+		"priority", priority.String(),
+		"in_flight", lb.admission.InFlight(),
+		"queued", lb.admission.QueueLen())
+	if lb.collector != nil {
+		lb.collector.RequestsShedTotal.Inc()
+	}
+	w.Header().Set("Retry-After", "1")
+	if lb.tenants != nil {
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return false
+	}
+	http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+	return false
+}
@@ -0,0 +1,356 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// waitForQueueLen polls until the queue holds n waiters
+func waitForQueueLen(t *testing.T, q *AdmissionQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for q.QueueLen() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d queued requests (have %d)", n, q.QueueLen())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestAdmissionQueueFIFO tests freed slots go to the oldest waiter
+func TestAdmissionQueueFIFO(t *testing.T) {
+	q := NewAdmissionQueue(1, 10)
+	if err := q.Acquire(context.Background()); err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+
+	const waiters = 5
+	order := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		go func(i int) {
+			q.Acquire(context.Background())
+			order <- i
+			q.Release()
+		}(i)
+		waitForQueueLen(t, q, i+1) // Enqueue in a known order
+	}
+
+	q.Release()
+	for want := 0; want < waiters; want++ {
+		if got := <-order; got != want {
+			t.Fatalf("Expected waiter %d admitted next, got %d", want, got)
+		}
+	}
+	if q.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", q.InFlight())
+	}
+}
+
+// TestAdmissionQueueShedding tests a full queue rejects and a cancelled waiter leaves the queue
+func TestAdmissionQueueShedding(t *testing.T) {
+	q := NewAdmissionQueue(1, 1)
+	q.Acquire(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- q.Acquire(ctx) }()
+	waitForQueueLen(t, q, 1)
+
+	if err := q.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancelled waiter to fail with context.Canceled, got %v", err)
+	}
+	if q.QueueLen() != 0 {
+		t.Errorf("Cancelled waiter should leave the queue, have %d", q.QueueLen())
+	}
+
+	q.Release()
+	if q.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", q.InFlight())
+	}
+}
+
+// TestE2EAdmissionQueueArrivalOrder tests requests queued against a saturated pool complete in arrival order
+func TestE2EAdmissionQueueArrivalOrder(t *testing.T) {
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	var served []string
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/block" {
+			<-unblock
+		}
+		mu.Lock()
+		served = append(served, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	queue := NewAdmissionQueue(1, 10)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetAdmissionQueue(queue, 0)
+
+	var wg sync.WaitGroup
+	serve := func(path string) {
+		defer wg.Done()
+		balancer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Saturate the single slot, then queue requests one at a time
+	wg.Add(1)
+	go serve("/block")
+	for queue.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	const queued = 5
+	for i := 0; i < queued; i++ {
+		wg.Add(1)
+		go serve(fmt.Sprintf("/req-%d", i))
+		waitForQueueLen(t, queue, i+1)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 0; i < queued; i++ {
+		if want := fmt.Sprintf("/req-%d", i); served[i+1] != want {
+			t.Fatalf("Expected arrival order, got %v", served)
+		}
+	}
+}
+
+// TestE2EAdmissionQueueSheds tests requests beyond the queue limit get 503
+func TestE2EAdmissionQueueSheds(t *testing.T) {
+	unblock := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	queue := NewAdmissionQueue(1, 0)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetAdmissionQueue(queue, 0)
+
+	done := make(chan struct{})
+	go func() {
+		balancer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	for queue.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when saturated, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Shed response should carry Retry-After")
+	}
+
+	close(unblock)
+	<-done
+}
+
+// TestAdmissionQueueWeightedShare tests freed slots are shared between waiting
+// weights in proportion to weight, in arrival order within a weight
+func TestAdmissionQueueWeightedShare(t *testing.T) {
+	q := NewAdmissionQueue(1, 100)
+	q.Acquire(context.Background())
+
+	type admission struct{ weight, seq int }
+	order := make(chan admission, 20)
+	enqueue := func(weight, seq int) {
+		go func() {
+			q.AcquireWeighted(context.Background(), weight)
+			order <- admission{weight, seq}
+			q.Release()
+		}()
+	}
+	// Light waiters arrive first; heavy ones must still overtake them
+	for i := 0; i < 10; i++ {
+		enqueue(1, i)
+		waitForQueueLen(t, q, i+1)
+	}
+	for i := 0; i < 10; i++ {
+		enqueue(3, i)
+		waitForQueueLen(t, q, i+11)
+	}
+
+	q.Release()
+	next := map[int]int{} // Next expected seq per weight
+	heavy := 0
+	for i := 0; i < 20; i++ {
+		a := <-order
+		if a.seq != next[a.weight] {
+			t.Fatalf("Weight %d admitted seq %d, expected %d (FIFO within a weight)", a.weight, a.seq, next[a.weight])
+		}
+		next[a.weight]++
+		if i < 8 && a.weight == 3 {
+			heavy++
+		}
+	}
+	if heavy != 6 {
+		t.Errorf("Expected 6 of the first 8 slots for weight 3 vs 1, got %d", heavy)
+	}
+	if q.InFlight() != 0 || q.QueueLen() != 0 {
+		t.Errorf("Expected an empty queue, got %d in flight, %d queued", q.InFlight(), q.QueueLen())
+	}
+}
+
+// TestAdmissionQueuePreemption tests a heavier request evicts the newest
+// lightest waiter from a full queue, and is itself rejected only by equal or heavier waiters
+func TestAdmissionQueuePreemption(t *testing.T) {
+	q := NewAdmissionQueue(1, 2)
+	q.Acquire(context.Background())
+
+	light := make([]chan error, 2)
+	for i := range light {
+		light[i] = make(chan error, 1)
+		go func(errc chan error) { errc <- q.AcquireWeighted(context.Background(), 1) }(light[i])
+		waitForQueueLen(t, q, i+1)
+	}
+
+	heavy := make(chan error, 2)
+	go func() { heavy <- q.AcquireWeighted(context.Background(), 5) }()
+	if err := <-light[1]; !errors.Is(err, ErrPreempted) {
+		t.Fatalf("Expected the newest light waiter preempted, got %v", err)
+	}
+	waitForQueueLen(t, q, 2)
+
+	go func() { heavy <- q.AcquireWeighted(context.Background(), 5) }()
+	if err := <-light[0]; !errors.Is(err, ErrPreempted) {
+		t.Fatalf("Expected the remaining light waiter preempted, got %v", err)
+	}
+	waitForQueueLen(t, q, 2)
+
+	if err := q.AcquireWeighted(context.Background(), 5); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected an equal weight to find the queue full, got %v", err)
+	}
+	if err := q.AcquireWeighted(context.Background(), 1); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected a lighter weight to find the queue full, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		q.Release()
+		if err := <-heavy; err != nil {
+			t.Fatalf("Expected the heavy waiter admitted, got %v", err)
+		}
+	}
+	q.Release()
+	if q.InFlight() != 0 {
+		t.Errorf("Expected no requests in flight, got %d", q.InFlight())
+	}
+}
+
+// TestE2ETenantWeightedShedding tests a saturated balancer keeps admitting a
+// high-priority tenant while the low-priority tenant is shed with 429
+func TestE2ETenantWeightedShedding(t *testing.T) {
+	unblock := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	queue := NewAdmissionQueue(1, 2)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetAdmissionQueue(queue, 0)
+	balancer.SetTenantWeights(&TenantWeights{
+		Header:  "X-Tenant",
+		Weights: map[string]int{"gold": 10, "free": 1},
+		Default: 1,
+	})
+
+	var mu sync.Mutex
+	codes := map[string][]int{}
+	var wg sync.WaitGroup
+	serve := func(tenant string) {
+		defer wg.Done()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+		mu.Lock()
+		codes[tenant] = append(codes[tenant], w.Code)
+		mu.Unlock()
+	}
+
+	// Saturate the slot and fill the queue with free-tier requests
+	wg.Add(1)
+	go serve("free")
+	for queue.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go serve("free")
+		waitForQueueLen(t, queue, i+1)
+	}
+
+	// Gold requests push the queued free requests out; a late free request is shed at once
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go serve("gold")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		shed := len(codes["free"])
+		mu.Unlock()
+		if shed == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the free requests to be shed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	wg.Add(1)
+	serve("free")
+
+	close(unblock)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := codes["gold"]; len(got) != 2 || got[0] != http.StatusOK || got[1] != http.StatusOK {
+		t.Errorf("Expected both gold requests admitted, got %v", got)
+	}
+	shed := 0
+	for _, code := range codes["free"] {
+		if code == http.StatusTooManyRequests {
+			shed++
+		}
+	}
+	if len(codes["free"]) != 4 || shed != 3 {
+		t.Errorf("Expected 3 of 4 free requests shed with 429, got %v", codes["free"])
+	}
+}
@@ -3,14 +3,16 @@ package balancer
 import (
 	"bytes"
 	"context"
-	"fmt"
-	"io"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/cache"
 	"github.com/Nash0810/gobalance/internal/health"
 	"github.com/Nash0810/gobalance/internal/logging"
 	"github.com/Nash0810/gobalance/internal/metrics"
@@ -20,17 +22,45 @@ import (
 
 // Balancer handles request routing
 type Balancer struct {
-	pool            *backend.Pool
-	strategy        Strategy
-	passiveTracker  *health.PassiveTracker
-	retryPolicy     *retry.Policy
-	requestTimeout  time.Duration                     // Per-request timeout (FIX #8)
-	circuitBreakers map[string]*health.CircuitBreaker // Per-backend circuit breakers
-	cbMux           sync.RWMutex                      // Protects circuit breakers map
-	collector       *metrics.Collector                // Prometheus metrics
-	logger          *logging.Logger                   // Structured logger
+	pool              *backend.Pool
+	strategy          Strategy
+	passiveTracker    *health.PassiveTracker
+	retryPolicy       *retry.Policy
+	requestTimeout    time.Duration                     // Per-request timeout (FIX #8)
+	circuitBreakers   map[string]*health.CircuitBreaker // Per-backend circuit breakers
+	cbMux             sync.RWMutex                      // Protects circuit breakers map and cbTimeoutWeight
+	cbTimeoutWeight   int                               // Failures a timeout counts as on circuit breakers (0 = 1)
+	collector         *metrics.Collector                // Prometheus metrics
+	logger            *logging.Logger                   // Structured logger
+	cache             *cache.Cache                      // Optional response cache for GETs
+	isFailure         FailurePredicate                  // Decides which statuses count against backend health
+	admission         *AdmissionQueue                   // Optional in-flight limit with (weighted) FIFO queueing
+	admissionWait     time.Duration                     // Longest a request may wait for admission (0 = request timeout)
+	tenants           *TenantWeights                    // Optional per-tenant admission weights
+	priorities        *RequestPriorities                // Optional priority header and per-priority budgets
+	vhosts            *vhostTable                       // Optional Host header → backend group routing
+	sniRoutes         *vhostTable                       // Optional TLS server name → backend group routing, checked before vhosts
+	defaultRoute      *VirtualHost                      // Optional group for requests matching no route (nil = own pool)
+	rejectUnmatched   bool                              // Answer requests matching no route with 404 instead of using the own pool
+	sticky            *StickySessions                   // Optional cookie-based session affinity
+	inFlight          int64                             // Requests inside ServeHTTP, across all backends (atomic)
+	maxMemoryBody     int64                             // Retry-buffered bodies above this size spill to disk (0 = no limit)
+	bodyErrorStatus   int                               // Status for unreadable request bodies (0 = 400)
+	noBackendLog      *logging.RateLimiter              // Keeps outages from flooding the log
+	failOpenWait      time.Duration                     // Longest a request waits for a backend to recover (0 = fail at once)
+	failOpenHeld      *AdmissionQueue                   // Caps requests held waiting for a backend (nil = no cap)
+	access            *AccessControl                    // Optional method and path filtering
+	retryToHealthiest bool                              // Retries go to the healthiest untried backend, bypassing the strategy
+	requestBodyHook   RequestBodyHook                   // Optional request body transform, applied per attempt
+	responseBodyHook  ResponseBodyHook                  // Optional response body transform, applied by the backend's proxy
+	clientCertHeader  string                            // Header carrying the verified client certificate subject ("" = not sent)
+	serverTiming      bool                              // Add a Server-Timing header with the backend's response time
+	serverTimingKey   []byte                            // HMAC key for Server-Timing backend ids
 }
 
+// noBackendLogInterval is how often "no healthy backends" is logged during an outage
+const noBackendLogInterval = 10 * time.Second
+
 // NewBalancer creates a new balancer instance
 func NewBalancer(pool *backend.Pool, strategy Strategy, passiveTracker *health.PassiveTracker, retryPolicy *retry.Policy, requestTimeout time.Duration, collector *metrics.Collector, logger *logging.Logger) *Balancer {
 	return &Balancer{
@@ -42,6 +72,149 @@ func NewBalancer(pool *backend.Pool, strategy Strategy, passiveTracker *health.P
 		circuitBreakers: make(map[string]*health.CircuitBreaker),
 		collector:       collector,
 		logger:          logger,
+		isFailure:       DefaultFailurePredicate,
+		noBackendLog:    logging.NewRateLimiter(noBackendLogInterval),
+	}
+}
+
+// SetCache enables response caching for cacheable GET requests
+func (lb *Balancer) SetCache(c *cache.Cache) {
+	lb.cache = c
+}
+
+// SetAdmissionQueue limits in-flight requests; excess requests wait in FIFO order for up to maxWait
+func (lb *Balancer) SetAdmissionQueue(q *AdmissionQueue, maxWait time.Duration) {
+	lb.admission = q
+	lb.admissionWait = maxWait
+}
+
+// SetMaxInMemoryBodyBytes makes request bodies larger than n bytes buffer to a
+// temp file instead of memory when they're kept for retries (0 = always memory)
+func (lb *Balancer) SetMaxInMemoryBodyBytes(n int64) {
+	lb.maxMemoryBody = n
+}
+
+// SetFailurePredicate overrides which response statuses count as backend failures
+func (lb *Balancer) SetFailurePredicate(fn FailurePredicate) {
+	if fn == nil {
+		fn = DefaultFailurePredicate
+	}
+	lb.isFailure = fn
+}
+
+// backendAttempts counts a request's attempts per backend so retries avoid
+// backends that have used up their share
+type backendAttempts struct {
+	counts map[*backend.Backend]int
+	max    int // Attempts allowed per backend
+}
+
+// newBackendAttempts creates an empty tracker allowing maxPerBackend attempts per backend
+func newBackendAttempts(maxPerBackend int) *backendAttempts {
+	return &backendAttempts{counts: make(map[*backend.Backend]int), max: maxPerBackend}
+}
+
+// record counts an attempt against b
+func (ba *backendAttempts) record(b *backend.Backend) {
+	ba.counts[b]++
+}
+
+// skip rules b out for the rest of the request without an attempt, e.g.
+// because it went down after the strategy picked it
+func (ba *backendAttempts) skip(b *backend.Backend) {
+	ba.counts[b] = max(ba.counts[b], ba.max)
+}
+
+// exhausted reports whether b may not be attempted again
+func (ba *backendAttempts) exhausted(b *backend.Backend) bool {
+	return ba.counts[b] >= ba.max
+}
+
+// selectUntried asks the strategy for a backend this request may still try.
+// If the strategy keeps returning exhausted backends, any other healthy one is used.
+func selectUntried(pool *backend.Pool, strategy Strategy, attempts *backendAttempts) *backend.Backend {
+	if len(attempts.counts) == 0 {
+		return strategy.SelectBackend(pool)
+	}
+
+	healthy := pool.GetRoutableBackends()
+	for range healthy {
+		b := strategy.SelectBackend(pool)
+		if b == nil {
+			return nil
+		}
+		if !attempts.exhausted(b) {
+			return b
+		}
+	}
+	for _, b := range healthy {
+		if !attempts.exhausted(b) {
+			return b
+		}
+	}
+	return nil
+}
+
+// skipUnroutable returns b, or another backend if b has gone down since the
+// strategy listed it, e.g. ejected by a health check. Nothing was sent to b,
+// so this doesn't use up an attempt; b is just ruled out for the request.
+func (lb *Balancer) skipUnroutable(b *backend.Backend, pool *backend.Pool, strategy Strategy, attempts *backendAttempts, requestID string) *backend.Backend {
+	for b != nil && !b.IsRoutable() {
+		lb.logger.Warn("selected_backend_unavailable",
+			"request_id", requestID,
+			"backend", b.URL.Host)
+		attempts.skip(b)
+		b = selectUntried(pool, strategy, attempts)
+	}
+	return b
+}
+
+// hasUntried reports whether a healthy backend remains that this request may still try
+func hasUntried(pool *backend.Pool, attempts *backendAttempts) bool {
+	for _, b := range pool.GetRoutableBackends() {
+		if !attempts.exhausted(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordNoBackend counts a request that found no healthy backend, logging at
+// most once per interval with the number of occurrences suppressed since
+func (lb *Balancer) recordNoBackend(requestID string) {
+	if lb.collector != nil {
+		lb.collector.NoBackendTotal.Inc()
+	}
+	if ok, suppressed := lb.noBackendLog.Allow(); ok {
+		lb.logger.Error("no_healthy_backends_available",
+			"request_id", requestID,
+			"suppressed", suppressed)
+	}
+}
+
+// recordClientCanceled counts a request abandoned by the client (backend "" = before an attempt)
+func (lb *Balancer) recordClientCanceled(requestID, backendHost string) {
+	if lb.collector != nil {
+		lb.collector.ClientCanceledTotal.Inc()
+	}
+	lb.logger.Warn("client_canceled_request",
+		"request_id", requestID,
+		"backend", backendHost)
+}
+
+// CircuitBreaker returns the circuit breaker guarding a backend, creating it if needed
+func (lb *Balancer) CircuitBreaker(b *backend.Backend) *health.CircuitBreaker {
+	return lb.getCircuitBreaker(b)
+}
+
+// SetCircuitBreakerTimeoutWeight makes timed-out requests count as n failures
+// toward opening a backend's circuit, for existing and future breakers
+func (lb *Balancer) SetCircuitBreakerTimeoutWeight(n int) {
+	lb.cbMux.Lock()
+	defer lb.cbMux.Unlock()
+	lb.cbTimeoutWeight = n
+	for _, cb := range lb.circuitBreakers {
+		cb.SetTimeoutWeight(n)
 	}
 }
 
@@ -67,59 +240,242 @@ func (lb *Balancer) getCircuitBreaker(backend *backend.Backend) *health.CircuitB
 
 	// Create new circuit breaker
 	cb = health.NewCircuitBreaker(key)
+	if lb.cbTimeoutWeight > 0 {
+		cb.SetTimeoutWeight(lb.cbTimeoutWeight)
+	}
+	if lb.collector != nil {
+		cb.SetStateChangeHandler(lb.recordCircuitTransition)
+	}
 	lb.circuitBreakers[key] = cb
 	return cb
 }
 
+// recordCircuitTransition updates circuit breaker metrics on state changes
+func (lb *Balancer) recordCircuitTransition(name string, from, to health.CircuitState) {
+	if to == health.StateOpen {
+		lb.collector.CircuitBreakerTrips.WithLabelValues(name).Inc()
+	}
+
+	// Gauge encoding: 0=CLOSED, 1=HALF_OPEN, 2=OPEN
+	var value float64
+	switch to {
+	case health.StateHalfOpen:
+		value = 1
+	case health.StateOpen:
+		value = 2
+	}
+	lb.collector.CircuitBreakerState.WithLabelValues(name).Set(value)
+}
+
+// InFlight returns how many requests are currently being served, including
+// those queued for admission, retrying, or waiting on backend selection
+func (lb *Balancer) InFlight() int64 {
+	return atomic.LoadInt64(&lb.inFlight)
+}
+
+// trackInFlight counts a request entering ServeHTTP; the returned func marks it done
+func (lb *Balancer) trackInFlight() func() {
+	atomic.AddInt64(&lb.inFlight, 1)
+	if lb.collector != nil {
+		lb.collector.InFlightRequests.Inc()
+	}
+	return func() {
+		atomic.AddInt64(&lb.inFlight, -1)
+		if lb.collector != nil {
+			lb.collector.InFlightRequests.Dec()
+		}
+	}
+}
+
 // ServeHTTP implements http.Handler interface
 // Incorporates FIX #2 (body buffering), FIX #4 (context propagation), FIX #8 (request timeout)
 func (lb *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer lb.trackInFlight()()
+
 	// Generate request ID
 	requestID := uuid.New().String()
 	r.Header.Set("X-Request-ID", requestID)
 
+	// Reject filtered methods and paths before any other work
+	if lb.access != nil {
+		if status := lb.access.check(r); status != 0 {
+			lb.logger.Warn("request_denied",
+				"request_id", requestID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status)
+			if status == http.StatusMethodNotAllowed && len(lb.access.AllowMethods) > 0 {
+				w.Header().Set("Allow", lb.access.allowHeader())
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
 	// FIX #8: Apply request timeout with context
-	ctx, cancel := context.WithTimeout(r.Context(), lb.requestTimeout)
+	priority, budget := lb.requestPriority(r)
+	requestTimeout := lb.requestTimeout
+	if budget.Timeout > 0 {
+		requestTimeout = budget.Timeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
 	defer cancel()
+	if lb.responseBodyHook != nil {
+		ctx = backend.WithResponseBodyHook(ctx, lb.responseBodyHook)
+	}
 	r = r.WithContext(ctx)
 
 	startTime := time.Now()
 
+	// Serve cacheable GETs from the response cache
+	cacheKey := ""
+	if lb.cache != nil && cache.RequestAllowsCache(r) {
+		cacheKey = cache.Key(r)
+		if entry, ok := lb.cache.Get(cacheKey); ok {
+			lb.logger.Info("cache_hit",
+				"request_id", requestID,
+				"path", r.URL.Path)
+			if lb.collector != nil {
+				lb.collector.CacheHitsTotal.Inc()
+			}
+			serveCached(w, entry)
+			return
+		}
+	}
+
+	// Load shedding: wait for an in-flight slot by priority, then arrival order
+	if lb.admission != nil {
+		if !lb.admit(w, r, requestID, priority) {
+			return
+		}
+		defer lb.admission.Release()
+	}
+
+	// Expect: 100-continue uploads stream straight through so the backend can
+	// accept or reject them before the client sends the body. Buffering would
+	// trigger the 100 Continue early, so these requests are never retried.
+	// Chunked uploads of unknown length are only retried when they can spill
+	// to disk; otherwise buffering them could hold an unbounded body in memory.
+	retriesAllowed := lb.retryPolicy != nil && !expectsContinue(r) &&
+		!(unknownLength(r) && lb.maxMemoryBody <= 0)
+
 	// FIX #2: Buffer request body for potential retries
-	var bodyBytes []byte
-	var err error
-	if lb.retryPolicy != nil && r.Body != nil {
-		bodyBytes, err = io.ReadAll(r.Body)
+	var body *bufferedBody
+	if retriesAllowed && r.Body != nil && r.Body != http.NoBody {
+		var err error
+		body, err = bufferRequestBody(r.Body, lb.maxMemoryBody)
 		if err != nil {
-			lb.logger.Error("failed_to_buffer_body",
-				"request_id", requestID,
-				"error", err.Error())
-			http.Error(w, "Bad Request", http.StatusBadRequest)
+			lb.bodyReadFailed(w, r, requestID, err)
 			return
 		}
 		r.Body.Close()
-		// Restore body for first attempt
-		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		defer body.Close() // Removes the temp file of a spilled body
+		if body.spilled() {
+			lb.logger.Info("request_body_spilled_to_disk", "request_id", requestID)
+		}
 	}
 
+	// Record how many attempts the request took once it's done
+	attempts := 0
+	defer func() {
+		if lb.collector != nil && attempts > 0 {
+			lb.collector.RequestAttempts.Observe(float64(attempts))
+		}
+	}()
+
 	maxAttempts := 1
-	if lb.retryPolicy != nil {
+	if retriesAllowed {
 		lb.retryPolicy.GetBudget().TrackRequest() // Track for adaptive budget
-		maxAttempts = 3 // Allow up to 3 total attempts (original + 2 retries)
+		maxAttempts = 3                           // Allow up to 3 total attempts (original + 2 retries)
+		if budget.MaxAttempts > 0 {
+			maxAttempts = budget.MaxAttempts
+		}
+	}
+
+	// Pick the backend group for this host before strategy selection
+	pool, strategy := lb.route(r)
+	if pool == nil {
+		lb.logger.Warn("no_route", "request_id", requestID, "host", r.Host)
+		http.Error(w, "No Route For Host", http.StatusNotFound)
+		return
 	}
 
+	setForwardedFor(r)
+	lb.setClientCert(r)
+
+	// Attempts per backend for this request; retries go elsewhere once a backend's share is used
+	maxPerBackend := 1
+	if retriesAllowed {
+		maxPerBackend = lb.retryPolicy.MaxPerBackend()
+	}
+	tried := newBackendAttempts(maxPerBackend)
+
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		attempts = attempt
+
 		// FIX #4: Check if client canceled request
-		if r.Context().Err() != nil {
-			lb.logger.Warn("client_canceled_request", "request_id", requestID)
+		if err := r.Context().Err(); err != nil {
+			if errors.Is(err, context.Canceled) {
+				lb.recordClientCanceled(requestID, "")
+			} else {
+				lb.logger.Warn("client_canceled_request", "request_id", requestID)
+			}
 			http.Error(w, "Request Canceled", 499)
 			return
 		}
 
-		backend := lb.strategy.SelectBackend(lb.pool)
+		// Sticky sessions: prefer the backend pinned by the client's cookie
+		var backend *backend.Backend
+		if lb.sticky != nil && attempt == 1 {
+			pinned, valid := lb.sticky.lookup(r, pool)
+			if pinned != nil && pinned.IsRoutable() {
+				backend = pinned
+			} else if valid && lb.sticky.OnBackendDown == StickyFail {
+				lb.logger.Warn("sticky_backend_unavailable", "request_id", requestID)
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		repin := lb.sticky != nil && backend == nil
+
+		// Passive recovery: now and then a first attempt goes to a backend on
+		// probation, whose success counts toward restoring it
+		trial := false
+		if backend == nil && attempt == 1 {
+			if backend = lb.trialBackend(pool); backend != nil {
+				trial = true
+				defer lb.passiveTracker.EndTrial(backend)
+				lb.logger.Info("passive_recovery_trial",
+					"request_id", requestID,
+					"backend", backend.URL.Host)
+			}
+		}
+
+		if backend == nil && attempt > 1 && lb.retryToHealthiest {
+			backend = lb.selectHealthiest(pool, tried)
+		}
+		if backend == nil {
+			backend = selectUntried(pool, strategy, tried)
+		}
+
+		if !trial {
+			backend = lb.skipUnroutable(backend, pool, strategy, tried, requestID)
+		}
+
+		// Fail open: during a total outage (e.g. a rolling restart) hold the
+		// request briefly for a backend to come back instead of failing at once.
+		// Backends skipped above were never attempted, so any that recovered
+		// may be picked again.
+		if backend == nil && lb.failOpenWait > 0 && attempt == 1 &&
+			lb.waitForBackend(r.Context(), pool, requestID) {
+			backend = lb.skipUnroutable(strategy.SelectBackend(pool), pool, strategy, tried, requestID)
+		}
 
 		if backend == nil {
-			lb.logger.Error("no_healthy_backends_available", "request_id", requestID)
+			lb.recordNoBackend(requestID)
+			if lb.serveStale(w, cacheKey, requestID) {
+				return
+			}
 			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 			return
 		}
@@ -127,6 +483,10 @@ func (lb *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// Get circuit breaker for this backend
 		cb := lb.getCircuitBreaker(backend)
 		backendHost := backend.URL.Host
+		tried.record(backend)
+		if lb.collector != nil {
+			lb.collector.BackendSelections.WithLabelValues(backendHost).Inc()
+		}
 
 		// Check circuit breaker
 		if !cb.AllowRequest() {
@@ -139,9 +499,12 @@ func (lb *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				lb.collector.RetriesTotal.WithLabelValues("circuit_open").Inc()
 			}
 
-			if attempt < maxAttempts {
+			if attempt < maxAttempts && hasUntried(pool, tried) {
 				continue // Try different backend
 			}
+			if lb.serveStale(w, cacheKey, requestID) {
+				return
+			}
 			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
 			return
 		}
@@ -150,64 +513,123 @@ func (lb *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			"request_id", requestID,
 			"backend", backendHost,
 			"attempt", attempt,
+			"client_ip", clientIP(r),
 			"method", r.Method,
 			"path", r.URL.Path)
 
+		// FIX #2: Restore body for each attempt
+		if body != nil {
+			if err := body.restoreRequestBody(r); err != nil {
+				lb.logger.Error("failed_to_restore_body",
+					"request_id", requestID,
+					"error", err.Error())
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := lb.applyRequestBodyHook(r); err != nil {
+			lb.logger.Error("request_body_hook_failed",
+				"request_id", requestID,
+				"error", err.Error())
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
 		backend.IncrementActiveRequests()
 		if lb.collector != nil {
 			lb.collector.ActiveRequests.WithLabelValues(backendHost).Inc()
 		}
 
-		// Create a custom response writer to capture errors
-		crw := &captureResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		// A backend may opt out of retries (e.g. payments) or into retrying any method
+		retryOverride := backend.Retryable()
+		canRetry := retriesAllowed && (retryOverride == nil || *retryOverride)
 
-		// FIX #2: Restore body for retry attempts
-		if bodyBytes != nil && attempt > 1 {
-			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		// Create a custom response writer to capture errors
+		crw := newCaptureResponseWriter(w)
+		if canRetry && attempt < maxAttempts {
+			// Hold back failures so a retry can still produce a clean response
+			crw.holdFailure = lb.isFailure
+		}
+		if repin {
+			// Part of this attempt's headers, so it is dropped if the attempt is retried.
+			// Added rather than set so the backend's own Set-Cookie headers are kept.
+			crw.header.Add("Set-Cookie", lb.sticky.cookie(backend).String())
+		}
+		if cacheKey != "" {
+			crw.body = &bytes.Buffer{}
+			crw.maxBody = lb.cache.MaxEntryBytes()
 		}
 
 		// Forward request
+		attemptStart := time.Now()
+		if lb.serverTiming {
+			crw.timingID = lb.serverTimingID(backend)
+			crw.attemptedAt = attemptStart
+		}
 		backend.ReverseProxy.ServeHTTP(crw, r)
+		attemptDuration := time.Since(attemptStart)
 
 		backend.DecrementActiveRequests()
 		if lb.collector != nil {
 			lb.collector.ActiveRequests.WithLabelValues(backendHost).Dec()
 		}
 
+		// The client hung up mid-flight: not the backend's fault, so it must not
+		// count against the backend's health or circuit breaker
+		if crw.proxyError() != nil && errors.Is(r.Context().Err(), context.Canceled) {
+			lb.recordClientCanceled(requestID, backendHost)
+			return
+		}
+
 		duration := time.Since(startTime).Seconds()
 		statusStr := strconv.Itoa(crw.statusCode)
 
 		// Record metrics
 		if lb.collector != nil {
-			lb.collector.RequestsTotal.WithLabelValues(backendHost, r.Method, statusStr).Inc()
-			lb.collector.RequestDuration.WithLabelValues(backendHost, r.Method).Observe(duration)
+			lb.collector.RecordRequest(backendHost, r.Method, statusStr, duration)
+			if firstByte := crw.firstByteTime(); !firstByte.IsZero() && crw.proxyError() == nil {
+				lb.collector.RecordTTFB(backendHost, r.Method, firstByte.Sub(startTime).Seconds())
+			}
 		}
 
 		// Check if request succeeded
-		if crw.statusCode >= 500 {
-			err := fmt.Errorf("status %d", crw.statusCode)
-			lb.passiveTracker.RecordFailure(backend, err)
-			cb.RecordFailure()
+		if lb.isFailure(crw.statusCode) {
+			proxyErr := crw.proxyError()
+			err := &retry.StatusError{StatusCode: crw.statusCode, Err: proxyErr}
+			lb.passiveTracker.RecordPoolFailure(pool, backend, err)
+			backend.RecordRequestFailure()
+			if timedOut(proxyErr) {
+				cb.RecordTimeout()
+			} else {
+				cb.RecordFailure()
+			}
+			if lb.collector != nil && headerTooLarge(proxyErr) {
+				lb.collector.OversizedHeaders.WithLabelValues(backendHost).Inc()
+			}
 
 			lb.logger.Warn("request_failed",
 				"request_id", requestID,
 				"backend", backendHost,
 				"status", crw.statusCode,
+				"error", err.Error(),
 				"duration_ms", duration*1000)
 
 			// Should retry?
-			if lb.retryPolicy != nil && lb.retryPolicy.ShouldRetry(r, err, attempt) {
+			if canRetry && hasUntried(pool, tried) && lb.shouldRetry(r, err, attempt, budget.MaxAttempts, retryOverride) {
 				if lb.collector != nil {
-					lb.collector.RetriesTotal.WithLabelValues("server_error").Inc()
+					lb.collector.RetriesTotal.WithLabelValues(retryReason(proxyErr)).Inc()
 				}
 				continue
 			}
 
-			return // Don't retry
+			crw.release() // Don't retry: send the held-back failure
+			return
 		}
 
 		// Success
 		lb.passiveTracker.RecordSuccess(backend)
+		backend.RecordRequestSuccess()
+		backend.RecordLatency(attemptDuration)
 		cb.RecordSuccess()
 
 		lb.logger.Info("request_completed",
@@ -216,20 +638,20 @@ func (lb *Balancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			"status", crw.statusCode,
 			"duration_ms", duration*1000)
 
+		if cacheKey != "" {
+			lb.storeResponse(cacheKey, crw)
+		}
+
 		return
 	}
 }
 
-// captureResponseWriter captures the status code (FIX #1: Added mutex for thread-safety)
-type captureResponseWriter struct {
-	http.ResponseWriter
-	statusCode int
-	mu         sync.Mutex
+// expectsContinue returns true if the client is waiting for 100 Continue before sending the body
+func expectsContinue(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Expect"), "100-continue")
 }
 
-func (crw *captureResponseWriter) WriteHeader(code int) {
-	crw.mu.Lock()
-	crw.statusCode = code
-	crw.mu.Unlock()
-	crw.ResponseWriter.WriteHeader(code)
+// unknownLength returns true if the request body has no declared length (chunked transfer-encoding)
+func unknownLength(r *http.Request) bool {
+	return r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody
 }
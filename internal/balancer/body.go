@@ -0,0 +1,118 @@
+package balancer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// SetBodyReadErrorStatus sets the status returned when a request body can't be
+// read for a reason other than the client disconnecting (0 = 400 Bad Request)
+func (lb *Balancer) SetBodyReadErrorStatus(status int) {
+	lb.bodyErrorStatus = status
+}
+
+// bodyReadFailed responds to a request whose body couldn't be buffered. A
+// client that hung up mid-upload is counted as canceled and gets 499, which
+// nobody reads; any other read error gets the configured status.
+func (lb *Balancer) bodyReadFailed(w http.ResponseWriter, r *http.Request, requestID string, err error) {
+	if clientDisconnected(r, err) {
+		lb.recordClientCanceled(requestID, "")
+		http.Error(w, "Request Canceled", 499)
+		return
+	}
+
+	lb.logger.Error("failed_to_buffer_body",
+		"request_id", requestID,
+		"error", err.Error())
+	status := lb.bodyErrorStatus
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// clientDisconnected reports whether a body read failed because the client went away
+func clientDisconnected(r *http.Request, err error) bool {
+	return errors.Is(r.Context().Err(), context.Canceled) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || // Connection closed before the declared length arrived
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, net.ErrClosed)
+}
+
+// bufferedBody holds a request body so it can be replayed on retries. Bodies
+// larger than the in-memory limit spill to a temp file.
+type bufferedBody struct {
+	data []byte // Body held in memory (nil when spilled)
+	path string // Temp file holding the body ("" when in memory)
+}
+
+// bufferRequestBody reads body fully, spilling to a temp file once it exceeds
+// maxInMemory bytes (0 = always keep in memory)
+func bufferRequestBody(body io.Reader, maxInMemory int64) (*bufferedBody, error) {
+	if maxInMemory <= 0 {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return &bufferedBody{data: data}, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxInMemory+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) <= maxInMemory {
+		return &bufferedBody{data: data}, nil
+	}
+
+	// Too large for memory: write what we have plus the rest to disk
+	f, err := os.CreateTemp("", "gobalance-body-*")
+	if err != nil {
+		return nil, err
+	}
+	bb := &bufferedBody{path: f.Name()}
+	if _, err := io.Copy(f, io.MultiReader(bytes.NewReader(data), body)); err != nil {
+		f.Close()
+		bb.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		bb.Close()
+		return nil, err
+	}
+	return bb, nil
+}
+
+// restoreRequestBody gives r a fresh copy of the body for the next attempt,
+// re-opening the temp file if the body was spilled to disk
+func (bb *bufferedBody) restoreRequestBody(r *http.Request) error {
+	if bb.path == "" {
+		r.Body = io.NopCloser(bytes.NewReader(bb.data))
+		return nil
+	}
+	f, err := os.Open(bb.path)
+	if err != nil {
+		return err
+	}
+	r.Body = f // Closed by the transport once sent
+	return nil
+}
+
+// spilled reports whether the body was written to a temp file
+func (bb *bufferedBody) spilled() bool {
+	return bb.path != ""
+}
+
+// Close removes the temp file, if any
+func (bb *bufferedBody) Close() error {
+	if bb.path == "" {
+		return nil
+	}
+	return os.Remove(bb.path)
+}
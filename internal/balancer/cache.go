@@ -0,0 +1,62 @@
+package balancer
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/cache"
+)
+
+// serveCached writes a cached response to the client
+func serveCached(w http.ResponseWriter, entry *cache.Entry) {
+	copyHeader(w.Header(), entry.Header)
+	age := int(time.Since(entry.StoredAt).Seconds())
+	w.Header().Set("Age", strconv.Itoa(age))
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
+}
+
+// serveStale serves a cached (possibly expired) response when no backend can take the
+// request. Returns false if there is nothing suitable to serve.
+func (lb *Balancer) serveStale(w http.ResponseWriter, key string, requestID string) bool {
+	if key == "" {
+		return false
+	}
+	entry, ok := lb.cache.GetStale(key)
+	if !ok {
+		return false
+	}
+
+	lb.logger.Warn("serving_stale_response",
+		"request_id", requestID,
+		"key", key)
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	serveCached(w, entry)
+	return true
+}
+
+// storeResponse caches a completed response if the backend marked it cacheable
+func (lb *Balancer) storeResponse(key string, crw *captureResponseWriter) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+
+	if crw.body == nil {
+		return // Body exceeded the cache entry limit
+	}
+
+	header := crw.header.Clone()
+	ttl, ok := cache.Freshness(crw.statusCode, header)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	lb.cache.Set(key, &cache.Entry{
+		StatusCode: crw.statusCode,
+		Header:     header,
+		Body:       crw.body.Bytes(),
+		StoredAt:   now,
+		ExpiresAt:  now.Add(ttl),
+	})
+}
@@ -0,0 +1,235 @@
+package balancer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxHeldFailureBytes caps how much of a held-back failure response is buffered
+// before it is committed to the client (and can no longer be retried)
+const maxHeldFailureBytes = 64 << 10
+
+// captureResponseWriter captures the status code (FIX #1: Added mutex for thread-safety)
+// All state is guarded by mu and only the first WriteHeader/Write decides the response:
+// later WriteHeader calls are ignored so exactly one status line reaches the client.
+// Each attempt gets its own header map, copied to the client only when committed.
+type captureResponseWriter struct {
+	http.ResponseWriter
+	header       http.Header // Headers for this attempt, copied to the client on commit
+	statusCode   int
+	wroteHeader  bool // WriteHeader (or an implicit 200) has been called
+	committed    bool // Status line has been sent to the client
+	bytesWritten int64
+	firstByteAt  time.Time // When the response status was decided (zero = no response yet)
+	proxyErr     error     // Transport error reported by the reverse proxy (nil = backend responded)
+	mu           sync.Mutex
+
+	holdFailure func(statusCode int) bool // Hold back responses this returns true for (retryable failures)
+	held        *bytes.Buffer             // Body of a held-back response (nil = not holding)
+
+	body    *bytes.Buffer // Optional copy of the body for caching (nil = not capturing)
+	maxBody int           // Stop capturing once the body exceeds this size (0 = unlimited)
+
+	timingID    string    // Backend id for a Server-Timing header ("" = no header)
+	attemptedAt time.Time // When the attempt was sent, the start of the Server-Timing duration
+}
+
+// newCaptureResponseWriter wraps w for a single proxy attempt
+func newCaptureResponseWriter(w http.ResponseWriter) *captureResponseWriter {
+	return &captureResponseWriter{
+		ResponseWriter: w,
+		header:         make(http.Header),
+		statusCode:     http.StatusOK,
+	}
+}
+
+// Header returns this attempt's header map. Once committed it returns the
+// client's header map so trailers set after the body are propagated.
+func (crw *captureResponseWriter) Header() http.Header {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	if crw.committed {
+		return crw.ResponseWriter.Header()
+	}
+	return crw.header
+}
+
+// Flush sends buffered data to the client so streaming responses aren't held
+// until completion. Held-back failures are not flushed.
+func (crw *captureResponseWriter) Flush() {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	if !crw.committed {
+		return
+	}
+	if flusher, ok := crw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the client connection for protocol upgrades (e.g. WebSockets).
+// Returns an error if the underlying writer doesn't support hijacking.
+func (crw *captureResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+
+	hijacker, ok := crw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack not supported by %T: %w", crw.ResponseWriter, http.ErrNotSupported)
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The upgrade response is written straight to the connection
+	crw.wroteHeader = true
+	crw.committed = true
+	crw.statusCode = http.StatusSwitchingProtocols
+	crw.firstByteAt = time.Now()
+	return conn, brw, nil
+}
+
+// WriteHeader records the status; only the first call takes effect
+func (crw *captureResponseWriter) WriteHeader(code int) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.writeHeaderLocked(code)
+}
+
+// writeHeaderLocked implements WriteHeader (caller holds lock)
+func (crw *captureResponseWriter) writeHeaderLocked(code int) {
+	// Informational responses (e.g. 103 Early Hints) pass through without deciding the status
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		if !crw.wroteHeader {
+			// Send only the informational headers, leaving the client's header map as it was
+			h := crw.ResponseWriter.Header()
+			saved := h.Clone()
+			copyHeader(h, crw.header)
+			crw.ResponseWriter.WriteHeader(code)
+			clear(h)
+			copyHeader(h, saved)
+		}
+		return
+	}
+
+	if crw.wroteHeader {
+		return // First status wins
+	}
+	crw.wroteHeader = true
+	crw.statusCode = code
+	crw.firstByteAt = time.Now()
+	if crw.timingID != "" && crw.proxyErr == nil {
+		crw.header.Add("Server-Timing", serverTimingValue(crw.timingID, crw.firstByteAt.Sub(crw.attemptedAt)))
+	}
+
+	if crw.holdFailure != nil && crw.holdFailure(code) {
+		crw.held = &bytes.Buffer{}
+		return
+	}
+	crw.commitLocked()
+}
+
+// commitLocked sends the headers and status to the client (caller holds lock)
+func (crw *captureResponseWriter) commitLocked() {
+	if crw.committed {
+		return
+	}
+	crw.committed = true
+	copyHeader(crw.ResponseWriter.Header(), crw.header)
+	crw.ResponseWriter.WriteHeader(crw.statusCode)
+}
+
+// Write writes the body, holding it back if this is a retryable failure
+func (crw *captureResponseWriter) Write(b []byte) (int, error) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+
+	if !crw.wroteHeader {
+		crw.writeHeaderLocked(http.StatusOK)
+	}
+
+	if crw.held != nil {
+		if crw.held.Len()+len(b) <= maxHeldFailureBytes {
+			return crw.held.Write(b)
+		}
+		// Too large to hold: commit what we have and stream the rest
+		if err := crw.releaseLocked(); err != nil {
+			return 0, err
+		}
+	}
+
+	if crw.body != nil {
+		if crw.maxBody > 0 && crw.body.Len()+len(b) > crw.maxBody {
+			crw.body = nil // Too large to cache
+		} else {
+			crw.body.Write(b)
+		}
+	}
+
+	n, err := crw.ResponseWriter.Write(b)
+	crw.bytesWritten += int64(n)
+	return n, err
+}
+
+// release sends a held-back response to the client (when it won't be retried)
+func (crw *captureResponseWriter) release() error {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	if crw.held == nil {
+		return nil
+	}
+	return crw.releaseLocked()
+}
+
+// releaseLocked commits a held-back response (caller holds lock)
+func (crw *captureResponseWriter) releaseLocked() error {
+	held := crw.held
+	crw.held = nil
+	crw.commitLocked()
+	n, err := crw.ResponseWriter.Write(held.Bytes())
+	crw.bytesWritten += int64(n)
+	return err
+}
+
+// RecordProxyError records why the reverse proxy couldn't get a response from the backend
+func (crw *captureResponseWriter) RecordProxyError(err error) {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	crw.proxyErr = err
+}
+
+// proxyError returns the recorded transport error, if any
+func (crw *captureResponseWriter) proxyError() error {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	return crw.proxyErr
+}
+
+// firstByteTime returns when the backend's response started (zero if it never did)
+func (crw *captureResponseWriter) firstByteTime() time.Time {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	return crw.firstByteAt
+}
+
+// status returns the recorded status code
+func (crw *captureResponseWriter) status() int {
+	crw.mu.Lock()
+	defer crw.mu.Unlock()
+	return crw.statusCode
+}
+
+// copyHeader adds all values from src to dst
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		for _, v := range values {
+			dst.Add(key, v)
+		}
+	}
+}
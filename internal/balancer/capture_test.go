@@ -0,0 +1,124 @@
+package balancer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingResponseWriter counts WriteHeader calls reaching the client
+type countingResponseWriter struct {
+	*httptest.ResponseRecorder
+	writeHeaderCalls atomic.Int32
+}
+
+func (w *countingResponseWriter) WriteHeader(code int) {
+	w.writeHeaderCalls.Add(1)
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+// TestCaptureResponseWriterConcurrentWrites tests concurrent writers emit exactly one response (run with -race)
+func TestCaptureResponseWriterConcurrentWrites(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		client := &countingResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+		crw := newCaptureResponseWriter(client)
+
+		var wg sync.WaitGroup
+		for _, code := range []int{http.StatusOK, http.StatusAccepted} {
+			wg.Add(1)
+			go func(code int) {
+				defer wg.Done()
+				crw.WriteHeader(code)
+				crw.Write([]byte("x"))
+			}(code)
+		}
+		wg.Wait()
+
+		if calls := client.writeHeaderCalls.Load(); calls != 1 {
+			t.Fatalf("Expected exactly one status line, got %d", calls)
+		}
+		if client.Code != crw.status() {
+			t.Fatalf("Recorded status %d doesn't match emitted %d", crw.status(), client.Code)
+		}
+		if crw.bytesWritten != int64(client.Body.Len()) {
+			t.Fatalf("Byte count %d doesn't match body length %d", crw.bytesWritten, client.Body.Len())
+		}
+	}
+}
+
+// TestCaptureResponseWriterHoldsFailures tests held-back failures don't reach the client until released
+func TestCaptureResponseWriterHoldsFailures(t *testing.T) {
+	client := httptest.NewRecorder()
+
+	// A held failure that gets retried leaves the client untouched
+	discarded := newCaptureResponseWriter(client)
+	discarded.holdFailure = DefaultFailurePredicate
+	discarded.Header().Set("X-Attempt", "1")
+	discarded.WriteHeader(http.StatusBadGateway)
+	discarded.Write([]byte("bad gateway"))
+
+	if client.Body.Len() != 0 || client.Header().Get("X-Attempt") != "" {
+		t.Fatal("Held failure leaked to the client")
+	}
+
+	// The next attempt's response goes out cleanly
+	success := newCaptureResponseWriter(client)
+	success.holdFailure = DefaultFailurePredicate
+	success.Header().Set("X-Attempt", "2")
+	success.WriteHeader(http.StatusOK)
+	success.Write([]byte("ok"))
+
+	if client.Code != http.StatusOK || client.Body.String() != "ok" {
+		t.Errorf("Expected clean 200 'ok', got %d %q", client.Code, client.Body.String())
+	}
+	if values := client.Header().Values("X-Attempt"); len(values) != 1 || values[0] != "2" {
+		t.Errorf("Expected only the second attempt's headers, got %v", values)
+	}
+
+	// Releasing a held failure sends it to the client
+	client = httptest.NewRecorder()
+	final := newCaptureResponseWriter(client)
+	final.holdFailure = DefaultFailurePredicate
+	final.WriteHeader(http.StatusServiceUnavailable)
+	final.Write([]byte("unavailable"))
+	final.release()
+
+	if client.Code != http.StatusServiceUnavailable || client.Body.String() != "unavailable" {
+		t.Errorf("Expected released 503, got %d %q", client.Code, client.Body.String())
+	}
+}
+
+// TestCaptureResponseWriterFlush tests the wrapper is a Flusher and flushes reach the client
+func TestCaptureResponseWriterFlush(t *testing.T) {
+	client := httptest.NewRecorder()
+	crw := newCaptureResponseWriter(client)
+
+	var w http.ResponseWriter = crw
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("captureResponseWriter should implement http.Flusher")
+	}
+
+	crw.Write([]byte("chunk"))
+	flusher.Flush()
+	if !client.Flushed {
+		t.Error("Flush did not propagate to the underlying writer")
+	}
+}
+
+// TestCaptureResponseWriterHijackUnsupported tests Hijack errors when the client writer can't hijack
+func TestCaptureResponseWriterHijackUnsupported(t *testing.T) {
+	crw := newCaptureResponseWriter(httptest.NewRecorder())
+
+	var w http.ResponseWriter = crw
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		t.Fatal("captureResponseWriter should implement http.Hijacker")
+	}
+	if _, _, err := hijacker.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package balancer
+
+import "net/http"
+
+// SetClientCertHeader passes the subject of the client's verified TLS
+// certificate to backends in the named header ("" = not sent). Any value the
+// client sent itself is removed, so backends can trust the header.
+func (lb *Balancer) SetClientCertHeader(name string) {
+	lb.clientCertHeader = http.CanonicalHeaderKey(name)
+}
+
+// setClientCert replaces the client certificate header with the verified
+// subject, or drops it when the connection carries no verified certificate
+func (lb *Balancer) setClientCert(r *http.Request) {
+	if lb.clientCertHeader == "" {
+		return
+	}
+	r.Header.Del(lb.clientCertHeader)
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return
+	}
+	r.Header.Set(lb.clientCertHeader, r.TLS.VerifiedChains[0][0].Subject.String())
+}
@@ -0,0 +1,46 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientIP returns the connecting peer's IP from r.RemoteAddr. Handles IPv4 and
+// IPv6 with or without a port ("10.0.0.1:1234", "[::1]:1234", "[::1]", "::1").
+// Returns "" if RemoteAddr holds no IP.
+func clientIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+
+	// Zones ("fe80::1%eth0") are kept, but the address itself must parse
+	addr, _, _ := strings.Cut(host, "%")
+	if net.ParseIP(addr) == nil {
+		return ""
+	}
+	return host
+}
+
+// setForwardedFor appends the client IP to X-Forwarded-For when the reverse
+// proxy can't: it only does so for RemoteAddrs in host:port form
+func setForwardedFor(r *http.Request) {
+	if _, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return // ReverseProxy appends it
+	}
+	if prior, exists := r.Header["X-Forwarded-For"]; exists && prior == nil {
+		return // Forwarding explicitly disabled
+	}
+	ip := clientIP(r)
+	if ip == "" {
+		return
+	}
+
+	if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+		ip = strings.Join(prior, ", ") + ", " + ip
+	}
+	r.Header.Set("X-Forwarded-For", ip)
+}
@@ -0,0 +1,83 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// TestClientIP tests the client IP is extracted from IPv4 and IPv6 remote addresses
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"192.0.2.10:1234", "192.0.2.10"},
+		{"192.0.2.10", "192.0.2.10"},
+		{"[::1]:1234", "::1"},
+		{"[2001:db8::1]:443", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"[fe80::1%eth0]:1234", "fe80::1%eth0"},
+		{"", ""},
+		{"not-an-ip:1234", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("clientIP(%q) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+// TestForwardedForChain tests the backend sees the client IP appended to any
+// existing X-Forwarded-For chain, for IPv4 and IPv6 clients
+func TestForwardedForChain(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Forwarded-For")
+	}))
+	defer server.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(server.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		prior      string
+		want       string
+	}{
+		{"ipv4", "192.0.2.10:1234", "", "192.0.2.10"},
+		{"ipv6", "[::1]:1234", "", "::1"},
+		{"ipv6 chained", "[2001:db8::1]:1234", "203.0.113.7, 10.0.0.1", "203.0.113.7, 10.0.0.1, 2001:db8::1"},
+		{"ipv6 without port", "[2001:db8::1]", "203.0.113.7", "203.0.113.7, 2001:db8::1"},
+		{"ipv4 without port", "192.0.2.10", "", "192.0.2.10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.prior != "" {
+				r.Header.Set("X-Forwarded-For", tt.prior)
+			}
+			w := httptest.NewRecorder()
+			balancer.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", w.Code)
+			}
+			if got := <-received; got != tt.want {
+				t.Errorf("X-Forwarded-For = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package balancer
+
+import (
+	"sync"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// CompositeScoreCoefficients weight each signal in a backend's composite score
+type CompositeScoreCoefficients struct {
+	Connections float64 // Per in-flight request, divided by the backend's weight
+	ErrorRate   float64 // Per unit of recent error rate (0-1)
+	LatencyMs   float64 // Per millisecond of moving-average latency
+}
+
+// DefaultCompositeScoreCoefficients make a backend at 100% errors or 100ms slower
+// cost about as much as 10 extra in-flight requests
+var DefaultCompositeScoreCoefficients = CompositeScoreCoefficients{
+	Connections: 1,
+	ErrorRate:   10,
+	LatencyMs:   0.1,
+}
+
+// CompositeScoreStrategy routes to the backend with the lowest combined load score
+// for pools of unequal hardware:
+//
+//	score = Connections·active/weight + ErrorRate·errorRate + LatencyMs·ewmaLatencyMs
+//
+// Backends with no latency samples yet score no latency penalty. Ties go to the
+// backend listed first, so selection is deterministic for fixed inputs.
+type CompositeScoreStrategy struct {
+	coefficients CompositeScoreCoefficients
+	mux          sync.RWMutex
+}
+
+// NewCompositeScoreStrategy creates a new composite score strategy
+func NewCompositeScoreStrategy(coefficients CompositeScoreCoefficients) *CompositeScoreStrategy {
+	return &CompositeScoreStrategy{coefficients: coefficients}
+}
+
+// SetCoefficients changes how the signals are weighted
+func (cs *CompositeScoreStrategy) SetCoefficients(coefficients CompositeScoreCoefficients) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+	cs.coefficients = coefficients
+}
+
+// SelectBackend picks the healthy backend with the lowest composite score
+func (cs *CompositeScoreStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	backends := pool.GetRoutableBackends()
+
+	if len(backends) == 0 {
+		return nil
+	}
+
+	cs.mux.RLock()
+	coefficients := cs.coefficients
+	cs.mux.RUnlock()
+
+	var selected *backend.Backend
+	var bestScore float64
+	for _, b := range backends {
+		score := compositeScore(b, coefficients)
+		if selected == nil || score < bestScore {
+			selected = b
+			bestScore = score
+		}
+	}
+	return selected
+}
+
+// compositeScore computes a backend's load score; lower is better
+func compositeScore(b *backend.Backend, c CompositeScoreCoefficients) float64 {
+	weight := b.Weight
+	if weight < 1 {
+		weight = 1
+	}
+	score := c.Connections * float64(b.GetActiveRequests()) / float64(weight)
+	score += c.ErrorRate * b.GetErrorRate(backend.DefaultErrorRateWindow)
+	if latency, ok := b.LatencyEWMA(); ok {
+		score += c.LatencyMs * float64(latency.Microseconds()) / 1000
+	}
+	return score
+}
+
+// Name returns the strategy name
+func (cs *CompositeScoreStrategy) Name() string {
+	return "composite"
+}
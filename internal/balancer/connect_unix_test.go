@@ -0,0 +1,139 @@
+//go:build unix
+
+package balancer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// newUnacceptingBackend returns the URL of a listener that never accepts and
+// whose backlog is already full, so new connections hang in the handshake
+// like a backend behind a black-holed route
+func newUnacceptingBackend(t *testing.T) *url.URL {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", sa.(*syscall.SockaddrInet4).Port)
+
+	// Take the only backlog slot
+	filler, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { filler.Close() })
+	if _, err := net.DialTimeout("tcp", addr, 100*time.Millisecond); err == nil {
+		t.Skip("platform accepted a connection past the full backlog")
+	}
+	return &url.URL{Scheme: "http", Host: addr}
+}
+
+// TestE2EConnectTimeoutFailsOver tests a backend that never completes the
+// handshake fails within the connect timeout and the request fails over
+func TestE2EConnectTimeoutFailsOver(t *testing.T) {
+	connectTimeout := 200 * time.Millisecond
+	transport := backend.NewTransport(backend.TransportConfig{Protocol: backend.ProtocolHTTP1, ConnectTimeout: connectTimeout})
+	defer transport.CloseIdleConnections()
+
+	dead := backend.NewBackend(newUnacceptingBackend(t))
+	dead.SetTransport(transport)
+
+	// Alone, the dead backend fails long before the 10s request timeout
+	pool := backend.NewPool()
+	pool.AddBackend(dead)
+	start := time.Now()
+	w := httptest.NewRecorder()
+	createTestBalancer(pool, NewRoundRobinStrategy()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if elapsed := time.Since(start); elapsed > 5*connectTimeout {
+		t.Errorf("Expected the dead backend to fail within the connect timeout, took %v", elapsed)
+	}
+	if w.Code < 500 {
+		t.Errorf("Expected a 5xx from the dead backend, got %d", w.Code)
+	}
+
+	// Beside a live one, the failed dial is retried there
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer live.Close()
+	u, _ := url.Parse(live.URL)
+	liveBackend := backend.NewBackend(u)
+	liveBackend.SetTransport(transport)
+
+	redialed := backend.NewBackend(dead.URL) // Fresh health state
+	redialed.SetTransport(transport)
+	pool = backend.NewPool()
+	pool.AddBackend(redialed)
+	pool.AddBackend(liveBackend)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "ok" {
+			t.Errorf("Request %d: expected failover to 200 'ok', got %d %q", i, w.Code, w.Body.String())
+		}
+		if elapsed := time.Since(start); elapsed > 5*connectTimeout {
+			t.Errorf("Request %d: failover took %v", i, elapsed)
+		}
+	}
+}
+
+// TestE2EConnectTimeoutSparesSlowResponse tests a response streaming for far
+// longer than the connect timeout is delivered whole
+func TestE2EConnectTimeoutSparesSlowResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(w, "chunk%d\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	b.SetTransport(backend.NewTransport(backend.TransportConfig{Protocol: backend.ProtocolHTTP1, ConnectTimeout: 50 * time.Millisecond}))
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	front := httptest.NewServer(createTestBalancer(pool, NewRoundRobinStrategy()))
+	defer front.Close()
+
+	resp, err := http.Get(front.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Response cut short: %v", err)
+	}
+	if strings.Count(string(body), "chunk") != 5 {
+		t.Errorf("Expected all 5 chunks, got %q", body)
+	}
+}
@@ -0,0 +1,53 @@
+package balancer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// failOpenPollInterval is how often a held request checks for a recovered backend
+const failOpenPollInterval = 25 * time.Millisecond
+
+// SetFailOpen makes requests that find no healthy backend wait up to maxWait for
+// one to recover instead of failing with 503 at once. At most maxHeld requests
+// are held at a time; the rest fail immediately (maxHeld < 1 = no cap).
+func (lb *Balancer) SetFailOpen(maxWait time.Duration, maxHeld int) {
+	lb.failOpenWait = maxWait
+	lb.failOpenHeld = nil
+	if maxHeld > 0 {
+		lb.failOpenHeld = NewAdmissionQueue(maxHeld, 0)
+	}
+}
+
+// waitForBackend holds a request until pool has a healthy backend, the wait
+// expires or the client gives up. Returns true if a backend became available.
+func (lb *Balancer) waitForBackend(ctx context.Context, pool *backend.Pool, requestID string) bool {
+	if lb.failOpenHeld != nil {
+		if err := lb.failOpenHeld.Acquire(ctx); err != nil {
+			return false // Too many requests already held
+		}
+		defer lb.failOpenHeld.Release()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lb.failOpenWait)
+	defer cancel()
+
+	lb.logger.Info("waiting_for_backend",
+		"request_id", requestID,
+		"max_wait_ms", lb.failOpenWait.Milliseconds())
+
+	ticker := time.NewTicker(failOpenPollInterval)
+	defer ticker.Stop()
+	for {
+		if len(pool.GetRoutableBackends()) > 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
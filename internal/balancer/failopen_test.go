@@ -0,0 +1,87 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// newDownPool creates a pool of backends serving 200 that are all marked down
+func newDownPool(t *testing.T, n int) *backend.Pool {
+	t.Helper()
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), n)
+	for _, b := range pool.GetBackends() {
+		b.SetAlive(false)
+	}
+	return pool
+}
+
+// TestFailOpenBackendRecovers tests a request held during a total outage is
+// proxied once a backend recovers within the wait window
+func TestFailOpenBackendRecovers(t *testing.T) {
+	pool := newDownPool(t, 2)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetFailOpen(2*time.Second, 0)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		pool.GetBackends()[1].SetAlive(true)
+	}()
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once the backend recovered, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the request to be held until recovery (~100ms), took %v", elapsed)
+	}
+}
+
+// TestFailOpenWaitExpires tests a held request gets 503 once the wait runs out
+func TestFailOpenWaitExpires(t *testing.T) {
+	lb := createTestBalancer(newDownPool(t, 1), NewRoundRobinStrategy())
+	lb.SetFailOpen(100*time.Millisecond, 0)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after the wait, got %d", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the request to be held for the wait, took %v", elapsed)
+	}
+}
+
+// TestFailOpenMaxHeld tests requests beyond the held cap fail immediately
+func TestFailOpenMaxHeld(t *testing.T) {
+	lb := createTestBalancer(newDownPool(t, 1), NewRoundRobinStrategy())
+	lb.SetFailOpen(time.Second, 1)
+
+	done := make(chan struct{})
+	go func() {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(done)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for lb.failOpenHeld.InFlight() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected an immediate 503 beyond the cap, got %d after %v", w.Code, time.Since(start))
+	}
+	<-done
+}
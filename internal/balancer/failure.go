@@ -0,0 +1,68 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// FailurePredicate decides whether a backend response status counts as a failure
+// against the backend's passive health and circuit breaker
+type FailurePredicate func(statusCode int) bool
+
+// DefaultFailurePredicate treats every 5xx response as a failure
+func DefaultFailurePredicate(statusCode int) bool {
+	return statusCode >= 500
+}
+
+// StatusFailurePredicate treats 5xx responses as failures except the ignored codes,
+// plus any additional codes listed in failStatus (e.g. 429)
+func StatusFailurePredicate(ignoreStatus []int, failStatus []int) FailurePredicate {
+	ignored := make(map[int]bool, len(ignoreStatus))
+	for _, code := range ignoreStatus {
+		ignored[code] = true
+	}
+	failed := make(map[int]bool, len(failStatus))
+	for _, code := range failStatus {
+		failed[code] = true
+	}
+
+	return func(statusCode int) bool {
+		if failed[statusCode] {
+			return true
+		}
+		if ignored[statusCode] {
+			return false
+		}
+		return DefaultFailurePredicate(statusCode)
+	}
+}
+
+// retryReason classifies a failed attempt for the retries metric
+func retryReason(proxyErr error) string {
+	if proxyErr == nil {
+		return "server_error" // Backend responded with a failure status
+	}
+	if headerTooLarge(proxyErr) {
+		return "response_header_too_large"
+	}
+	if timedOut(proxyErr) {
+		return "timeout"
+	}
+	return "connection_error"
+}
+
+// timedOut reports whether an attempt failed because the backend did not
+// answer in time (connect, response or request deadline)
+func timedOut(proxyErr error) bool {
+	var netErr net.Error
+	return errors.Is(proxyErr, context.DeadlineExceeded) || (errors.As(proxyErr, &netErr) && netErr.Timeout())
+}
+
+// headerTooLarge reports whether an attempt failed because the backend's
+// response headers exceeded the size limit
+func headerTooLarge(proxyErr error) bool {
+	return errors.Is(proxyErr, backend.ErrResponseHeaderTooLarge)
+}
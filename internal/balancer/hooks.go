@@ -0,0 +1,52 @@
+package balancer
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// RequestBodyHook replaces a request body before it is sent to a backend. It
+// gets the request and its body, which it must close or wrap, and returns the
+// body to send. It runs once per attempt, on a fresh copy of the body when the
+// request may be retried. Returning an error answers the client with 500.
+type RequestBodyHook func(r *http.Request, body io.ReadCloser) (io.ReadCloser, error)
+
+// ResponseBodyHook replaces a backend response body before it is copied to
+// the client (see backend.ResponseBodyHook)
+type ResponseBodyHook = backend.ResponseBodyHook
+
+// SetRequestBodyHook transforms request bodies on their way to backends, e.g.
+// to redact fields. Hooks wrap the body as a stream, so a hook that reads as
+// it is read keeps uploads streaming; one that buffers the whole body (to
+// parse JSON, say) holds it in memory and delays the request until it is
+// complete. The transformed body is sent chunked, without Content-Length.
+func (lb *Balancer) SetRequestBodyHook(hook RequestBodyHook) {
+	lb.requestBodyHook = hook
+}
+
+// SetResponseBodyHook transforms backend response bodies on their way to
+// clients, e.g. to inject a snippet into HTML pages. As with request hooks, a
+// hook that buffers the body disables streaming: server-sent events and long
+// downloads reach the client only once the hook has read them. Hooks see the
+// body as the backend sent it, so a compressed body arrives compressed.
+func (lb *Balancer) SetResponseBodyHook(hook ResponseBodyHook) {
+	lb.responseBodyHook = hook
+}
+
+// applyRequestBodyHook replaces r's body with the hook's result. The new
+// length is unknown, so Content-Length is dropped.
+func (lb *Balancer) applyRequestBodyHook(r *http.Request) error {
+	if lb.requestBodyHook == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, err := lb.requestBodyHook(r, r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	r.ContentLength = -1
+	r.Header.Del("Content-Length")
+	return nil
+}
@@ -0,0 +1,90 @@
+package balancer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// upperReader uppercases ASCII text as it streams through
+type upperReader struct {
+	io.ReadCloser
+}
+
+func (u upperReader) Read(p []byte) (int, error) {
+	n, err := u.ReadCloser.Read(p)
+	copy(p[:n], bytes.ToUpper(p[:n]))
+	return n, err
+}
+
+// TestResponseBodyHook tests a response body hook transforms what the client receives
+func TestResponseBodyHook(t *testing.T) {
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello, world"))
+	}), 1)
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetResponseBodyHook(func(resp *http.Response, body io.ReadCloser) (io.ReadCloser, error) {
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/") {
+			return body, nil
+		}
+		resp.Header.Set("X-Transformed", "upper")
+		return upperReader{body}, nil
+	})
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "HELLO, WORLD" {
+		t.Fatalf("Expected the uppercased body, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Transformed") != "upper" {
+		t.Error("Expected header changes made by the hook to reach the client")
+	}
+}
+
+// TestRequestBodyHook tests a request body hook runs on every attempt's copy
+// of the body, and that a failing hook answers 500 without reaching a backend
+func TestRequestBodyHook(t *testing.T) {
+	var attempts int
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}), 2)
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetRequestBodyHook(func(r *http.Request, body io.ReadCloser) (io.ReadCloser, error) {
+		data, err := io.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(data, []byte("reject")) {
+			return nil, errors.New("rejected by hook")
+		}
+		return io.NopCloser(bytes.NewReader(bytes.ReplaceAll(data, []byte("secret"), []byte("[redacted]")))), nil
+	})
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader(`{"token":"secret"}`)))
+	if attempts != 2 || w.Body.String() != `{"token":"[redacted]"}` {
+		t.Fatalf("Expected the retried attempt to get the redacted body, got %d attempts and %q", attempts, w.Body.String())
+	}
+
+	attempts = 0
+	w = httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("PUT", "/", strings.NewReader("reject")))
+	if w.Code != http.StatusInternalServerError || attempts != 0 {
+		t.Errorf("Expected 500 without reaching a backend, got %d after %d attempts", w.Code, attempts)
+	}
+}
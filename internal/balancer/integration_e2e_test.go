@@ -1,21 +1,35 @@
 package balancer
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/cache"
 	"github.com/Nash0810/gobalance/internal/health"
 	"github.com/Nash0810/gobalance/internal/logging"
 	"github.com/Nash0810/gobalance/internal/metrics"
 	"github.com/Nash0810/gobalance/internal/retry"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // sharedCollector - Prometheus requires single registration per test run
@@ -38,6 +52,20 @@ func createTestBalancer(pool *backend.Pool, strategy Strategy) *Balancer {
 	return NewBalancer(pool, strategy, passiveTracker, retryPolicy, 10*time.Second, getSharedCollector(), logger)
 }
 
+// newReplicaPool starts n servers sharing handler and returns a pool of them, so
+// retries (which never reuse a backend within a request) reach the same handler
+func newReplicaPool(t *testing.T, handler http.Handler, n int) *backend.Pool {
+	t.Helper()
+	pool := backend.NewPool()
+	for i := 0; i < n; i++ {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+		pool.AddBackend(backend.NewBackend(u))
+	}
+	return pool
+}
+
 // TestE2EHealthyBackend tests basic request routing
 func TestE2EHealthyBackend(t *testing.T) {
 	requestReceived := make(chan bool, 1)
@@ -496,3 +524,1598 @@ func TestE2ERequestIDPropagation(t *testing.T) {
 		t.Error("X-Request-ID not set")
 	}
 }
+
+// TestE2EResponseCache tests cacheable GETs are served from cache on repeat
+func TestE2EResponseCache(t *testing.T) {
+	hits := atomic.Int32{}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/no-store" {
+			w.Header().Set("Cache-Control", "no-store")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("cached body"))
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetCache(cache.NewCache(100, 1024))
+
+	// Cacheable response: second request is served from cache
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/cacheable", nil)
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK || w.Body.String() != "cached body" {
+			t.Errorf("Request %d: unexpected response %d %q", i, w.Code, w.Body.String())
+		}
+		if i == 1 && w.Header().Get("Age") == "" {
+			t.Error("Cached response should carry an Age header")
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected backend to be hit once, got %d", hits.Load())
+	}
+
+	// no-store response: every request reaches the backend
+	hits.Store(0)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("GET", "/no-store", nil)
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+	}
+	if hits.Load() != 2 {
+		t.Errorf("no-store response should not be cached, backend hit %d times", hits.Load())
+	}
+}
+
+// TestE2EConditionalRequestNotModified tests 304 responses pass through faithfully
+func TestE2EConditionalRequestNotModified(t *testing.T) {
+	const etag = `"v1"`
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("full body"))
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetCache(cache.NewCache(100, 1024))
+
+	// Prime the cache with the full response
+	req := httptest.NewRequest("GET", "/conditional", nil)
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, req)
+
+	requestsTotal := getSharedCollector().RequestsTotal.WithLabelValues(u.Host, "GET", "304")
+	before := counterValue(t, requestsTotal)
+
+	// Conditional request must reach the backend and return its 304
+	req = httptest.NewRequest("GET", "/conditional", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	balancer.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 response should have no body, got %q", w.Body.String())
+	}
+	if w.Header().Get("ETag") != etag {
+		t.Errorf("ETag not propagated, got %q", w.Header().Get("ETag"))
+	}
+
+	if after := counterValue(t, requestsTotal); after != before+1 {
+		t.Errorf("Expected 304 to be recorded in metrics once, got delta %v", after-before)
+	}
+}
+
+// counterValue reads the current value of a Prometheus counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("Failed to read counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+// readTracker records whether a request body was read
+type readTracker struct {
+	r    io.Reader
+	read atomic.Bool
+}
+
+func (rt *readTracker) Read(p []byte) (int, error) {
+	rt.read.Store(true)
+	return rt.r.Read(p)
+}
+
+// TestE2EExpectContinue tests the 100-continue handshake flows to the backend
+func TestE2EExpectContinue(t *testing.T) {
+	var receivedBody atomic.Value
+	var receivedExpect atomic.Value
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedExpect.Store(r.Header.Get("Expect"))
+		if r.URL.Path == "/reject" {
+			// Reject without reading: the client should never send the body
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		receivedBody.Store(string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	lbServer := httptest.NewServer(createTestBalancer(pool, NewRoundRobinStrategy()))
+	defer lbServer.Close()
+
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+	defer client.CloseIdleConnections()
+
+	send := func(path string, body *readTracker) *http.Response {
+		req, _ := http.NewRequest("PUT", lbServer.URL+path, body)
+		req.ContentLength = 6
+		req.Header.Set("Expect", "100-continue")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	// Backend rejects: the body must not be pre-buffered by the balancer
+	rejected := &readTracker{r: strings.NewReader("upload")}
+	resp := send("/reject", rejected)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 from backend, got %d", resp.StatusCode)
+	}
+	if rejected.read.Load() {
+		t.Error("Body was sent before the backend agreed to receive it")
+	}
+	if receivedExpect.Load() != "100-continue" {
+		t.Errorf("Expect header not forwarded, got %v", receivedExpect.Load())
+	}
+
+	// Backend accepts: the body flows after the handshake
+	accepted := &readTracker{r: strings.NewReader("upload")}
+	resp = send("/accept", accepted)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+	if receivedBody.Load() != "upload" {
+		t.Errorf("Expected body 'upload', got %v", receivedBody.Load())
+	}
+}
+
+// TestCircuitBreakerTripsMetric tests the trips counter increments per trip, not per failure
+func TestCircuitBreakerTripsMetric(t *testing.T) {
+	pool := backend.NewPool()
+	u, _ := url.Parse("http://trip-metric.test:8080")
+	b := backend.NewBackend(u)
+	pool.AddBackend(b)
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	cb := balancer.getCircuitBreaker(b)
+
+	trips := getSharedCollector().CircuitBreakerTrips.WithLabelValues(u.Host)
+	before := counterValue(t, trips)
+
+	// Failures past the threshold while already open must not count again
+	for i := 0; i < 10; i++ {
+		cb.RecordFailure()
+	}
+
+	if after := counterValue(t, trips); after != before+1 {
+		t.Errorf("Expected exactly one trip, got %v", after-before)
+	}
+}
+
+// TestE2EFailurePredicate tests configured-OK statuses don't count against the breaker
+func TestE2EFailurePredicate(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/not-implemented" {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	newBalancer := func() (*Balancer, *backend.Backend) {
+		pool := backend.NewPool()
+		u, _ := url.Parse(mockServer.URL)
+		b := backend.NewBackend(u)
+		pool.AddBackend(b)
+
+		// High passive threshold so only the breaker reacts
+		lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(100), nil,
+			10*time.Second, getSharedCollector(), logging.NewLogger("test"))
+		lb.SetFailurePredicate(StatusFailurePredicate([]int{http.StatusNotImplemented}, nil))
+		return lb, b
+	}
+
+	// 501 is configured as a normal response: breaker stays closed
+	lb, b := newBalancer()
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest("GET", "/not-implemented", nil))
+		if w.Code != http.StatusNotImplemented {
+			t.Fatalf("Expected 501 to be proxied, got %d", w.Code)
+		}
+	}
+	if state := lb.getCircuitBreaker(b).GetState(); state != health.StateClosed {
+		t.Errorf("501 should not trip the breaker, got %v", state)
+	}
+	if failures := b.GetHealthMetrics().ConsecutiveFailures; failures != 0 {
+		t.Errorf("501 should not count as a passive failure, got %d", failures)
+	}
+
+	// 500 still counts as a failure
+	lb, b = newBalancer()
+	for i := 0; i < 5; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil))
+	}
+	if state := lb.getCircuitBreaker(b).GetState(); state != health.StateOpen {
+		t.Errorf("500s should trip the breaker, got %v", state)
+	}
+}
+
+// TestE2ECircuitBreakerTimeoutWeight tests timed-out requests count with the
+// configured weight on the circuit breaker, while 5xx responses count once
+func TestE2ECircuitBreakerTimeoutWeight(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	newBalancer := func() (*Balancer, *backend.Backend) {
+		pool := backend.NewPool()
+		u, _ := url.Parse(mockServer.URL)
+		b := backend.NewBackend(u)
+		pool.AddBackend(b)
+		lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(100), nil,
+			50*time.Millisecond, getSharedCollector(), logging.NewLogger("test"))
+		lb.SetCircuitBreakerTimeoutWeight(5)
+		return lb, b
+	}
+
+	lb, b := newBalancer()
+	for i := 0; i < 4; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil))
+	}
+	if state := lb.CircuitBreaker(b).GetState(); state != health.StateClosed {
+		t.Fatalf("Expected 4 errors to leave the breaker closed, got %v", state)
+	}
+
+	lb, b = newBalancer()
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+	if state := lb.CircuitBreaker(b).GetState(); state != health.StateOpen {
+		t.Errorf("Expected one timeout weighted 5 to open the breaker, got %v", state)
+	}
+}
+
+// TestStatusFailurePredicate tests the status allowlist/blocklist predicate
+func TestStatusFailurePredicate(t *testing.T) {
+	isFailure := StatusFailurePredicate([]int{501}, []int{429})
+
+	cases := map[int]bool{200: false, 404: false, 429: true, 500: true, 501: false, 503: true}
+	for code, expected := range cases {
+		if isFailure(code) != expected {
+			t.Errorf("Status %d: expected failure=%v", code, expected)
+		}
+	}
+}
+
+// TestE2EBackendProtocols tests h2c and HTTP/1 backends are proxied with their own transport
+func TestE2EBackendProtocols(t *testing.T) {
+	protoSeen := make(chan int, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protoSeen <- r.ProtoMajor
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Backend accepting both HTTP/1 and cleartext HTTP/2
+	mockServer := httptest.NewUnstartedServer(handler)
+	mockServer.Config.Protocols = new(http.Protocols)
+	mockServer.Config.Protocols.SetHTTP1(true)
+	mockServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	mockServer.Start()
+	defer mockServer.Close()
+
+	for protocol, expectedMajor := range map[string]int{backend.ProtocolHTTP1: 1, backend.ProtocolH2C: 2} {
+		pool := backend.NewPool()
+		u, _ := url.Parse(mockServer.URL)
+		b := backend.NewBackend(u)
+		b.SetTransport(backend.NewTransport(backend.TransportConfig{Protocol: protocol}))
+		pool.AddBackend(b)
+
+		balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d", protocol, w.Code)
+		}
+		if major := <-protoSeen; major != expectedMajor {
+			t.Errorf("%s: backend saw HTTP/%d, expected HTTP/%d", protocol, major, expectedMajor)
+		}
+	}
+}
+
+// TestE2ERetryDiscardsFailedAttempt tests the client only sees the successful attempt
+func TestE2ERetryDiscardsFailedAttempt(t *testing.T) {
+	attempt := atomic.Int32{}
+
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempt.Add(1) == 1 {
+			w.Header().Set("X-Failed", "true")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("unavailable"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}), 2)
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if attempt.Load() != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", attempt.Load())
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Expected clean 200 'ok', got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Failed") != "" {
+		t.Error("Headers from the failed attempt leaked into the response")
+	}
+}
+
+// TestE2ERetryPicksDifferentBackend tests a retry goes to a backend the request
+// hasn't tried, even when the strategy would hand back the one that just failed
+func TestE2ERetryPicksDifferentBackend(t *testing.T) {
+	failedHits := atomic.Int32{}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failedHits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthyHits := atomic.Int32{}
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyHits.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+
+	pool := backend.NewPool()
+	failingURL, _ := url.Parse(failing.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
+	failingBackend := backend.NewBackend(failingURL)
+	pool.AddBackend(failingBackend)
+	pool.AddBackend(backend.NewBackend(healthyURL))
+
+	// Always prefers the failing backend, as a small round-robin pool can
+	balancer := createTestBalancer(pool, &fixedStrategy{backend: failingBackend})
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from the second backend, got %d", w.Code)
+	}
+	if failedHits.Load() != 1 || healthyHits.Load() != 1 {
+		t.Errorf("Expected one attempt on each backend, got failing=%d healthy=%d", failedHits.Load(), healthyHits.Load())
+	}
+}
+
+// TestE2ERetrySkippedForSingleBackend tests the only backend isn't retried after it fails
+func TestE2ERetrySkippedForSingleBackend(t *testing.T) {
+	hits := atomic.Int32{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("unavailable"))
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if hits.Load() != 1 {
+		t.Errorf("Expected a single attempt, got %d", hits.Load())
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "unavailable" {
+		t.Errorf("Expected the backend's 503 to reach the client, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// TestE2ERetryMaxPerBackend tests MaxPerBackend caps attempts against each backend
+// independently of the total attempt budget
+func TestE2ERetryMaxPerBackend(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		mu.Lock()
+		hits[server]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	t.Run("one attempt per backend", func(t *testing.T) {
+		clear(hits)
+		pool := newReplicaPool(t, handler, 2)
+		policy := retry.NewPolicy(3, 100) // Would allow a third attempt
+		policy.SetMaxPerBackend(1)
+		lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), policy,
+			10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		if len(hits) != 2 {
+			t.Fatalf("Expected both backends tried, got %v", hits)
+		}
+		for host, n := range hits {
+			if n != 1 {
+				t.Errorf("Backend %s tried %d times, expected once", host, n)
+			}
+		}
+	})
+
+	t.Run("two attempts on a single backend", func(t *testing.T) {
+		clear(hits)
+		pool := newReplicaPool(t, handler, 1)
+		policy := retry.NewPolicy(3, 100)
+		policy.SetMaxPerBackend(2)
+		lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), policy,
+			10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		if len(hits) != 1 {
+			t.Fatalf("Expected the single backend tried, got %v", hits)
+		}
+		for host, n := range hits {
+			if n != 2 {
+				t.Errorf("Backend %s tried %d times, expected 2", host, n)
+			}
+		}
+	})
+}
+
+// fixedStrategy always selects the same backend
+type fixedStrategy struct {
+	backend *backend.Backend
+}
+
+// SelectBackend returns the fixed backend
+func (s *fixedStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	return s.backend
+}
+
+// Name returns the strategy name
+func (s *fixedStrategy) Name() string {
+	return "fixed"
+}
+
+// TestE2EResponseTrailers tests trailers set after the body reach the client
+func TestE2EResponseTrailers(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("payload"))
+		w.Header().Set("X-Checksum", "abc123")
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	lbServer := httptest.NewServer(createTestBalancer(pool, NewRoundRobinStrategy()))
+	defer lbServer.Close()
+
+	resp, err := http.Get(lbServer.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body) // Trailers are available after the body is read
+	if string(body) != "payload" {
+		t.Errorf("Expected body 'payload', got %q", body)
+	}
+	if resp.Trailer.Get("X-Checksum") != "abc123" {
+		t.Errorf("Trailer not propagated, got %v", resp.Trailer)
+	}
+}
+
+// TestE2EStreamingResponseFlushed tests chunked responses are flushed incrementally
+func TestE2EStreamingResponseFlushed(t *testing.T) {
+	release := make(chan struct{})
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+
+		// Don't finish until the client has seen the first chunk
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		w.Write([]byte("second\n"))
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	lbServer := httptest.NewServer(createTestBalancer(pool, NewRoundRobinStrategy()))
+	defer lbServer.Close()
+
+	resp, err := http.Get(lbServer.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	firstChunk := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := resp.Body.Read(buf)
+		firstChunk <- string(buf[:n])
+	}()
+
+	select {
+	case chunk := <-firstChunk:
+		if chunk != "first\n" {
+			t.Errorf("Expected first chunk 'first\\n', got %q", chunk)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("First chunk was buffered instead of flushed")
+	}
+}
+
+// TestE2EServerSentEvents tests SSE events reach the client as they are emitted
+func TestE2EServerSentEvents(t *testing.T) {
+	const events = 3
+	ack := make(chan struct{})
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < events; i++ {
+			fmt.Fprintf(w, "data: event-%d\n\n", i)
+			w.(http.Flusher).Flush()
+
+			// Emit the next event only once the client has seen this one
+			select {
+			case <-ack:
+			case <-time.After(5 * time.Second):
+				return
+			}
+		}
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	b := backend.NewBackend(u)
+	b.SetFlushInterval(-1) // flush_interval_ms: -1
+	pool.AddBackend(b)
+
+	lbServer := httptest.NewServer(createTestBalancer(pool, NewRoundRobinStrategy()))
+	defer lbServer.Close()
+
+	resp, err := http.Get(lbServer.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				lines <- line
+			}
+		}
+		close(lines)
+	}()
+
+	for i := 0; i < events; i++ {
+		select {
+		case line := <-lines:
+			if want := fmt.Sprintf("data: event-%d", i); line != want {
+				t.Fatalf("Expected %q, got %q", want, line)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Event %d was not delivered incrementally", i)
+		}
+		ack <- struct{}{}
+	}
+}
+
+// TestE2EProtocolUpgrade tests upgraded connections (e.g. WebSockets) are tunnelled to the backend
+func TestE2EProtocolUpgrade(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+		io.Copy(conn, brw) // Echo until the client hangs up
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+
+	// Hijacked connections outlive lbServer.Close, so wait for the tunnel to finish
+	// before returning to keep its metrics out of later tests
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	served := make(chan struct{})
+	lbServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lb.ServeHTTP(w, r)
+		close(served)
+	}))
+	defer lbServer.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(lbServer.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Reading upgrade response failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+
+	io.WriteString(conn, "ping")
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatalf("Reading echo failed: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("Expected echo 'ping', got %q", buf)
+	}
+
+	conn.Close()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Error("Tunnel didn't finish after the client hung up")
+	}
+}
+
+// TestE2EStaleIfError tests expired cached responses are served when all backends are down
+func TestE2EStaleIfError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("cached body"))
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	b := backend.NewBackend(u)
+	pool.AddBackend(b)
+
+	responseCache := cache.NewCache(100, 1024)
+	responseCache.SetStaleIfError(time.Minute)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetCache(responseCache)
+
+	// Populate the cache, then let the entry expire
+	req := httptest.NewRequest("GET", "/page", nil)
+	balancer.ServeHTTP(httptest.NewRecorder(), req)
+	entry, ok := responseCache.Get(cache.Key(req))
+	if !ok {
+		t.Fatal("Expected response to be cached")
+	}
+	entry.ExpiresAt = time.Now().Add(-time.Second)
+
+	b.SetAlive(false)
+
+	// Cached GET: served stale instead of 503
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/page", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "cached body" {
+		t.Errorf("Expected stale cached response, got %d %q", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Header().Get("Warning"), "110") {
+		t.Errorf("Expected Warning: 110 header, got %q", w.Header().Get("Warning"))
+	}
+
+	// Non-cacheable request: still 503
+	w = httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("POST", "/page", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for non-cacheable request, got %d", w.Code)
+	}
+}
+
+// histogramSample returns a histogram's current sample count and sum
+func histogramSample(t *testing.T, h prometheus.Histogram) (uint64, float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("Failed to read histogram: %v", err)
+	}
+	return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+}
+
+// TestRequestAttemptsMetric tests the attempt histogram observes the final attempt count
+func TestRequestAttemptsMetric(t *testing.T) {
+	attempt := atomic.Int32{}
+
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempt.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), 3)
+
+	collector := getSharedCollector()
+	balancer := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), retry.NewPolicy(3, 100),
+		10*time.Second, collector, logging.NewLogger("balancer"))
+
+	countBefore, sumBefore := histogramSample(t, collector.RequestAttempts)
+	serverErrorsBefore := counterValue(t, collector.RetriesTotal.WithLabelValues("server_error"))
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retries, got %d", w.Code)
+	}
+	count, sum := histogramSample(t, collector.RequestAttempts)
+	if count-countBefore != 1 || sum-sumBefore != 3 {
+		t.Errorf("Expected one observation of 3 attempts, got %d observations summing to %v", count-countBefore, sum-sumBefore)
+	}
+	if got := counterValue(t, collector.RetriesTotal.WithLabelValues("server_error")) - serverErrorsBefore; got != 2 {
+		t.Errorf("Expected 2 server_error retries, got %v", got)
+	}
+}
+
+// TestBackendSelectionsMetric tests selections are counted per backend,
+// following the strategy's weights and including attempts that fail
+func TestBackendSelectionsMetric(t *testing.T) {
+	collector := getSharedCollector()
+	selections := func(b *backend.Backend) float64 {
+		return counterValue(t, collector.BackendSelections.WithLabelValues(b.URL.Host))
+	}
+
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), 2)
+	backends := pool.GetBackends()
+	backends[0].SetWeight(3)
+	backends[1].SetWeight(1)
+	lb := createTestBalancer(pool, NewWeightedRoundRobinStrategy())
+
+	for i := 0; i < 8; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	if heavy, light := selections(backends[0]), selections(backends[1]); heavy != 6 || light != 2 {
+		t.Errorf("Expected 6 and 2 selections for weights 3:1, got %v and %v", heavy, light)
+	}
+
+	// A backend that refuses connections is still counted as selected
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	u, _ := url.Parse(closed.URL)
+	down := backend.NewBackend(u)
+	downPool := backend.NewPool()
+	downPool.AddBackend(down)
+	w := httptest.NewRecorder()
+	createTestBalancer(downPool, NewRoundRobinStrategy()).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502 from the closed backend, got %d", w.Code)
+	}
+	if got := selections(down); got != 1 {
+		t.Errorf("Expected the failed attempt counted as 1 selection, got %v", got)
+	}
+}
+
+// TestRequestTTFBMetric tests TTFB covers the backend's delay before its first
+// byte but not the time spent streaming the rest of the body
+func TestRequestTTFBMetric(t *testing.T) {
+	const firstByteDelay = 100 * time.Millisecond
+	const streamTime = 200 * time.Millisecond
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(firstByteDelay)
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for i := 0; i < 4; i++ {
+			time.Sleep(streamTime / 4)
+			fmt.Fprintf(w, "chunk %d\n", i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	collector := getSharedCollector()
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil,
+		10*time.Second, collector, logging.NewLogger("balancer"))
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	ttfbCount, ttfb := histogramSample(t, collector.RequestTTFB.WithLabelValues(u.Host, "GET").(prometheus.Histogram))
+	durationCount, duration := histogramSample(t, collector.RequestDuration.WithLabelValues(u.Host, "GET").(prometheus.Histogram))
+	if ttfbCount != 1 || durationCount != 1 {
+		t.Fatalf("Expected one TTFB and one duration observation, got %d and %d", ttfbCount, durationCount)
+	}
+	if ttfb < firstByteDelay.Seconds() || ttfb >= (firstByteDelay+streamTime/2).Seconds() {
+		t.Errorf("Expected TTFB close to %v, got %.3fs", firstByteDelay, ttfb)
+	}
+	if duration < (firstByteDelay + streamTime).Seconds() {
+		t.Errorf("Expected total duration of at least %v, got %.3fs", firstByteDelay+streamTime, duration)
+	}
+}
+
+// TestRetryReasonConnectionError tests transport failures are labelled connection_error
+func TestRetryReasonConnectionError(t *testing.T) {
+	pool := backend.NewPool()
+	for i := 0; i < 2; i++ {
+		deadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		deadURL, _ := url.Parse(deadServer.URL)
+		deadServer.Close() // Connections are refused from now on
+		pool.AddBackend(backend.NewBackend(deadURL))
+	}
+
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	collector := getSharedCollector()
+	before := counterValue(t, collector.RetriesTotal.WithLabelValues("connection_error"))
+
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", w.Code)
+	}
+	if counterValue(t, collector.RetriesTotal.WithLabelValues("connection_error")) <= before {
+		t.Error("Expected a connection_error retry to be recorded")
+	}
+}
+
+// TestRetryReason tests failed attempts are classified by cause
+func TestRetryReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "server_error"},
+		{context.DeadlineExceeded, "timeout"},
+		{&net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, "timeout"},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, "connection_error"},
+	}
+	for _, tt := range tests {
+		if got := retryReason(tt.err); got != tt.want {
+			t.Errorf("retryReason(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestE2EBackendErrorRate tests proxied outcomes feed the backend's rolling error rate
+func TestE2EBackendErrorRate(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	b := backend.NewBackend(u)
+	pool.AddBackend(b)
+
+	balancer := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(100), nil,
+		10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+	for _, path := range []string{"/ok", "/ok", "/ok", "/fail"} {
+		balancer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if rate := b.GetErrorRate(time.Minute); rate != 0.25 {
+		t.Errorf("Expected error rate 0.25, got %v", rate)
+	}
+}
+
+// TestE2ERetryDisabledStreamsBody tests that without a retry policy an upload reaches
+// the backend while the client is still sending it (no body buffering)
+func TestE2ERetryDisabledStreamsBody(t *testing.T) {
+	firstChunk := make(chan []byte, 1)
+	var received atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 1024)
+		n, _ := io.ReadFull(r.Body, buf)
+		firstChunk <- buf[:n]
+		rest, _ := io.Copy(io.Discard, r.Body)
+		received.Store(int64(n) + rest)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil,
+		10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+	bodyReader, bodyWriter := io.Pipe()
+	req := httptest.NewRequest("POST", "/upload", bodyReader)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		lb.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	chunk := bytes.Repeat([]byte("x"), 1024)
+	bodyWriter.Write(chunk)
+	select {
+	case got := <-firstChunk:
+		if !bytes.Equal(got, chunk) {
+			t.Errorf("Backend received unexpected first chunk of %d bytes", len(got))
+		}
+	case <-time.After(2 * time.Second):
+		bodyWriter.Close()
+		t.Fatal("Backend didn't see the first chunk before the upload finished: body was buffered")
+	}
+
+	// Send the remaining 4 MiB, then finish the upload
+	for i := 0; i < 4096; i++ {
+		bodyWriter.Write(chunk)
+	}
+	bodyWriter.Close()
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if received.Load() != 4097*1024 {
+		t.Errorf("Expected %d bytes at backend, got %d", 4097*1024, received.Load())
+	}
+}
+
+// TestE2EInFlightRequests tests the process-level in-flight gauge peaks at the
+// number of concurrent requests and returns to zero once they finish
+func TestE2EInFlightRequests(t *testing.T) {
+	const concurrency = 8
+	arrived := make(chan struct{}, concurrency)
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	collector := getSharedCollector()
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil,
+		10*time.Second, collector, logging.NewLogger("balancer"))
+
+	before := gaugeValue(t, collector.InFlightRequests)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+	for i := 0; i < concurrency; i++ {
+		<-arrived
+	}
+
+	if got := lb.InFlight(); got != concurrency {
+		t.Errorf("Expected %d in flight, got %d", concurrency, got)
+	}
+	if got := gaugeValue(t, collector.InFlightRequests) - before; got != concurrency {
+		t.Errorf("Expected gauge to rise by %d, got %v", concurrency, got)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if got := lb.InFlight(); got != 0 {
+		t.Errorf("Expected 0 in flight after completion, got %d", got)
+	}
+	if got := gaugeValue(t, collector.InFlightRequests); got != before {
+		t.Errorf("Expected gauge back at %v, got %v", before, got)
+	}
+}
+
+// gaugeValue reads the current value of a gauge
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatalf("Failed to read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+// failingBody yields a few bytes and then fails with err
+type failingBody struct {
+	err  error
+	sent bool
+}
+
+// Read returns the first chunk, then the error
+func (b *failingBody) Read(p []byte) (int, error) {
+	if !b.sent {
+		b.sent = true
+		return copy(p, "partial"), nil
+	}
+	return 0, b.err
+}
+
+// TestE2EBodyReadError tests a body that fails to buffer gets the configured
+// status, while a client disconnect gets 499 and counts as a cancellation
+func TestE2EBodyReadError(t *testing.T) {
+	hits := atomic.Int32{}
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}), 2)
+	collector := getSharedCollector()
+
+	tests := []struct {
+		name     string
+		err      error
+		status   int // Configured body read error status
+		cancel   bool
+		want     int
+		canceled bool
+	}{
+		{"malformed body", errors.New("malformed chunked encoding"), 0, false, http.StatusBadRequest, false},
+		{"configured status", errors.New("malformed chunked encoding"), http.StatusUnprocessableEntity, false, http.StatusUnprocessableEntity, false},
+		{"connection closed mid-body", io.ErrUnexpectedEOF, http.StatusUnprocessableEntity, false, 499, true},
+		{"client canceled", errors.New("read tcp: use of closed connection"), 0, true, 499, true},
+	}
+	for _, tt := range tests {
+		lb := createTestBalancer(pool, NewRoundRobinStrategy())
+		lb.SetBodyReadErrorStatus(tt.status)
+
+		req := httptest.NewRequest("PUT", "/upload", &failingBody{err: tt.err})
+		req.ContentLength = 100 // Known length, so the body is buffered for retries
+		if tt.cancel {
+			ctx, cancel := context.WithCancel(req.Context())
+			cancel()
+			req = req.WithContext(ctx)
+		}
+		canceledBefore := counterValue(t, collector.ClientCanceledTotal)
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, req)
+
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+		if canceled := counterValue(t, collector.ClientCanceledTotal) > canceledBefore; canceled != tt.canceled {
+			t.Errorf("%s: expected canceled=%v, got %v", tt.name, tt.canceled, canceled)
+		}
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Unreadable bodies should never reach a backend, got %d requests", hits.Load())
+	}
+}
+
+// TestE2ELargeBodySpillsToDisk tests a body above the in-memory limit is retried
+// from a temp file and the file is removed once the request is done
+func TestE2ELargeBodySpillsToDisk(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	payload := strings.Repeat("0123456789", 1000) // 10 KB
+	attempt := atomic.Int32{}
+	var received []string
+	var mu sync.Mutex
+	var spilledFiles int
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		entries, _ := os.ReadDir(tmpDir)
+		spilledFiles = len(entries)
+		mu.Unlock()
+		if attempt.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), 2)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetMaxInMemoryBodyBytes(1024)
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("PUT", "/upload", strings.NewReader(payload)))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retry, got %d", w.Code)
+	}
+	if len(received) != 2 || received[0] != payload || received[1] != payload {
+		t.Fatalf("Expected the full body on both attempts, got %d attempts", len(received))
+	}
+	if spilledFiles != 1 {
+		t.Errorf("Expected the body in one temp file during the request, found %d files", spilledFiles)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("Temp file should be removed after the request, found %d files", len(entries))
+	}
+}
+
+// TestE2EChunkedBodyStreamsWithRetries tests a chunked upload with no in-memory
+// limit reaches the backend while the client is still sending it, even with retries on
+func TestE2EChunkedBodyStreamsWithRetries(t *testing.T) {
+	firstChunk := make(chan []byte, 1)
+	var received atomic.Int64
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 1024)
+		n, _ := io.ReadFull(r.Body, buf)
+		firstChunk <- buf[:n]
+		rest, _ := io.Copy(io.Discard, r.Body)
+		received.Store(int64(n) + rest)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+
+	bodyReader, bodyWriter := io.Pipe()
+	req := httptest.NewRequest("POST", "/upload", bodyReader)
+	req.ContentLength = -1 // Chunked: no declared length
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		lb.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	chunk := bytes.Repeat([]byte("x"), 1024)
+	bodyWriter.Write(chunk)
+	select {
+	case got := <-firstChunk:
+		if !bytes.Equal(got, chunk) {
+			t.Errorf("Backend received unexpected first chunk of %d bytes", len(got))
+		}
+	case <-time.After(2 * time.Second):
+		bodyWriter.Close()
+		t.Fatal("Backend didn't see the first chunk before the upload finished: body was buffered")
+	}
+
+	for i := 0; i < 1023; i++ {
+		bodyWriter.Write(chunk)
+	}
+	bodyWriter.Close()
+	<-done
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", w.Code)
+	}
+	if received.Load() != 1024*1024 {
+		t.Errorf("Expected %d bytes at backend, got %d", 1024*1024, received.Load())
+	}
+}
+
+// TestE2EChunkedBodySpillsToDisk tests a chunked upload above the in-memory limit
+// is buffered to a temp file rather than memory and still retried
+func TestE2EChunkedBodySpillsToDisk(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	payload := strings.Repeat("0123456789", 1000) // 10 KB
+	attempt := atomic.Int32{}
+	var received []string
+	var mu sync.Mutex
+	var spilledFiles int
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		entries, _ := os.ReadDir(tmpDir)
+		spilledFiles = len(entries)
+		mu.Unlock()
+		if attempt.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}), 2)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetMaxInMemoryBodyBytes(1024)
+
+	bodyReader, bodyWriter := io.Pipe()
+	go func() {
+		for i := 0; i < len(payload); i += 1000 {
+			bodyWriter.Write([]byte(payload[i : i+1000]))
+		}
+		bodyWriter.Close()
+	}()
+	req := httptest.NewRequest("PUT", "/upload", bodyReader)
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retry, got %d", w.Code)
+	}
+	if len(received) != 2 || received[0] != payload || received[1] != payload {
+		t.Fatalf("Expected the full body on both attempts, got %d attempts", len(received))
+	}
+	if spilledFiles != 1 {
+		t.Errorf("Expected the body in one temp file during the request, found %d files", spilledFiles)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("Temp file should be removed after the request, found %d files", len(entries))
+	}
+}
+
+// TestE2ENoBackendLogRateLimited tests an outage logs "no healthy backends" at most
+// once per interval while every request still gets a 503 and is counted
+func TestE2ENoBackendLogRateLimited(t *testing.T) {
+	pool := backend.NewPool()
+	u, _ := url.Parse("http://localhost:8081")
+	b := backend.NewBackend(u)
+	b.SetAlive(false)
+	pool.AddBackend(b)
+
+	collector := getSharedCollector()
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil,
+		10*time.Second, collector, logging.NewLogger("balancer"))
+	lb.noBackendLog = logging.NewRateLimiter(200 * time.Millisecond)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	before := counterValue(t, collector.NoBackendTotal)
+	for i := 0; i < 50; i++ {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected 503, got %d", w.Code)
+		}
+	}
+
+	if got := strings.Count(logs.String(), "no_healthy_backends_available"); got != 1 {
+		t.Errorf("Expected 1 log line within the interval, got %d", got)
+	}
+	if got := counterValue(t, collector.NoBackendTotal) - before; got != 50 {
+		t.Errorf("Expected 50 no-backend requests counted, got %v", got)
+	}
+
+	// Next interval: one line summarizing the suppressed requests
+	time.Sleep(250 * time.Millisecond)
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := strings.Count(logs.String(), "no_healthy_backends_available"); got != 2 {
+		t.Errorf("Expected a second log line after the interval, got %d", got)
+	}
+	if !strings.Contains(logs.String(), "suppressed=49") {
+		t.Errorf("Expected suppressed count in the summary line, got:\n%s", logs.String())
+	}
+}
+
+// TestE2EClientCanceledMidFlight tests a client hanging up mid-request is counted
+// separately and not held against the backend's health
+func TestE2EClientCanceledMidFlight(t *testing.T) {
+	started := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done() // Slow backend: still working when the client gives up
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	b := backend.NewBackend(u)
+	pool.AddBackend(b)
+
+	collector := getSharedCollector()
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(1), retry.NewPolicy(3, 100),
+		10*time.Second, collector, logging.NewLogger("balancer"))
+	before := counterValue(t, collector.ClientCanceledTotal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	<-started
+	cancel()
+	<-done
+
+	if got := counterValue(t, collector.ClientCanceledTotal) - before; got != 1 {
+		t.Errorf("Expected 1 client cancellation counted, got %v", got)
+	}
+	if !b.IsAlive() {
+		t.Error("Client cancellation must not mark the backend unhealthy")
+	}
+	if rate := b.GetErrorRate(time.Minute); rate != 0 {
+		t.Errorf("Client cancellation must not count as a backend failure, error rate %v", rate)
+	}
+	if state := lb.CircuitBreaker(b).GetState(); state != health.StateClosed {
+		t.Errorf("Circuit breaker should stay closed, got %v", state)
+	}
+}
+
+// TestE2EPausedBackend tests a paused backend gets no new requests from any
+// strategy, and takes traffic again once resumed
+func TestE2EPausedBackend(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		mu.Lock()
+		hits[server]++
+		mu.Unlock()
+	})
+
+	strategies := []Strategy{
+		NewRoundRobinStrategy(),
+		NewWeightedRoundRobinStrategy(),
+		NewLeastConnectionsStrategy(),
+		NewWeightedRandomStrategy(),
+		NewLatencyP99Strategy(),
+		NewCompositeScoreStrategy(DefaultCompositeScoreCoefficients),
+	}
+	for _, strategy := range strategies {
+		// A fresh pool per strategy, so latency-aware strategies see the
+		// resumed backend as unsampled and try it
+		clear(hits)
+		pool := newReplicaPool(t, handler, 2)
+		backends := pool.GetBackends()
+		paused := backends[0]
+		lb := createTestBalancer(pool, strategy)
+
+		paused.SetPaused(true)
+		for i := 0; i < 10; i++ {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+		if hits[paused.URL.Host] != 0 || hits[backends[1].URL.Host] != 10 {
+			t.Errorf("%s: expected all traffic on the unpaused backend, got %v", strategy.Name(), hits)
+		}
+		if paused.GetState() != backend.Healthy {
+			t.Errorf("%s: pausing should leave the backend HEALTHY, got %v", strategy.Name(), paused.GetState())
+		}
+
+		paused.SetPaused(false)
+		for i := 0; i < 20 && hits[paused.URL.Host] == 0; i++ {
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}
+		if hits[paused.URL.Host] == 0 {
+			t.Errorf("%s: resumed backend got no traffic", strategy.Name())
+		}
+	}
+}
+
+// TestE2EBackendRequestTotals tests concurrent requests are counted exactly in
+// the backend's success and failure totals
+func TestE2EBackendRequestTotals(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer mockServer.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(mockServer.URL)
+	b := backend.NewBackend(u)
+	pool.AddBackend(b)
+
+	// Keep the backend selectable however many requests fail
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(1000), nil, 10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+	lb.CircuitBreaker(b).ForceClose()
+
+	const successes, failures = 60, 40
+	var wg sync.WaitGroup
+	for i := 0; i < successes+failures; i++ {
+		path := "/ok"
+		if i%5 < 2 {
+			path = "/fail"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}()
+	}
+	wg.Wait()
+
+	if got := b.SuccessCount(); got != successes {
+		t.Errorf("Expected %d successes, got %d", successes, got)
+	}
+	if got := b.FailureCount(); got != failures {
+		t.Errorf("Expected %d failures, got %d", failures, got)
+	}
+}
+
+// TestE2EOversizedResponseHeaders tests a backend response with a header
+// block over the limit becomes a 502 and is counted, while normal ones pass
+func TestE2EOversizedResponseHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge" {
+			for i := 0; i < 64; i++ {
+				w.Header().Set(fmt.Sprintf("X-Junk-%d", i), strings.Repeat("x", 256))
+			}
+		}
+		w.Header().Set("X-Normal", "yes")
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	b.SetMaxResponseHeaderBytes(8 << 10)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	collector := metrics.NewCollectorWithOptions(prometheus.NewRegistry(), metrics.CollectorOptions{})
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(5), nil,
+		10*time.Second, collector, logging.NewLogger("balancer"))
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Normal") != "yes" || w.Body.String() != "ok" {
+		t.Fatalf("Expected a normal response passed through, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/huge", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected 502 for oversized response headers, got %d", w.Code)
+	}
+	if w.Header().Get("X-Junk-0") != "" || w.Header().Get("X-Normal") != "" {
+		t.Error("Oversized response headers leaked to the client")
+	}
+	if got := counterValue(t, collector.OversizedHeaders.WithLabelValues(u.Host)); got != 1 {
+		t.Errorf("Expected one oversized response counted, got %v", got)
+	}
+}
+
+// TestE2EServerTiming tests served responses carry one well-formed
+// Server-Timing entry for the backend that answered, and none when disabled
+func TestE2EServerTiming(t *testing.T) {
+	var calls atomic.Int64
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // Retried on the other replica
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}), 2)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetServerTiming(true)
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after the retry, got %d", w.Code)
+	}
+	values := w.Header().Values("Server-Timing")
+	if len(values) != 1 {
+		t.Fatalf("Expected one Server-Timing entry from the served attempt, got %q", values)
+	}
+	match := regexp.MustCompile(`^backend;dur=(\d+\.\d{3});desc="([0-9a-f]{8})"$`).FindStringSubmatch(values[0])
+	if match == nil {
+		t.Fatalf("Malformed Server-Timing header %q", values[0])
+	}
+	if dur, _ := strconv.ParseFloat(match[1], 64); dur < 20 {
+		t.Errorf("Expected the backend's 20ms delay in the duration, got %sms", match[1])
+	}
+	served := pool.GetBackends()[1]
+	if match[2] != lb.serverTimingID(served) {
+		t.Errorf("Expected the id of the backend that answered (%s), got %s", lb.serverTimingID(served), match[2])
+	}
+	if strings.Contains(values[0], served.URL.Host) {
+		t.Errorf("Server-Timing must not reveal the backend address: %q", values[0])
+	}
+
+	lb.SetServerTiming(false)
+	w = httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if got := w.Header().Values("Server-Timing"); len(got) != 0 {
+		t.Errorf("Expected no Server-Timing header when disabled, got %q", got)
+	}
+}
+
+// TestServerTimingKey tests backend ids depend on the key: instances sharing a
+// key agree, and without one each balancer gets its own random key
+func TestServerTimingKey(t *testing.T) {
+	u, _ := url.Parse("http://10.0.0.1:8080")
+	b := backend.NewBackend(u)
+	newLB := func(key string) *Balancer {
+		lb := createTestBalancer(backend.NewPool(), NewRoundRobinStrategy())
+		if key != "" {
+			lb.SetServerTimingKey([]byte(key))
+		}
+		lb.SetServerTiming(true)
+		return lb
+	}
+
+	if first, second := newLB("secret").serverTimingID(b), newLB("secret").serverTimingID(b); first != second {
+		t.Errorf("Expected the same id under a shared key, got %s and %s", first, second)
+	}
+	if first, second := newLB("secret").serverTimingID(b), newLB("other").serverTimingID(b); first == second {
+		t.Errorf("Expected different keys to give different ids, both %s", first)
+	}
+	if first, second := newLB("").serverTimingID(b), newLB("").serverTimingID(b); first == second {
+		t.Errorf("Expected random keys to give different ids, both %s", first)
+	}
+}
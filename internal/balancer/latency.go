@@ -0,0 +1,74 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// LatencyP99Strategy routes to the backend with the best recent p99 latency, so a
+// backend with occasional large spikes is deprioritized even if its average is low.
+// Backends without enough samples are tried first so their latency gets measured.
+type LatencyP99Strategy struct {
+	maxActive int64 // In-flight requests at which a backend has no spare capacity (0 = unlimited, atomic)
+}
+
+// NewLatencyP99Strategy creates a new p99 latency strategy
+func NewLatencyP99Strategy() *LatencyP99Strategy {
+	return &LatencyP99Strategy{}
+}
+
+// SetMaxActiveRequests sets how many in-flight requests a backend may have before
+// it is passed over in favour of slower backends with spare capacity
+func (lp *LatencyP99Strategy) SetMaxActiveRequests(n int64) {
+	atomic.StoreInt64(&lp.maxActive, n)
+}
+
+// SelectBackend picks the backend with the lowest load-adjusted p99 among those with spare capacity
+func (lp *LatencyP99Strategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	backends := pool.GetRoutableBackends()
+
+	if len(backends) == 0 {
+		return nil
+	}
+
+	maxActive := atomic.LoadInt64(&lp.maxActive)
+	var selected, unsampled *backend.Backend
+	var bestScore time.Duration
+	for _, b := range backends {
+		active := b.GetActiveRequests()
+		if maxActive > 0 && active >= maxActive {
+			continue // No spare capacity
+		}
+
+		p99, ok := b.LatencyP99()
+		if !ok {
+			if unsampled == nil || active < unsampled.GetActiveRequests() {
+				unsampled = b
+			}
+			continue
+		}
+
+		// Scale by load so concurrent requests spread instead of piling onto one backend
+		score := p99 * time.Duration(active+1)
+		if selected == nil || score < bestScore {
+			selected = b
+			bestScore = score
+		}
+	}
+
+	if unsampled != nil {
+		return unsampled
+	}
+	if selected != nil {
+		return selected
+	}
+	// Everyone is at capacity: fall back to the least loaded backend
+	return NewLeastConnectionsStrategy().SelectBackend(pool)
+}
+
+// Name returns the strategy name
+func (lp *LatencyP99Strategy) Name() string {
+	return "latency-p99"
+}
@@ -16,7 +16,7 @@ func NewLeastConnectionsStrategy() *LeastConnectionsStrategy {
 
 // SelectBackend picks the backend with minimum active requests
 func (lc *LeastConnectionsStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
-	backends := pool.GetHealthyBackends()
+	backends := pool.GetRoutableBackends()
 
 	if len(backends) == 0 {
 		return nil
@@ -0,0 +1,117 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// ZoneTag is the backend tag holding the backend's zone
+const ZoneTag = "zone"
+
+// LocalityStrategy prefers backends in the local zone. Requests go to the
+// local strategy over the backends tagged with the zone, and spill over to the
+// remote strategy over the other zones' backends when no local backend is
+// selectable, or when every local backend is saturated.
+// Each side has its own strategy so their cached per-pool state never mixes.
+type LocalityStrategy struct {
+	zone      string
+	local     Strategy // Picks among local-zone backends
+	spillover Strategy // Picks among other zones' backends when the local zone can't take the request
+	maxActive int64    // In-flight requests at which a local backend is saturated (0 = unlimited, atomic)
+
+	zones atomic.Pointer[zonePools] // Zone split of the last pool seen; replaced, never modified
+	mux   sync.Mutex                // Serializes rebuilds of zones
+}
+
+// zonePools splits a pool's backends into the local zone and the rest
+type zonePools struct {
+	source  *backend.Pool // Pool the split was built from
+	version uint64        // Version of source the split was built for
+	local   *backend.Pool
+	remote  *backend.Pool
+}
+
+// NewLocalityStrategy creates a strategy preferring backends tagged with zone
+func NewLocalityStrategy(zone string, local, spillover Strategy) *LocalityStrategy {
+	return &LocalityStrategy{
+		zone:      zone,
+		local:     local,
+		spillover: spillover,
+	}
+}
+
+// SetMaxActiveRequests sets how many in-flight requests a local backend may
+// have before it counts as saturated; once all are, requests spill over to
+// other zones (0 = only spill when no local backend is available)
+func (ls *LocalityStrategy) SetMaxActiveRequests(n int64) {
+	atomic.StoreInt64(&ls.maxActive, n)
+}
+
+// SelectBackend picks a local-zone backend, falling back to other zones
+func (ls *LocalityStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	zones := ls.zonePools(pool)
+	if !ls.saturated(zones.local) {
+		if b := ls.local.SelectBackend(zones.local); b != nil {
+			return b
+		}
+	}
+	if b := ls.spillover.SelectBackend(zones.remote); b != nil {
+		return b
+	}
+	// No other zone can take it either: a saturated local backend beats none
+	return ls.local.SelectBackend(zones.local)
+}
+
+// saturated reports whether every routable backend of local is at the
+// in-flight limit (false without a limit or without routable backends)
+func (ls *LocalityStrategy) saturated(local *backend.Pool) bool {
+	maxActive := atomic.LoadInt64(&ls.maxActive)
+	if maxActive <= 0 {
+		return false
+	}
+	backends := local.GetRoutableBackends()
+	for _, b := range backends {
+		if b.GetActiveRequests() < maxActive {
+			return false
+		}
+	}
+	return len(backends) > 0
+}
+
+// zonePools returns pool split by zone, rebuilding the split when pool
+// membership changed. A rebuild makes new pools and swaps them in whole, so
+// concurrent requests never see a half-built zone.
+func (ls *LocalityStrategy) zonePools(pool *backend.Pool) *zonePools {
+	version := pool.Version()
+	if zones := ls.zones.Load(); zones != nil && zones.source == pool && zones.version == version {
+		return zones
+	}
+
+	ls.mux.Lock()
+	defer ls.mux.Unlock()
+	if zones := ls.zones.Load(); zones != nil && zones.source == pool && zones.version == version {
+		return zones // Rebuilt while we waited
+	}
+	zones := &zonePools{
+		source:  pool,
+		version: version,
+		local:   backend.NewPool(),
+		remote:  backend.NewPool(),
+	}
+	for _, b := range pool.GetBackends() {
+		if b.Tags[ZoneTag] == ls.zone {
+			zones.local.AddBackend(b)
+		} else {
+			zones.remote.AddBackend(b)
+		}
+	}
+	ls.zones.Store(zones)
+	return zones
+}
+
+// Name returns the strategy name
+func (ls *LocalityStrategy) Name() string {
+	return ls.local.Name() + " (prefer zone " + ls.zone + ")"
+}
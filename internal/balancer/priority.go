@@ -0,0 +1,99 @@
+package balancer
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Priority ranks requests in the admission queue: under saturation higher
+// priorities are admitted first and lower ones are shed first
+type Priority int
+
+// Request priorities, lowest first
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// String returns the priority's header value
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+// ParsePriority parses a priority header value (high, normal or low, in any
+// case). Missing or unknown values count as normal; ok reports whether the
+// value was recognised.
+func ParsePriority(value string) (p Priority, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "high":
+		return PriorityHigh, true
+	case "normal":
+		return PriorityNormal, true
+	case "low":
+		return PriorityLow, true
+	}
+	return PriorityNormal, false
+}
+
+// PriorityBudget overrides the request timeout and retry attempts for
+// requests of one priority
+type PriorityBudget struct {
+	Timeout     time.Duration // Per-request timeout (0 = the balancer's)
+	MaxAttempts int           // Total attempts including the first when retries apply (0 = default)
+}
+
+// RequestPriorities reads each request's priority from a header and optionally
+// gives each priority its own timeout and retry budget. Any client can send
+// the header, so unless an edge proxy sets it, limit it to TrustedSources.
+type RequestPriorities struct {
+	Header         string                      // Request header carrying the priority, e.g. X-Priority
+	Budgets        map[Priority]PriorityBudget // Per-priority budgets (missing = defaults)
+	TrustedSources []netip.Prefix              // Peers whose header is honoured; others' is stripped and they count as normal (empty = all)
+}
+
+// SetRequestPriorities enables priority admission (nil = every request is normal priority)
+func (lb *Balancer) SetRequestPriorities(rp *RequestPriorities) {
+	lb.priorities = rp
+}
+
+// requestPriority returns r's priority and its budget. Without priorities
+// configured every request is normal with the default budget. A header from
+// an untrusted peer is removed so backends don't act on it either.
+func (lb *Balancer) requestPriority(r *http.Request) (Priority, PriorityBudget) {
+	if lb.priorities == nil {
+		return PriorityNormal, PriorityBudget{}
+	}
+	if !lb.priorities.trusted(r) {
+		r.Header.Del(lb.priorities.Header)
+		return PriorityNormal, lb.priorities.Budgets[PriorityNormal]
+	}
+	p, _ := ParsePriority(r.Header.Get(lb.priorities.Header))
+	return p, lb.priorities.Budgets[p]
+}
+
+// trusted reports whether r's peer may set its priority
+func (rp *RequestPriorities) trusted(r *http.Request) bool {
+	if len(rp.TrustedSources) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, prefix := range rp.TrustedSources {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
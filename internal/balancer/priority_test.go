@@ -0,0 +1,227 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/Nash0810/gobalance/internal/retry"
+)
+
+// TestParsePriority tests header values are matched loosely and anything else counts as normal
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Priority
+		ok    bool
+	}{
+		{"high", PriorityHigh, true},
+		{" HIGH ", PriorityHigh, true},
+		{"Normal", PriorityNormal, true},
+		{"low", PriorityLow, true},
+		{"", PriorityNormal, false},
+		{"urgent", PriorityNormal, false},
+		{"1", PriorityNormal, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePriority(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParsePriority(%q) = %v, %v; expected %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestAdmissionQueuePriorityOrder tests higher priorities are admitted first
+// regardless of arrival order or tenant weight, in arrival order within a priority
+func TestAdmissionQueuePriorityOrder(t *testing.T) {
+	q := NewAdmissionQueue(1, 100)
+	q.Acquire(context.Background())
+
+	type admission struct {
+		priority Priority
+		seq      int
+	}
+	order := make(chan admission, 9)
+	enqueue := func(priority Priority, weight, seq int) {
+		go func() {
+			q.AcquirePriority(context.Background(), priority, weight)
+			order <- admission{priority, seq}
+			q.Release()
+		}()
+	}
+	// Heavy low-priority waiters arrive first; lighter higher priorities still overtake them
+	for i, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		for seq := 0; seq < 3; seq++ {
+			enqueue(p, 10-3*i, seq)
+			waitForQueueLen(t, q, 3*i+seq+1)
+		}
+	}
+
+	q.Release()
+	for _, want := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		for seq := 0; seq < 3; seq++ {
+			if a := <-order; a.priority != want || a.seq != seq {
+				t.Fatalf("Expected %v #%d admitted next, got %v #%d", want, seq, a.priority, a.seq)
+			}
+		}
+	}
+	if q.InFlight() != 0 || q.QueueLen() != 0 {
+		t.Errorf("Expected an empty queue, got %d in flight, %d queued", q.InFlight(), q.QueueLen())
+	}
+}
+
+// TestE2EPriorityAdmission tests a saturated balancer admits high-priority
+// requests before low-priority ones, with unknown priorities treated as normal
+func TestE2EPriorityAdmission(t *testing.T) {
+	unblock := make(chan struct{})
+	var mu sync.Mutex
+	var admitted []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		admitted = append(admitted, r.Header.Get("X-Priority"))
+		mu.Unlock()
+		<-unblock
+	})
+	pool := newReplicaPool(t, handler, 1)
+
+	queue := NewAdmissionQueue(1, 10)
+	balancer := createTestBalancer(pool, NewRoundRobinStrategy())
+	balancer.SetAdmissionQueue(queue, 0)
+	balancer.SetRequestPriorities(&RequestPriorities{Header: "X-Priority"})
+
+	var wg sync.WaitGroup
+	serve := func(priority string) {
+		defer wg.Done()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Priority", priority)
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected %q request served, got %d", priority, w.Code)
+		}
+	}
+
+	// Saturate the slot, then queue low, unknown and high priority requests in that order
+	wg.Add(1)
+	go serve("first")
+	for queue.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	for i, priority := range []string{"low", "bogus", "high"} {
+		wg.Add(1)
+		go serve(priority)
+		waitForQueueLen(t, queue, i+1)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	want := []string{"first", "high", "bogus", "low"}
+	if len(admitted) != len(want) {
+		t.Fatalf("Expected %d requests admitted, got %v", len(want), admitted)
+	}
+	for i := range want {
+		if admitted[i] != want[i] {
+			t.Fatalf("Expected admission order %v, got %v", want, admitted)
+		}
+	}
+}
+
+// TestE2EPriorityBudgets tests each priority gets its own retry attempts and
+// request timeout, and unknown priorities get the normal budget
+func TestE2EPriorityBudgets(t *testing.T) {
+	var hits atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	pool := newReplicaPool(t, handler, 5)
+
+	// The loader's default policy: 2 attempts, budget 10%
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), health.NewPassiveTracker(100), retry.NewPolicy(2, 10),
+		10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+	lb.SetRequestPriorities(&RequestPriorities{
+		Header: "X-Priority",
+		Budgets: map[Priority]PriorityBudget{
+			PriorityHigh:   {MaxAttempts: 5},
+			PriorityNormal: {MaxAttempts: 2},
+			PriorityLow:    {MaxAttempts: 1, Timeout: 50 * time.Millisecond},
+		},
+	})
+
+	for _, tt := range []struct {
+		priority string
+		want     int32
+	}{
+		{"high", 5},
+		{"normal", 2},
+		{"unknown", 2},
+		{"low", 1},
+	} {
+		hits.Store(0)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Priority", tt.priority)
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+		if got := hits.Load(); got != tt.want {
+			t.Errorf("Priority %q: expected %d attempts, got %d", tt.priority, tt.want, got)
+		}
+	}
+
+	start := time.Now()
+	req := httptest.NewRequest("GET", "/slow", nil)
+	req.Header.Set("X-Priority", "low")
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, req)
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("Expected the low-priority timeout to cut the request short, took %v", elapsed)
+	}
+	if w.Code == http.StatusServiceUnavailable {
+		t.Errorf("Expected the low-priority request to time out, got the backend's %d", w.Code)
+	}
+}
+
+// TestPriorityTrustedSources tests only trusted peers may set a priority, and
+// the header is stripped from everyone else's requests
+func TestPriorityTrustedSources(t *testing.T) {
+	var forwarded atomic.Value
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Store(r.Header.Get("X-Priority"))
+	})
+	lb := createTestBalancer(newReplicaPool(t, handler, 1), NewRoundRobinStrategy())
+	lb.SetRequestPriorities(&RequestPriorities{
+		Header:         "X-Priority",
+		TrustedSources: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+	})
+
+	for _, tt := range []struct {
+		remoteAddr string
+		want       Priority
+		forwarded  string
+	}{
+		{"10.1.2.3:5000", PriorityHigh, "high"},
+		{"[::ffff:10.1.2.3]:5000", PriorityHigh, "high"},
+		{"192.0.2.1:5000", PriorityNormal, ""},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Priority", "high")
+		if got, _ := lb.requestPriority(req.Clone(context.Background())); got != tt.want {
+			t.Errorf("%s: expected priority %v, got %v", tt.remoteAddr, tt.want, got)
+		}
+
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+		if got := forwarded.Load(); got != tt.forwarded {
+			t.Errorf("%s: expected the backend to see %q, got %q", tt.remoteAddr, tt.forwarded, got)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package balancer
+
+import (
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// trialBackend returns a passively downed backend of pool that is due a trial
+// request, so passive recovery sees successes without active checks (nil =
+// none). The trial must be ended with passiveTracker.EndTrial. A backend whose
+// circuit still rejects requests is passed over until its breaker lets one through.
+func (lb *Balancer) trialBackend(pool *backend.Pool) *backend.Backend {
+	b := lb.passiveTracker.TrialBackend(pool)
+	if b == nil {
+		return nil
+	}
+	if !lb.getCircuitBreaker(b).AllowRequest() {
+		lb.passiveTracker.EndTrial(b)
+		return nil
+	}
+	return b
+}
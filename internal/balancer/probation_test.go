@@ -0,0 +1,127 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/health"
+	"github.com/Nash0810/gobalance/internal/logging"
+)
+
+// TestE2EPassiveRecoveryTrials tests a backend marked down by request failures
+// is restored by trial requests alone, with no active health checker running
+func TestE2EPassiveRecoveryTrials(t *testing.T) {
+	var failing atomic.Bool
+	var flakyHits atomic.Int32
+	failing.Store(true)
+	flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flakyHits.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer flakyServer.Close()
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer goodServer.Close()
+
+	pool := backend.NewPool()
+	flakyURL, _ := url.Parse(flakyServer.URL)
+	goodURL, _ := url.Parse(goodServer.URL)
+	flaky := backend.NewBackend(flakyURL)
+	pool.AddBackend(flaky)
+	pool.AddBackend(backend.NewBackend(goodURL))
+
+	const cooldown = 100 * time.Millisecond
+	tracker := health.NewPassiveTracker(2)
+	tracker.SetRecoveryThreshold(2)
+	tracker.SetRecoveryCooldown(cooldown)
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), tracker, nil,
+		10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+	serve := func() {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	for i := 0; i < 10 && flaky.GetState() == backend.Healthy; i++ {
+		serve()
+	}
+	if flaky.GetState() != backend.Unhealthy {
+		t.Fatalf("Expected request failures to mark the backend unhealthy, got %v", flaky.GetState())
+	}
+
+	// Out of rotation during the cooldown
+	failing.Store(false)
+	flakyHits.Store(0)
+	for i := 0; i < 5; i++ {
+		serve()
+	}
+	if n := flakyHits.Load(); n != 0 {
+		t.Fatalf("Expected no traffic during the cooldown, got %d requests", n)
+	}
+
+	// Trial requests after the cooldown bring it back
+	time.Sleep(cooldown)
+	for i := 0; i < 5 && flaky.GetState() != backend.Healthy; i++ {
+		serve()
+	}
+	if flaky.GetState() != backend.Healthy {
+		t.Fatalf("Expected trial successes to restore the backend, got %v", flaky.GetState())
+	}
+	if n := flakyHits.Load(); n != 2 {
+		t.Errorf("Expected 2 trial requests before recovery, got %d", n)
+	}
+}
+
+// TestE2EPassiveRecoveryFailedTrial tests a failed trial keeps the backend
+// down and starts the cooldown again
+func TestE2EPassiveRecoveryFailedTrial(t *testing.T) {
+	var flakyHits atomic.Int32
+	flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flakyHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer flakyServer.Close()
+	goodServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer goodServer.Close()
+
+	pool := backend.NewPool()
+	flakyURL, _ := url.Parse(flakyServer.URL)
+	goodURL, _ := url.Parse(goodServer.URL)
+	flaky := backend.NewBackend(flakyURL)
+	pool.AddBackend(flaky)
+	pool.AddBackend(backend.NewBackend(goodURL))
+
+	const cooldown = 100 * time.Millisecond
+	tracker := health.NewPassiveTracker(1)
+	tracker.SetRecoveryThreshold(1)
+	tracker.SetRecoveryCooldown(cooldown)
+	lb := NewBalancer(pool, NewRoundRobinStrategy(), tracker, nil,
+		10*time.Second, getSharedCollector(), logging.NewLogger("balancer"))
+
+	serve := func() {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	for i := 0; i < 4 && flaky.GetState() == backend.Healthy; i++ {
+		serve()
+	}
+	if flaky.GetState() != backend.Unhealthy {
+		t.Fatalf("Expected the backend marked unhealthy, got %v", flaky.GetState())
+	}
+
+	time.Sleep(cooldown)
+	flakyHits.Store(0)
+	for i := 0; i < 5; i++ {
+		serve()
+	}
+	if n := flakyHits.Load(); n != 1 {
+		t.Errorf("Expected a single failed trial before the cooldown restarts, got %d", n)
+	}
+	if flaky.GetState() != backend.Unhealthy {
+		t.Errorf("Expected the backend still unhealthy after a failed trial, got %v", flaky.GetState())
+	}
+}
@@ -0,0 +1,75 @@
+package balancer
+
+import (
+	"net/http"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/health"
+)
+
+// shouldRetry asks the retry policy whether a failed attempt is retried,
+// within maxAttempts when the request's priority sets it (0 = the policy's
+// limit). A backend marked retryable (override set and true) is retried
+// whatever the method; backends marked non-retryable never reach here.
+func (lb *Balancer) shouldRetry(r *http.Request, err error, attempt, maxAttempts int, override *bool) bool {
+	return lb.retryPolicy.ShouldRetryWithin(r, err, attempt, maxAttempts, override != nil && *override)
+}
+
+// SetRetryToHealthiest makes retries go to the healthiest backend the request
+// may still try instead of asking the strategy again
+func (lb *Balancer) SetRetryToHealthiest(enabled bool) {
+	lb.retryToHealthiest = enabled
+}
+
+// selectHealthiest picks the untried backend most likely to succeed: closed
+// breakers first, then the lowest recent error rate, then the lowest moving-average
+// latency. Ties go to the backend listed first, so the choice is deterministic.
+func (lb *Balancer) selectHealthiest(pool *backend.Pool, attempts *backendAttempts) *backend.Backend {
+	var selected *backend.Backend
+	var best healthRank
+	for _, b := range pool.GetRoutableBackends() {
+		if attempts.exhausted(b) {
+			continue
+		}
+		rank := lb.healthRank(b)
+		if selected == nil || rank.better(best) {
+			selected = b
+			best = rank
+		}
+	}
+	return selected
+}
+
+// healthRank orders backends by how likely a retry against them is to succeed
+type healthRank struct {
+	breakerOpen bool
+	errorRate   float64
+	latency     float64 // Moving-average latency in ms (0 = no samples yet)
+}
+
+// better reports whether r ranks strictly ahead of other
+func (r healthRank) better(other healthRank) bool {
+	if r.breakerOpen != other.breakerOpen {
+		return !r.breakerOpen
+	}
+	if r.errorRate != other.errorRate {
+		return r.errorRate < other.errorRate
+	}
+	return r.latency < other.latency
+}
+
+// healthRank reads b's current health signals without creating a circuit breaker for it
+func (lb *Balancer) healthRank(b *backend.Backend) healthRank {
+	lb.cbMux.RLock()
+	cb := lb.circuitBreakers[b.URL.Host]
+	lb.cbMux.RUnlock()
+
+	rank := healthRank{
+		breakerOpen: cb != nil && cb.GetState() == health.StateOpen,
+		errorRate:   b.GetErrorRate(backend.DefaultErrorRateWindow),
+	}
+	if latency, ok := b.LatencyEWMA(); ok {
+		rank.latency = float64(latency.Microseconds()) / 1000
+	}
+	return rank
+}
@@ -0,0 +1,236 @@
+package balancer
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// TestRetryToHealthiest tests a retry skips the strategy and lands on the
+// healthiest untried backend: closed breaker, then fewest errors, then fastest
+func TestRetryToHealthiest(t *testing.T) {
+	var mu sync.Mutex
+	var primary string
+	hits := make(map[string]int)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		mu.Lock()
+		hits[server]++
+		mu.Unlock()
+		if server == primary {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// setup returns a balancer whose strategy always picks a failing backend,
+	// plus three retry candidates: an open breaker, a 50% error rate, and a
+	// clean but slow backend
+	setup := func(toHealthiest bool) (*Balancer, []*backend.Backend) {
+		clear(hits)
+		pool := newReplicaPool(t, handler, 4)
+		backends := pool.GetBackends()
+		primary = backends[0].URL.Host
+
+		lb := createTestBalancer(pool, &fixedStrategy{backend: backends[0]})
+		lb.SetRetryToHealthiest(toHealthiest)
+
+		cb := lb.CircuitBreaker(backends[1])
+		for i := 0; i < 5; i++ {
+			cb.RecordFailure()
+		}
+		for i := 0; i < 10; i++ {
+			backends[2].RecordRequestSuccess()
+			backends[2].RecordRequestFailure()
+		}
+		backends[3].RecordLatency(100 * time.Millisecond)
+		return lb, backends
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		lb, backends := setup(true)
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected the retry to succeed, got %d", w.Code)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if hits[backends[3].URL.Host] != 3 || hits[backends[1].URL.Host] != 0 || hits[backends[2].URL.Host] != 0 {
+			t.Errorf("Expected every retry on the healthiest backend, got %v", hits)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		lb, backends := setup(false)
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		// The strategy's fallback walks the pool in order: past the open
+		// breaker to the erroring backend
+		mu.Lock()
+		defer mu.Unlock()
+		if hits[backends[2].URL.Host] != 1 || hits[backends[3].URL.Host] != 0 {
+			t.Errorf("Expected the retry on the first untried backend, got %v", hits)
+		}
+	})
+}
+
+// TestSelectHealthiestPrefersLowerLatency tests latency breaks ties between
+// backends with no errors
+func TestSelectHealthiestPrefersLowerLatency(t *testing.T) {
+	pool := newReplicaPool(t, http.NotFoundHandler(), 3)
+	backends := pool.GetBackends()
+	backends[0].RecordLatency(200 * time.Millisecond)
+	backends[1].RecordLatency(50 * time.Millisecond)
+	backends[2].RecordLatency(20 * time.Millisecond)
+
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	tried := newBackendAttempts(1)
+	if got := lb.selectHealthiest(pool, tried); got != backends[2] {
+		t.Errorf("Expected the fastest backend, got %s", got.URL)
+	}
+
+	tried.record(backends[2])
+	if got := lb.selectHealthiest(pool, tried); got != backends[1] {
+		t.Errorf("Expected the fastest untried backend, got %s", got.URL)
+	}
+}
+
+// TestBackendRetryable tests a backend's retry override: non-retryable
+// backends are never retried, not even for a GET, and retryable ones are
+// retried for any method
+func TestBackendRetryable(t *testing.T) {
+	never, always := false, true
+	tests := []struct {
+		name      string
+		method    string
+		retryable *bool
+		attempts  int32
+		status    int
+	}{
+		{"default GET", "GET", nil, 2, http.StatusOK},
+		{"default POST", "POST", nil, 1, http.StatusServiceUnavailable},
+		{"non-retryable GET", "GET", &never, 1, http.StatusServiceUnavailable},
+		{"retryable POST", "POST", &always, 2, http.StatusOK},
+	}
+	for _, tt := range tests {
+		var attempts atomic.Int32
+		pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write(body)
+		}), 2)
+		for _, b := range pool.GetBackends() {
+			b.SetRetryable(tt.retryable)
+		}
+
+		w := httptest.NewRecorder()
+		createTestBalancer(pool, NewRoundRobinStrategy()).ServeHTTP(w, httptest.NewRequest(tt.method, "/", strings.NewReader("payload")))
+
+		if attempts.Load() != tt.attempts || w.Code != tt.status {
+			t.Errorf("%s: expected %d attempts and %d, got %d attempts and %d", tt.name, tt.attempts, tt.status, attempts.Load(), w.Code)
+		}
+		if tt.status == http.StatusOK && w.Body.String() != "payload" {
+			t.Errorf("%s: expected the body replayed on retry, got %q", tt.name, w.Body.String())
+		}
+	}
+}
+
+// racingStrategy picks through an inner strategy, then ejects the pick as a
+// health check racing with the request would
+type racingStrategy struct {
+	Strategy
+	victim  *backend.Backend
+	flipped bool
+}
+
+// SelectBackend returns the inner strategy's pick, taking the victim down once it is picked
+func (rs *racingStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	b := rs.Strategy.SelectBackend(pool)
+	if b == rs.victim && !rs.flipped {
+		rs.flipped = true
+		b.SetState(backend.Unhealthy)
+	}
+	return b
+}
+
+// TestSelectedBackendWentDown tests a backend that goes down between selection
+// and use is skipped for a healthy one, without sending it the request and
+// without using up an attempt (retries disabled)
+func TestSelectedBackendWentDown(t *testing.T) {
+	var victimHits, healthyHits atomic.Int64
+	pool := backend.NewPool()
+	for _, hits := range []*atomic.Int64{&victimHits, &healthyHits} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			io.WriteString(w, "ok")
+		}))
+		defer server.Close()
+		u, _ := url.Parse(server.URL)
+		pool.AddBackend(backend.NewBackend(u))
+	}
+	victim := pool.GetBackends()[0]
+
+	lb := createTestBalancer(pool, &racingStrategy{Strategy: NewRoundRobinStrategy(), victim: victim})
+	lb.retryPolicy = nil // A single attempt: the reselection must not consume it
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the request served by the healthy backend, got %d", w.Code)
+	}
+	if victimHits.Load() != 0 || healthyHits.Load() != 1 {
+		t.Errorf("Expected 0 requests to the downed backend and 1 to the healthy one, got %d and %d",
+			victimHits.Load(), healthyHits.Load())
+	}
+
+	// With every backend down the request fails as usual
+	pool.GetBackends()[1].SetState(backend.Unhealthy)
+	w = httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 with no healthy backend, got %d", w.Code)
+	}
+}
+
+// TestSelectedBackendWentDownFailOpen tests a request whose only backend goes
+// down between selection and use is held for fail open, not failed at once
+func TestSelectedBackendWentDownFailOpen(t *testing.T) {
+	var hits atomic.Int64
+	pool := newReplicaPool(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}), 1)
+	victim := pool.GetBackends()[0]
+
+	lb := createTestBalancer(pool, &racingStrategy{Strategy: NewRoundRobinStrategy(), victim: victim})
+	lb.SetFailOpen(2*time.Second, 0)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		victim.SetState(backend.Healthy)
+	}()
+
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the held request served once the backend recovered, got %d", w.Code)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected 1 request to the recovered backend, got %d", hits.Load())
+	}
+}
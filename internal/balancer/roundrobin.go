@@ -21,7 +21,7 @@ func NewRoundRobinStrategy() *RoundRobinStrategy {
 // SelectBackend picks the next backend in round-robin order
 func (rr *RoundRobinStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
 	// Get healthy backends
-	backends := pool.GetHealthyBackends()
+	backends := pool.GetRoutableBackends()
 
 	if len(backends) == 0 {
 		return nil // No healthy backends
@@ -0,0 +1,44 @@
+package balancer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// SetServerTiming adds a Server-Timing header to proxied responses with how long
+// the backend took to respond and an opaque id of the backend that served it.
+// Without a key set by SetServerTimingKey a random one is used, so ids change
+// on restart and differ between instances.
+func (lb *Balancer) SetServerTiming(enabled bool) {
+	if enabled && lb.serverTimingKey == nil {
+		key := make([]byte, 32)
+		rand.Read(key)
+		lb.serverTimingKey = key
+	}
+	lb.serverTiming = enabled
+}
+
+// SetServerTimingKey sets the secret keying Server-Timing backend ids, so
+// instances sharing it report the same id for the same backend
+func (lb *Balancer) SetServerTimingKey(key []byte) {
+	lb.serverTimingKey = key
+}
+
+// serverTimingID returns an opaque, stable id for b: clients can tell backends
+// apart, but without the key can't test guessed addresses against the id
+func (lb *Balancer) serverTimingID(b *backend.Backend) string {
+	mac := hmac.New(sha256.New, lb.serverTimingKey)
+	mac.Write([]byte(b.URL.String()))
+	return hex.EncodeToString(mac.Sum(nil)[:4])
+}
+
+// serverTimingValue formats a Server-Timing entry for a backend response
+func serverTimingValue(id string, d time.Duration) string {
+	return fmt.Sprintf(`backend;dur=%.3f;desc="%s"`, float64(d)/float64(time.Millisecond), id)
+}
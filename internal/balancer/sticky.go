@@ -0,0 +1,79 @@
+package balancer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// Sticky session policies for when the pinned backend is unavailable
+const (
+	StickyRebalance = "rebalance" // Pick a new backend and re-pin the client
+	StickyFail      = "fail"      // Return 503 rather than moving the session
+)
+
+// StickySessions pins clients to a backend with a cookie. The pin lives in
+// the client's cookie, so the balancer holds no per-session state and there
+// is nothing to evict however many sessions clients open.
+type StickySessions struct {
+	CookieName    string        // Cookie carrying the pinned backend
+	TTL           time.Duration // How long a pin lasts (0 = browser session)
+	OnBackendDown string        // StickyRebalance or StickyFail
+}
+
+// SetStickySessions enables cookie-based session affinity (nil disables it)
+func (lb *Balancer) SetStickySessions(s *StickySessions) {
+	lb.sticky = s
+}
+
+// lookup returns the backend pinned by the request's cookie. valid is false if
+// there is no cookie or it has expired; a valid pin to a backend no longer in
+// the pool returns a nil backend.
+func (s *StickySessions) lookup(r *http.Request, pool *backend.Pool) (pinned *backend.Backend, valid bool) {
+	cookie, err := r.Cookie(s.CookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	key, expires, hasExpiry := strings.Cut(cookie.Value, ".")
+	if hasExpiry {
+		expiresAt, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() >= expiresAt {
+			return nil, false // Expired pins get a fresh assignment
+		}
+	}
+
+	for _, b := range pool.GetBackends() {
+		if stickyKey(b) == key {
+			return b, true
+		}
+	}
+	return nil, true
+}
+
+// cookie builds the cookie pinning a client to b
+func (s *StickySessions) cookie(b *backend.Backend) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     s.CookieName,
+		Value:    stickyKey(b),
+		Path:     "/",
+		HttpOnly: true,
+	}
+	if s.TTL > 0 {
+		// The expiry is also in the value so stale cookies are rejected server-side
+		cookie.Value += "." + strconv.FormatInt(time.Now().Add(s.TTL).Unix(), 10)
+		cookie.MaxAge = int(s.TTL.Seconds())
+	}
+	return cookie
+}
+
+// stickyKey is an opaque identifier for a backend that doesn't reveal its address
+func stickyKey(b *backend.Backend) string {
+	sum := sha256.Sum256([]byte(b.URL.String()))
+	return hex.EncodeToString(sum[:8])
+}
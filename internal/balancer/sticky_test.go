@@ -0,0 +1,198 @@
+package balancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// newStickyTestPool creates a pool of backends that answer with their index
+func newStickyTestPool(t *testing.T, n int) (*backend.Pool, []*backend.Backend) {
+	t.Helper()
+	pool := backend.NewPool()
+	var backends []*backend.Backend
+	for i := 0; i < n; i++ {
+		name := strconv.Itoa(i)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+		b := backend.NewBackend(u)
+		pool.AddBackend(b)
+		backends = append(backends, b)
+	}
+	return pool, backends
+}
+
+// stickyRequest sends a request with an optional sticky cookie
+func stickyRequest(lb *Balancer, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	lb.ServeHTTP(w, req)
+	return w
+}
+
+// stickyCookie returns the sticky cookie set by a response, or nil
+func stickyCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "GOBALANCE_BACKEND" {
+			return c
+		}
+	}
+	return nil
+}
+
+// TestStickySessionsPinAndTTL tests clients stay pinned with a Max-Age cookie
+func TestStickySessionsPinAndTTL(t *testing.T) {
+	pool, _ := newStickyTestPool(t, 3)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetStickySessions(&StickySessions{CookieName: "GOBALANCE_BACKEND", TTL: time.Hour, OnBackendDown: StickyRebalance})
+
+	first := stickyRequest(lb, nil)
+	cookie := stickyCookie(first)
+	if cookie == nil {
+		t.Fatal("Expected a sticky cookie on first request")
+	}
+	if cookie.MaxAge != 3600 {
+		t.Errorf("Expected Max-Age 3600, got %d", cookie.MaxAge)
+	}
+
+	// Round-robin would move on; the cookie keeps the client on its backend
+	for i := 0; i < 5; i++ {
+		w := stickyRequest(lb, cookie)
+		if w.Body.String() != first.Body.String() {
+			t.Fatalf("Request %d went to backend %s, expected pinned %s", i, w.Body.String(), first.Body.String())
+		}
+		if stickyCookie(w) != nil {
+			t.Error("Pinned requests shouldn't re-issue the cookie")
+		}
+	}
+}
+
+// TestStickySessionsExpiredCookie tests an expired pin gets a fresh assignment
+func TestStickySessionsExpiredCookie(t *testing.T) {
+	pool, backends := newStickyTestPool(t, 2)
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	sticky := &StickySessions{CookieName: "GOBALANCE_BACKEND", TTL: time.Hour, OnBackendDown: StickyRebalance}
+	lb.SetStickySessions(sticky)
+
+	expired := sticky.cookie(backends[0])
+	expired.Value = stickyKey(backends[0]) + "." + strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	w := stickyRequest(lb, expired)
+	if stickyCookie(w) == nil {
+		t.Error("Expired cookie should be replaced with a fresh assignment")
+	}
+}
+
+// TestStickySessionsBackendDown tests both policies for an unavailable pinned backend
+func TestStickySessionsBackendDown(t *testing.T) {
+	for _, policy := range []string{StickyRebalance, StickyFail} {
+		pool, backends := newStickyTestPool(t, 2)
+		lb := createTestBalancer(pool, NewRoundRobinStrategy())
+		sticky := &StickySessions{CookieName: "GOBALANCE_BACKEND", TTL: time.Hour, OnBackendDown: policy}
+		lb.SetStickySessions(sticky)
+
+		cookie := sticky.cookie(backends[0])
+		backends[0].SetAlive(false)
+
+		w := stickyRequest(lb, cookie)
+		switch policy {
+		case StickyRebalance:
+			if w.Code != http.StatusOK || w.Body.String() != "1" {
+				t.Errorf("rebalance: expected healthy backend 1, got %d %q", w.Code, w.Body.String())
+			}
+			if repinned := stickyCookie(w); repinned == nil || repinned.Value == cookie.Value {
+				t.Error("rebalance: expected the client to be re-pinned")
+			}
+		case StickyFail:
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("fail: expected 503, got %d", w.Code)
+			}
+		}
+	}
+}
+
+// TestStickySessionsKeepBackendCookies tests the sticky cookie is added alongside
+// the backend's own Set-Cookie headers, and other multi-valued headers survive
+func TestStickySessionsKeepBackendCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		w.Header().Add("Link", "</a.css>; rel=preload")
+		w.Header().Add("Link", "</b.js>; rel=preload")
+	}))
+	defer server.Close()
+
+	pool := backend.NewPool()
+	u, _ := url.Parse(server.URL)
+	pool.AddBackend(backend.NewBackend(u))
+	lb := createTestBalancer(pool, NewRoundRobinStrategy())
+	lb.SetStickySessions(&StickySessions{CookieName: "GOBALANCE_BACKEND", OnBackendDown: StickyRebalance})
+
+	w := stickyRequest(lb, nil)
+
+	names := make(map[string]bool)
+	for _, c := range w.Result().Cookies() {
+		names[c.Name] = true
+	}
+	if len(w.Header().Values("Set-Cookie")) != 3 || !names["session"] || !names["theme"] || !names["GOBALANCE_BACKEND"] {
+		t.Errorf("Expected backend cookies and the sticky cookie, got %q", w.Header().Values("Set-Cookie"))
+	}
+	if links := w.Header().Values("Link"); len(links) != 2 {
+		t.Errorf("Expected both Link headers, got %q", links)
+	}
+}
+
+// TestStickySessionsRetryCookies tests cookies from a retried attempt are dropped
+// while the serving backend's cookies and the sticky cookie reach the client
+func TestStickySessionsRetryCookies(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "failed", Value: "1"})
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+	}))
+	defer healthy.Close()
+
+	pool := backend.NewPool()
+	failingURL, _ := url.Parse(failing.URL)
+	healthyURL, _ := url.Parse(healthy.URL)
+	failingBackend := backend.NewBackend(failingURL)
+	healthyBackend := backend.NewBackend(healthyURL)
+	pool.AddBackend(failingBackend)
+	pool.AddBackend(healthyBackend)
+	lb := createTestBalancer(pool, &fixedStrategy{backend: failingBackend})
+	lb.SetStickySessions(&StickySessions{CookieName: "GOBALANCE_BACKEND", OnBackendDown: StickyRebalance})
+
+	w := stickyRequest(lb, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 after retry, got %d", w.Code)
+	}
+	cookies := make(map[string]string)
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	if len(w.Header().Values("Set-Cookie")) != 3 || cookies["session"] != "abc" || cookies["theme"] != "dark" {
+		t.Errorf("Expected 2 backend cookies and the sticky cookie, got %q", w.Header().Values("Set-Cookie"))
+	}
+	if _, exists := cookies["failed"]; exists {
+		t.Error("Cookie from the failed attempt leaked into the response")
+	}
+	if cookies["GOBALANCE_BACKEND"] != stickyKey(healthyBackend) {
+		t.Error("Sticky cookie should pin the backend that served the response")
+	}
+}
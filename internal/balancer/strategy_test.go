@@ -1,11 +1,16 @@
 package balancer
 
 import (
+	"fmt"
+	"math/rand/v2"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/clock"
 )
 
 // TestRoundRobin tests the round-robin strategy
@@ -231,3 +236,752 @@ func TestWeightedRoundRobin(t *testing.T) {
 		t.Errorf("b3: expected ~100, got %d", b3_count)
 	}
 }
+
+// TestWeightedRoundRobinExactCycle tests a fresh strategy gives every backend
+// exactly its weight in each full cycle of totalWeight selections, starting with the first
+func TestWeightedRoundRobinExactCycle(t *testing.T) {
+	for _, weights := range [][]int{{3, 2, 1}, {5, 1, 1}, {1, 1, 1}, {7, 3}, {100, 1}, {4, 6, 9, 1}} {
+		t.Run(fmt.Sprint(weights), func(t *testing.T) {
+			pool := backend.NewPool()
+			want := make(map[*backend.Backend]int)
+			totalWeight := 0
+			for i, weight := range weights {
+				u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 8081+i))
+				b := backend.NewBackend(u)
+				b.SetWeight(weight)
+				pool.AddBackend(b)
+				want[b] = weight
+				totalWeight += weight
+			}
+
+			strategy := NewWeightedRoundRobinStrategy()
+			for cycle := 1; cycle <= 3; cycle++ {
+				got := make(map[*backend.Backend]int)
+				for i := 0; i < totalWeight; i++ {
+					got[strategy.SelectBackend(pool)]++
+				}
+				for b, weight := range want {
+					if got[b] != weight {
+						t.Errorf("Cycle %d: expected %s selected exactly %d times, got %d", cycle, b.URL.Host, weight, got[b])
+					}
+				}
+				for key, wb := range strategy.weightedBackends {
+					if wb.currentWeight != 0 {
+						t.Errorf("Cycle %d: expected current weight of %s back at 0, got %d", cycle, key, wb.currentWeight)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestWeightedRoundRobinDeterministicTies tests equal current weights are
+// broken by URL order, so the sequence is the same whatever the pool order
+func TestWeightedRoundRobinDeterministicTies(t *testing.T) {
+	want := []string{"a:80", "b:80", "c:80", "a:80", "b:80", "c:80", "a:80", "b:80", "c:80"}
+
+	for run, hosts := range [][]string{{"a", "b", "c"}, {"c", "a", "b"}, {"b", "c", "a"}, {"c", "b", "a"}} {
+		for repeat := 0; repeat < 20; repeat++ {
+			pool := backend.NewPool()
+			for _, host := range hosts {
+				u, _ := url.Parse("http://" + host + ":80")
+				pool.AddBackend(backend.NewBackend(u))
+			}
+
+			strategy := NewWeightedRoundRobinStrategy()
+			for i, host := range want {
+				if got := strategy.SelectBackend(pool).URL.Host; got != host {
+					t.Fatalf("Pool order %v, run %d: selection %d expected %s, got %s", hosts, run, i, host, got)
+				}
+			}
+		}
+	}
+}
+
+// TestWeightedRoundRobinFailurePenalty tests a backend that just failed a
+// request gets a reduced share that recovers over the cooldown, without
+// leaving the rotation
+func TestWeightedRoundRobinFailurePenalty(t *testing.T) {
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	failing, steady := backend.NewBackend(u1), backend.NewBackend(u2)
+	pool := backend.NewPool()
+	pool.AddBackend(failing)
+	pool.AddBackend(steady)
+
+	// Far from wall time: failures must be timed on the strategy's clock
+	fake := clock.NewFake(time.Unix(0, 0))
+	strategy := NewWeightedRoundRobinStrategy()
+	strategy.SetFailurePenalty(10 * time.Second)
+	strategy.SetClock(fake)
+
+	share := func() float64 {
+		const selections = 1100
+		picked := 0
+		for i := 0; i < selections; i++ {
+			if strategy.SelectBackend(pool) == failing {
+				picked++
+			}
+		}
+		return float64(picked) / selections
+	}
+	if got := share(); got != 0.5 {
+		t.Fatalf("Expected an even split before any failure, got %.3f", got)
+	}
+
+	failing.RecordRequestFailure()
+	// Weights 10 and 100: the failed backend keeps 1/11 of the traffic
+	if got := share(); got < 0.08 || got > 0.10 {
+		t.Errorf("Expected about 9%% right after the failure, got %.3f", got)
+	}
+	if failing.GetState() != backend.Healthy {
+		t.Errorf("Expected the penalty to leave health alone, got %v", failing.GetState())
+	}
+
+	// Halfway through: weights 55 and 100
+	fake.Advance(5 * time.Second)
+	if got := share(); got < 0.34 || got > 0.37 {
+		t.Errorf("Expected about 35%% halfway through the cooldown, got %.3f", got)
+	}
+
+	fake.Advance(5 * time.Second)
+	if got := share(); got < 0.49 || got > 0.51 {
+		t.Errorf("Expected an even split once the cooldown passed, got %.3f", got)
+	}
+}
+
+// TestWeightedRoundRobinCurrentWeightBounded tests current weights stay within
+// ±totalWeight over 10 million selections, including while membership churns
+func TestWeightedRoundRobinCurrentWeightBounded(t *testing.T) {
+	if testing.Short() {
+		t.Skip("long-running selection loop")
+	}
+	const selections = 10_000_000
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+
+	t.Run("single backend", func(t *testing.T) {
+		pool := backend.NewPool()
+		b := backend.NewBackend(u1)
+		b.SetWeight(100)
+		pool.AddBackend(b)
+
+		strategy := NewWeightedRoundRobinStrategy()
+		for i := 0; i < selections; i++ {
+			if strategy.SelectBackend(pool) != b {
+				t.Fatal("Expected the only backend")
+			}
+		}
+		if cw := strategy.weightedBackends[u1.String()].currentWeight; cw != 0 {
+			t.Errorf("Expected current weight 0 for a single backend, got %d", cw)
+		}
+	})
+
+	t.Run("two backends", func(t *testing.T) {
+		pool := backend.NewPool()
+		b1 := backend.NewBackend(u1)
+		b2 := backend.NewBackend(u2)
+		b1.SetWeight(5)
+		b2.SetWeight(1)
+		pool.AddBackend(b1)
+		pool.AddBackend(b2)
+
+		strategy := NewWeightedRoundRobinStrategy()
+		const totalWeight = 6
+		for i := 0; i < selections; i++ {
+			// b2 leaves and rejoins the pool now and then, taking its current weight with it
+			if i%7919 == 0 {
+				if pool.RemoveBackend(u2.String()) == nil {
+					pool.AddBackend(b2)
+				}
+			}
+			strategy.SelectBackend(pool)
+			for key, wb := range strategy.weightedBackends {
+				if wb.currentWeight > totalWeight || wb.currentWeight < -totalWeight {
+					t.Fatalf("Current weight of %s drifted to %d after %d selections", key, wb.currentWeight, i+1)
+				}
+			}
+		}
+	})
+}
+
+// TestWeightedRandom tests the alias-method weighted random strategy
+func TestWeightedRandom(t *testing.T) {
+	pool := backend.NewPool()
+
+	weights := map[string]int{
+		"localhost:8081": 5,
+		"localhost:8082": 3,
+		"localhost:8083": 2,
+	}
+	for _, host := range []string{"localhost:8081", "localhost:8082", "localhost:8083"} {
+		u, _ := url.Parse("http://" + host)
+		b := backend.NewBackend(u)
+		b.SetWeight(weights[host])
+		pool.AddBackend(b)
+	}
+
+	strategy := NewWeightedRandomStrategy()
+
+	const total = 100000
+	selections := make(map[string]int)
+	for i := 0; i < total; i++ {
+		selected := strategy.SelectBackend(pool)
+		if selected == nil {
+			t.Fatal("Strategy returned nil backend")
+		}
+		selections[selected.URL.Host]++
+	}
+
+	// Expected shares: 50%, 30%, 20% (allow 2 percentage points of variance)
+	for host, weight := range weights {
+		expected := float64(weight) / 10.0
+		actual := float64(selections[host]) / total
+		if actual < expected-0.02 || actual > expected+0.02 {
+			t.Errorf("%s: expected share ~%.2f, got %.3f", host, expected, actual)
+		}
+	}
+}
+
+// TestWeightedRandomRebuildsOnChange tests the alias table follows pool changes
+func TestWeightedRandomRebuildsOnChange(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	b1 := backend.NewBackend(u1)
+	pool.AddBackend(b1)
+
+	strategy := NewWeightedRandomStrategy()
+	if strategy.SelectBackend(pool) != b1 {
+		t.Fatal("Single backend should always be selected")
+	}
+
+	// Adding a backend bumps the pool version and must be picked up
+	u2, _ := url.Parse("http://localhost:8082")
+	b2 := backend.NewBackend(u2)
+	b2.SetWeight(100)
+	pool.AddBackend(b2)
+
+	seenB2 := false
+	for i := 0; i < 100; i++ {
+		if strategy.SelectBackend(pool) == b2 {
+			seenB2 = true
+			break
+		}
+	}
+	if !seenB2 {
+		t.Error("Newly added backend should be selectable")
+	}
+
+	// Health changes don't bump the version but change the healthy set
+	b2.SetAlive(false)
+	for i := 0; i < 100; i++ {
+		if strategy.SelectBackend(pool) != b1 {
+			t.Fatal("Unhealthy backend should not be selected")
+		}
+	}
+}
+
+// weightedBenchmarkPool builds a pool of n backends with varying weights
+func weightedBenchmarkPool(n int) *backend.Pool {
+	pool := backend.NewPool()
+	for i := 0; i < n; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://backend-%d:8080", i))
+		b := backend.NewBackend(u)
+		b.SetWeight(i%10 + 1)
+		pool.AddBackend(b)
+	}
+	return pool
+}
+
+// naiveWeightedSelect sums weights on every selection (baseline for the benchmark)
+func naiveWeightedSelect(pool *backend.Pool) *backend.Backend {
+	backends := pool.GetHealthyBackends()
+	totalWeight := 0
+	for _, b := range backends {
+		totalWeight += b.Weight
+	}
+	r := rand.IntN(totalWeight)
+	for _, b := range backends {
+		r -= b.Weight
+		if r < 0 {
+			return b
+		}
+	}
+	return nil
+}
+
+// BenchmarkWeightedRandomAlias benchmarks alias-method selection
+func BenchmarkWeightedRandomAlias(b *testing.B) {
+	pool := weightedBenchmarkPool(1000)
+	strategy := NewWeightedRandomStrategy()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		strategy.SelectBackend(pool)
+	}
+}
+
+// BenchmarkWeightedRandomNaive benchmarks linear-scan weighted selection
+func BenchmarkWeightedRandomNaive(b *testing.B) {
+	pool := weightedBenchmarkPool(1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveWeightedSelect(pool)
+	}
+}
+
+// TestWeightedRoundRobinFollowsReplacedBackends tests WRR returns reloaded backend instances
+func TestWeightedRoundRobinFollowsReplacedBackends(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	pool.AddBackend(backend.NewBackend(u1))
+
+	strategy := NewWeightedRoundRobinStrategy()
+	strategy.SelectBackend(pool)
+
+	// Reload replaces the instance for the same URL
+	replacement := backend.NewBackend(u1)
+	pool.ReplaceBackends([]*backend.Backend{replacement})
+
+	if selected := strategy.SelectBackend(pool); selected != replacement {
+		t.Error("WRR should select the replacement backend instance after reload")
+	}
+}
+
+// TestLatencyP99PrefersSteadyBackend tests a backend that is mostly fast but spikes
+// occasionally receives less traffic than a consistently-medium backend
+func TestLatencyP99PrefersSteadyBackend(t *testing.T) {
+	pool := backend.NewPool()
+
+	uBimodal, _ := url.Parse("http://localhost:8081")
+	uSteady, _ := url.Parse("http://localhost:8082")
+	bimodal := backend.NewBackend(uBimodal)
+	steady := backend.NewBackend(uSteady)
+	pool.AddBackend(bimodal)
+	pool.AddBackend(steady)
+
+	strategy := NewLatencyP99Strategy()
+
+	// Simulate the latency each backend responds with: 1ms normally with a 500ms
+	// spike every 10th request, versus a steady 20ms
+	served := map[*backend.Backend]int{}
+	for i := 0; i < 500; i++ {
+		b := strategy.SelectBackend(pool)
+		if b == nil {
+			t.Fatal("Strategy returned nil backend")
+		}
+		served[b]++
+		latency := 20 * time.Millisecond
+		if b == bimodal {
+			latency = time.Millisecond
+			if served[b]%10 == 0 {
+				latency = 500 * time.Millisecond
+			}
+		}
+		b.RecordLatency(latency)
+	}
+
+	if served[bimodal] >= served[steady] {
+		t.Errorf("Spiky backend should get less traffic: bimodal=%d steady=%d", served[bimodal], served[steady])
+	}
+	if served[bimodal] < backend.MinLatencySamples {
+		t.Errorf("Each backend should be sampled before being ranked, bimodal got %d", served[bimodal])
+	}
+}
+
+// TestWeightedLatencyFavoursCapacity tests a high-weight backend keeps its
+// traffic despite modestly higher latency, while a slow low-weight backend is
+// deprioritized
+func TestWeightedLatencyFavoursCapacity(t *testing.T) {
+	pool := backend.NewPool()
+	latencies := map[*backend.Backend]time.Duration{}
+	add := func(rawURL string, weight int, latency time.Duration) *backend.Backend {
+		u, _ := url.Parse(rawURL)
+		b := backend.NewBackend(u)
+		b.Weight = weight
+		pool.AddBackend(b)
+		latencies[b] = latency
+		return b
+	}
+	small := add("http://localhost:8081", 1, 20*time.Millisecond)
+	large := add("http://localhost:8082", 5, 30*time.Millisecond)
+	slow := add("http://localhost:8083", 1, 100*time.Millisecond)
+	idle := add("http://localhost:8084", 0, time.Millisecond)
+
+	strategy := NewWeightedLatencyStrategy()
+	served := map[*backend.Backend]int{}
+	for i := 0; i < 100; i++ {
+		b := strategy.SelectBackend(pool)
+		if b == nil {
+			t.Fatal("Strategy returned nil backend")
+		}
+		served[b]++
+		b.RecordLatency(latencies[b])
+	}
+
+	// Each weighted backend is measured once, then the large one wins: 30ms/5 < 20ms/1
+	if served[small] != 1 || served[slow] != 1 || served[large] != 98 {
+		t.Errorf("Expected 1/98/1 for small/large/slow, got %d/%d/%d", served[small], served[large], served[slow])
+	}
+	if served[idle] != 0 {
+		t.Errorf("Expected no traffic for the zero-weight backend, got %d", served[idle])
+	}
+
+	// Load spreads requests: with 5 in flight the large backend scores 36ms, above the small one
+	for i := 0; i < 5; i++ {
+		large.IncrementActiveRequests()
+	}
+	if got := strategy.SelectBackend(pool); got != small {
+		t.Errorf("Expected the loaded large backend to spill over to the small one, got %s", got.URL.Host)
+	}
+
+	// Capacity limit: a backend at it is skipped despite the best score
+	for i := 0; i < 5; i++ {
+		large.DecrementActiveRequests()
+	}
+	strategy.SetMaxActiveRequests(1)
+	large.IncrementActiveRequests()
+	if got := strategy.SelectBackend(pool); got == large {
+		t.Error("Expected a backend at its in-flight limit skipped")
+	}
+}
+
+// TestLatencyP99SpareCapacity tests a saturated backend is skipped despite the best p99
+func TestLatencyP99SpareCapacity(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	fast := backend.NewBackend(u1)
+	slow := backend.NewBackend(u2)
+	pool.AddBackend(fast)
+	pool.AddBackend(slow)
+	for i := 0; i < backend.MinLatencySamples; i++ {
+		fast.RecordLatency(time.Millisecond)
+		slow.RecordLatency(50 * time.Millisecond)
+	}
+
+	strategy := NewLatencyP99Strategy()
+	strategy.SetMaxActiveRequests(2)
+	if got := strategy.SelectBackend(pool); got != fast {
+		t.Fatalf("Expected fast backend, got %s", got.URL.Host)
+	}
+
+	fast.IncrementActiveRequests()
+	fast.IncrementActiveRequests()
+	if got := strategy.SelectBackend(pool); got != slow {
+		t.Errorf("Saturated backend should be skipped, got %s", got.URL.Host)
+	}
+}
+
+// TestCompositeScoreCoefficients tests a busy but fast backend is preferred over an
+// idle slow one only when latency is weighted heavily enough
+func TestCompositeScoreCoefficients(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	fast := backend.NewBackend(u1)
+	slow := backend.NewBackend(u2)
+	pool.AddBackend(fast)
+	pool.AddBackend(slow)
+	fast.RecordLatency(2 * time.Millisecond)
+	slow.RecordLatency(100 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		fast.IncrementActiveRequests()
+	}
+	slow.IncrementActiveRequests()
+
+	// Defaults: fast = 5 + 0.2, slow = 1 + 10
+	strategy := NewCompositeScoreStrategy(DefaultCompositeScoreCoefficients)
+	if got := strategy.SelectBackend(pool); got != fast {
+		t.Errorf("Expected the fast backend despite its connections, got %s", got.URL.Host)
+	}
+
+	// Connections dominate: fast = 5 + 0.02, slow = 1 + 1
+	strategy.SetCoefficients(CompositeScoreCoefficients{Connections: 1, LatencyMs: 0.01})
+	if got := strategy.SelectBackend(pool); got != slow {
+		t.Errorf("Expected the idle backend when latency barely counts, got %s", got.URL.Host)
+	}
+
+	// Weight scales capacity: fast = 5/10 + 0.02
+	fast.SetWeight(10)
+	if got := strategy.SelectBackend(pool); got != fast {
+		t.Errorf("Expected the heavier backend, got %s", got.URL.Host)
+	}
+}
+
+// TestCompositeScoreDeterministic tests fixed inputs always give the same choice,
+// with ties going to the first backend and errors counting against a backend
+func TestCompositeScoreDeterministic(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	b1 := backend.NewBackend(u1)
+	b2 := backend.NewBackend(u2)
+	pool.AddBackend(b1)
+	pool.AddBackend(b2)
+
+	strategy := NewCompositeScoreStrategy(DefaultCompositeScoreCoefficients)
+	for i := 0; i < 100; i++ {
+		if got := strategy.SelectBackend(pool); got != b1 {
+			t.Fatalf("Selection %d: expected tie to go to the first backend, got %s", i, got.URL.Host)
+		}
+	}
+
+	b1.RecordRequestFailure()
+	b2.RecordRequestSuccess()
+	for i := 0; i < 100; i++ {
+		if got := strategy.SelectBackend(pool); got != b2 {
+			t.Fatalf("Selection %d: expected the backend without errors, got %s", i, got.URL.Host)
+		}
+	}
+}
+
+// TestWeightedRoundRobinZeroWeights tests zero-weight backends get no traffic, and that
+// when every healthy backend has zero weight requests fall back to plain round robin
+func TestWeightedRoundRobinZeroWeights(t *testing.T) {
+	pool := backend.NewPool()
+
+	u1, _ := url.Parse("http://localhost:8081")
+	u2, _ := url.Parse("http://localhost:8082")
+	b1 := backend.NewBackend(u1)
+	b2 := backend.NewBackend(u2)
+	b2.Weight = 0
+	pool.AddBackend(b1)
+	pool.AddBackend(b2)
+
+	strategy := NewWeightedRoundRobinStrategy()
+	for i := 0; i < 10; i++ {
+		if got := strategy.SelectBackend(pool); got != b1 {
+			t.Fatalf("Zero-weight backend should get no traffic, got %v", got)
+		}
+	}
+
+	b1.Weight = 0
+	selections := make(map[*backend.Backend]int)
+	for i := 0; i < 10; i++ {
+		got := strategy.SelectBackend(pool)
+		if got == nil {
+			t.Fatal("All-zero weights should fall back to round robin, not return nil")
+		}
+		selections[got]++
+	}
+	if selections[b1] != 5 || selections[b2] != 5 {
+		t.Errorf("Expected even round-robin split, got b1=%d b2=%d", selections[b1], selections[b2])
+	}
+
+	b1.SetAlive(false)
+	b2.SetAlive(false)
+	if got := strategy.SelectBackend(pool); got != nil {
+		t.Errorf("Expected nil with no healthy backends, got %s", got.URL.Host)
+	}
+}
+
+// TestWeightedStrategiesFollowRuntimeMembership tests backends added at runtime are
+// selectable immediately and removed ones drop out of rotation
+func TestWeightedStrategiesFollowRuntimeMembership(t *testing.T) {
+	for _, strategy := range []Strategy{NewWeightedRoundRobinStrategy(), NewWeightedRandomStrategy()} {
+		pool := backend.NewPool()
+		u1, _ := url.Parse("http://localhost:8081")
+		u2, _ := url.Parse("http://localhost:8082")
+		b1 := backend.NewBackend(u1)
+		pool.AddBackend(b1)
+		strategy.SelectBackend(pool) // Build cached state for the one-backend pool
+
+		b2 := backend.NewBackend(u2)
+		b2.SetWeight(10)
+		pool.AddBackend(b2)
+
+		seen := make(map[*backend.Backend]int)
+		for i := 0; i < 100; i++ {
+			seen[strategy.SelectBackend(pool)]++
+		}
+		if seen[b2] == 0 {
+			t.Errorf("%s: backend added at runtime was never selected", strategy.Name())
+		}
+
+		pool.RemoveBackend(u1.String())
+		for i := 0; i < 20; i++ {
+			if got := strategy.SelectBackend(pool); got != b2 {
+				t.Fatalf("%s: removed backend still in rotation", strategy.Name())
+			}
+		}
+	}
+}
+
+// TestWeightedRoundRobinIgnoresDrainingDuplicate tests a backend re-added while its
+// old instance is still draining gets traffic
+func TestWeightedRoundRobinIgnoresDrainingDuplicate(t *testing.T) {
+	pool := backend.NewPool()
+	pool.SetDrainTimeout(time.Minute)
+
+	u1, _ := url.Parse("http://localhost:8081")
+	old := backend.NewBackend(u1)
+	pool.AddBackend(old)
+	old.IncrementActiveRequests() // Keeps it draining once removed
+
+	strategy := NewWeightedRoundRobinStrategy()
+	strategy.SelectBackend(pool)
+
+	pool.ReplaceBackends(nil)
+	readded := backend.NewBackend(u1)
+	pool.ReplaceBackends([]*backend.Backend{readded})
+
+	if got := strategy.SelectBackend(pool); got != readded {
+		t.Errorf("Expected the re-added backend, got %v", got)
+	}
+}
+
+// newZonedBackend creates a backend tagged with zone
+func newZonedBackend(rawURL, zone string) *backend.Backend {
+	u, _ := url.Parse(rawURL)
+	b := backend.NewBackend(u)
+	b.Tags = map[string]string{ZoneTag: zone}
+	return b
+}
+
+// TestLocalityPrefersLocalZone tests local-zone backends take all traffic and
+// other zones are used only once every local backend is down
+func TestLocalityPrefersLocalZone(t *testing.T) {
+	pool := backend.NewPool()
+	local1 := newZonedBackend("http://localhost:8081", "zone-a")
+	local2 := newZonedBackend("http://localhost:8082", "zone-a")
+	remote1 := newZonedBackend("http://localhost:8083", "zone-b")
+	remote2 := newZonedBackend("http://localhost:8084", "")
+	for _, b := range []*backend.Backend{remote1, local1, remote2, local2} {
+		pool.AddBackend(b)
+	}
+
+	strategy := NewLocalityStrategy("zone-a", NewRoundRobinStrategy(), NewRoundRobinStrategy())
+	counts := func() map[*backend.Backend]int {
+		selections := make(map[*backend.Backend]int)
+		for i := 0; i < 100; i++ {
+			selections[strategy.SelectBackend(pool)]++
+		}
+		return selections
+	}
+
+	if got := counts(); got[local1] != 50 || got[local2] != 50 {
+		t.Errorf("Expected traffic split over local backends only, got %v", got)
+	}
+
+	local1.SetAlive(false)
+	if got := counts(); got[local2] != 100 {
+		t.Errorf("Expected the remaining local backend to take all traffic, got %v", got)
+	}
+
+	local2.SetAlive(false)
+	if got := counts(); got[remote1] != 50 || got[remote2] != 50 {
+		t.Errorf("Expected spillover to the other zones, got %v", got)
+	}
+
+	local1.SetAlive(true)
+	if got := counts(); got[local1] != 100 {
+		t.Errorf("Expected traffic back on the recovered local backend, got %v", got)
+	}
+}
+
+// TestLocalitySpillsWhenSaturated tests requests spill to other zones once
+// every local backend is at the in-flight limit, and come back as load drops
+func TestLocalitySpillsWhenSaturated(t *testing.T) {
+	pool := backend.NewPool()
+	local1 := newZonedBackend("http://localhost:8081", "zone-a")
+	local2 := newZonedBackend("http://localhost:8082", "zone-a")
+	remote := newZonedBackend("http://localhost:8083", "zone-b")
+	for _, b := range []*backend.Backend{local1, local2, remote} {
+		pool.AddBackend(b)
+	}
+
+	strategy := NewLocalityStrategy("zone-a", NewLeastConnectionsStrategy(), NewRoundRobinStrategy())
+	strategy.SetMaxActiveRequests(2)
+
+	local1.IncrementActiveRequests()
+	local1.IncrementActiveRequests()
+	if got := strategy.SelectBackend(pool); got != local2 {
+		t.Fatalf("Expected the local backend with spare capacity, got %v", got)
+	}
+
+	local2.IncrementActiveRequests()
+	local2.IncrementActiveRequests()
+	if got := strategy.SelectBackend(pool); got != remote {
+		t.Fatalf("Expected spillover with every local backend saturated, got %v", got)
+	}
+
+	remote.SetAlive(false)
+	if got := strategy.SelectBackend(pool); got != local1 && got != local2 {
+		t.Fatalf("Expected a saturated local backend rather than none, got %v", got)
+	}
+	remote.SetAlive(true)
+
+	local2.DecrementActiveRequests()
+	if got := strategy.SelectBackend(pool); got != local2 {
+		t.Errorf("Expected traffic back in the local zone once it has capacity, got %v", got)
+	}
+}
+
+// TestLocalityConcurrentPoolChanges tests requests never spill over while a
+// local backend is available, even as the zone split is rebuilt under them
+func TestLocalityConcurrentPoolChanges(t *testing.T) {
+	pool := backend.NewPool()
+	local := newZonedBackend("http://localhost:8081", "zone-a")
+	remote := newZonedBackend("http://localhost:8082", "zone-b")
+	pool.AddBackend(local)
+	pool.AddBackend(remote)
+	strategy := NewLocalityStrategy("zone-a", NewRoundRobinStrategy(), NewRoundRobinStrategy())
+
+	done := make(chan struct{})
+	var spilled atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if strategy.SelectBackend(pool) != local {
+					spilled.Add(1)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20000; i++ {
+		pool.ReplaceBackends([]*backend.Backend{local, remote}) // Same members, new version
+	}
+	close(done)
+	wg.Wait()
+	if n := spilled.Load(); n != 0 {
+		t.Errorf("Expected every request on the local backend, %d spilled over", n)
+	}
+}
+
+// TestLocalityFollowsPoolChanges tests zone membership is rebuilt when the pool changes
+func TestLocalityFollowsPoolChanges(t *testing.T) {
+	pool := backend.NewPool()
+	pool.AddBackend(newZonedBackend("http://localhost:8081", "zone-b"))
+
+	strategy := NewLocalityStrategy("zone-a", NewWeightedRoundRobinStrategy(), NewWeightedRoundRobinStrategy())
+	if got := strategy.SelectBackend(pool); got == nil || got.URL.Port() != "8081" {
+		t.Fatalf("Expected spillover to the only backend, got %v", got)
+	}
+
+	local := newZonedBackend("http://localhost:8082", "zone-a")
+	pool.ReplaceBackends([]*backend.Backend{newZonedBackend("http://localhost:8081", "zone-b"), local})
+	for i := 0; i < 10; i++ {
+		if got := strategy.SelectBackend(pool); got != local {
+			t.Fatalf("Expected the newly added local backend, got %v", got)
+		}
+	}
+
+	pool.ReplaceBackends([]*backend.Backend{newZonedBackend("http://localhost:8081", "zone-b")})
+	if got := strategy.SelectBackend(pool); got == nil || got.URL.Port() != "8081" {
+		t.Errorf("Expected spillover once the local backend is removed, got %v", got)
+	}
+}
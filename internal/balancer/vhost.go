@@ -0,0 +1,109 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// VirtualHost routes requests whose Host matches Pattern to a backend group
+type VirtualHost struct {
+	Pattern  string        // Exact host ("api.example.com") or wildcard ("*.example.com")
+	Pool     *backend.Pool // Backends serving this host
+	Strategy Strategy      // Selection strategy for this group (strategies keep per-pool state)
+}
+
+// vhostTable is the host routing table: exact matches first, then wildcards by longest suffix
+type vhostTable struct {
+	exact     map[string]*VirtualHost
+	wildcards []*VirtualHost // Sorted by descending suffix length
+}
+
+// newVhostTable builds a routing table from virtual hosts
+func newVhostTable(vhosts []VirtualHost) *vhostTable {
+	table := &vhostTable{exact: make(map[string]*VirtualHost)}
+	for i := range vhosts {
+		vh := &vhosts[i]
+		pattern := strings.ToLower(vh.Pattern)
+		if strings.HasPrefix(pattern, "*.") {
+			table.wildcards = append(table.wildcards, vh)
+		} else {
+			table.exact[pattern] = vh
+		}
+	}
+	sort.SliceStable(table.wildcards, func(i, j int) bool {
+		return len(table.wildcards[i].Pattern) > len(table.wildcards[j].Pattern)
+	})
+	return table
+}
+
+// match returns the virtual host for a request host, or nil if none matches
+func (t *vhostTable) match(host string) *VirtualHost {
+	host = normalizeHost(host)
+	if vh, exists := t.exact[host]; exists {
+		return vh
+	}
+	for _, vh := range t.wildcards {
+		// "*.example.com" matches any subdomain but not example.com itself
+		if strings.HasSuffix(host, strings.ToLower(vh.Pattern[1:])) {
+			return vh
+		}
+	}
+	return nil
+}
+
+// normalizeHost lowercases a Host header and strips any port
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// SetVirtualHosts routes requests to backend groups by Host header.
+// Requests matching no virtual host go to the default route (see SetDefaultRoute).
+func (lb *Balancer) SetVirtualHosts(vhosts []VirtualHost) {
+	lb.vhosts = newVhostTable(vhosts)
+}
+
+// SetSNIRoutes routes TLS requests to backend groups by the server name the
+// client sent in its ClientHello (SNI). SNI routes are matched before Host
+// header routes, so a client can't reach another group by sending a different
+// Host over the same connection. Pattern is matched like VirtualHost.Pattern.
+func (lb *Balancer) SetSNIRoutes(routes []VirtualHost) {
+	lb.sniRoutes = newVhostTable(routes)
+}
+
+// SetDefaultRoute sets the group serving requests that match no SNI route or
+// virtual host, in place of the balancer's own pool. A nil route rejects them
+// with 404, so unmatched traffic is told apart from a group with no healthy
+// backends (503).
+func (lb *Balancer) SetDefaultRoute(route *VirtualHost) {
+	lb.defaultRoute = route
+	lb.rejectUnmatched = route == nil
+}
+
+// route picks the backend pool and strategy for a request, or a nil pool if
+// it matches no route and unmatched requests are rejected
+func (lb *Balancer) route(r *http.Request) (*backend.Pool, Strategy) {
+	if lb.sniRoutes != nil && r.TLS != nil && r.TLS.ServerName != "" {
+		if vh := lb.sniRoutes.match(r.TLS.ServerName); vh != nil {
+			return vh.Pool, vh.Strategy
+		}
+	}
+	if lb.vhosts != nil {
+		if vh := lb.vhosts.match(r.Host); vh != nil {
+			return vh.Pool, vh.Strategy
+		}
+	}
+	switch {
+	case lb.defaultRoute != nil:
+		return lb.defaultRoute.Pool, lb.defaultRoute.Strategy
+	case lb.rejectUnmatched:
+		return nil, nil
+	}
+	return lb.pool, lb.strategy
+}
@@ -0,0 +1,200 @@
+package balancer
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// namedBackendPool creates a pool with one backend that answers with name
+func namedBackendPool(t *testing.T, name string) *backend.Pool {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name))
+	}))
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	pool := backend.NewPool()
+	pool.AddBackend(backend.NewBackend(u))
+	return pool
+}
+
+// TestVirtualHostRouting tests requests are routed to backend groups by Host header
+func TestVirtualHostRouting(t *testing.T) {
+	balancer := createTestBalancer(namedBackendPool(t, "default"), NewRoundRobinStrategy())
+	balancer.SetVirtualHosts([]VirtualHost{
+		{Pattern: "api.example.com", Pool: namedBackendPool(t, "api"), Strategy: NewRoundRobinStrategy()},
+		{Pattern: "*.static.example.com", Pool: namedBackendPool(t, "static"), Strategy: NewRoundRobinStrategy()},
+		{Pattern: "*.example.com", Pool: namedBackendPool(t, "wildcard"), Strategy: NewRoundRobinStrategy()},
+	})
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"api.example.com", "api"},
+		{"API.Example.com:8443", "api"},      // Case and port are ignored
+		{"img.static.example.com", "static"}, // Longest wildcard wins
+		{"www.example.com", "wildcard"},
+		{"example.com", "default"}, // Wildcard doesn't match the apex
+		{"other.org", "default"},   // No match falls back to the default pool
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+
+		if w.Body.String() != tt.want {
+			t.Errorf("Host %s: expected group %s, got %q", tt.host, tt.want, w.Body.String())
+		}
+	}
+}
+
+// TestVirtualHostGroupStrategies tests each group balances with its own strategy
+func TestVirtualHostGroupStrategies(t *testing.T) {
+	// Every backend answers with its own address so the test can count picks
+	newGroup := func(weights ...int) *backend.Pool {
+		pool := backend.NewPool()
+		for _, weight := range weights {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()))
+			}))
+			t.Cleanup(server.Close)
+			u, _ := url.Parse(server.URL)
+			b := backend.NewBackend(u)
+			b.SetWeight(weight)
+			pool.AddBackend(b)
+		}
+		return pool
+	}
+	canary := newGroup(3, 1)
+	stable := newGroup(3, 1)
+
+	balancer := createTestBalancer(namedBackendPool(t, "default"), NewRoundRobinStrategy())
+	balancer.SetVirtualHosts([]VirtualHost{
+		{Pattern: "canary.example.com", Pool: canary, Strategy: NewRoundRobinStrategy()},
+		{Pattern: "stable.example.com", Pool: stable, Strategy: NewWeightedRoundRobinStrategy()},
+	})
+
+	picks := func(host string) map[string]int {
+		counts := make(map[string]int)
+		for i := 0; i < 40; i++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = host
+			w := httptest.NewRecorder()
+			balancer.ServeHTTP(w, req)
+			counts[w.Body.String()]++
+		}
+		return counts
+	}
+
+	heavy, light := canary.GetBackends()[0].URL.Host, canary.GetBackends()[1].URL.Host
+	if got := picks("canary.example.com"); got[heavy] != 20 || got[light] != 20 {
+		t.Errorf("Round-robin group should ignore weights and split evenly, got %v", got)
+	}
+	heavy, light = stable.GetBackends()[0].URL.Host, stable.GetBackends()[1].URL.Host
+	if got := picks("stable.example.com"); got[heavy] != 30 || got[light] != 10 {
+		t.Errorf("Weighted group should split 3:1, got %v", got)
+	}
+}
+
+// TestSNIRouting tests TLS connections are routed by the server name in the
+// handshake, ahead of the Host header, and fall back to Host routing otherwise
+func TestSNIRouting(t *testing.T) {
+	balancer := createTestBalancer(namedBackendPool(t, "default"), NewRoundRobinStrategy())
+	balancer.SetSNIRoutes([]VirtualHost{
+		{Pattern: "a.example.com", Pool: namedBackendPool(t, "A"), Strategy: NewRoundRobinStrategy()},
+		{Pattern: "*.b.example.com", Pool: namedBackendPool(t, "B"), Strategy: NewRoundRobinStrategy()},
+	})
+	balancer.SetVirtualHosts([]VirtualHost{
+		{Pattern: "api.example.com", Pool: namedBackendPool(t, "api"), Strategy: NewRoundRobinStrategy()},
+	})
+
+	server := httptest.NewUnstartedServer(balancer)
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		serverName string
+		host       string
+		want       string
+	}{
+		{"a.example.com", "a.example.com", "A"},
+		{"a.example.com", "api.example.com", "A"}, // SNI wins over the Host header
+		{"x.b.example.com", "x.b.example.com", "B"},
+		{"c.example.com", "api.example.com", "api"}, // Unrouted SNI falls back to Host
+		{"c.example.com", "c.example.com", "default"},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: tt.serverName, InsecureSkipVerify: true},
+		}}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Host = tt.host
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("SNI %s: request failed: %v", tt.serverName, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != tt.want {
+			t.Errorf("SNI %s, Host %s: expected group %s, got %q", tt.serverName, tt.host, tt.want, body)
+		}
+	}
+
+	// Plain HTTP has no server name: Host routing applies
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "a.example.com"
+	w := httptest.NewRecorder()
+	balancer.ServeHTTP(w, req)
+	if w.Body.String() != "default" {
+		t.Errorf("Expected plain HTTP to ignore SNI routes, got %q", w.Body.String())
+	}
+}
+
+// TestDefaultRoute tests unmatched requests go to the default group, and are
+// rejected with 404 (not the 503 of a group without backends) when there is none
+func TestDefaultRoute(t *testing.T) {
+	emptyGroup := backend.NewPool()
+	balancer := createTestBalancer(namedBackendPool(t, "top-level"), NewRoundRobinStrategy())
+	balancer.SetVirtualHosts([]VirtualHost{
+		{Pattern: "api.example.com", Pool: namedBackendPool(t, "api"), Strategy: NewRoundRobinStrategy()},
+		{Pattern: "down.example.com", Pool: emptyGroup, Strategy: NewRoundRobinStrategy()},
+	})
+	serve := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		balancer.ServeHTTP(w, req)
+		return w
+	}
+
+	balancer.SetDefaultRoute(&VirtualHost{Pattern: "*", Pool: namedBackendPool(t, "fallback"), Strategy: NewRoundRobinStrategy()})
+	if w := serve("other.org"); w.Body.String() != "fallback" {
+		t.Errorf("Expected the default group to serve unmatched hosts, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("api.example.com"); w.Body.String() != "api" {
+		t.Errorf("Expected matched hosts to keep their group, got %q", w.Body.String())
+	}
+
+	balancer.SetDefaultRoute(nil)
+	if w := serve("other.org"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unmatched hosts without a default, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("down.example.com"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a matched group without backends, got %d", w.Code)
+	}
+	if w := serve("api.example.com"); w.Body.String() != "api" {
+		t.Errorf("Expected matched hosts still served, got %q", w.Body.String())
+	}
+}
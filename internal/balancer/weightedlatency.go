@@ -0,0 +1,82 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// WeightedLatencyStrategy routes to the backend with the lowest moving-average
+// latency per unit of weight, so a high-capacity backend keeps its traffic
+// while only modestly slower than a small one:
+//
+//	score = ewmaLatency × (activeRequests + 1) / weight
+//
+// Backends without a latency sample yet are tried first so they get measured.
+// Zero-weight backends get no traffic, and ties go to the backend listed first.
+type WeightedLatencyStrategy struct {
+	maxActive int64 // In-flight requests at which a backend has no spare capacity (0 = unlimited, atomic)
+}
+
+// NewWeightedLatencyStrategy creates a new weighted least-response-time strategy
+func NewWeightedLatencyStrategy() *WeightedLatencyStrategy {
+	return &WeightedLatencyStrategy{}
+}
+
+// SetMaxActiveRequests sets how many in-flight requests a backend may have before
+// it is passed over in favour of slower backends with spare capacity
+func (wl *WeightedLatencyStrategy) SetMaxActiveRequests(n int64) {
+	atomic.StoreInt64(&wl.maxActive, n)
+}
+
+// SelectBackend picks the backend with the lowest weighted latency among those with spare capacity
+func (wl *WeightedLatencyStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	backends := pool.GetRoutableBackends()
+
+	if len(backends) == 0 {
+		return nil
+	}
+
+	maxActive := atomic.LoadInt64(&wl.maxActive)
+	var selected, unsampled *backend.Backend
+	var bestScore float64
+	for _, b := range backends {
+		if b.Weight <= 0 {
+			continue // Zero weight: no traffic
+		}
+		active := b.GetActiveRequests()
+		if maxActive > 0 && active >= maxActive {
+			continue // No spare capacity
+		}
+
+		latency, ok := b.LatencyEWMA()
+		if !ok {
+			if unsampled == nil || active < unsampled.GetActiveRequests() {
+				unsampled = b
+			}
+			continue
+		}
+
+		// Scale by load so concurrent requests spread instead of piling onto one backend
+		score := float64(latency) / float64(time.Millisecond) * float64(active+1) / float64(b.Weight)
+		if selected == nil || score < bestScore {
+			selected = b
+			bestScore = score
+		}
+	}
+
+	if unsampled != nil {
+		return unsampled
+	}
+	if selected != nil {
+		return selected
+	}
+	// Everyone is at capacity or weighted zero: fall back to the least loaded backend
+	return NewLeastConnectionsStrategy().SelectBackend(pool)
+}
+
+// Name returns the strategy name
+func (wl *WeightedLatencyStrategy) Name() string {
+	return "weighted-latency"
+}
@@ -0,0 +1,133 @@
+package balancer
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// aliasTable supports O(1) weighted sampling (Vose's alias method)
+type aliasTable struct {
+	backends []*backend.Backend
+	weights  []int     // Weights the table was built from
+	prob     []float64 // Probability of keeping column i
+	alias    []int     // Column to use when column i is not kept
+}
+
+// WeightedRandomStrategy picks backends at random in proportion to their weights.
+// The alias table is rebuilt only when the pool version or healthy set changes.
+type WeightedRandomStrategy struct {
+	table   *aliasTable
+	version uint64 // Pool version the table was built for
+	mux     sync.Mutex
+}
+
+// NewWeightedRandomStrategy creates a new weighted random strategy
+func NewWeightedRandomStrategy() *WeightedRandomStrategy {
+	return &WeightedRandomStrategy{}
+}
+
+// SelectBackend picks a backend with probability proportional to its weight
+func (wr *WeightedRandomStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	backends := pool.GetRoutableBackends()
+
+	if len(backends) == 0 {
+		return nil
+	}
+
+	wr.mux.Lock()
+	version := pool.Version()
+	if wr.table == nil || wr.version != version || !wr.table.matches(backends) {
+		wr.table = newAliasTable(backends)
+		wr.version = version
+	}
+	table := wr.table
+	wr.mux.Unlock()
+
+	return table.pick()
+}
+
+// Name returns the strategy name
+func (wr *WeightedRandomStrategy) Name() string {
+	return "weighted-random"
+}
+
+// newAliasTable builds an alias table from the backends' weights
+func newAliasTable(backends []*backend.Backend) *aliasTable {
+	n := len(backends)
+	t := &aliasTable{
+		backends: backends,
+		weights:  make([]int, n),
+		prob:     make([]float64, n),
+		alias:    make([]int, n),
+	}
+
+	totalWeight := 0
+	for i, b := range backends {
+		t.weights[i] = b.Weight
+		totalWeight += b.Weight
+	}
+
+	// Scale probabilities so the average column holds exactly 1.0
+	scaled := make([]float64, n)
+	var small, large []int
+	for i, w := range t.weights {
+		scaled[i] = float64(w) * float64(n) / float64(totalWeight)
+		if scaled[i] < 1.0 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	// Pair each under-full column with an over-full one
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		t.prob[s] = scaled[s]
+		t.alias[s] = l
+
+		scaled[l] = scaled[l] + scaled[s] - 1.0
+		if scaled[l] < 1.0 {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+
+	// Remaining columns are full (modulo floating point error)
+	for _, i := range large {
+		t.prob[i] = 1.0
+	}
+	for _, i := range small {
+		t.prob[i] = 1.0
+	}
+
+	return t
+}
+
+// matches returns true if the table was built from the same backends and weights
+func (t *aliasTable) matches(backends []*backend.Backend) bool {
+	if len(backends) != len(t.backends) {
+		return false
+	}
+	for i, b := range backends {
+		if b != t.backends[i] || b.Weight != t.weights[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pick samples a backend in O(1)
+func (t *aliasTable) pick() *backend.Backend {
+	i := rand.IntN(len(t.backends))
+	if rand.Float64() < t.prob[i] {
+		return t.backends[i]
+	}
+	return t.backends[t.alias[i]]
+}
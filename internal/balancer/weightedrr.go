@@ -2,22 +2,59 @@ package balancer
 
 import (
 	"math"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/clock"
 )
 
+// failurePenaltyFloor is the fraction of its weight a backend keeps right
+// after a failed request; the rest comes back linearly over the cooldown
+const failurePenaltyFloor = 0.1
+
+// penaltyScale multiplies weights while failure penalties are on, so that
+// reduced weights keep their resolution (scaling every weight alike leaves
+// the selection sequence unchanged)
+const penaltyScale = 100
+
 // WeightedBackend tracks current weight for smooth weighted round robin
 type WeightedBackend struct {
 	backend       *backend.Backend
 	weight        int
 	currentWeight int
+	failures      int64     // Backend failure count when last selected from
+	failedAt      time.Time // When a new failure was first seen, on the strategy's clock
 }
 
 // WeightedRoundRobinStrategy distributes requests using smooth weighted round robin (Nginx algorithm)
 // FIX #7: Implemented smooth weighted round robin for better distribution
+// Zero-weight backends get no traffic; if every healthy backend has weight 0,
+// requests are spread with plain round robin rather than failing with 503.
+//
+// No warm-up is needed: current weights start at 0, and while the set of
+// routable backends and their weights stay the same, every run of totalWeight
+// selections gives each backend exactly its weight, the first run included.
+// A failure penalty changes the weight on every selection while it recovers,
+// so during the cooldown shares are only approximate.
+//
+// Backends are visited in URL order and ties go to the first visited, so the
+// selection sequence is the same on every run and every instance.
+//
+// With a failure penalty set, a backend whose request just failed drops to a
+// tenth of its weight and recovers linearly over the cooldown, staying
+// routable throughout. Failures are noticed from the backend's failure count
+// and timed with the strategy's own clock.
 type WeightedRoundRobinStrategy struct {
 	weightedBackends map[string]*WeightedBackend
+	order            []*WeightedBackend  // weightedBackends sorted by URL, the visiting order
+	version          uint64              // Pool version weightedBackends was built for
+	synced           bool                // False until the first sync with a pool
+	fallback         *RoundRobinStrategy // Used when all healthy backends have zero weight
+	penalty          time.Duration       // Recovery window after a failed request (0 = no penalty)
+	clock            clock.Clock         // Time source for penalties (a fake in tests)
 	mux              sync.RWMutex
 }
 
@@ -25,60 +62,65 @@ type WeightedRoundRobinStrategy struct {
 func NewWeightedRoundRobinStrategy() *WeightedRoundRobinStrategy {
 	return &WeightedRoundRobinStrategy{
 		weightedBackends: make(map[string]*WeightedBackend),
+		fallback:         NewRoundRobinStrategy(),
+		clock:            clock.Real,
 	}
 }
 
-// SelectBackend picks backend using smooth weighted round-robin (Nginx algorithm)
-func (wrr *WeightedRoundRobinStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
-	backends := pool.GetHealthyBackends()
-
-	if len(backends) == 0 {
-		return nil
-	}
+// SetFailurePenalty reduces the share of a backend that failed a request
+// within the last cooldown (0 = off)
+func (wrr *WeightedRoundRobinStrategy) SetFailurePenalty(cooldown time.Duration) {
+	wrr.mux.Lock()
+	defer wrr.mux.Unlock()
+	wrr.penalty = cooldown
+}
 
+// SetClock replaces the time source (for tests)
+func (wrr *WeightedRoundRobinStrategy) SetClock(c clock.Clock) {
 	wrr.mux.Lock()
 	defer wrr.mux.Unlock()
+	wrr.clock = c
+}
 
-	// Initialize or update weighted backends
-	for _, b := range backends {
-		key := b.URL.String()
-		if _, exists := wrr.weightedBackends[key]; !exists {
-			wrr.weightedBackends[key] = &WeightedBackend{
-				backend:       b,
-				weight:        b.Weight,
-				currentWeight: 0,
-			}
-		} else {
-			// Update weight in case it changed
-			wrr.weightedBackends[key].weight = b.Weight
-		}
-	}
+// SelectBackend picks backend using smooth weighted round-robin (Nginx algorithm)
+func (wrr *WeightedRoundRobinStrategy) SelectBackend(pool *backend.Pool) *backend.Backend {
+	wrr.mux.Lock()
+	defer wrr.mux.Unlock()
 
-	// Remove weighted backends that are no longer in the pool
-	for key := range wrr.weightedBackends {
-		found := false
-		for _, b := range backends {
-			if b.URL.String() == key {
-				found = true
-				break
-			}
-		}
-		if !found {
-			delete(wrr.weightedBackends, key)
-		}
+	// Rebuild weighted backends only when pool membership changed
+	if version := pool.Version(); !wrr.synced || version != wrr.version {
+		wrr.sync(pool)
+		wrr.version = version
+		wrr.synced = true
 	}
 
-	// Smooth weighted round robin algorithm
+	// Smooth weighted round robin algorithm (healthy backends only)
 	totalWeight := 0
+	sumCurrentWeight := 0 // Sum of participants' current weights after this round's increase
 	var selected *WeightedBackend
 	maxCurrentWeight := math.MinInt
+	var now time.Time
+	if wrr.penalty > 0 {
+		now = wrr.clock.Now()
+	}
+
+	for _, wb := range wrr.order {
+		if !wb.backend.IsRoutable() {
+			continue
+		}
+
+		// Pick up weight changes and failure penalties
+		wb.weight = wrr.effectiveWeight(wb, now)
+		if wb.weight <= 0 {
+			continue // Zero weight: no traffic
+		}
 
-	for _, wb := range wrr.weightedBackends {
 		// Increase current weight by configured weight
 		wb.currentWeight += wb.weight
 		totalWeight += wb.weight
+		sumCurrentWeight += wb.currentWeight
 
-		// Select backend with highest current weight
+		// Select backend with highest current weight (first in order on ties)
 		if wb.currentWeight > maxCurrentWeight {
 			maxCurrentWeight = wb.currentWeight
 			selected = wb
@@ -88,10 +130,78 @@ func (wrr *WeightedRoundRobinStrategy) SelectBackend(pool *backend.Pool) *backen
 	if selected != nil {
 		// Decrease selected backend's current weight by total weight
 		selected.currentWeight -= totalWeight
+
+		// Participants' current weights sum to zero, which keeps each within
+		// ±totalWeight. Backends leaving or rejoining (health, weight changes)
+		// break that, so fold the drift back in before it can accumulate.
+		if drift := sumCurrentWeight - totalWeight; drift != 0 {
+			selected.currentWeight -= drift
+		}
 		return selected.backend
 	}
 
-	return nil
+	// Healthy backends exist but all have zero weight
+	return wrr.fallback.SelectBackend(pool)
+}
+
+// effectiveWeight returns wb's weight, scaled and reduced for a recent failed
+// request while failure penalties are on (caller holds lock)
+func (wrr *WeightedRoundRobinStrategy) effectiveWeight(wb *WeightedBackend, now time.Time) int {
+	weight := wb.backend.Weight
+	if wrr.penalty <= 0 || weight <= 0 {
+		return weight
+	}
+	weight *= penaltyScale
+
+	if failures := wb.backend.FailureCount(); failures != wb.failures {
+		if failures > wb.failures {
+			wb.failedAt = now
+		}
+		wb.failures = failures
+	}
+	if wb.failedAt.IsZero() {
+		return weight
+	}
+	elapsed := max(now.Sub(wb.failedAt), 0)
+	if elapsed >= wrr.penalty {
+		return weight
+	}
+	factor := failurePenaltyFloor + (1-failurePenaltyFloor)*float64(elapsed)/float64(wrr.penalty)
+	return max(1, int(float64(weight)*factor))
+}
+
+// sync rebuilds weighted backends and their visiting order from the pool,
+// keeping current weights of backends that are still present (caller holds lock)
+func (wrr *WeightedRoundRobinStrategy) sync(pool *backend.Pool) {
+	backends := pool.GetBackends()
+	weightedBackends := make(map[string]*WeightedBackend, len(backends))
+
+	for _, b := range backends {
+		if b.GetState() == backend.Draining {
+			continue // Removed from config; may share its URL with a re-added backend
+		}
+		key := b.URL.String()
+		if wb, exists := wrr.weightedBackends[key]; exists {
+			wb.backend = b // Instance may have been replaced on reload
+			weightedBackends[key] = wb
+			continue
+		}
+		weightedBackends[key] = &WeightedBackend{
+			backend:       b,
+			weight:        b.Weight,
+			currentWeight: 0,
+			failures:      b.FailureCount(), // Only failures from now on are penalised
+		}
+	}
+
+	wrr.weightedBackends = weightedBackends
+	wrr.order = wrr.order[:0]
+	for _, wb := range weightedBackends {
+		wrr.order = append(wrr.order, wb)
+	}
+	slices.SortFunc(wrr.order, func(a, b *WeightedBackend) int {
+		return strings.Compare(a.backend.URL.String(), b.backend.URL.String())
+	})
 }
 
 // Name returns the strategy name
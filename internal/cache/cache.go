@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a cached backend response
+type Entry struct {
+	StatusCode int         // Response status code
+	Header     http.Header // Response headers (copy)
+	Body       []byte      // Response body
+	StoredAt   time.Time   // Time the response was stored
+	ExpiresAt  time.Time   // Time the response stops being fresh
+}
+
+// Cache is a size-capped in-memory LRU cache for backend responses with TTL eviction
+type Cache struct {
+	entries       map[string]*list.Element
+	lru           *list.List    // Front = most recently used
+	maxEntries    int           // Maximum number of cached responses
+	maxEntryBytes int           // Maximum body size of a single cached response
+	staleIfError  time.Duration // How long expired entries are kept for stale-if-error (0 = disabled)
+	mux           sync.Mutex
+}
+
+// item is the value stored in the LRU list
+type item struct {
+	key   string
+	entry *Entry
+}
+
+// NewCache creates a new response cache
+func NewCache(maxEntries int, maxEntryBytes int) *Cache {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	return &Cache{
+		entries:       make(map[string]*list.Element),
+		lru:           list.New(),
+		maxEntries:    maxEntries,
+		maxEntryBytes: maxEntryBytes,
+	}
+}
+
+// Key builds the cache key for a request (method + host + URL)
+func Key(r *http.Request) string {
+	return r.Method + " " + r.Host + r.URL.RequestURI()
+}
+
+// SetStaleIfError keeps expired entries for d so they can be served when no backend is available
+func (c *Cache) SetStaleIfError(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.staleIfError = d
+}
+
+// MaxEntryBytes returns the largest body size that will be cached
+func (c *Cache) MaxEntryBytes() int {
+	return c.maxEntryBytes
+}
+
+// Get returns a fresh entry for key, evicting it if it has expired
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	it := elem.Value.(*item)
+	now := time.Now()
+	if now.After(it.entry.ExpiresAt) {
+		// Keep the entry around while it can still be served stale
+		if now.After(it.entry.ExpiresAt.Add(c.staleIfError)) {
+			c.removeElement(elem)
+		}
+		return nil, false
+	}
+
+	c.lru.MoveToFront(elem)
+	return it.entry, true
+}
+
+// GetStale returns an entry for key that may be served when backends are failing:
+// either fresh or expired within the stale-if-error window. Responses marked
+// must-revalidate or proxy-revalidate are never served stale.
+func (c *Cache) GetStale(key string) (*Entry, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	elem, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	it := elem.Value.(*item)
+	if time.Now().After(it.entry.ExpiresAt.Add(c.staleIfError)) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	directives := parseCacheControl(it.entry.Header.Get("Cache-Control"))
+	for _, d := range []string{"must-revalidate", "proxy-revalidate"} {
+		if _, exists := directives[d]; exists {
+			return nil, false
+		}
+	}
+
+	c.lru.MoveToFront(elem)
+	return it.entry, true
+}
+
+// Set stores an entry, evicting the least recently used entry if the cache is full
+func (c *Cache) Set(key string, entry *Entry) {
+	if c.maxEntryBytes > 0 && len(entry.Body) > c.maxEntryBytes {
+		return // Too large to cache
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if elem, exists := c.entries[key]; exists {
+		elem.Value.(*item).entry = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&item{key: key, entry: entry})
+
+	for c.lru.Len() > c.maxEntries {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// Len returns the number of cached entries
+func (c *Cache) Len() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.lru.Len()
+}
+
+// removeElement deletes an element from the list and index (caller holds lock)
+func (c *Cache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*item).key)
+}
+
+// Freshness returns how long a response may be cached, or false if it must not be stored.
+// Only responses explicitly marked cacheable via Cache-Control max-age/s-maxage or
+// Expires are stored; no-store, no-cache and private responses are never stored.
+func Freshness(statusCode int, header http.Header) (time.Duration, bool) {
+	if !cacheableStatus(statusCode) {
+		return 0, false
+	}
+
+	// Per-user or content-negotiated responses are not safe to share
+	if header.Get("Set-Cookie") != "" || header.Get("Vary") != "" {
+		return 0, false
+	}
+
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, exists := directives[d]; exists {
+			return 0, false
+		}
+	}
+
+	// s-maxage takes precedence for shared caches
+	for _, d := range []string{"s-maxage", "max-age"} {
+		if v, exists := directives[d]; exists {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0, false
+		}
+		ttl := time.Until(t)
+		if ttl <= 0 {
+			return 0, false
+		}
+		return ttl, true
+	}
+
+	return 0, false
+}
+
+// RequestAllowsCache returns false if the client asked to bypass the cache
+func RequestAllowsCache(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	// Conditional requests go to the backend so validators are evaluated there
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	directives := parseCacheControl(r.Header.Get("Cache-Control"))
+	if _, exists := directives["no-store"]; exists {
+		return false
+	}
+	if _, exists := directives["no-cache"]; exists {
+		return false
+	}
+	return true
+}
+
+// cacheableStatus returns true for status codes that are cacheable by default
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently,
+		http.StatusNotFound, http.StatusGone:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseCacheControl parses a Cache-Control header into directive → value
+func parseCacheControl(value string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+	return directives
+}
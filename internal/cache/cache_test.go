@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestCacheSetGet tests storing and retrieving a fresh entry
+func TestCacheSetGet(t *testing.T) {
+	c := NewCache(10, 1024)
+
+	c.Set("GET /a", &Entry{StatusCode: 200, Body: []byte("a"), ExpiresAt: time.Now().Add(time.Minute)})
+
+	entry, ok := c.Get("GET /a")
+	if !ok {
+		t.Fatal("Expected cache hit")
+	}
+	if string(entry.Body) != "a" {
+		t.Errorf("Expected body 'a', got %q", entry.Body)
+	}
+}
+
+// TestCacheTTLEviction tests expired entries are not served
+func TestCacheTTLEviction(t *testing.T) {
+	c := NewCache(10, 1024)
+
+	c.Set("GET /a", &Entry{StatusCode: 200, ExpiresAt: time.Now().Add(-time.Second)})
+
+	if _, ok := c.Get("GET /a"); ok {
+		t.Error("Expired entry should not be served")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expired entry should be evicted, got %d entries", c.Len())
+	}
+}
+
+// TestCacheGetStale tests expired entries are kept for stale-if-error within the window
+func TestCacheGetStale(t *testing.T) {
+	c := NewCache(10, 1024)
+	c.SetStaleIfError(time.Minute)
+
+	c.Set("GET /a", &Entry{StatusCode: 200, Body: []byte("a"), ExpiresAt: time.Now().Add(-time.Second)})
+	c.Set("GET /old", &Entry{StatusCode: 200, ExpiresAt: time.Now().Add(-2 * time.Minute)})
+	c.Set("GET /strict", &Entry{
+		StatusCode: 200,
+		Header:     http.Header{"Cache-Control": []string{"max-age=60, must-revalidate"}},
+		ExpiresAt:  time.Now().Add(-time.Second),
+	})
+
+	if _, ok := c.Get("GET /a"); ok {
+		t.Error("Expired entry should not be served as fresh")
+	}
+	if entry, ok := c.GetStale("GET /a"); !ok || string(entry.Body) != "a" {
+		t.Error("Expired entry within the stale window should be served stale")
+	}
+	if _, ok := c.GetStale("GET /old"); ok {
+		t.Error("Entry past the stale window should not be served")
+	}
+	if _, ok := c.GetStale("GET /strict"); ok {
+		t.Error("must-revalidate entry should never be served stale")
+	}
+}
+
+// TestCacheSizeCap tests least recently used entries are evicted at capacity
+func TestCacheSizeCap(t *testing.T) {
+	c := NewCache(2, 1024)
+	expires := time.Now().Add(time.Minute)
+
+	c.Set("a", &Entry{ExpiresAt: expires})
+	c.Set("b", &Entry{ExpiresAt: expires})
+	c.Get("a") // a is now most recently used
+	c.Set("c", &Entry{ExpiresAt: expires})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("Least recently used entry should be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Recently used entry should be kept")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", c.Len())
+	}
+}
+
+// TestCacheMaxEntryBytes tests oversized bodies are not stored
+func TestCacheMaxEntryBytes(t *testing.T) {
+	c := NewCache(10, 4)
+
+	c.Set("a", &Entry{Body: []byte("too large"), ExpiresAt: time.Now().Add(time.Minute)})
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Oversized entry should not be cached")
+	}
+}
+
+// TestFreshness tests Cache-Control and Expires handling
+func TestFreshness(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		header    map[string]string
+		cacheable bool
+	}{
+		{"max-age", 200, map[string]string{"Cache-Control": "public, max-age=60"}, true},
+		{"s-maxage", 200, map[string]string{"Cache-Control": "s-maxage=30"}, true},
+		{"expires", 200, map[string]string{"Expires": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, true},
+		{"no directives", 200, map[string]string{}, false},
+		{"no-store", 200, map[string]string{"Cache-Control": "no-store, max-age=60"}, false},
+		{"private", 200, map[string]string{"Cache-Control": "private, max-age=60"}, false},
+		{"no-cache", 200, map[string]string{"Cache-Control": "no-cache"}, false},
+		{"expired", 200, map[string]string{"Expires": time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}, false},
+		{"not modified", 304, map[string]string{"Cache-Control": "max-age=60"}, false},
+		{"server error", 500, map[string]string{"Cache-Control": "max-age=60"}, false},
+		{"set-cookie", 200, map[string]string{"Cache-Control": "max-age=60", "Set-Cookie": "a=b"}, false},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		for k, v := range tt.header {
+			header.Set(k, v)
+		}
+		_, ok := Freshness(tt.status, header)
+		if ok != tt.cacheable {
+			t.Errorf("%s: expected cacheable=%v, got %v", tt.name, tt.cacheable, ok)
+		}
+	}
+}
+
+// TestRequestAllowsCache tests client cache bypass
+func TestRequestAllowsCache(t *testing.T) {
+	get := httptest.NewRequest("GET", "/", nil)
+	if !RequestAllowsCache(get) {
+		t.Error("Plain GET should be cacheable")
+	}
+
+	post := httptest.NewRequest("POST", "/", nil)
+	if RequestAllowsCache(post) {
+		t.Error("POST should not be cacheable")
+	}
+
+	noStore := httptest.NewRequest("GET", "/", nil)
+	noStore.Header.Set("Cache-Control", "no-store")
+	if RequestAllowsCache(noStore) {
+		t.Error("GET with no-store should bypass cache")
+	}
+
+	conditional := httptest.NewRequest("GET", "/", nil)
+	conditional.Header.Set("If-None-Match", `"v1"`)
+	if RequestAllowsCache(conditional) {
+		t.Error("Conditional GET should bypass cache")
+	}
+}
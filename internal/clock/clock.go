@@ -0,0 +1,55 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. Components that measure time take one so tests can
+// drive them with a Fake instead of sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// Real is the wall clock
+var Real Clock = realClock{}
+
+// realClock reads time.Now
+type realClock struct{}
+
+// Now returns the current wall-clock time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock that only moves when told to
+type Fake struct {
+	now time.Time
+	mux sync.Mutex
+}
+
+// NewFake creates a fake clock stopped at t
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+// Now returns the fake clock's current time
+func (f *Fake) Now() time.Time {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.now
+}
+
+// Advance moves the fake clock forward by d (backward if d is negative)
+func (f *Fake) Advance(d time.Duration) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Set moves the fake clock to t
+func (f *Fake) Set(t time.Time) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.now = t
+}
@@ -0,0 +1,34 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// TestFake tests the fake clock only moves when advanced or set
+func TestFake(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFake(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Expected %v, got %v", start, c.Now())
+	}
+
+	c.Advance(90 * time.Second)
+	if want := start.Add(90 * time.Second); !c.Now().Equal(want) {
+		t.Errorf("Expected %v after advancing, got %v", want, c.Now())
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %v after setting, got %v", start, c.Now())
+	}
+}
+
+// TestReal tests the real clock tracks wall-clock time
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Real clock returned %v outside the surrounding wall-clock reads", got)
+	}
+}
@@ -1,66 +1,728 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
 	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/Nash0810/gobalance/internal/retry"
 )
 
 // Config represents the load balancer configuration
 type Config struct {
-	Port           int               `yaml:"port"`            // Load balancer port
-	Backends       []BackendConfig   `yaml:"backends"`        // Backend URLs with weights
-	Strategy       string            `yaml:"strategy"`        // Load balancing strategy
-	RequestTimeout int               `yaml:"request_timeout"` // Per-request timeout in seconds
-	HealthCheck    HealthCheckConfig `yaml:"health_check"`    // Health check configuration
-	Retry          RetryConfig       `yaml:"retry"`           // Retry configuration
+	Port                   int                  `yaml:"port"`                     // Load balancer port
+	Backends               []BackendConfig      `yaml:"backends"`                 // Backend URLs with weights
+	Strategy               string               `yaml:"strategy"`                 // Load balancing strategy
+	RequestTimeout         int                  `yaml:"request_timeout"`          // Per-request timeout in seconds
+	ConnectTimeoutMs       int                  `yaml:"connect_timeout_ms"`       // Longest to wait for a backend connection, within the request timeout (0 = 30s)
+	HealthCheck            HealthCheckConfig    `yaml:"health_check"`             // Health check configuration
+	Retry                  RetryConfig          `yaml:"retry"`                    // Retry configuration
+	Cache                  CacheConfig          `yaml:"cache"`                    // Response cache configuration
+	FailurePolicy          FailurePolicyConfig  `yaml:"failure_policy"`           // Which statuses count as backend failures
+	FlushIntervalMs        int                  `yaml:"flush_interval_ms"`        // Response flush interval (0 = default, -1 = flush immediately)
+	Admission              AdmissionConfig      `yaml:"admission"`                // In-flight limit and FIFO queueing
+	Groups                 []GroupConfig        `yaml:"groups"`                   // Named backend groups for host routing
+	VirtualHosts           []VirtualHostConfig  `yaml:"virtual_hosts"`            // Host header → group routing table
+	TLS                    TLSConfig            `yaml:"tls"`                      // Terminate TLS on the traffic port
+	SNIRoutes              []VirtualHostConfig  `yaml:"sni_routes"`               // TLS server name → group routing table, checked before virtual_hosts
+	DefaultGroup           string               `yaml:"default_group"`            // Group serving requests that match no route ("" = top-level backends)
+	StickySessions         StickySessionsConfig `yaml:"sticky_sessions"`          // Cookie-based session affinity
+	DrainTimeoutSeconds    int                  `yaml:"drain_timeout_seconds"`    // Max wait for removed backends' in-flight requests (0 = remove immediately)
+	MaxInMemoryBodyBytes   int64                `yaml:"max_in_memory_body_bytes"` // Retry-buffered request bodies above this spill to a temp file (0 = no limit)
+	BodyReadErrorStatus    int                  `yaml:"body_read_error_status"`   // Status for request bodies that fail to read, other than client disconnects (0 = 400)
+	MaxHeaderBytes         int                  `yaml:"max_header_bytes"`         // Largest request header block accepted; bigger ones get 431 (0 = 1 MiB)
+	MaxBackendHeaderBytes  int                  `yaml:"max_backend_header_bytes"` // Largest backend response header block passed on; bigger ones become 502 (0 = no limit)
+	AdminPort              int                  `yaml:"admin_port"`               // Port serving the admin API (0 = no admin API; /admin/ is proxied like any path)
+	AdminBindAddress       string               `yaml:"admin_bind_address"`       // Interface admin_port listens on (default 127.0.0.1)
+	EnablePprof            bool                 `yaml:"enable_pprof"`             // Serve /debug/pprof on the admin port (requires admin_port)
+	ShutdownDelaySeconds   int                  `yaml:"shutdown_delay_seconds"`   // Keep serving after /readyz reports draining so orchestrators can deregister
+	ShutdownTimeoutSeconds int                  `yaml:"shutdown_timeout_seconds"` // Longest graceful shutdown waits for in-flight requests (0 = 30s)
+	CompositeScore         CompositeScoreConfig `yaml:"composite_score"`          // Signal coefficients for the composite strategy
+	FailurePenaltyMs       int                  `yaml:"failure_penalty_ms"`       // Weighted round robin: a backend that just failed a request gets a reduced share, recovering over this window (0 = off)
+	StateFile              StateFileConfig      `yaml:"state_file"`               // Persist backend health across restarts
+	LocalZone              string               `yaml:"local_zone"`               // Prefer backends tagged with this zone, spilling over when none is selectable or all are saturated ("" = off)
+	LocalZoneMaxActive     int                  `yaml:"local_zone_max_active"`    // In-flight requests at which a local backend is saturated; all saturated spills over (0 = no limit)
+	Metrics                MetricsConfig        `yaml:"metrics"`                  // Prometheus label schema
+	FailOpen               FailOpenConfig       `yaml:"fail_open"`                // Hold requests during a total outage instead of failing at once
+	CircuitBreaker         CircuitBreakerConfig `yaml:"circuit_breaker"`          // Per-backend circuit breaker tuning
+	ServerTiming           bool                 `yaml:"server_timing"`            // Add a Server-Timing header with the backend's response time and an opaque backend id
+	ServerTimingKey        string               `yaml:"server_timing_key"`        // Secret keying the backend ids; share it so instances agree (empty = random per process)
+	WarmUp                 WarmUpConfig         `yaml:"warm_up"`                  // Pre-open connections to backends added on reload
+	AccessControl          AccessControlConfig  `yaml:"access_control"`           // Method and path allow/deny lists
+	AllowEmptyBackends     bool                 `yaml:"allow_empty_backends"`     // Start (and reload) with no backends, answering 503 until some are configured
 }
 
 // BackendConfig represents a single backend configuration
 type BackendConfig struct {
-	URL    string `yaml:"url"`            // Backend URL
-	Weight int    `yaml:"weight,omitempty"` // Optional weight
+	URL       string            `yaml:"url"`                  // Backend URL
+	Weight    int               `yaml:"weight,omitempty"`     // Optional weight
+	Protocol  string            `yaml:"protocol,omitempty"`   // "http1" (default), "h2" (TLS) or "h2c" (cleartext)
+	KeepAlive KeepAliveConfig   `yaml:"keepalive,omitempty"`  // Connection reuse tuning
+	HealthURL string            `yaml:"health_url,omitempty"` // Full health check URL (default: URL + health_check.path)
+	LocalAddr string            `yaml:"local_addr,omitempty"` // Source IP to originate backend connections from
+	Tags      map[string]string `yaml:"tags,omitempty"`       // Free-form labels, e.g. zone
+	Retryable *bool             `yaml:"retryable,omitempty"`  // Override retry eligibility: false never retries, true retries any method (unset = retry policy)
+}
+
+// KeepAliveConfig tunes connection reuse to a backend
+type KeepAliveConfig struct {
+	Disabled            bool `yaml:"disabled"`                // Close connections after each request
+	IdleTimeoutSeconds  int  `yaml:"idle_timeout_seconds"`    // How long idle connections are kept
+	MaxIdleConnsPerHost int  `yaml:"max_idle_conns_per_host"` // Idle connections kept per backend (0 = 2, raised to warm_up.connections)
 }
 
 // HealthCheckConfig defines health check parameters
 type HealthCheckConfig struct {
-	Enabled            bool   `yaml:"enabled"`             // Enable health checks
-	Interval           int    `yaml:"interval"`            // Seconds between checks
-	Timeout            int    `yaml:"timeout"`             // Check timeout in seconds
-	HealthyThreshold   int    `yaml:"healthy_threshold"`   // Successes needed to mark healthy
-	UnhealthyThreshold int    `yaml:"unhealthy_threshold"` // Failures needed to mark unhealthy
-	Path               string `yaml:"path"`                // Health check endpoint path
+	Enabled             bool    `yaml:"enabled"`               // Enable health checks
+	Interval            int     `yaml:"interval"`              // Seconds between checks
+	Timeout             int     `yaml:"timeout"`               // Check timeout in seconds
+	HealthyThreshold    int     `yaml:"healthy_threshold"`     // Successes needed to mark healthy
+	UnhealthyThreshold  int     `yaml:"unhealthy_threshold"`   // Failures needed to mark unhealthy
+	Path                string  `yaml:"path"`                  // Health check endpoint path
+	Method              string  `yaml:"method"`                // Probe request method (default GET)
+	Body                string  `yaml:"body"`                  // Optional static body sent with every probe
+	ExpectBody          string  `yaml:"expect_body"`           // Optional substring the response body must contain
+	FeedCircuitBreaker  bool    `yaml:"feed_circuit_breaker"`  // Record check results on the backend's circuit breaker
+	InitialDelaySeconds int     `yaml:"initial_delay_seconds"` // Grace period after a backend is added during which failures don't count
+	SuccessStatusMin    int     `yaml:"success_status_min"`    // Lowest status counted as healthy (0 = 200)
+	SuccessStatusMax    int     `yaml:"success_status_max"`    // Highest status counted as healthy (0 = 299)
+	CheckType           string  `yaml:"check_type"`            // "http" (default) or "grpc" for the gRPC health checking protocol
+	GRPCService         string  `yaml:"grpc_service"`          // Service name asked about by gRPC checks ("" = the server as a whole)
+	PassiveRecovery     int     `yaml:"passive_recovery"`      // Request successes that restore a backend downed by request failures (0 = active checks only)
+	PassiveCooldownMs   int     `yaml:"passive_cooldown_ms"`   // Time a passively downed backend gets no traffic before trial requests (0 = 10s)
+	MinHealthy          int     `yaml:"min_healthy"`           // Never eject a backend if fewer would stay healthy (0 = no minimum)
+	MinHealthyFraction  float64 `yaml:"min_healthy_fraction"`  // Never eject a backend if less of its pool would stay healthy (0-1, 0 = no minimum)
+}
+
+// SuccessStatusRange returns the statuses counted as healthy, resolving the
+// defaults: 200 to 299
+func (h HealthCheckConfig) SuccessStatusRange() (minStatus, maxStatus int) {
+	minStatus, maxStatus = h.SuccessStatusMin, h.SuccessStatusMax
+	if minStatus == 0 {
+		minStatus = 200
+	}
+	if maxStatus == 0 {
+		maxStatus = 299
+	}
+	return minStatus, maxStatus
 }
 
 // RetryConfig defines retry behavior
 type RetryConfig struct {
-	Enabled       bool `yaml:"enabled"`        // Enable retries
-	MaxAttempts   int  `yaml:"max_attempts"`   // Total attempts (original + retries)
-	BudgetPercent int  `yaml:"budget_percent"` // % of requests that can be retries
+	Enabled       bool     `yaml:"enabled"`         // Enable retries
+	MaxAttempts   int      `yaml:"max_attempts"`    // Total attempts (original + retries)
+	BudgetPercent int      `yaml:"budget_percent"`  // % of requests that can be retries
+	MaxPerBackend int      `yaml:"max_per_backend"` // Attempts allowed against any one backend per request (0 = 1)
+	ToHealthiest  bool     `yaml:"to_healthiest"`   // Retry on the healthiest untried backend instead of the strategy's pick
+	RetryOn       []string `yaml:"retry_on"`        // Error classes to retry, e.g. [timeout, connection_refused] (empty = all)
+}
+
+// CompositeScoreConfig weights the signals of the composite strategy. Each
+// unset coefficient takes its default; an explicit 0 ignores that signal.
+type CompositeScoreConfig struct {
+	Connections *float64 `yaml:"connections"` // Per in-flight request, divided by weight (unset = 1)
+	ErrorRate   *float64 `yaml:"error_rate"`  // Per unit of recent error rate, 0-1 (unset = 10)
+	LatencyMs   *float64 `yaml:"latency_ms"`  // Per millisecond of average latency (unset = 0.1)
+}
+
+// MetricsConfig tunes metric cardinality
+type MetricsConfig struct {
+	DropMethodLabel bool      `yaml:"drop_method_label"` // Aggregate request metrics across HTTP methods
+	DurationBuckets []float64 `yaml:"duration_buckets"`  // Request duration and TTFB histogram bounds in seconds, increasing (empty = Prometheus defaults)
+}
+
+// StateFileConfig persists backend health states across restarts
+type StateFileConfig struct {
+	Path            string `yaml:"path"`             // File to write states to (empty = disabled; requires health checks)
+	IntervalSeconds int    `yaml:"interval_seconds"` // How often changed states are written (0 = 5)
+	MaxAgeSeconds   int    `yaml:"max_age_seconds"`  // Ignore saved states older than this on startup (0 = never stale)
+}
+
+// CacheConfig defines response caching for GET requests
+type CacheConfig struct {
+	Enabled             bool `yaml:"enabled"`                // Enable response caching
+	MaxEntries          int  `yaml:"max_entries"`            // Maximum number of cached responses
+	MaxEntryBytes       int  `yaml:"max_entry_bytes"`        // Largest response body that will be cached
+	StaleIfErrorSeconds int  `yaml:"stale_if_error_seconds"` // Serve expired entries this long when no backend is available (0 = disabled)
+}
+
+// AdmissionConfig limits concurrent requests; excess requests queue in arrival order
+type AdmissionConfig struct {
+	Enabled        bool `yaml:"enabled"`          // Enable the in-flight limit
+	MaxInFlight    int  `yaml:"max_in_flight"`    // Requests proxied concurrently
+	MaxQueue       int  `yaml:"max_queue"`        // Requests allowed to wait; beyond this they get 503
+	QueueTimeoutMs int  `yaml:"queue_timeout_ms"` // Longest a request waits for a slot (0 = request timeout)
+
+	TenantHeader        string         `yaml:"tenant_header"`         // Header naming the request's tenant ("" = no tenant weighting)
+	TenantWeights       map[string]int `yaml:"tenant_weights"`        // Admission weight per tenant; lighter tenants are shed first (429)
+	DefaultTenantWeight int            `yaml:"default_tenant_weight"` // Weight of unlisted tenants (0 = 1)
+
+	PriorityHeader  string                    `yaml:"priority_header"`  // Header carrying high|normal|low; higher priorities are admitted first ("" = off)
+	Priorities      map[string]PriorityConfig `yaml:"priorities"`       // Timeout and retry budget per priority, any case (missing = defaults)
+	PriorityTrusted []string                  `yaml:"priority_trusted"` // CIDRs of peers whose priority header is honoured; others' is stripped (empty = all)
+}
+
+// PriorityConfig overrides the request budget for one priority
+type PriorityConfig struct {
+	TimeoutMs   int `yaml:"timeout_ms"`   // Per-request timeout (0 = request_timeout)
+	MaxAttempts int `yaml:"max_attempts"` // Total attempts including the first when retries apply, in place of retry.max_attempts (0 = default)
+}
+
+// knownPriority reports whether name is a request priority, matched the way
+// request headers are (any case, surrounding spaces ignored)
+func knownPriority(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "high", "normal", "low":
+		return true
+	}
+	return false
+}
+
+// WarmUpConfig pre-opens connections to a newly added backend before it takes traffic
+type WarmUpConfig struct {
+	Connections int `yaml:"connections"` // HEAD probes sent concurrently to the health path on the traffic URL (0 = disabled)
+	TimeoutMs   int `yaml:"timeout_ms"`  // Longest warm-up may delay a reload (0 = 1000)
+}
+
+// CircuitBreakerConfig tunes the per-backend circuit breakers
+type CircuitBreakerConfig struct {
+	TimeoutWeight int `yaml:"timeout_weight"` // Failures a timed-out request counts as toward opening the circuit (0 = 1)
+}
+
+// FailOpenConfig holds requests that find no healthy backend until one recovers
+type FailOpenConfig struct {
+	Enabled   bool `yaml:"enabled"`     // Wait for a backend instead of returning 503 immediately
+	MaxWaitMs int  `yaml:"max_wait_ms"` // Longest a request is held
+	MaxHeld   int  `yaml:"max_held"`    // Requests held at once; beyond this they get 503 (0 = no limit)
+}
+
+// AccessControlConfig rejects requests by method (405) or path (403) before they
+// reach a backend. Deny lists win; empty allow lists allow everything.
+type AccessControlConfig struct {
+	AllowMethods []string `yaml:"allow_methods"` // Only these methods are proxied
+	DenyMethods  []string `yaml:"deny_methods"`  // e.g. ["TRACE"]
+	AllowPaths   []string `yaml:"allow_paths"`   // Only matching paths are proxied (path.Match patterns)
+	DenyPaths    []string `yaml:"deny_paths"`    // e.g. ["/.git", "/*.php"]; a pattern also covers paths below it
+}
+
+// StickySessionsConfig pins clients to a backend with a cookie
+type StickySessionsConfig struct {
+	Enabled       bool   `yaml:"enabled"`         // Enable session affinity
+	CookieName    string `yaml:"cookie_name"`     // Cookie carrying the pinned backend
+	TTLSeconds    int    `yaml:"ttl_seconds"`     // Cookie Max-Age; expired pins get a fresh backend (0 = browser session)
+	OnBackendDown string `yaml:"on_backend_down"` // "rebalance" (default) or "fail" when the pinned backend is unavailable
+}
+
+// FailurePolicyConfig defines which backend response statuses count against backend health
+type FailurePolicyConfig struct {
+	IgnoreStatus []int `yaml:"ignore_status"` // 5xx codes that are normal responses (e.g. 501)
+	FailStatus   []int `yaml:"fail_status"`   // Non-5xx codes that count as failures (e.g. 429)
+}
+
+// GroupConfig is a named set of backends that routes can target
+type GroupConfig struct {
+	Name     string          `yaml:"name"`     // Group name referenced by routes
+	Strategy string          `yaml:"strategy"` // Strategy for this group ("" = top-level strategy)
+	Backends []BackendConfig `yaml:"backends"` // Backends in this group
+}
+
+// TLSConfig terminates TLS on the traffic port (both files or neither)
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`          // PEM certificate chain
+	KeyFile      string `yaml:"key_file"`           // PEM private key
+	ClientCAFile string `yaml:"client_ca_file"`     // PEM CAs client certificates are verified against
+	ClientAuth   string `yaml:"client_auth"`        // "none", "optional" (verify if sent) or "require" ("" = require with client_ca_file, else none)
+	ClientHeader string `yaml:"client_cert_header"` // Header passing the verified client subject to backends ("" = not sent)
+}
+
+// Client certificate modes (tls.client_auth)
+const (
+	ClientAuthNone     = "none"     // Client certificates are not requested
+	ClientAuthOptional = "optional" // Certificates are verified when sent; clients without one are still served
+	ClientAuthRequire  = "require"  // Every client must present a verified certificate
+)
+
+// Enabled reports whether TLS termination is configured
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ClientAuthMode returns the client certificate mode, resolving the default:
+// require when a client CA is configured, none otherwise
+func (t TLSConfig) ClientAuthMode() string {
+	if t.ClientAuth != "" {
+		return t.ClientAuth
+	}
+	if t.ClientCAFile != "" {
+		return ClientAuthRequire
+	}
+	return ClientAuthNone
+}
+
+// VirtualHostConfig routes requests for a Host to a backend group
+type VirtualHostConfig struct {
+	Host  string `yaml:"host"`  // Exact host ("api.example.com") or wildcard ("*.example.com")
+	Group string `yaml:"group"` // Group that serves this host
 }
 
 // ParsedBackend represents a backend with parsed URL
 type ParsedBackend struct {
-	URL    *url.URL
-	Weight int
+	URL       *url.URL
+	Weight    int
+	Protocol  string
+	KeepAlive KeepAliveConfig
+	HealthURL *url.URL // nil = derive from URL
+	LocalAddr string   // "" = chosen by the OS
+	Tags      map[string]string
+	Retryable *bool // nil = follow the retry policy
 }
 
-// ParseBackends converts BackendConfig to ParsedBackend
+// ParseBackends converts BackendConfig to ParsedBackend, failing on the first bad entry
 func (c *Config) ParseBackends() ([]*ParsedBackend, error) {
+	return parseBackends(c.Backends)
+}
+
+// ParseBackendsLenient converts BackendConfig to ParsedBackend, skipping bad entries.
+// Used on reload so one typo doesn't block updates to the valid backends.
+func (c *Config) ParseBackendsLenient() ([]*ParsedBackend, []error) {
+	return parseBackendsLenient(c.Backends)
+}
+
+// ParseBackends converts the group's BackendConfig to ParsedBackend
+func (g *GroupConfig) ParseBackends() ([]*ParsedBackend, error) {
+	return parseBackends(g.Backends)
+}
+
+// ParseBackendsLenient converts the group's BackendConfig to ParsedBackend, skipping bad entries
+func (g *GroupConfig) ParseBackendsLenient() ([]*ParsedBackend, []error) {
+	return parseBackendsLenient(g.Backends)
+}
+
+// parseBackends converts BackendConfig to ParsedBackend (strict)
+func parseBackends(configs []BackendConfig) ([]*ParsedBackend, error) {
 	var backends []*ParsedBackend
-	for _, bc := range c.Backends {
-		u, err := url.Parse(bc.URL)
+	for _, bc := range configs {
+		pb, err := parseBackend(bc)
 		if err != nil {
 			return nil, err
 		}
+		backends = append(backends, pb)
+	}
+	return backends, nil
+}
 
-		weight := bc.Weight
-		if weight == 0 {
-			weight = 1 // Default weight
+// parseBackendsLenient converts BackendConfig to ParsedBackend, returning the
+// valid entries along with one error per skipped entry
+func parseBackendsLenient(configs []BackendConfig) ([]*ParsedBackend, []error) {
+	var backends []*ParsedBackend
+	var skipped []error
+	for _, bc := range configs {
+		pb, err := parseBackend(bc)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
 		}
+		backends = append(backends, pb)
+	}
+	return backends, skipped
+}
 
-		backends = append(backends, &ParsedBackend{
-			URL:    u,
-			Weight: weight,
-		})
+// parseBackend converts a single BackendConfig to ParsedBackend
+func parseBackend(bc BackendConfig) (*ParsedBackend, error) {
+	u, err := url.Parse(bc.URL)
+	if err != nil {
+		return nil, err
 	}
-	return backends, nil
+	if err := checkAbsoluteURL(u); err != nil {
+		return nil, fmt.Errorf("backend %s: %w", bc.URL, err)
+	}
+
+	weight := bc.Weight
+	if weight == 0 {
+		weight = 1 // Default weight
+	}
+
+	switch bc.Protocol {
+	case "", "http1", "h2", "h2c":
+	default:
+		return nil, fmt.Errorf("backend %s: unknown protocol %q", bc.URL, bc.Protocol)
+	}
+
+	if bc.LocalAddr != "" && net.ParseIP(bc.LocalAddr) == nil {
+		return nil, fmt.Errorf("backend %s: local_addr %q is not an IP address", bc.URL, bc.LocalAddr)
+	}
+
+	var healthURL *url.URL
+	if bc.HealthURL != "" {
+		healthURL, err = url.Parse(bc.HealthURL)
+		if err == nil {
+			err = checkAbsoluteURL(healthURL)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: bad health_url: %w", bc.URL, err)
+		}
+	}
+
+	return &ParsedBackend{
+		URL:       u,
+		Weight:    weight,
+		Protocol:  bc.Protocol,
+		KeepAlive: bc.KeepAlive,
+		HealthURL: healthURL,
+		LocalAddr: bc.LocalAddr,
+		Tags:      bc.Tags,
+		Retryable: bc.Retryable,
+	}, nil
+}
+
+// knownStrategy reports whether name is a strategy the load balancer implements ("" = default)
+func knownStrategy(name string) bool {
+	switch name {
+	case "", "round-robin", "weighted-round-robin", "least-connections", "weighted-random", "latency-p99", "weighted-latency", "composite":
+		return true
+	}
+	return false
+}
+
+// negative reports whether an optional value is set below zero
+func negative(v *float64) bool {
+	return v != nil && *v < 0
+}
+
+// checkAbsoluteURL rejects URLs the reverse proxy can't dial, such as
+// "localhost:8081", which parses as scheme "localhost" with no host
+func checkAbsoluteURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("URL must be absolute with an http or https scheme (e.g. \"http://localhost:8081\")")
+	}
+	return nil
+}
+
+// Validate checks the configuration for values the load balancer can't run with.
+// All problems are reported together so a whole file can be fixed in one pass.
+func (c *Config) Validate() error {
+	return c.validate(false)
+}
+
+// ValidateLenient is Validate for reloads: backend entries that fail to parse
+// are left for ParseBackendsLenient to skip, as long as each pool keeps at least one.
+func (c *Config) ValidateLenient() error {
+	return c.validate(true)
+}
+
+// validate implements Validate and ValidateLenient
+func (c *Config) validate(lenient bool) error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d out of range 1-65535", c.Port))
+	}
+	if c.AdminPort < 0 || c.AdminPort > 65535 {
+		errs = append(errs, fmt.Errorf("admin_port %d out of range 1-65535", c.AdminPort))
+	} else if c.AdminPort != 0 && c.AdminPort == c.Port {
+		errs = append(errs, fmt.Errorf("admin_port must differ from port %d", c.Port))
+	}
+	// Profiles expose process internals, so never serve them on the traffic port
+	if c.EnablePprof && c.AdminPort == 0 {
+		errs = append(errs, fmt.Errorf("enable_pprof requires admin_port"))
+	}
+
+	if !knownStrategy(c.Strategy) {
+		errs = append(errs, fmt.Errorf("unknown strategy %q", c.Strategy))
+	}
+
+	if len(c.Backends) == 0 && c.RequiresBackends() {
+		errs = append(errs, fmt.Errorf("no backends configured"))
+	}
+	for _, bc := range c.Backends {
+		if bc.Weight < 0 || bc.Weight > 100 {
+			errs = append(errs, fmt.Errorf("backend %s: weight %d out of range 0-100", bc.URL, bc.Weight))
+		}
+	}
+	if err := validateBackends(c.Backends, lenient); err != nil {
+		errs = append(errs, err)
+	}
+
+	groups := make(map[string]bool)
+	for _, g := range c.Groups {
+		if g.Name == "" {
+			errs = append(errs, fmt.Errorf("group name must not be empty"))
+			continue
+		}
+		if groups[g.Name] {
+			errs = append(errs, fmt.Errorf("duplicate group %q", g.Name))
+		}
+		groups[g.Name] = true
+		if !knownStrategy(g.Strategy) {
+			errs = append(errs, fmt.Errorf("group %q: unknown strategy %q", g.Name, g.Strategy))
+		}
+		if len(g.Backends) == 0 {
+			errs = append(errs, fmt.Errorf("group %q has no backends", g.Name))
+		}
+		if err := validateBackends(g.Backends, lenient); err != nil {
+			errs = append(errs, fmt.Errorf("group %q: %w", g.Name, err))
+		}
+	}
+	errs = append(errs, validateHostRoutes("virtual host", c.VirtualHosts, groups)...)
+	errs = append(errs, validateHostRoutes("sni route", c.SNIRoutes, groups)...)
+	if c.DefaultGroup != "" {
+		if !groups[c.DefaultGroup] {
+			errs = append(errs, fmt.Errorf("default_group: unknown group %q", c.DefaultGroup))
+		}
+		if len(c.Backends) > 0 {
+			errs = append(errs, fmt.Errorf("default_group replaces the top-level backends; configure one or the other"))
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("tls needs both cert_file and key_file"))
+	}
+	if len(c.SNIRoutes) > 0 && !c.TLS.Enabled() {
+		errs = append(errs, fmt.Errorf("sni_routes need tls to be configured"))
+	}
+	switch mode := c.TLS.ClientAuthMode(); mode {
+	case ClientAuthNone:
+		if c.TLS.ClientHeader != "" {
+			errs = append(errs, fmt.Errorf("tls.client_cert_header needs client certificates to be verified"))
+		}
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.TLS.ClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("tls.client_auth %q needs client_ca_file", mode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tls.client_auth must be none, optional or require, got %q", mode))
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled() {
+		errs = append(errs, fmt.Errorf("tls.client_ca_file needs cert_file and key_file"))
+	}
+
+	if c.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("request_timeout must not be negative"))
+	}
+	if c.ConnectTimeoutMs < 0 {
+		errs = append(errs, fmt.Errorf("connect_timeout_ms must not be negative"))
+	} else if c.ConnectTimeoutMs > 0 && c.RequestTimeout > 0 && c.ConnectTimeoutMs >= c.RequestTimeout*1000 {
+		errs = append(errs, fmt.Errorf("connect_timeout_ms (%d) must be shorter than request_timeout (%ds)", c.ConnectTimeoutMs, c.RequestTimeout))
+	}
+	if c.MaxInMemoryBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_in_memory_body_bytes must not be negative"))
+	}
+	if c.BodyReadErrorStatus != 0 && (c.BodyReadErrorStatus < 400 || c.BodyReadErrorStatus > 599) {
+		errs = append(errs, fmt.Errorf("body_read_error_status %d must be a 4xx or 5xx status", c.BodyReadErrorStatus))
+	}
+	if c.MaxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_header_bytes must not be negative"))
+	}
+	if c.MaxBackendHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("max_backend_header_bytes must not be negative"))
+	}
+	if c.DrainTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("drain_timeout_seconds must not be negative"))
+	}
+	if c.ShutdownDelaySeconds < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_delay_seconds must not be negative"))
+	}
+	if c.ShutdownTimeoutSeconds < 0 {
+		errs = append(errs, fmt.Errorf("shutdown_timeout_seconds must not be negative"))
+	}
+	if c.StateFile.Path != "" && c.StateFile.IntervalSeconds < 1 {
+		errs = append(errs, fmt.Errorf("state_file.interval_seconds must be at least 1"))
+	}
+	if c.StateFile.Path != "" && !c.HealthCheck.Enabled {
+		// Saved Unhealthy states are only cleared by a passing health check
+		errs = append(errs, fmt.Errorf("state_file requires health_check.enabled"))
+	}
+	if c.StateFile.MaxAgeSeconds < 0 {
+		errs = append(errs, fmt.Errorf("state_file.max_age_seconds must not be negative"))
+	}
+	if negative(c.CompositeScore.Connections) || negative(c.CompositeScore.ErrorRate) || negative(c.CompositeScore.LatencyMs) {
+		errs = append(errs, fmt.Errorf("composite_score coefficients must not be negative"))
+	}
+	for i, bound := range c.Metrics.DurationBuckets {
+		if bound <= 0 || (i > 0 && bound <= c.Metrics.DurationBuckets[i-1]) {
+			errs = append(errs, fmt.Errorf("metrics.duration_buckets must be positive and strictly increasing"))
+			break
+		}
+	}
+	if c.HealthCheck.MinHealthy < 0 {
+		errs = append(errs, fmt.Errorf("health_check.min_healthy must not be negative"))
+	}
+	if c.HealthCheck.MinHealthyFraction < 0 || c.HealthCheck.MinHealthyFraction > 1 {
+		errs = append(errs, fmt.Errorf("health_check.min_healthy_fraction must be between 0 and 1"))
+	}
+	if c.CircuitBreaker.TimeoutWeight < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker.timeout_weight must not be negative"))
+	}
+	if c.FailurePenaltyMs < 0 {
+		errs = append(errs, fmt.Errorf("failure_penalty_ms must not be negative"))
+	}
+	if c.LocalZoneMaxActive < 0 {
+		errs = append(errs, fmt.Errorf("local_zone_max_active must not be negative"))
+	}
+	if c.FlushIntervalMs < -1 {
+		errs = append(errs, fmt.Errorf("flush_interval_ms must be -1 (immediate) or non-negative"))
+	}
+
+	if c.HealthCheck.Interval < 0 || c.HealthCheck.Timeout < 0 ||
+		c.HealthCheck.HealthyThreshold < 0 || c.HealthCheck.UnhealthyThreshold < 0 ||
+		c.HealthCheck.InitialDelaySeconds < 0 || c.HealthCheck.PassiveRecovery < 0 ||
+		c.HealthCheck.PassiveCooldownMs < 0 {
+		errs = append(errs, fmt.Errorf("health_check values must not be negative"))
+	}
+	minStatus, maxStatus := c.HealthCheck.SuccessStatusRange()
+	if minStatus < 100 || maxStatus > 599 || minStatus > maxStatus {
+		errs = append(errs, fmt.Errorf("health_check success status range %d-%d is invalid", minStatus, maxStatus))
+	}
+	switch c.HealthCheck.Method {
+	case "", "GET", "HEAD", "POST", "PUT", "OPTIONS":
+	default:
+		errs = append(errs, fmt.Errorf("health_check.method %q is not supported", c.HealthCheck.Method))
+	}
+	if c.HealthCheck.Body != "" && (c.HealthCheck.Method == "" || c.HealthCheck.Method == "GET" || c.HealthCheck.Method == "HEAD") {
+		errs = append(errs, fmt.Errorf("health_check.body needs a method that takes a body (e.g. POST)"))
+	}
+	switch c.HealthCheck.CheckType {
+	case "", "http":
+		if c.HealthCheck.GRPCService != "" {
+			errs = append(errs, fmt.Errorf("health_check.grpc_service needs check_type grpc"))
+		}
+	case "grpc":
+		if c.HealthCheck.Method != "" || c.HealthCheck.Body != "" || c.HealthCheck.ExpectBody != "" ||
+			c.HealthCheck.SuccessStatusMin != 0 || c.HealthCheck.SuccessStatusMax != 0 {
+			errs = append(errs, fmt.Errorf("health_check method, body, expect_body and success_status_* do not apply to grpc checks"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("health_check.check_type %q is not supported (want http or grpc)", c.HealthCheck.CheckType))
+	}
+
+	if c.Retry.MaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("retry.max_attempts must not be negative"))
+	}
+	if c.Retry.MaxPerBackend < 0 {
+		errs = append(errs, fmt.Errorf("retry.max_per_backend must not be negative"))
+	}
+	if c.Retry.BudgetPercent < 0 || c.Retry.BudgetPercent > 100 {
+		errs = append(errs, fmt.Errorf("retry.budget_percent %d out of range 0-100", c.Retry.BudgetPercent))
+	}
+	for _, class := range c.Retry.RetryOn {
+		if !slices.Contains(retry.ErrorClasses, class) {
+			errs = append(errs, fmt.Errorf("retry.retry_on: unknown error class %q (want one of %s)", class, strings.Join(retry.ErrorClasses, ", ")))
+		}
+	}
+
+	if c.FailOpen.Enabled && c.FailOpen.MaxWaitMs < 1 {
+		errs = append(errs, fmt.Errorf("fail_open.max_wait_ms must be at least 1"))
+	}
+	if c.WarmUp.Connections < 0 || c.WarmUp.TimeoutMs < 0 {
+		errs = append(errs, fmt.Errorf("warm_up values must not be negative"))
+	}
+	if c.FailOpen.MaxHeld < 0 {
+		errs = append(errs, fmt.Errorf("fail_open.max_held must not be negative"))
+	}
+
+	if c.Admission.Enabled && c.Admission.MaxInFlight < 1 {
+		errs = append(errs, fmt.Errorf("admission.max_in_flight must be at least 1"))
+	}
+	if c.Admission.MaxQueue < 0 || c.Admission.QueueTimeoutMs < 0 {
+		errs = append(errs, fmt.Errorf("admission values must not be negative"))
+	}
+	if c.Admission.DefaultTenantWeight < 0 {
+		errs = append(errs, fmt.Errorf("admission.default_tenant_weight must not be negative"))
+	}
+	for tenant, weight := range c.Admission.TenantWeights {
+		if weight < 1 {
+			errs = append(errs, fmt.Errorf("admission.tenant_weights[%q] must be at least 1", tenant))
+		}
+	}
+	if len(c.Admission.TenantWeights) > 0 && c.Admission.TenantHeader == "" {
+		errs = append(errs, fmt.Errorf("admission.tenant_weights needs tenant_header"))
+	}
+	seenPriorities := make(map[string]bool, len(c.Admission.Priorities))
+	for name, p := range c.Admission.Priorities {
+		if !knownPriority(name) {
+			errs = append(errs, fmt.Errorf("admission.priorities: unknown priority %q (want high, normal or low)", name))
+		}
+		normalized := strings.ToLower(strings.TrimSpace(name))
+		if seenPriorities[normalized] {
+			errs = append(errs, fmt.Errorf("admission.priorities lists %q more than once", normalized))
+		}
+		seenPriorities[normalized] = true
+		if p.TimeoutMs < 0 || p.MaxAttempts < 0 {
+			errs = append(errs, fmt.Errorf("admission.priorities[%q] values must not be negative", name))
+		}
+	}
+	if len(c.Admission.Priorities) > 0 && c.Admission.PriorityHeader == "" {
+		errs = append(errs, fmt.Errorf("admission.priorities needs priority_header"))
+	}
+	for _, cidr := range c.Admission.PriorityTrusted {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			errs = append(errs, fmt.Errorf("admission.priority_trusted: %q is not a CIDR (e.g. 10.0.0.0/8)", cidr))
+		}
+	}
+
+	if c.StickySessions.TTLSeconds < 0 {
+		errs = append(errs, fmt.Errorf("sticky_sessions.ttl_seconds must not be negative"))
+	}
+	switch c.StickySessions.OnBackendDown {
+	case "", "rebalance", "fail":
+	default:
+		errs = append(errs, fmt.Errorf("sticky_sessions.on_backend_down must be rebalance or fail, got %q", c.StickySessions.OnBackendDown))
+	}
+
+	for _, pattern := range slices.Concat(c.AccessControl.AllowPaths, c.AccessControl.DenyPaths) {
+		if _, err := path.Match(pattern, "/"); err != nil || !strings.HasPrefix(pattern, "/") {
+			errs = append(errs, fmt.Errorf("access_control path pattern %q is invalid", pattern))
+		}
+	}
+	for _, method := range slices.Concat(c.AccessControl.AllowMethods, c.AccessControl.DenyMethods) {
+		if method == "" || strings.ContainsAny(method, " \t/") {
+			errs = append(errs, fmt.Errorf("access_control method %q is invalid", method))
+		}
+	}
+
+	if c.Cache.MaxEntries < 0 || c.Cache.MaxEntryBytes < 0 || c.Cache.StaleIfErrorSeconds < 0 {
+		errs = append(errs, fmt.Errorf("cache limits must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RequiresBackends reports whether top-level backends must be configured.
+// They serve requests matching no route, so they are optional when a default
+// group does instead, when host routes exist (unmatched requests then get
+// 404), or when allow_empty_backends is set.
+func (c *Config) RequiresBackends() bool {
+	return !c.AllowEmptyBackends && c.DefaultGroup == "" && len(c.VirtualHosts) == 0 && len(c.SNIRoutes) == 0
+}
+
+// validateHostRoutes checks host patterns and that each route's group exists
+func validateHostRoutes(kind string, routes []VirtualHostConfig, groups map[string]bool) []error {
+	var errs []error
+	for _, vh := range routes {
+		if vh.Host == "" || strings.Contains(strings.TrimPrefix(vh.Host, "*."), "*") {
+			errs = append(errs, fmt.Errorf("%s %q: wildcards are only allowed as a leading \"*.\"", kind, vh.Host))
+		}
+		if !groups[vh.Group] {
+			errs = append(errs, fmt.Errorf("%s %q: unknown group %q", kind, vh.Host, vh.Group))
+		}
+	}
+	return errs
+}
+
+// validateBackends checks that configs parse. In lenient mode bad entries are
+// tolerated unless none of the entries parse.
+func validateBackends(configs []BackendConfig, lenient bool) error {
+	if !lenient {
+		_, err := parseBackends(configs)
+		return err
+	}
+	backends, skipped := parseBackendsLenient(configs)
+	if len(backends) == 0 && len(skipped) > 0 {
+		return fmt.Errorf("no valid backends: %w", errors.Join(skipped...))
+	}
+	return nil
 }
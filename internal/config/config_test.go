@@ -1,8 +1,14 @@
 package config
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/Nash0810/gobalance/internal/logging"
 )
 
 // TestLoadConfigDefaults verifies configuration defaults are applied
@@ -127,3 +133,406 @@ func TestRetryConfig(t *testing.T) {
 		t.Error("BudgetPercent not set correctly")
 	}
 }
+
+// TestValidate verifies invalid configurations are rejected
+func TestValidate(t *testing.T) {
+	valid := Config{
+		Port:     8080,
+		Strategy: "round-robin",
+		Backends: []BackendConfig{{URL: "http://localhost:8081", Weight: 3}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+	}{
+		{"port out of range", func(c *Config) { c.Port = 70000 }},
+		{"unknown strategy", func(c *Config) { c.Strategy = "random" }},
+		{"no backends", func(c *Config) { c.Backends = nil }},
+		{"weight out of range", func(c *Config) { c.Backends[0].Weight = 500 }},
+		{"unknown protocol", func(c *Config) { c.Backends[0].Protocol = "spdy" }},
+		{"bad health url", func(c *Config) { c.Backends[0].HealthURL = "http://[::1" }},
+		{"health url without scheme", func(c *Config) { c.Backends[0].HealthURL = "localhost:9081/health" }},
+		{"bad local addr", func(c *Config) { c.Backends[0].LocalAddr = "eth0" }},
+		{"inverted health status range", func(c *Config) { c.HealthCheck.SuccessStatusMin = 400; c.HealthCheck.SuccessStatusMax = 200 }},
+		{"unsupported health method", func(c *Config) { c.HealthCheck.Method = "DELETE" }},
+		{"health body with GET", func(c *Config) { c.HealthCheck.Body = "{}" }},
+		{"negative timeout", func(c *Config) { c.RequestTimeout = -1 }},
+		{"negative connect timeout", func(c *Config) { c.ConnectTimeoutMs = -1 }},
+		{"negative backend header limit", func(c *Config) { c.MaxBackendHeaderBytes = -1 }},
+		{"connect timeout not below request timeout", func(c *Config) { c.RequestTimeout = 2; c.ConnectTimeoutMs = 2000 }},
+		{"unknown health check type", func(c *Config) { c.HealthCheck.CheckType = "tcp" }},
+		{"grpc check with expect body", func(c *Config) { c.HealthCheck.CheckType = "grpc"; c.HealthCheck.ExpectBody = "ok" }},
+		{"grpc service on http check", func(c *Config) { c.HealthCheck.GRPCService = "api.Users" }},
+		{"negative passive recovery", func(c *Config) { c.HealthCheck.PassiveRecovery = -1 }},
+		{"negative passive cooldown", func(c *Config) { c.HealthCheck.PassiveCooldownMs = -1 }},
+		{"negative min healthy", func(c *Config) { c.HealthCheck.MinHealthy = -1 }},
+		{"min healthy fraction above 1", func(c *Config) { c.HealthCheck.MinHealthyFraction = 1.5 }},
+		{"unknown default group", func(c *Config) { c.DefaultGroup = "api" }},
+		{"default group beside top-level backends", func(c *Config) {
+			c.Groups = []GroupConfig{{Name: "api", Backends: []BackendConfig{{URL: "http://localhost:9001"}}}}
+			c.DefaultGroup = "api"
+		}},
+		{"body read error status not an error", func(c *Config) { c.BodyReadErrorStatus = 200 }},
+		{"unknown retry error class", func(c *Config) { c.Retry.RetryOn = []string{"timeout", "dns"} }},
+		{"negative max header bytes", func(c *Config) { c.MaxHeaderBytes = -1 }},
+		{"admin port same as port", func(c *Config) { c.AdminPort = c.Port }},
+		{"pprof without admin port", func(c *Config) { c.EnablePprof = true }},
+		{"negative composite coefficient", func(c *Config) { latency := -1.0; c.CompositeScore.LatencyMs = &latency }},
+		{"state file without interval", func(c *Config) { c.HealthCheck.Enabled = true; c.StateFile.Path = "/tmp/states.json" }},
+		{"state file without health checks", func(c *Config) { c.StateFile.Path = "/tmp/states.json"; c.StateFile.IntervalSeconds = 5 }},
+		{"retry budget out of range", func(c *Config) { c.Retry.BudgetPercent = 150 }},
+		{"negative retries per backend", func(c *Config) { c.Retry.MaxPerBackend = -1 }},
+		{"fail open without wait", func(c *Config) { c.FailOpen.Enabled = true }},
+		{"zero tenant weight", func(c *Config) {
+			c.Admission.TenantHeader = "X-Tenant"
+			c.Admission.TenantWeights = map[string]int{"free": 0}
+		}},
+		{"tenant weights without header", func(c *Config) { c.Admission.TenantWeights = map[string]int{"gold": 10} }},
+		{"unknown priority", func(c *Config) {
+			c.Admission.PriorityHeader = "X-Priority"
+			c.Admission.Priorities = map[string]PriorityConfig{"urgent": {TimeoutMs: 100}}
+		}},
+		{"negative priority timeout", func(c *Config) {
+			c.Admission.PriorityHeader = "X-Priority"
+			c.Admission.Priorities = map[string]PriorityConfig{"low": {TimeoutMs: -1}}
+		}},
+		{"priorities without header", func(c *Config) { c.Admission.Priorities = map[string]PriorityConfig{"high": {MaxAttempts: 5}} }},
+		{"duplicate priority", func(c *Config) {
+			c.Admission.PriorityHeader = "X-Priority"
+			c.Admission.Priorities = map[string]PriorityConfig{"High": {MaxAttempts: 5}, "high": {MaxAttempts: 3}}
+		}},
+		{"bad priority trusted cidr", func(c *Config) { c.Admission.PriorityTrusted = []string{"10.0.0.1"} }},
+		{"bad access path pattern", func(c *Config) { c.AccessControl.DenyPaths = []string{"/[a-"} }},
+		{"relative access path pattern", func(c *Config) { c.AccessControl.AllowPaths = []string{"api/*"} }},
+		{"empty access method", func(c *Config) { c.AccessControl.DenyMethods = []string{""} }},
+		{"virtual host unknown group", func(c *Config) {
+			c.VirtualHosts = []VirtualHostConfig{{Host: "api.example.com", Group: "api"}}
+		}},
+		{"virtual host bad wildcard", func(c *Config) {
+			c.Groups = []GroupConfig{{Name: "api", Backends: []BackendConfig{{URL: "http://localhost:9001"}}}}
+			c.VirtualHosts = []VirtualHostConfig{{Host: "api.*.com", Group: "api"}}
+		}},
+		{"empty group", func(c *Config) { c.Groups = []GroupConfig{{Name: "api"}} }},
+		{"tls cert without key", func(c *Config) { c.TLS.CertFile = "cert.pem" }},
+		{"sni routes without tls", func(c *Config) {
+			c.Groups = []GroupConfig{{Name: "api", Backends: []BackendConfig{{URL: "http://localhost:9001"}}}}
+			c.SNIRoutes = []VirtualHostConfig{{Host: "a.example.com", Group: "api"}}
+		}},
+		{"client ca without tls", func(c *Config) { c.TLS.ClientCAFile = "ca.pem" }},
+		{"client auth without client ca", func(c *Config) {
+			c.TLS = TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientAuth: ClientAuthRequire}
+		}},
+		{"unknown client auth", func(c *Config) {
+			c.TLS = TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientCAFile: "ca.pem", ClientAuth: "always"}
+		}},
+		{"client cert header without verification", func(c *Config) {
+			c.TLS = TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ClientHeader: "X-Client-Cert"}
+		}},
+		{"sni route unknown group", func(c *Config) {
+			c.TLS = TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}
+			c.SNIRoutes = []VirtualHostConfig{{Host: "a.example.com", Group: "missing"}}
+		}},
+		{"unordered duration buckets", func(c *Config) { c.Metrics.DurationBuckets = []float64{0.1, 0.01} }},
+		{"negative circuit breaker timeout weight", func(c *Config) { c.CircuitBreaker.TimeoutWeight = -1 }},
+		{"negative failure penalty", func(c *Config) { c.FailurePenaltyMs = -1 }},
+		{"negative local zone max active", func(c *Config) { c.LocalZoneMaxActive = -1 }},
+		{"unknown group strategy", func(c *Config) {
+			c.Groups = []GroupConfig{{Name: "api", Strategy: "random", Backends: []BackendConfig{{URL: "http://localhost:9001"}}}}
+		}},
+	}
+
+	for _, tt := range tests {
+		cfg := valid
+		cfg.Backends = append([]BackendConfig(nil), valid.Backends...)
+		tt.mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", tt.name)
+		}
+	}
+
+	// Priority names match the way the header does
+	cfg := valid
+	cfg.Admission.PriorityHeader = "X-Priority"
+	cfg.Admission.Priorities = map[string]PriorityConfig{"High": {MaxAttempts: 5}, "LOW": {TimeoutMs: 100}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected mixed-case priorities accepted, got %v", err)
+	}
+}
+
+// TestParseBackendsLenient tests bad entries are skipped while valid ones are kept
+func TestParseBackendsLenient(t *testing.T) {
+	cfg := &Config{
+		Port:     8080,
+		Backends: []BackendConfig{{URL: "http://localhost:8081"}, {URL: "http://[::1"}, {URL: "http://localhost:8083"}},
+	}
+
+	if _, err := cfg.ParseBackends(); err == nil {
+		t.Error("Strict parse should fail on the bad entry")
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Strict validation should fail on the bad entry")
+	}
+
+	backends, skipped := cfg.ParseBackendsLenient()
+	if len(backends) != 2 || len(skipped) != 1 {
+		t.Fatalf("Expected 2 parsed and 1 skipped, got %d and %d", len(backends), len(skipped))
+	}
+	if backends[0].URL.Host != "localhost:8081" || backends[1].URL.Host != "localhost:8083" {
+		t.Errorf("Unexpected backends kept: %s, %s", backends[0].URL, backends[1].URL)
+	}
+	if err := cfg.ValidateLenient(); err != nil {
+		t.Errorf("Lenient validation should accept a partly valid list, got %v", err)
+	}
+
+	cfg.Backends = []BackendConfig{{URL: "http://[::1"}}
+	if err := cfg.ValidateLenient(); err == nil {
+		t.Error("Lenient validation should fail when no backend parses")
+	}
+}
+
+// TestParseBackendsScheme tests scheme-less backend URLs are rejected with a clear
+// error while absolute URLs pass through unchanged
+func TestParseBackendsScheme(t *testing.T) {
+	for _, raw := range []string{"localhost:8081", "//localhost:8081", "/backend", "ftp://localhost:8081"} {
+		cfg := &Config{Port: 8080, Backends: []BackendConfig{{URL: raw}}}
+		_, err := cfg.ParseBackends()
+		if err == nil || !strings.Contains(err.Error(), "http://localhost:8081") {
+			t.Errorf("%q: expected an error suggesting a full URL, got %v", raw, err)
+		}
+	}
+
+	for _, raw := range []string{"http://localhost:8081", "https://api.internal/v1"} {
+		cfg := &Config{Port: 8080, Backends: []BackendConfig{{URL: raw}}}
+		backends, err := cfg.ParseBackends()
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", raw, err)
+		}
+		if got := backends[0].URL.String(); got != raw {
+			t.Errorf("Expected %q unchanged, got %q", raw, got)
+		}
+	}
+}
+
+// writeConfigFile writes a config file into dir and returns its path
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestLoadConfigStateFileInterval tests a state file path alone gets the
+// default write interval, so the loaded config validates
+func TestLoadConfigStateFileInterval(t *testing.T) {
+	path := writeConfigFile(t, t.TempDir(), "config.yaml", `
+backends:
+  - url: http://localhost:8081
+health_check:
+  enabled: true
+state_file:
+  path: /tmp/states.json
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.StateFile.IntervalSeconds != 5 {
+		t.Errorf("Expected the default interval of 5s, got %d", cfg.StateFile.IntervalSeconds)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the loaded config to validate, got %v", err)
+	}
+}
+
+// TestLoadConfigMerge tests an override file merges over a base config key by
+// key, with backends replaced or appended per the merge directive
+func TestLoadConfigMerge(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfigFile(t, dir, "base.yaml", `
+port: 9090
+strategy: round-robin
+backends:
+  - url: http://localhost:8081
+    weight: 3
+health_check:
+  interval: 10
+  path: /healthz
+retry:
+  enabled: true
+  max_attempts: 3
+access_control:
+  deny_methods: [TRACE, CONNECT]
+`)
+	override := `
+strategy: least-connections
+health_check:
+  interval: 2
+backends:
+  - url: http://localhost:8082
+access_control:
+  deny_methods: [TRACE]
+`
+	replace := writeConfigFile(t, dir, "replace.yaml", override)
+	appendFile := writeConfigFile(t, dir, "append.yaml", "merge_backends: append\n"+override)
+
+	cfg, err := LoadConfig(base + "," + replace)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Port != 9090 || cfg.Strategy != "least-connections" {
+		t.Errorf("Expected port from base and strategy from override, got %d/%s", cfg.Port, cfg.Strategy)
+	}
+	if cfg.HealthCheck.Interval != 2 || cfg.HealthCheck.Path != "/healthz" {
+		t.Errorf("Expected nested keys merged, got interval %d path %s", cfg.HealthCheck.Interval, cfg.HealthCheck.Path)
+	}
+	if !cfg.Retry.Enabled || cfg.Retry.MaxAttempts != 3 {
+		t.Errorf("Expected untouched section kept, got %+v", cfg.Retry)
+	}
+	if len(cfg.AccessControl.DenyMethods) != 1 || cfg.AccessControl.DenyMethods[0] != "TRACE" {
+		t.Errorf("Expected lists replaced, got %v", cfg.AccessControl.DenyMethods)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].URL != "http://localhost:8082" {
+		t.Errorf("Expected backends replaced, got %+v", cfg.Backends)
+	}
+
+	cfg, err = LoadConfig(base + "," + appendFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].URL != "http://localhost:8081" ||
+		cfg.Backends[0].Weight != 3 || cfg.Backends[1].URL != "http://localhost:8082" {
+		t.Errorf("Expected backends appended in order, got %+v", cfg.Backends)
+	}
+
+	bad := writeConfigFile(t, dir, "bad.yaml", "merge_backends: prepend\n")
+	if _, err := LoadConfig(base + "," + bad); err == nil || !strings.Contains(err.Error(), "merge_backends") {
+		t.Errorf("Expected an invalid merge directive rejected, got %v", err)
+	}
+}
+
+// TestLoadConfigDirectory tests a directory loads its YAML files in name order
+func TestLoadConfigDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, dir, "20-prod.yaml", "port: 7000\nmerge_backends: append\nbackends:\n  - url: http://localhost:8082\n")
+	writeConfigFile(t, dir, "10-base.yaml", "port: 9090\nbackends:\n  - url: http://localhost:8081\n")
+	writeConfigFile(t, dir, "README.md", "port: 1\n") // Not YAML: ignored
+
+	files, err := Files(dir)
+	if err != nil {
+		t.Fatalf("Files failed: %v", err)
+	}
+	if len(files) != 2 || filepath.Base(files[0]) != "10-base.yaml" || filepath.Base(files[1]) != "20-prod.yaml" {
+		t.Fatalf("Expected both YAML files in name order, got %v", files)
+	}
+
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Port != 7000 {
+		t.Errorf("Expected the later file's port, got %d", cfg.Port)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].URL != "http://localhost:8081" {
+		t.Errorf("Expected base backends followed by appended ones, got %+v", cfg.Backends)
+	}
+}
+
+// TestRoutesWithoutBackends tests top-level backends are optional once a
+// default group or host routes handle the traffic
+func TestRoutesWithoutBackends(t *testing.T) {
+	dir := t.TempDir()
+	groups := "groups:\n  - name: api\n    backends:\n      - url: \"http://localhost:9001\"\n"
+
+	if _, err := LoadConfig(writeConfigFile(t, dir, "groups.yaml", groups)); err == nil {
+		t.Error("Expected groups without routes or a default group to still need backends")
+	}
+
+	for name, content := range map[string]string{
+		"default.yaml": groups + "default_group: api\n",
+		"routes.yaml":  groups + "virtual_hosts:\n  - host: api.example.com\n    group: api\n",
+	} {
+		cfg, err := LoadConfig(writeConfigFile(t, dir, name, content))
+		if err != nil {
+			t.Fatalf("%s: LoadConfig failed: %v", name, err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: expected valid config without top-level backends, got %v", name, err)
+		}
+	}
+}
+
+// TestWatcherReloadRetries tests a config read mid-write is retried until it
+// loads, and that retries stop at the cap
+func TestWatcherReloadRetries(t *testing.T) {
+	dir := t.TempDir()
+	full := "backends:\n  - url: \"http://localhost:8081\"\n"
+	path := writeConfigFile(t, dir, "config.yaml", full[:20]) // Writer not done yet
+
+	var applied []*Config
+	w, err := NewWatcher(path, logging.NewLogger("config"), func(cfg *Config) error {
+		applied = append(applied, cfg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewWatcher failed: %v", err)
+	}
+	defer w.watcher.Close()
+	w.retryBase = time.Millisecond
+	w.retryMaxWait = 4 * time.Millisecond
+
+	loads, finished := 0, false
+	w.load = func(path string) (*Config, error) {
+		loads++
+		cfg, err := LoadConfig(path)
+		if loads == 2 && !finished {
+			os.WriteFile(path, []byte(full), 0o644) // Writer finishes between retries
+			finished = true
+		}
+		return cfg, err
+	}
+
+	w.reloadConfig(context.Background())
+	if loads != 3 || len(applied) != 1 {
+		t.Fatalf("Expected the third load applied, got %d loads and %d applied", loads, len(applied))
+	}
+	if applied[0].Backends[0].URL != "http://localhost:8081" {
+		t.Errorf("Expected the completed config applied, got %+v", applied[0].Backends)
+	}
+
+	// A file that never becomes valid gives up after the capped retries
+	os.WriteFile(path, []byte("backends: ["), 0o644)
+	loads, applied = 0, nil
+	w.reloadConfig(context.Background())
+	if loads != reloadRetries+1 || len(applied) != 0 {
+		t.Errorf("Expected %d loads and nothing applied, got %d loads and %d applied", reloadRetries+1, loads, len(applied))
+	}
+
+	// A newer change cancels the pending retries
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	loads = 0
+	w.reloadConfig(ctx)
+	if loads != 1 {
+		t.Errorf("Expected a cancelled reload to stop after one load, got %d", loads)
+	}
+}
+
+// TestWatcherRetryDelay tests retry delays double up to the cap with jitter
+// keeping each in the upper half of its step
+func TestWatcherRetryDelay(t *testing.T) {
+	w := &Watcher{retryBase: 100 * time.Millisecond, retryMaxWait: time.Second}
+	for attempt, step := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		step *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			if d := w.retryDelay(attempt); d < step/2 || d > step {
+				t.Fatalf("Attempt %d: delay %v outside [%v, %v]", attempt, d, step/2, step)
+			}
+		}
+	}
+}
@@ -2,22 +2,23 @@ package config
 
 import (
 	"fmt"
-	"os"
-
-	"gopkg.in/yaml.v3"
 )
 
-// LoadConfig reads YAML file and parses it into Config struct
+// LoadConfig reads YAML file and parses it into Config struct. The path may
+// also name several files or a directory (see Files); they are merged in order.
 func LoadConfig(filepath string) (*Config, error) {
-	// Read file
-	data, err := os.ReadFile(filepath)
+	files, err := Files(filepath)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := loadMerged(files)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
 
 	// Parse YAML
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := merged.Decode(&config); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
@@ -25,10 +26,15 @@ func LoadConfig(filepath string) (*Config, error) {
 	if config.Port == 0 {
 		config.Port = 8080
 	}
-	if len(config.Backends) == 0 {
+	if len(config.Backends) == 0 && config.RequiresBackends() {
 		return nil, fmt.Errorf("no backends configured")
 	}
 
+	// The admin port carries backend controls: keep it local unless asked
+	if config.AdminBindAddress == "" {
+		config.AdminBindAddress = "127.0.0.1"
+	}
+
 	// Request timeout default (FIX #8)
 	if config.RequestTimeout == 0 {
 		config.RequestTimeout = 30 // 30 seconds default
@@ -59,5 +65,26 @@ func LoadConfig(filepath string) (*Config, error) {
 		config.Retry.BudgetPercent = 10 // 10% of requests can be retries
 	}
 
+	// Cache defaults
+	if config.Cache.MaxEntries == 0 {
+		config.Cache.MaxEntries = 1000
+	}
+	if config.Cache.MaxEntryBytes == 0 {
+		config.Cache.MaxEntryBytes = 1 << 20 // 1 MiB
+	}
+
+	// State file default: a path alone is enough to persist states
+	if config.StateFile.Path != "" && config.StateFile.IntervalSeconds == 0 {
+		config.StateFile.IntervalSeconds = 5
+	}
+
+	// Sticky session defaults
+	if config.StickySessions.CookieName == "" {
+		config.StickySessions.CookieName = "GOBALANCE_BACKEND"
+	}
+	if config.StickySessions.OnBackendDown == "" {
+		config.StickySessions.OnBackendDown = "rebalance"
+	}
+
 	return &config, nil
 }
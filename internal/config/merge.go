@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeBackendsKey is the merge directive an override file sets to say how its
+// backends combine with those from earlier files: "replace" (default) or "append"
+const mergeBackendsKey = "merge_backends"
+
+// Files resolves a config path to the files it names, in merge order. The path
+// may be a single file, a comma-separated list of files, or a directory, in
+// which case its *.yaml and *.yml files are used in lexical order.
+func Files(path string) ([]string, error) {
+	var files []string
+	for _, part := range strings.Split(path, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		info, err := os.Stat(part)
+		if err != nil || !info.IsDir() {
+			files = append(files, part) // Missing files fail when read
+			continue
+		}
+		entries, err := os.ReadDir(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config directory: %w", err)
+		}
+		var dirFiles []string
+		for _, entry := range entries {
+			if !entry.IsDir() && isYAML(entry.Name()) {
+				dirFiles = append(dirFiles, filepath.Join(part, entry.Name()))
+			}
+		}
+		if len(dirFiles) == 0 {
+			return nil, fmt.Errorf("config directory %s has no .yaml files", part)
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files given")
+	}
+	return files, nil
+}
+
+// isYAML reports whether a file name has a YAML extension
+func isYAML(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// loadMerged reads the config files in order and merges them into one YAML
+// mapping. Later files override earlier ones key by key: nested mappings merge,
+// scalars and lists replace, except that backends follow each file's
+// merge_backends directive.
+func loadMerged(files []string) (*yaml.Node, error) {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", file, err)
+		}
+		if doc.Kind == 0 {
+			continue // Empty file
+		}
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("failed to parse config %s: top level must be a mapping", file)
+		}
+		if err := mergeFile(merged, root); err != nil {
+			return nil, fmt.Errorf("failed to merge config %s: %w", file, err)
+		}
+	}
+	return merged, nil
+}
+
+// mergeFile merges one file's top-level mapping into dst, applying its merge directive
+func mergeFile(dst, src *yaml.Node) error {
+	appendBackends := false
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if src.Content[i].Value != mergeBackendsKey {
+			continue
+		}
+		switch mode := src.Content[i+1].Value; mode {
+		case "append":
+			appendBackends = true
+		case "replace":
+		default:
+			return fmt.Errorf("%s must be replace or append, got %q", mergeBackendsKey, mode)
+		}
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if key.Value == mergeBackendsKey {
+			continue
+		}
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case key.Value == "backends" && appendBackends &&
+			existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			existing.Content = append(existing.Content, value.Content...)
+		default:
+			mergeNode(existing, value)
+		}
+	}
+	return nil
+}
+
+// mergeNode overlays src onto dst: mappings merge recursively, anything else replaces
+func mergeNode(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if existing := mappingValue(dst, key.Value); existing != nil {
+			mergeNode(existing, value)
+			continue
+		}
+		dst.Content = append(dst.Content, key, value)
+	}
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
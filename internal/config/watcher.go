@@ -2,45 +2,91 @@ package config
 
 import (
 	"context"
+	"math/rand/v2"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/Nash0810/gobalance/internal/logging"
 	"github.com/fsnotify/fsnotify"
 )
 
+// Reload retry defaults: a config caught mid-write usually reads fine a
+// moment later, so failed loads are retried a few times before giving up
+const (
+	reloadRetries      = 4                      // Retries after the first failed load
+	reloadRetryBase    = 100 * time.Millisecond // Delay before the first retry
+	reloadRetryMaxWait = 2 * time.Second        // Cap on the doubling delay
+)
+
 // Watcher watches for config file changes and triggers reloads
 type Watcher struct {
 	filepath string
 	logger   *logging.Logger
 	onChange func(*Config) error
 	watcher  *fsnotify.Watcher
+	files    map[string]bool // Config files to react to
+	dirs     map[string]bool // Config directories; any YAML file in them counts
+
+	load         func(path string) (*Config, error) // Reads the config (LoadConfig; replaced in tests)
+	retries      int                                // Retries after a failed load
+	retryBase    time.Duration                      // First retry delay, doubled per retry
+	retryMaxWait time.Duration                      // Cap on the retry delay
 }
 
-// NewWatcher creates a new config file watcher
-func NewWatcher(filepath string, logger *logging.Logger, onChange func(*Config) error) (*Watcher, error) {
-	watcher, err := fsnotify.NewWatcher()
+// NewWatcher creates a new config file watcher. configPath may name several
+// files or a directory, as accepted by LoadConfig.
+func NewWatcher(configPath string, logger *logging.Logger, onChange func(*Config) error) (*Watcher, error) {
+	files, err := Files(configPath)
 	if err != nil {
 		return nil, err
 	}
 
-	// Watch the directory containing the config file (handles editor atomic writes)
-	dir := filepath
-	if !isDir(filepath) {
-		dir = filepath[:len(filepath)-len(filepath[len(filepath)-1:])]
-	}
-
-	if err := watcher.Add(dir); err != nil {
-		watcher.Close()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
 		return nil, err
 	}
 
-	return &Watcher{
-		filepath: filepath,
+	w := &Watcher{
+		filepath: configPath,
 		logger:   logger,
 		onChange: onChange,
 		watcher:  watcher,
-	}, nil
+		files:    make(map[string]bool),
+		dirs:     make(map[string]bool),
+
+		load:         LoadConfig,
+		retries:      reloadRetries,
+		retryBase:    reloadRetryBase,
+		retryMaxWait: reloadRetryMaxWait,
+	}
+
+	// Watch the directories containing the config files (handles editor atomic writes)
+	watched := make(map[string]bool)
+	for _, file := range files {
+		w.files[filepath.Clean(file)] = true
+		watched[filepath.Dir(file)] = true
+	}
+	for _, part := range strings.Split(configPath, ",") {
+		if info, err := os.Stat(strings.TrimSpace(part)); err == nil && info.IsDir() {
+			w.dirs[filepath.Clean(strings.TrimSpace(part))] = true
+		}
+	}
+	for dir := range watched {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// isConfigFile reports whether a changed file is one of the watched config files
+func (w *Watcher) isConfigFile(name string) bool {
+	name = filepath.Clean(name)
+	return w.files[name] || (w.dirs[filepath.Dir(name)] && isYAML(name))
 }
 
 // Start begins watching for config changes
@@ -51,6 +97,10 @@ func (w *Watcher) Start(ctx context.Context) {
 	var debounceTimer *time.Timer
 	debounceDuration := 500 * time.Millisecond
 
+	// Cancels the retries of the previous reload once a newer change arrives
+	cancelReload := func() {}
+	defer func() { cancelReload() }()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -65,17 +115,20 @@ func (w *Watcher) Start(ctx context.Context) {
 
 			// Only reload on Write or Create events for our config file
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-				// Check if this is our config file (compare base names)
-				if filepath.Base(event.Name) == filepath.Base(w.filepath) {
+				// Check if this is one of our config files
+				if w.isConfigFile(event.Name) {
 					w.logger.Info("config_file_changed", "event", event.Op.String())
 
 					// Debounce: reset timer if already running
 					if debounceTimer != nil {
 						debounceTimer.Stop()
 					}
+					cancelReload()
 
+					reloadCtx, cancel := context.WithCancel(ctx)
+					cancelReload = cancel
 					debounceTimer = time.AfterFunc(debounceDuration, func() {
-						w.reloadConfig()
+						w.reloadConfig(reloadCtx)
 					})
 				}
 			}
@@ -89,14 +142,33 @@ func (w *Watcher) Start(ctx context.Context) {
 	}
 }
 
-// reloadConfig loads the config and calls the onChange callback
-func (w *Watcher) reloadConfig() {
+// reloadConfig loads the config and calls the onChange callback. A failed
+// load, e.g. of a file caught mid-write, is retried with jittered exponential
+// backoff until the retries run out or ctx is cancelled by a newer change.
+func (w *Watcher) reloadConfig(ctx context.Context) {
 	w.logger.Info("reloading_config", "file", w.filepath)
 
-	cfg, err := LoadConfig(w.filepath)
-	if err != nil {
-		w.logger.Error("config_reload_failed", "error", err.Error())
-		return
+	var cfg *Config
+	for attempt := 0; ; attempt++ {
+		var err error
+		cfg, err = w.load(w.filepath)
+		if err == nil {
+			break
+		}
+		if attempt == w.retries {
+			w.logger.Error("config_reload_failed", "error", err.Error(), "attempts", attempt+1)
+			return
+		}
+
+		delay := w.retryDelay(attempt)
+		w.logger.Warn("config_reload_retrying", "error", err.Error(), "attempt", attempt+1, "delay", delay.String())
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
 	}
 
 	if err := w.onChange(cfg); err != nil {
@@ -107,9 +179,14 @@ func (w *Watcher) reloadConfig() {
 	w.logger.Info("config_reloaded_successfully")
 }
 
-// isDir checks if path is a directory
-func isDir(path string) bool {
-	// Simple heuristic: if it has an extension, it's a file
-	ext := filepath.Ext(path)
-	return ext == ""
+// retryDelay returns the wait before retry attempt+1: the base doubled per
+// attempt and capped, then jittered into its upper half so watchers sharing a
+// config volume don't retry in lockstep
+func (w *Watcher) retryDelay(attempt int) time.Duration {
+	delay := w.retryBase
+	for i := 0; i < attempt && delay < w.retryMaxWait; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.retryMaxWait)
+	return delay/2 + rand.N(delay/2+1)
 }
@@ -2,8 +2,13 @@ package health
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
@@ -14,25 +19,50 @@ import (
 
 // ActiveChecker performs periodic health checks on backends
 type ActiveChecker struct {
-	pool       *backend.Pool
-	config     config.HealthCheckConfig
-	client     *http.Client
-	collector  *metrics.Collector   // Prometheus metrics
-	logger     *logging.Logger      // Structured logger
+	pool      *backend.Pool
+	config    config.HealthCheckConfig
+	client    *http.Client
+	collector *metrics.Collector                       // Prometheus metrics
+	logger    *logging.Logger                          // Structured logger
+	breakers  func(b *backend.Backend) *CircuitBreaker // Optional: breaker fed with check results
+	guard     *EjectionGuard                           // Optional: keeps a minimum of the pool healthy
+
+	initialDelay time.Duration // Grace period after a backend is added (failures don't count)
 }
 
 // NewActiveChecker creates a new active health checker
-func NewActiveChecker(pool *backend.Pool, cfg config.HealthCheckConfig, 
+func NewActiveChecker(pool *backend.Pool, cfg config.HealthCheckConfig,
 	collector *metrics.Collector, logger *logging.Logger) *ActiveChecker {
-	return &ActiveChecker{
-		pool:      pool,
-		config:    cfg,
-		client:    &http.Client{
+	ac := &ActiveChecker{
+		pool:   pool,
+		config: cfg,
+		client: &http.Client{
 			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			// Judge the backend's own status; following a redirect would check another URL
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
 		},
-		collector: collector,
-		logger:    logger,
+		collector:    collector,
+		logger:       logger,
+		initialDelay: time.Duration(cfg.InitialDelaySeconds) * time.Second,
+	}
+	if cfg.CheckType == CheckTypeGRPC {
+		ac.client.Transport = newGRPCTransport()
 	}
+	return ac
+}
+
+// SetCircuitBreakerSource makes check results count on each backend's circuit breaker,
+// so failing checks open the circuit even without client traffic
+func (ac *ActiveChecker) SetCircuitBreakerSource(breakers func(b *backend.Backend) *CircuitBreaker) {
+	ac.breakers = breakers
+}
+
+// SetEjectionGuard makes failing checks mark a backend unhealthy only while
+// enough others in the pool stay healthy
+func (ac *ActiveChecker) SetEjectionGuard(g *EjectionGuard) {
+	ac.guard = g
 }
 
 // Start begins the health check loop (runs in background goroutine)
@@ -68,16 +98,83 @@ func (ac *ActiveChecker) checkAllBackends() {
 	backends := ac.pool.GetBackends()
 
 	for _, b := range backends {
+		if b.GetState() == backend.Draining {
+			continue // Being removed; a passing check must not make it routable again
+		}
 		go ac.checkBackend(b) // Check in parallel
 	}
 }
 
+// Health check failure reasons (reason label on HealthCheckFailures)
+const (
+	ReasonTimeout         = "timeout"          // No response within the check timeout
+	ReasonConnectionError = "connection_error" // Connection refused, reset, DNS failure, ...
+	ReasonBadStatus       = "bad_status"       // Status outside the success range (default 2xx)
+	ReasonBodyMismatch    = "body_mismatch"    // Response body lacks the expected content
+)
+
+// maxHealthBodyBytes caps how much of a health check response is read for body matching
+const maxHealthBodyBytes = 64 << 10
+
+// ProbeURL returns the URL health checks probe for b: its separate health URL
+// if set, otherwise checkPath under its traffic URL
+func ProbeURL(b *backend.Backend, checkPath string) string {
+	if healthURL := b.HealthURL(); healthURL != nil {
+		return healthURL.String() // Health served separately (e.g. admin port)
+	}
+	return probeURL(b.URL, checkPath)
+}
+
+// TrafficProbeURL returns checkPath under b's traffic URL even when health is
+// served separately, for probes that must open connections the proxy reuses
+func TrafficProbeURL(b *backend.Backend, checkPath string) string {
+	return probeURL(b.URL, checkPath)
+}
+
+// probeURL joins the health check path, which may carry a query string, onto
+// the backend URL, keeping the backend's base path and query
+func probeURL(base *url.URL, checkPath string) string {
+	path, query, _ := strings.Cut(checkPath, "?")
+	u := base.JoinPath(path)
+	if query != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&" + query
+		} else {
+			u.RawQuery = query
+		}
+	}
+	return u.String()
+}
+
+// probe sends the configured health check request. The body is a static string,
+// so each probe gets its own reader over it.
+func (ac *ActiveChecker) probe(url string) (*http.Response, error) {
+	method := ac.config.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if ac.config.Body != "" {
+		body = strings.NewReader(ac.config.Body)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return ac.client.Do(req)
+}
+
 // checkBackend performs health check on a single backend
 func (ac *ActiveChecker) checkBackend(b *backend.Backend) {
-	url := fmt.Sprintf("%s%s", b.URL.String(), ac.config.Path)
 	startTime := time.Now()
 
-	resp, err := ac.client.Get(url)
+	var reason string
+	var err error
+	if ac.config.CheckType == CheckTypeGRPC {
+		reason, err = ac.checkGRPC(b)
+	} else {
+		reason, err = ac.checkHTTP(b)
+	}
 	duration := time.Since(startTime).Seconds()
 
 	if ac.collector != nil {
@@ -87,33 +184,64 @@ func (ac *ActiveChecker) checkBackend(b *backend.Backend) {
 
 	if err != nil {
 		// Check failed
-		ac.handleFailure(b, err)
-		if ac.collector != nil {
-			ac.collector.HealthCheckTotal.WithLabelValues(b.URL.Host, "failure").Inc()
-		}
+		ac.handleFailure(b, reason, err)
 		return
 	}
+
+	// Check succeeded
+	ac.handleSuccess(b)
+	if ac.collector != nil {
+		ac.collector.HealthCheckTotal.WithLabelValues(b.URL.Host, "success").Inc()
+	}
+}
+
+// checkHTTP probes b's health URL, returning a failure reason and error
+// unless the status and body are healthy
+func (ac *ActiveChecker) checkHTTP(b *backend.Backend) (string, error) {
+	resp, err := ac.probe(ProbeURL(b, ac.config.Path))
+	if err != nil {
+		return errorReason(err), err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		// Check succeeded
-		ac.handleSuccess(b)
-		if ac.collector != nil {
-			ac.collector.HealthCheckTotal.WithLabelValues(b.URL.Host, "success").Inc()
+	if !ac.statusHealthy(resp.StatusCode) {
+		return ReasonBadStatus, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+
+	if ac.config.ExpectBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBodyBytes))
+		if err != nil {
+			return errorReason(err), err
 		}
-	} else {
-		// Non-2xx status code
-		ac.handleFailure(b, fmt.Errorf("status code: %d", resp.StatusCode))
-		if ac.collector != nil {
-			ac.collector.HealthCheckTotal.WithLabelValues(b.URL.Host, "failure").Inc()
+		if !strings.Contains(string(body), ac.config.ExpectBody) {
+			return ReasonBodyMismatch, fmt.Errorf("body does not contain %q", ac.config.ExpectBody)
 		}
 	}
+	return "", nil
+}
+
+// statusHealthy reports whether a health check status is in the configured success range (default 2xx)
+func (ac *ActiveChecker) statusHealthy(code int) bool {
+	minStatus, maxStatus := ac.config.SuccessStatusRange()
+	return code >= minStatus && code <= maxStatus
+}
+
+// errorReason classifies a transport error as a timeout or connection error
+func errorReason(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ReasonTimeout
+	}
+	return ReasonConnectionError
 }
 
 // handleSuccess processes successful health check
 // FIX: Added coordination with passive health tracker
 func (ac *ActiveChecker) handleSuccess(b *backend.Backend) {
 	b.RecordHealthCheckSuccess()
+	if ac.breakers != nil {
+		ac.breakers(b).RecordSuccess()
+	}
 	metrics := b.GetHealthMetrics()
 	currentState := b.GetState()
 
@@ -133,25 +261,50 @@ func (ac *ActiveChecker) handleSuccess(b *backend.Backend) {
 }
 
 // handleFailure processes failed health check
-func (ac *ActiveChecker) handleFailure(b *backend.Backend, err error) {
+func (ac *ActiveChecker) handleFailure(b *backend.Backend, reason string, err error) {
+	b.SetHealthCheckFailureReason(reason)
+	if ac.collector != nil {
+		ac.collector.HealthCheckTotal.WithLabelValues(b.URL.Host, "failure").Inc()
+		ac.collector.HealthCheckFailures.WithLabelValues(b.URL.Host, reason).Inc()
+	}
+
+	// Newly added backends may still be booting: don't count failures toward the threshold yet
+	if ac.initialDelay > 0 && time.Since(b.AddedAt()) < ac.initialDelay {
+		ac.logger.Info("health_check_failed_during_grace_period",
+			"backend", b.URL.Host,
+			"reason", reason,
+			"error", err.Error())
+		return
+	}
+
 	b.RecordHealthCheckFailure()
+	if ac.breakers != nil {
+		ac.breakers(b).RecordFailure()
+	}
 	metrics := b.GetHealthMetrics()
 	currentState := b.GetState()
 
 	ac.logger.Warn("health_check_failed",
 		"backend", b.URL.Host,
+		"reason", reason,
 		"error", err.Error(),
 		"consecutive_failures", metrics.ConsecutiveFailures)
 
 	// State transition: HEALTHY → UNHEALTHY
 	if currentState == backend.Healthy {
 		if metrics.ConsecutiveFailures >= ac.config.UnhealthyThreshold {
+			if !ac.guard.Eject(ac.pool, b) {
+				ac.logger.Warn("health_ejection_skipped",
+					"backend", b.URL.Host,
+					"reason", "min_healthy",
+					"consecutive_failures", metrics.ConsecutiveFailures)
+				return
+			}
 			ac.logger.Warn("health_state_transition",
 				"backend", b.URL.Host,
 				"old_state", currentState,
 				"new_state", "UNHEALTHY",
 				"consecutive_failures", metrics.ConsecutiveFailures)
-			b.SetState(backend.Unhealthy)
 		}
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"log"
 	"sync"
 	"time"
+
+	"github.com/Nash0810/gobalance/internal/clock"
 )
 
 // CircuitState represents the circuit breaker state
@@ -45,9 +47,17 @@ type CircuitBreaker struct {
 
 	// Configuration
 	failureThreshold int           // Failures before opening circuit
+	timeoutWeight    int           // Failures a timeout counts as toward the threshold
 	successThreshold int           // Successes to close circuit from half-open
-	timeout          time.Duration // Time before trying half-open
+	timeout          time.Duration // Base time before trying half-open
+	maxTimeout       time.Duration // Cap for the exponentially growing open timeout
 	windowSize       time.Duration // FIX #6: Rolling window duration
+
+	reopens int         // Consecutive failed half-open probes (doubles the open timeout)
+	clock   clock.Clock // Time source (a fake in tests)
+	forced  bool        // State pinned by an operator; automatic transitions are suspended
+
+	onStateChange func(name string, from, to CircuitState) // Optional transition callback
 }
 
 // NewCircuitBreaker creates a new circuit breaker
@@ -57,9 +67,44 @@ func NewCircuitBreaker(name string) *CircuitBreaker {
 		state:            StateClosed,
 		recentFailures:   make([]time.Time, 0),
 		failureThreshold: 5,
+		timeoutWeight:    1,
 		successThreshold: 2,
 		timeout:          30 * time.Second,
+		maxTimeout:       10 * time.Minute,
 		windowSize:       10 * time.Second, // FIX #6: 10 second sliding window
+		clock:            clock.Real,
+	}
+}
+
+// SetClock replaces the breaker's time source
+func (cb *CircuitBreaker) SetClock(c clock.Clock) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.clock = c
+}
+
+// SetTimeoutWeight makes each timeout count as n failures toward opening the
+// circuit, so a backend that hangs trips it sooner than one answering with errors
+func (cb *CircuitBreaker) SetTimeoutWeight(n int) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.timeoutWeight = max(n, 1)
+}
+
+// SetStateChangeHandler registers a callback invoked on every state transition.
+// The callback runs while the breaker lock is held and must not call back into the breaker.
+func (cb *CircuitBreaker) SetStateChangeHandler(fn func(name string, from, to CircuitState)) {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.onStateChange = fn
+}
+
+// transition moves the breaker to a new state and notifies the handler (caller holds lock)
+func (cb *CircuitBreaker) transition(to CircuitState) {
+	from := cb.state
+	cb.state = to
+	if cb.onStateChange != nil && from != to {
+		cb.onStateChange(cb.name, from, to)
 	}
 }
 
@@ -68,15 +113,19 @@ func (cb *CircuitBreaker) AllowRequest() bool {
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
 
+	if cb.forced {
+		return cb.state != StateOpen
+	}
+
 	switch cb.state {
 	case StateClosed:
 		return true
 
 	case StateOpen:
 		// Check if timeout elapsed, move to half-open
-		if time.Since(cb.lastFailTime) >= cb.timeout {
+		if cb.clock.Now().Sub(cb.lastFailTime) >= cb.openTimeout() {
 			log.Printf("[CIRCUIT] %s: OPEN → HALF_OPEN (timeout elapsed)", cb.name)
-			cb.state = StateHalfOpen
+			cb.transition(StateHalfOpen)
 			cb.successes = 0
 			return true
 		}
@@ -95,15 +144,19 @@ func (cb *CircuitBreaker) RecordSuccess() {
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
 
+	if cb.forced {
+		return
+	}
 	cb.successes++
 
 	if cb.state == StateHalfOpen {
 		if cb.successes >= int64(cb.successThreshold) {
 			log.Printf("[CIRCUIT] %s: HALF_OPEN → CLOSED (after %d successes)",
 				cb.name, cb.successes)
-			cb.state = StateClosed
+			cb.transition(StateClosed)
 			cb.recentFailures = make([]time.Time, 0) // Clear failure history
 			cb.successes = 0
+			cb.reopens = 0 // Backend recovered: back to the base open timeout
 		}
 	} else if cb.state == StateClosed {
 		// FIX #6: On success, clean old failures from sliding window
@@ -116,32 +169,104 @@ func (cb *CircuitBreaker) RecordSuccess() {
 func (cb *CircuitBreaker) RecordFailure() {
 	cb.mux.Lock()
 	defer cb.mux.Unlock()
+	cb.recordFailures(1)
+}
+
+// RecordTimeout records a request that timed out, counting it as the
+// configured timeout weight in failures
+func (cb *CircuitBreaker) RecordTimeout() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	cb.recordFailures(cb.timeoutWeight)
+}
 
-	now := time.Now()
-	cb.recentFailures = append(cb.recentFailures, now)
+// recordFailures adds n failures to the sliding window and opens the circuit
+// when they reach the threshold (caller holds lock)
+func (cb *CircuitBreaker) recordFailures(n int) {
+	if cb.forced {
+		return
+	}
+	now := cb.clock.Now()
+	for i := 0; i < n; i++ {
+		cb.recentFailures = append(cb.recentFailures, now)
+	}
 	cb.lastFailTime = now
 
 	// FIX #6: Remove failures outside the sliding window
 	cb.cleanOldFailures()
 
 	if cb.state == StateHalfOpen {
-		log.Printf("[CIRCUIT] %s: HALF_OPEN → OPEN (test failed)", cb.name)
-		cb.state = StateOpen
+		cb.reopens++
+		log.Printf("[CIRCUIT] %s: HALF_OPEN → OPEN (test failed, next probe in %v)", cb.name, cb.openTimeout())
+		cb.transition(StateOpen)
 		cb.successes = 0
 	} else if cb.state == StateClosed {
 		// FIX #6: Check failures within sliding window
 		if len(cb.recentFailures) >= cb.failureThreshold {
 			log.Printf("[CIRCUIT] %s: CLOSED → OPEN (after %d failures in %v window)",
 				cb.name, len(cb.recentFailures), cb.windowSize)
-			cb.state = StateOpen
+			cb.transition(StateOpen)
 		}
 	}
 }
 
+// ForceOpen pins the circuit open, rejecting every request until Reset,
+// whatever the backend's successes
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	log.Printf("[CIRCUIT] %s: %v → OPEN (forced)", cb.name, cb.state)
+	cb.forced = true
+	cb.transition(StateOpen)
+}
+
+// ForceClose pins the circuit closed, letting every request through until
+// Reset, whatever the backend's failures
+func (cb *CircuitBreaker) ForceClose() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	log.Printf("[CIRCUIT] %s: %v → CLOSED (forced)", cb.name, cb.state)
+	cb.forced = true
+	cb.transition(StateClosed)
+}
+
+// Reset releases a forced state and closes the circuit with a clean failure
+// history, handing control back to the automatic transitions
+func (cb *CircuitBreaker) Reset() {
+	cb.mux.Lock()
+	defer cb.mux.Unlock()
+	log.Printf("[CIRCUIT] %s: %v → CLOSED (reset)", cb.name, cb.state)
+	cb.forced = false
+	cb.recentFailures = make([]time.Time, 0)
+	cb.successes = 0
+	cb.reopens = 0
+	cb.transition(StateClosed)
+}
+
+// IsForced reports whether an operator has pinned the circuit's state
+func (cb *CircuitBreaker) IsForced() bool {
+	cb.mux.RLock()
+	defer cb.mux.RUnlock()
+	return cb.forced
+}
+
+// openTimeout returns how long the circuit stays open before probing (caller holds lock).
+// It doubles with every failed half-open probe, capped at maxTimeout.
+func (cb *CircuitBreaker) openTimeout() time.Duration {
+	timeout := cb.timeout
+	for i := 0; i < cb.reopens && timeout < cb.maxTimeout; i++ {
+		timeout *= 2
+	}
+	if timeout > cb.maxTimeout {
+		timeout = cb.maxTimeout
+	}
+	return timeout
+}
+
 // cleanOldFailures removes failures outside the sliding window
 // FIX #6: Sliding window implementation
 func (cb *CircuitBreaker) cleanOldFailures() {
-	cutoff := time.Now().Add(-cb.windowSize)
+	cutoff := cb.clock.Now().Add(-cb.windowSize)
 	validFailures := make([]time.Time, 0)
 
 	for _, t := range cb.recentFailures {
@@ -0,0 +1,183 @@
+package health
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// Health check types (health_check.check_type)
+const (
+	CheckTypeHTTP = "http" // GET (or the configured method) on the health path (default)
+	CheckTypeGRPC = "grpc" // grpc.health.v1.Health/Check over HTTP/2
+)
+
+// ReasonNotServing is the failure reason for a gRPC check answered with any
+// status other than SERVING
+const ReasonNotServing = "not_serving"
+
+// grpcHealthPath is the standard gRPC health checking method
+const grpcHealthPath = "/grpc.health.v1.Health/Check"
+
+// HealthCheckResponse.ServingStatus values (grpc.health.v1)
+const (
+	grpcStatusUnknown        = 0
+	grpcStatusServing        = 1
+	grpcStatusNotServing     = 2
+	grpcStatusServiceUnknown = 3
+)
+
+// grpcStatusNames names serving statuses for failure messages
+var grpcStatusNames = map[uint64]string{
+	grpcStatusUnknown:        "UNKNOWN",
+	grpcStatusServing:        "SERVING",
+	grpcStatusNotServing:     "NOT_SERVING",
+	grpcStatusServiceUnknown: "SERVICE_UNKNOWN",
+}
+
+// newGRPCTransport speaks HTTP/2 to gRPC backends: over TLS for https URLs
+// and with prior knowledge (h2c) for plain http ones
+func newGRPCTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	protocols := new(http.Protocols)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	t.Protocols = protocols
+	t.ForceAttemptHTTP2 = true
+	return t
+}
+
+// grpcProbeURL returns the Check method URL on b's health URL if set,
+// otherwise on its traffic URL
+func grpcProbeURL(b *backend.Backend) string {
+	base := b.URL
+	if healthURL := b.HealthURL(); healthURL != nil {
+		base = healthURL
+	}
+	return (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: grpcHealthPath}).String()
+}
+
+// checkGRPC calls grpc.health.v1.Health/Check on b, returning a failure
+// reason and error unless the backend answers SERVING
+func (ac *ActiveChecker) checkGRPC(b *backend.Backend) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, grpcProbeURL(b), bytes.NewReader(grpcFrame(grpcHealthRequest(ac.config.GRPCService))))
+	if err != nil {
+		return ReasonConnectionError, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if ac.client.Timeout > 0 {
+		req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dm", ac.client.Timeout/time.Millisecond))
+	}
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return errorReason(err), err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ReasonBadStatus, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBodyBytes))
+	if err != nil {
+		return errorReason(err), err
+	}
+
+	// A call that fails outright (e.g. UNIMPLEMENTED when the server has no
+	// health service) sends only headers, so check them before the trailers
+	code := resp.Header.Get("Grpc-Status")
+	message := resp.Header.Get("Grpc-Message")
+	if code == "" {
+		code, message = resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	}
+	if code != "0" {
+		return ReasonBadStatus, fmt.Errorf("grpc status %s: %s", code, message)
+	}
+
+	status, err := parseGRPCHealthResponse(body)
+	if err != nil {
+		return ReasonBadStatus, err
+	}
+	if status != grpcStatusServing {
+		name, known := grpcStatusNames[status]
+		if !known {
+			name = fmt.Sprintf("status %d", status)
+		}
+		return ReasonNotServing, fmt.Errorf("backend reports %s", name)
+	}
+	return "", nil
+}
+
+// grpcHealthRequest encodes a HealthCheckRequest: field 1 is the service name,
+// left out entirely for the server as a whole
+func grpcHealthRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	msg := []byte{0x0a} // Field 1, length-delimited
+	msg = binary.AppendUvarint(msg, uint64(len(service)))
+	return append(msg, service...)
+}
+
+// grpcFrame prefixes an uncompressed message with the gRPC length header
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// parseGRPCHealthResponse extracts the serving status (field 1) from a framed
+// HealthCheckResponse. An absent field is UNKNOWN, as in proto3.
+func parseGRPCHealthResponse(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, errors.New("grpc response has no message")
+	}
+	if body[0] != 0 {
+		return 0, errors.New("grpc response is compressed")
+	}
+	size := binary.BigEndian.Uint32(body[1:5])
+	msg := body[5:]
+	if uint32(len(msg)) < size {
+		return 0, errors.New("grpc response message is truncated")
+	}
+	msg = msg[:size]
+
+	status := uint64(grpcStatusUnknown)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return 0, errors.New("malformed grpc health response")
+		}
+		msg = msg[n:]
+
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case 0: // Varint
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return 0, errors.New("malformed grpc health response")
+			}
+			msg = msg[n:]
+			if field == 1 {
+				status = v
+			}
+		case 2: // Length-delimited: skipped, no such fields are expected
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < l {
+				return 0, errors.New("malformed grpc health response")
+			}
+			msg = msg[n+int(l):]
+		default:
+			return 0, fmt.Errorf("unexpected wire type %d in grpc health response", wireType)
+		}
+	}
+	return status, nil
+}
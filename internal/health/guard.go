@@ -0,0 +1,63 @@
+package health
+
+import (
+	"math"
+	"sync"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+)
+
+// EjectionGuard keeps health checks from ejecting a backend when that would
+// leave too few healthy ones in its pool (an Envoy-style panic threshold).
+// Past the threshold failing backends stay in rotation, spreading the load
+// instead of sending everything to the last healthy backend. Active checkers
+// and the passive tracker share one guard so ejections are decided one at a
+// time, even when a whole pool fails at once.
+type EjectionGuard struct {
+	minHealthy  int     // Healthy backends to keep (0 = no absolute minimum)
+	minFraction float64 // Fraction of the pool to keep healthy (0 = no minimum)
+	mux         sync.Mutex
+}
+
+// NewEjectionGuard creates a guard keeping at least minHealthy backends, and at
+// least minFraction of each pool, healthy
+func NewEjectionGuard(minHealthy int, minFraction float64) *EjectionGuard {
+	return &EjectionGuard{minHealthy: minHealthy, minFraction: minFraction}
+}
+
+// Eject marks b unhealthy unless that would leave pool below the minimum, and
+// reports whether it did. A nil guard, or an unknown pool, always ejects.
+func (g *EjectionGuard) Eject(pool *backend.Pool, b *backend.Backend) bool {
+	if g == nil || pool == nil {
+		b.SetState(backend.Unhealthy)
+		return true
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if !g.allows(pool) {
+		return false
+	}
+	b.SetState(backend.Unhealthy)
+	return true
+}
+
+// allows reports whether the pool keeps enough healthy backends with one fewer
+// (caller holds lock). Draining backends are leaving and don't count.
+func (g *EjectionGuard) allows(pool *backend.Pool) bool {
+	total, healthy := 0, 0
+	for _, b := range pool.GetBackends() {
+		switch b.GetState() {
+		case backend.Draining:
+			continue
+		case backend.Healthy:
+			healthy++
+		}
+		total++
+	}
+	remaining := healthy - 1
+	if remaining < g.minHealthy {
+		return false
+	}
+	return remaining >= int(math.Ceil(g.minFraction*float64(total)))
+}
@@ -1,11 +1,23 @@
 package health
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/Nash0810/gobalance/internal/clock"
+	"github.com/Nash0810/gobalance/internal/config"
+	"github.com/Nash0810/gobalance/internal/logging"
+	"github.com/Nash0810/gobalance/internal/metrics"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // TestCircuitBreakerInitialState tests circuit breaker starts CLOSED
@@ -37,58 +49,149 @@ func TestCircuitBreakerThreshold(t *testing.T) {
 	}
 }
 
-// TestCircuitBreakerSlidingWindow tests failures outside window are ignored
-func TestCircuitBreakerSlidingWindow(t *testing.T) {
+// TestCircuitBreakerTimeoutWeight tests weighted timeouts open the circuit
+// after fewer events than plain failures, sharing the same window
+func TestCircuitBreakerTimeoutWeight(t *testing.T) {
 	cb := NewCircuitBreaker("test-backend")
+	for i := 0; i < 4; i++ {
+		cb.RecordTimeout()
+	}
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Unweighted timeouts should count as single failures, got %v after 4", cb.GetState())
+	}
 
-	// Record 5 failures
-	for i := 0; i < 5; i++ {
-		cb.RecordFailure()
+	cb = NewCircuitBreaker("test-backend")
+	cb.SetTimeoutWeight(3)
+	cb.RecordTimeout()
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Expected closed after one timeout worth 3 failures, got %v", cb.GetState())
+	}
+	cb.RecordTimeout()
+	if cb.GetState() != StateOpen {
+		t.Errorf("Expected two timeouts worth 3 failures each to open the circuit, got %v", cb.GetState())
 	}
 
+	// Failures and timeouts add up in the window
+	cb = NewCircuitBreaker("test-backend")
+	cb.SetTimeoutWeight(3)
+	cb.RecordFailure()
+	cb.RecordTimeout()
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Expected closed at 4 weighted failures, got %v", cb.GetState())
+	}
+	cb.RecordFailure()
 	if cb.GetState() != StateOpen {
-		t.Error("Circuit should open after 5 failures")
+		t.Errorf("Expected open at 5 weighted failures, got %v", cb.GetState())
 	}
+}
 
-	// Wait for window to expire (10s), then record a new failure
-	// This simulates time passing and old failures falling out of window
-	// For testing purposes, we'll verify that a fresh circuit can record failures again
-	cb2 := NewCircuitBreaker("test-backend-2")
+// TestCircuitBreakerSlidingWindow tests failures outside window are ignored
+func TestCircuitBreakerSlidingWindow(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	cb := NewCircuitBreaker("test-backend")
+	cb.SetClock(fake)
 
-	// This tests that failures accumulate within the window
+	// 4 failures, then the window (10s) passes
 	for i := 0; i < 4; i++ {
-		cb2.RecordFailure()
+		cb.RecordFailure()
 	}
+	fake.Advance(11 * time.Second)
 
-	if cb2.GetState() != StateClosed {
-		t.Error("Circuit should still be StateClosed at 4 failures (threshold is 5)")
+	// The old failures no longer count towards the threshold of 5
+	for i := 0; i < 4; i++ {
+		cb.RecordFailure()
+	}
+	if cb.GetState() != StateClosed {
+		t.Error("Circuit should still be StateClosed with 4 failures inside the window")
 	}
 
-	// One more failure should open it
-	cb2.RecordFailure()
-	if cb2.GetState() != StateOpen {
-		t.Error("Circuit should be StateOpen at 5 failures")
+	// One more failure inside the window opens it
+	fake.Advance(time.Second)
+	cb.RecordFailure()
+	if cb.GetState() != StateOpen {
+		t.Error("Circuit should be StateOpen at 5 failures inside the window")
 	}
 }
 
-// TestCircuitBreakerHalfOpen tests transition to HALF_OPEN
+// TestCircuitBreakerHalfOpen tests the open circuit probes after its timeout
+// and closes after enough successful probes
 func TestCircuitBreakerHalfOpen(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
 	cb := NewCircuitBreaker("test-backend")
+	cb.SetClock(fake)
 
 	// Open the circuit
 	for i := 0; i < 5; i++ {
 		cb.RecordFailure()
 	}
-
 	if cb.GetState() != StateOpen {
-		t.Error("Circuit should be StateOpen")
+		t.Fatal("Circuit should be StateOpen")
+	}
+
+	fake.Advance(29 * time.Second)
+	if cb.AllowRequest() {
+		t.Fatal("Circuit should reject requests before the 30s timeout")
+	}
+
+	fake.Advance(time.Second)
+	if !cb.AllowRequest() || cb.GetState() != StateHalfOpen {
+		t.Fatalf("Circuit should be StateHalfOpen after the timeout, got %v", cb.GetState())
 	}
 
-	// Circuit breaker has a timeout before transitioning to HALF_OPEN
-	// We'll just verify that recording successes works when circuit is open
 	cb.RecordSuccess()
+	if cb.GetState() != StateHalfOpen {
+		t.Errorf("Circuit should stay StateHalfOpen after 1 success, got %v", cb.GetState())
+	}
 	cb.RecordSuccess()
-	// Note: The actual state transition logic is complex and time-dependent
+	if cb.GetState() != StateClosed {
+		t.Errorf("Circuit should be StateClosed after 2 successes, got %v", cb.GetState())
+	}
+}
+
+// TestCircuitBreakerForced tests a forced state holds against automatic
+// transitions until Reset hands control back
+func TestCircuitBreakerForced(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	cb := NewCircuitBreaker("test-backend")
+	cb.SetClock(fake)
+
+	cb.ForceOpen()
+	if !cb.IsForced() || cb.GetState() != StateOpen {
+		t.Fatalf("Expected a forced open circuit, got %v (forced=%v)", cb.GetState(), cb.IsForced())
+	}
+	for i := 0; i < 10; i++ {
+		cb.RecordSuccess()
+		if cb.AllowRequest() {
+			t.Fatalf("Forced open circuit allowed request %d", i+1)
+		}
+	}
+	fake.Advance(time.Hour) // Far past any open timeout: no half-open probe
+	if cb.AllowRequest() || cb.GetState() != StateOpen {
+		t.Fatalf("Forced open circuit should not go half-open, got %v", cb.GetState())
+	}
+
+	cb.ForceClose()
+	for i := 0; i < 10; i++ {
+		cb.RecordFailure()
+	}
+	if !cb.AllowRequest() || cb.GetState() != StateClosed {
+		t.Fatalf("Forced closed circuit should ignore failures, got %v", cb.GetState())
+	}
+
+	cb.Reset()
+	if cb.IsForced() || cb.GetState() != StateClosed {
+		t.Fatalf("Expected an automatic closed circuit after reset, got %v (forced=%v)", cb.GetState(), cb.IsForced())
+	}
+	for i := 0; i < 4; i++ {
+		cb.RecordFailure()
+	}
+	if cb.GetState() != StateClosed {
+		t.Fatal("Failures recorded while forced should not count after reset")
+	}
+	cb.RecordFailure()
+	if cb.GetState() != StateOpen {
+		t.Errorf("Expected automatic tripping after reset, got %v", cb.GetState())
+	}
 }
 
 // TestPassiveTrackerConsecutiveFailures tests failure counting
@@ -114,6 +217,64 @@ func TestPassiveTrackerConsecutiveFailures(t *testing.T) {
 	// The state machine requires active health checks to transition from Unhealthy → Healthy
 }
 
+// TestPassiveTrackerRecovery tests consecutive request successes restore a
+// backend that request failures marked down, but not one an active check failed
+func TestPassiveTrackerRecovery(t *testing.T) {
+	u, _ := url.Parse("http://localhost:8081")
+	b := backend.NewBackend(u)
+	tracker := NewPassiveTracker(3)
+	tracker.SetRecoveryThreshold(2)
+
+	for i := 0; i < 3; i++ {
+		tracker.RecordFailure(b, nil)
+	}
+	if b.GetState() != backend.Unhealthy {
+		t.Fatalf("Expected Unhealthy after 3 failures, got %v", b.GetState())
+	}
+
+	tracker.RecordSuccess(b)
+	if b.GetState() != backend.Unhealthy {
+		t.Fatalf("Expected one success below the threshold to leave it Unhealthy, got %v", b.GetState())
+	}
+	// A failure in between starts the count over
+	tracker.RecordFailure(b, nil)
+	tracker.RecordSuccess(b)
+	if b.GetState() != backend.Unhealthy {
+		t.Fatalf("Expected the count reset by a failure, got %v", b.GetState())
+	}
+	tracker.RecordSuccess(b)
+	if b.GetState() != backend.Healthy {
+		t.Fatalf("Expected Healthy after 2 consecutive successes, got %v", b.GetState())
+	}
+	if !b.IsRoutable() {
+		t.Error("Expected the restored backend routable again")
+	}
+
+	// The latest failure came from an active check: leave it to the checker
+	for i := 0; i < 3; i++ {
+		tracker.RecordFailure(b, nil)
+	}
+	time.Sleep(time.Millisecond) // Order the timestamps
+	b.RecordHealthCheckFailure()
+	for i := 0; i < 5; i++ {
+		tracker.RecordSuccess(b)
+	}
+	if b.GetState() != backend.Unhealthy {
+		t.Errorf("Expected passive successes not to override a failed active check, got %v", b.GetState())
+	}
+
+	// Without a recovery threshold only active checks restore health
+	other := backend.NewBackend(u)
+	plain := NewPassiveTracker(1)
+	plain.RecordFailure(other, nil)
+	for i := 0; i < 5; i++ {
+		plain.RecordSuccess(other)
+	}
+	if other.GetState() != backend.Unhealthy {
+		t.Errorf("Expected no passive recovery by default, got %v", other.GetState())
+	}
+}
+
 // TestBackendHealthStateTransitions tests all state transitions
 func TestBackendHealthStateTransitions(t *testing.T) {
 	u, _ := url.Parse("http://localhost:8081")
@@ -192,3 +353,620 @@ func TestCircuitBreakerConcurrency(t *testing.T) {
 		t.Logf("Circuit state after 100 concurrent failures: %v", cb.GetState())
 	}
 }
+
+// TestCircuitBreakerStateChangeHandler tests trips are reported once per transition
+func TestCircuitBreakerStateChangeHandler(t *testing.T) {
+	cb := NewCircuitBreaker("test-backend")
+	cb.timeout = 0 // Allow immediate OPEN → HALF_OPEN for the test
+
+	trips := 0
+	cb.SetStateChangeHandler(func(name string, from, to CircuitState) {
+		if name != "test-backend" {
+			t.Errorf("Unexpected breaker name %q", name)
+		}
+		if to == StateOpen {
+			trips++
+		}
+	})
+
+	// CLOSED → OPEN after 5 failures is a single trip
+	for i := 0; i < 5; i++ {
+		cb.RecordFailure()
+	}
+	if trips != 1 {
+		t.Fatalf("Expected 1 trip after threshold, got %d", trips)
+	}
+
+	// Failed half-open probes trip again, once each
+	for i := 0; i < 2; i++ {
+		if !cb.AllowRequest() || cb.GetState() != StateHalfOpen {
+			t.Fatal("Breaker should move to HALF_OPEN after timeout")
+		}
+		cb.RecordFailure()
+	}
+	if trips != 3 {
+		t.Errorf("Expected 3 trips, got %d", trips)
+	}
+}
+
+// TestCircuitBreakerOpenTimeoutBackoff tests the open timeout grows after failed probes and resets on close
+func TestCircuitBreakerOpenTimeoutBackoff(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	cb := NewCircuitBreaker("test-backend")
+	cb.SetClock(fake)
+	cb.timeout = 10 * time.Second
+	cb.maxTimeout = 40 * time.Second
+
+	for i := 0; i < 5; i++ {
+		cb.RecordFailure()
+	}
+
+	// probeAfter advances the clock and asserts the breaker opens for exactly d
+	probeAfter := func(d time.Duration) {
+		t.Helper()
+		fake.Advance(d - time.Millisecond)
+		if cb.AllowRequest() {
+			t.Fatalf("Breaker should still be open %v after tripping", d-time.Millisecond)
+		}
+		fake.Advance(time.Millisecond)
+		if !cb.AllowRequest() {
+			t.Fatalf("Breaker should probe %v after tripping", d)
+		}
+	}
+
+	// Each failed probe doubles the open timeout up to the cap
+	for _, d := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 40 * time.Second} {
+		probeAfter(d)
+		cb.RecordFailure()
+	}
+
+	// A successful close resets to the base timeout
+	probeAfter(40 * time.Second)
+	cb.RecordSuccess()
+	cb.RecordSuccess()
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Expected CLOSED after successful probes, got %v", cb.GetState())
+	}
+	for i := 0; i < 5; i++ {
+		cb.RecordFailure()
+	}
+	probeAfter(10 * time.Second)
+}
+
+var (
+	testCollector     *metrics.Collector
+	testCollectorOnce sync.Once
+)
+
+// getTestCollector returns a collector shared by tests (metrics register globally once)
+func getTestCollector() *metrics.Collector {
+	testCollectorOnce.Do(func() {
+		testCollector = metrics.NewCollector()
+	})
+	return testCollector
+}
+
+// TestActiveCheckFailureReasons tests each kind of health check failure records a distinct reason
+func TestActiveCheckFailureReasons(t *testing.T) {
+	collector := getTestCollector()
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		expectBody string
+		want       string
+	}{
+		{"timeout", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}, "", ReasonTimeout},
+		{"bad status", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, "", ReasonBadStatus},
+		{"body mismatch", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":"starting"}`))
+		}, `"status":"ready"`, ReasonBodyMismatch},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(tt.handler)
+		u, _ := url.Parse(server.URL)
+		b := backend.NewBackend(u)
+		pool := backend.NewPool()
+		pool.AddBackend(b)
+
+		cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 3, ExpectBody: tt.expectBody}
+		ac := NewActiveChecker(pool, cfg, collector, logging.NewLogger("health"))
+		ac.client.Timeout = 50 * time.Millisecond
+
+		ac.checkBackend(b)
+		server.Close()
+
+		if got := b.GetHealthMetrics().LastFailureReason; got != tt.want {
+			t.Errorf("%s: expected reason %s, got %q", tt.name, tt.want, got)
+		}
+		var m dto.Metric
+		collector.HealthCheckFailures.WithLabelValues(u.Host, tt.want).Write(&m)
+		if m.GetCounter().GetValue() != 1 {
+			t.Errorf("%s: expected one %s failure recorded, got %v", tt.name, tt.want, m.GetCounter().GetValue())
+		}
+	}
+}
+
+// TestActiveCheckExpectBody tests a matching body passes the check
+func TestActiveCheckExpectBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ready"}`))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	b.RecordHealthCheckFailure()
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", ExpectBody: `"status":"ready"`}
+	NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+
+	if b.GetHealthMetrics().ConsecutiveSuccesses != 1 {
+		t.Error("Matching body should count as a successful check")
+	}
+}
+
+// TestActiveCheckLastCheck tests each probe advances the last-check time and
+// records whether it succeeded
+func TestActiveCheckLastCheck(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+	checker := NewActiveChecker(pool, config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health"}, nil, logging.NewLogger("health"))
+
+	before := time.Now()
+	checker.checkBackend(b)
+	first := b.GetHealthMetrics()
+	if first.LastCheck.Before(before) || !first.LastCheck.Equal(first.LastSuccess) {
+		t.Fatalf("Expected a successful check after %v, got %+v", before, first)
+	}
+
+	time.Sleep(time.Millisecond)
+	healthy.Store(false)
+	checker.checkBackend(b)
+	second := b.GetHealthMetrics()
+	if !second.LastCheck.After(first.LastCheck) || !second.LastCheck.Equal(second.LastFailure) {
+		t.Errorf("Expected a later failed check, got %+v", second)
+	}
+	if !second.LastSuccess.Equal(first.LastSuccess) {
+		t.Errorf("Last success should stay at %v, got %v", first.LastSuccess, second.LastSuccess)
+	}
+}
+
+// TestActiveCheckPostBody tests a POST probe carries the configured body on every
+// check and its response is judged like any other
+func TestActiveCheckPostBody(t *testing.T) {
+	var ready atomic.Bool
+	var probes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"query":"ready"}` {
+			t.Errorf("Expected POST with the configured body, got %s %q", r.Method, body)
+		}
+		probes.Add(1)
+		if !ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ready"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/ready", Method: "POST",
+		Body: `{"query":"ready"}`, ExpectBody: "ready", HealthyThreshold: 1, UnhealthyThreshold: 1}
+	checker := NewActiveChecker(pool, cfg, nil, logging.NewLogger("health"))
+
+	checker.checkBackend(b)
+	if b.IsAlive() {
+		t.Error("Backend answering the POST probe with 503 should be unhealthy")
+	}
+	if got := b.GetHealthMetrics().LastFailureReason; got != ReasonBadStatus {
+		t.Errorf("Expected reason %s, got %q", ReasonBadStatus, got)
+	}
+
+	ready.Store(true)
+	checker.checkBackend(b)
+	if !b.IsAlive() {
+		t.Error("Backend answering the POST probe with 200 should recover")
+	}
+	if probes.Load() != 2 {
+		t.Errorf("Expected 2 probes, got %d", probes.Load())
+	}
+}
+
+// TestActiveCheckFeedsCircuitBreaker tests repeated failed checks open the breaker without client traffic
+func TestActiveCheckFeedsCircuitBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cb := NewCircuitBreaker(u.Host)
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 3, FeedCircuitBreaker: true}
+	ac := NewActiveChecker(pool, cfg, nil, logging.NewLogger("health"))
+	ac.SetCircuitBreakerSource(func(*backend.Backend) *CircuitBreaker { return cb })
+
+	for i := 0; i < 4; i++ {
+		ac.checkBackend(b)
+	}
+	if cb.GetState() != StateClosed {
+		t.Fatalf("Breaker should stay closed below the failure threshold, got %v", cb.GetState())
+	}
+
+	ac.checkBackend(b)
+	if cb.GetState() != StateOpen {
+		t.Errorf("Expected breaker OPEN after 5 failed checks, got %v", cb.GetState())
+	}
+}
+
+// TestActiveCheckSeparateHealthURL tests the health URL is probed instead of the traffic port
+func TestActiveCheckSeparateHealthURL(t *testing.T) {
+	traffic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer traffic.Close()
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			t.Errorf("Expected probe of /ready, got %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer health.Close()
+
+	u, _ := url.Parse(traffic.URL)
+	healthURL, _ := url.Parse(health.URL + "/ready")
+	b := backend.NewBackend(u)
+	b.SetHealthURL(healthURL)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 1}
+	NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+
+	if b.IsAlive() {
+		t.Error("Backend should follow the health port (503), not the healthy traffic port")
+	}
+	if got := b.GetHealthMetrics().LastFailureReason; got != ReasonBadStatus {
+		t.Errorf("Expected reason %s, got %q", ReasonBadStatus, got)
+	}
+}
+
+// TestProbeURL tests health paths and queries compose with the backend's base path and query
+func TestProbeURL(t *testing.T) {
+	tests := []struct {
+		backend string
+		path    string
+		want    string
+	}{
+		{"http://localhost:8081", "/health", "http://localhost:8081/health"},
+		{"http://localhost:8081/", "/health", "http://localhost:8081/health"},
+		{"http://localhost:8081/api", "/health", "http://localhost:8081/api/health"},
+		{"http://localhost:8081/api/", "health", "http://localhost:8081/api/health"},
+		{"http://localhost:8081/api", "/health?deep=1&fmt=json", "http://localhost:8081/api/health?deep=1&fmt=json"},
+		{"http://localhost:8081/api?token=x", "/health?deep=1", "http://localhost:8081/api/health?token=x&deep=1"},
+		{"http://localhost:8081/api?token=x", "/health", "http://localhost:8081/api/health?token=x"},
+	}
+	for _, tt := range tests {
+		base, _ := url.Parse(tt.backend)
+		if got := probeURL(base, tt.path); got != tt.want {
+			t.Errorf("probeURL(%q, %q) = %q, want %q", tt.backend, tt.path, got, tt.want)
+		}
+	}
+}
+
+// TestActiveCheckProbePathAndQuery tests the probe reaches the health path under the
+// backend's base path with the configured query intact
+func TestActiveCheckProbePathAndQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/health" || r.URL.Query().Get("deep") != "1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL + "/api")
+	b := backend.NewBackend(u)
+	b.SetAlive(false)
+	b.SetState(backend.Unhealthy)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health?deep=1", HealthyThreshold: 1}
+	NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+
+	if !b.IsAlive() {
+		t.Errorf("Expected the probe of /api/health?deep=1 to succeed, last failure: %q", b.GetHealthMetrics().LastFailureReason)
+	}
+}
+
+// TestActiveCheckInitialDelay tests failures during a new backend's grace period
+// don't eject it, while failures after the grace period do
+func TestActiveCheckInitialDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable) // Still booting
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 2}
+	ac := NewActiveChecker(pool, cfg, nil, logging.NewLogger("health"))
+	ac.initialDelay = 200 * time.Millisecond
+
+	for i := 0; i < 5; i++ {
+		ac.checkBackend(b)
+	}
+	if !b.IsAlive() || b.GetHealthMetrics().ConsecutiveFailures != 0 {
+		t.Fatalf("Failures during the grace period should not count, got state %v with %d failures",
+			b.GetState(), b.GetHealthMetrics().ConsecutiveFailures)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	ac.checkBackend(b)
+	ac.checkBackend(b)
+	if b.IsAlive() {
+		t.Error("Failures after the grace period should mark the backend unhealthy")
+	}
+}
+
+// TestActiveCheckSuccessStatusRange tests a 301 is unhealthy by default but healthy
+// when the success range is widened to include redirects
+func TestActiveCheckSuccessStatusRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusMovedPermanently)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		min     int
+		max     int
+		healthy bool
+	}{
+		{"default 2xx", 0, 0, false},
+		{"200-399", 200, 399, true},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(server.URL)
+		b := backend.NewBackend(u)
+		pool := backend.NewPool()
+		pool.AddBackend(b)
+
+		cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 1,
+			SuccessStatusMin: tt.min, SuccessStatusMax: tt.max}
+		NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+
+		if b.IsAlive() != tt.healthy {
+			t.Errorf("%s: expected healthy=%v for a 301, got %v", tt.name, tt.healthy, b.IsAlive())
+		}
+	}
+}
+
+// TestActiveCheckKeepsPause tests health checks update a paused backend's
+// health without clearing the pause
+func TestActiveCheckKeepsPause(t *testing.T) {
+	healthy := atomic.Bool{}
+	healthy.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+	b.SetPaused(true)
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 1, HealthyThreshold: 1}
+	ac := NewActiveChecker(pool, cfg, nil, logging.NewLogger("health"))
+
+	ac.checkBackend(b)
+	if !b.IsPaused() || b.GetState() != backend.Healthy || b.IsRoutable() {
+		t.Fatalf("Expected a healthy, paused, unroutable backend, got state %v paused=%v", b.GetState(), b.IsPaused())
+	}
+
+	healthy.Store(false)
+	ac.checkBackend(b)
+	if !b.IsPaused() || b.IsAlive() {
+		t.Fatalf("Expected the failed check to mark the backend unhealthy and keep the pause, got state %v paused=%v", b.GetState(), b.IsPaused())
+	}
+
+	healthy.Store(true)
+	ac.checkBackend(b)
+	if !b.IsPaused() || !b.IsAlive() {
+		t.Errorf("Expected recovery to keep the pause, got state %v paused=%v", b.GetState(), b.IsPaused())
+	}
+}
+
+// newGRPCHealthServer starts a cleartext HTTP/2 stub of grpc.health.v1.Health
+// answering Check with the current status (or failing services it does not know)
+func newGRPCHealthServer(t *testing.T, status *atomic.Uint64, services ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != grpcHealthPath || r.Header.Get("Content-Type") != "application/grpc" {
+			http.Error(w, "not a grpc health call", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		service := ""
+		if len(body) > 7 {
+			service = string(body[7:]) // Frame header, field tag and length byte
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		if service != "" && !slices.Contains(services, service) {
+			w.Header().Set("Grpc-Status", "5") // NOT_FOUND, sent trailers-only
+			w.Header().Set("Grpc-Message", "unknown service")
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write(grpcFrame([]byte{0x08, byte(status.Load())})) // Field 1, varint
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestActiveCheckGRPC tests gRPC health checks follow the backend's serving status
+func TestActiveCheckGRPC(t *testing.T) {
+	var status atomic.Uint64
+	status.Store(grpcStatusServing)
+	server := newGRPCHealthServer(t, &status, "api.Users")
+
+	u, _ := url.Parse(server.URL)
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+	collector := getTestCollector()
+
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, CheckType: CheckTypeGRPC, HealthyThreshold: 1, UnhealthyThreshold: 1}
+	ac := NewActiveChecker(pool, cfg, collector, logging.NewLogger("health"))
+
+	ac.checkBackend(b)
+	if !b.IsAlive() || b.GetHealthMetrics().ConsecutiveSuccesses != 1 {
+		t.Fatalf("Expected SERVING to pass the check, got state %v", b.GetState())
+	}
+
+	status.Store(grpcStatusNotServing)
+	ac.checkBackend(b)
+	if b.IsAlive() {
+		t.Fatal("Expected NOT_SERVING to mark the backend unhealthy")
+	}
+	if reason := b.GetHealthMetrics().LastFailureReason; reason != ReasonNotServing {
+		t.Errorf("Expected reason %s, got %q", ReasonNotServing, reason)
+	}
+	var m dto.Metric
+	collector.HealthCheckFailures.WithLabelValues(u.Host, ReasonNotServing).Write(&m)
+	if m.GetCounter().GetValue() != 1 {
+		t.Errorf("Expected one %s failure recorded, got %v", ReasonNotServing, m.GetCounter().GetValue())
+	}
+
+	status.Store(grpcStatusServing)
+	ac.checkBackend(b)
+	if !b.IsAlive() {
+		t.Error("Expected SERVING again to mark the backend healthy")
+	}
+
+	// Checks for a named service fail when the server does not know it
+	cfg.GRPCService = "api.Orders"
+	NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+	if b.IsAlive() || b.GetHealthMetrics().LastFailureReason != ReasonBadStatus {
+		t.Errorf("Expected an unknown service to fail with %s, got %q", ReasonBadStatus, b.GetHealthMetrics().LastFailureReason)
+	}
+	cfg.GRPCService = "api.Users"
+	NewActiveChecker(pool, cfg, nil, logging.NewLogger("health")).checkBackend(b)
+	if !b.IsAlive() {
+		t.Error("Expected a known, serving service to pass the check")
+	}
+}
+
+// TestEjectionGuardPassive tests request failures stop ejecting backends once
+// the pool is down to its minimum healthy fraction, keeping the rest in rotation
+func TestEjectionGuardPassive(t *testing.T) {
+	pool := backend.NewPool()
+	var backends []*backend.Backend
+	for i := 0; i < 4; i++ {
+		u, _ := url.Parse(fmt.Sprintf("http://localhost:%d", 8081+i))
+		b := backend.NewBackend(u)
+		pool.AddBackend(b)
+		backends = append(backends, b)
+	}
+	tracker := NewPassiveTracker(1)
+	tracker.SetEjectionGuard(NewEjectionGuard(0, 0.5))
+
+	// Every backend fails: only two may go before half the pool would be down
+	for _, b := range backends {
+		tracker.RecordPoolFailure(pool, b, nil)
+	}
+	if got := len(pool.GetRoutableBackends()); got != 2 {
+		t.Fatalf("Expected 2 backends kept in rotation, got %d", got)
+	}
+	for i, want := range []backend.HealthState{backend.Unhealthy, backend.Unhealthy, backend.Healthy, backend.Healthy} {
+		if got := backends[i].GetState(); got != want {
+			t.Errorf("Backend %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	// Once another backend recovers, the still-failing one can be ejected
+	backends[0].SetState(backend.Healthy)
+	tracker.RecordPoolFailure(pool, backends[2], nil)
+	if got := backends[2].GetState(); got != backend.Unhealthy {
+		t.Errorf("Expected ejection allowed again above the minimum, got %v", got)
+	}
+
+	// Without a pool to judge, failures eject as before
+	tracker.RecordFailure(backends[3], nil)
+	if got := backends[3].GetState(); got != backend.Unhealthy {
+		t.Errorf("Expected RecordFailure to eject without a pool, got %v", got)
+	}
+}
+
+// TestEjectionGuardActive tests failing checks keep the last healthy backends
+// in rotation when an absolute minimum is set
+func TestEjectionGuardActive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	pool := backend.NewPool()
+	for i := 0; i < 3; i++ {
+		u, _ := url.Parse(server.URL + fmt.Sprintf("/%d", i))
+		pool.AddBackend(backend.NewBackend(u))
+	}
+	cfg := config.HealthCheckConfig{Enabled: true, Timeout: 1, Path: "/health", UnhealthyThreshold: 1}
+	checker := NewActiveChecker(pool, cfg, nil, logging.NewLogger("health"))
+	checker.SetEjectionGuard(NewEjectionGuard(1, 0))
+
+	var wg sync.WaitGroup
+	for _, b := range pool.GetBackends() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checker.checkBackend(b)
+		}()
+	}
+	wg.Wait()
+
+	if got := len(pool.GetRoutableBackends()); got != 1 {
+		t.Errorf("Expected concurrent failing checks to keep 1 backend in rotation, got %d", got)
+	}
+}
@@ -2,34 +2,135 @@ package health
 
 import (
 	"log"
+	"sync"
+	"time"
 
 	"github.com/Nash0810/gobalance/internal/backend"
 )
 
+// defaultRecoveryCooldown is how long a passively downed backend sits out
+// before trial requests are sent to it, unless configured otherwise
+const defaultRecoveryCooldown = 10 * time.Second
+
 // PassiveTracker monitors real request failures
 type PassiveTracker struct {
-	failureThreshold int // Failures before marking unhealthy
+	failureThreshold  int                       // Failures before marking unhealthy
+	recoveryThreshold int                       // Successes before restoring a passively downed backend (0 = left to active checks)
+	recoveryCooldown  time.Duration             // Time after the last request failure before trial requests start
+	guard             *EjectionGuard            // Optional: keeps a minimum of each pool healthy
+	trials            map[*backend.Backend]bool // Backends with a trial request in flight
+	mux               sync.Mutex                // Protects trials
 }
 
 // NewPassiveTracker creates a new passive health tracker
 func NewPassiveTracker(threshold int) *PassiveTracker {
 	return &PassiveTracker{
 		failureThreshold: threshold,
+		recoveryCooldown: defaultRecoveryCooldown,
+		trials:           make(map[*backend.Backend]bool),
+	}
+}
+
+// SetRecoveryThreshold lets n consecutive successful requests restore a
+// backend that request failures marked unhealthy (0 = only active checks
+// restore it). Needed when active checks are disabled; the successes come from
+// trial requests the balancer sends once the recovery cooldown has passed.
+func (pt *PassiveTracker) SetRecoveryThreshold(n int) {
+	pt.recoveryThreshold = n
+}
+
+// SetRecoveryCooldown sets how long a passively downed backend gets no
+// traffic before trial requests start (<= 0 keeps the 10s default). A failed
+// trial starts the cooldown again.
+func (pt *PassiveTracker) SetRecoveryCooldown(d time.Duration) {
+	if d > 0 {
+		pt.recoveryCooldown = d
+	}
+}
+
+// TrialBackend returns a backend of pool on probation: marked down by request
+// failures, out of rotation for the recovery cooldown and with no other trial
+// in flight. The caller sends it one request and must then call EndTrial.
+// Returns nil if passive recovery is off or no backend is due a trial.
+func (pt *PassiveTracker) TrialBackend(pool *backend.Pool) *backend.Backend {
+	if pt.recoveryThreshold <= 0 {
+		return nil
+	}
+	backends := pool.GetBackends()
+	if len(pool.GetHealthyBackends()) == len(backends) {
+		return nil // Nothing down (cached snapshot, so this is cheap)
+	}
+
+	pt.mux.Lock()
+	defer pt.mux.Unlock()
+	for _, b := range backends {
+		if b.GetState() != backend.Unhealthy || b.IsPaused() || pt.trials[b] {
+			continue
+		}
+		metrics := b.GetHealthMetrics()
+		if !passivelyDown(metrics) || time.Since(metrics.LastPassiveFailure) < pt.recoveryCooldown {
+			continue
+		}
+		pt.trials[b] = true
+		return b
 	}
+	return nil
+}
+
+// EndTrial marks b's trial request as finished, letting another one start
+func (pt *PassiveTracker) EndTrial(b *backend.Backend) {
+	pt.mux.Lock()
+	defer pt.mux.Unlock()
+	delete(pt.trials, b)
+}
+
+// passivelyDown reports whether the latest failure was a request failure, so
+// the backend's state is the passive tracker's verdict rather than the active checker's
+func passivelyDown(metrics backend.HealthMetrics) bool {
+	return !metrics.LastPassiveFailure.IsZero() && !metrics.LastFailure.After(metrics.LastPassiveFailure)
+}
+
+// SetEjectionGuard makes request failures mark a backend unhealthy only while
+// enough others in its pool stay healthy
+func (pt *PassiveTracker) SetEjectionGuard(g *EjectionGuard) {
+	pt.guard = g
 }
 
 // RecordSuccess records a successful request
 func (pt *PassiveTracker) RecordSuccess(b *backend.Backend) {
-	// Reset failure counter on success
 	metrics := b.GetHealthMetrics()
-	if metrics.ConsecutiveFailures > 0 {
-		b.RecordHealthCheckSuccess()
+	if pt.recoveryThreshold <= 0 || b.GetState() != backend.Unhealthy {
+		// Reset failure counter on success
+		if metrics.ConsecutiveFailures > 0 {
+			b.RecordHealthCheckSuccess()
+		}
+		return
+	}
+
+	b.RecordHealthCheckSuccess()
+	metrics = b.GetHealthMetrics()
+	if metrics.ConsecutiveSuccesses < pt.recoveryThreshold {
+		return
 	}
+	// Only undo passive verdicts: a failed active check since the last
+	// request failure means the active checker decides
+	if !passivelyDown(metrics) {
+		return
+	}
+	log.Printf("[PASSIVE] %s: Marking HEALTHY (after %d request successes)",
+		b.URL.Host, metrics.ConsecutiveSuccesses)
+	b.SetState(backend.Healthy)
 }
 
 // RecordFailure records a failed request
 func (pt *PassiveTracker) RecordFailure(b *backend.Backend, err error) {
-	b.RecordHealthCheckFailure()
+	pt.RecordPoolFailure(nil, b, err)
+}
+
+// RecordPoolFailure records a failed request to a backend of pool, which the
+// ejection guard judges the backend's health against
+func (pt *PassiveTracker) RecordPoolFailure(pool *backend.Pool, b *backend.Backend, err error) {
+	b.RecordPassiveFailure()
 	metrics := b.GetHealthMetrics()
 	currentState := b.GetState()
 
@@ -39,9 +140,12 @@ func (pt *PassiveTracker) RecordFailure(b *backend.Backend, err error) {
 	// Mark unhealthy if threshold exceeded
 	if currentState == backend.Healthy {
 		if metrics.ConsecutiveFailures >= pt.failureThreshold {
+			if !pt.guard.Eject(pool, b) {
+				log.Printf("[PASSIVE] %s: Kept in rotation (too few healthy backends to eject)", b.URL.Host)
+				return
+			}
 			log.Printf("[PASSIVE] %s: Marking UNHEALTHY (after %d request failures)",
 				b.URL.Host, metrics.ConsecutiveFailures)
-			b.SetState(backend.Unhealthy)
 		}
 	}
 }
@@ -0,0 +1,89 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Group runs named background goroutines that all stop when its context is
+// canceled, and lets shutdown wait for every one of them to return
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running map[string]int // Goroutines still running, by name
+	mux     sync.Mutex
+}
+
+// NewGroup creates a group whose goroutines run until parent is done or Stop is called
+func NewGroup(parent context.Context) *Group {
+	ctx, cancel := context.WithCancel(parent)
+	return &Group{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]int),
+	}
+}
+
+// Go starts fn in a goroutine with the group's context. fn must return once
+// the context is done.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.mux.Lock()
+	g.running[name]++
+	g.mux.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.finished(name)
+		fn(g.ctx)
+	}()
+}
+
+// finished records that a goroutine returned
+func (g *Group) finished(name string) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.running[name]--; g.running[name] == 0 {
+		delete(g.running, name)
+	}
+}
+
+// WaitGroup returns the group's WaitGroup, done once every started goroutine has returned
+func (g *Group) WaitGroup() *sync.WaitGroup {
+	return &g.wg
+}
+
+// Running returns the names of goroutines that haven't returned yet
+func (g *Group) Running() []string {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	names := make([]string, 0, len(g.running))
+	for name := range g.running {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Stop cancels the group's context and waits up to timeout for its goroutines
+// to return. Returns an error naming any still running after the timeout.
+func (g *Group) Stop(timeout time.Duration) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("background goroutines still running after %v: %v", timeout, g.Running())
+	}
+}
@@ -0,0 +1,43 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestGroupStop tests Stop cancels every goroutine and waits for them to return
+func TestGroupStop(t *testing.T) {
+	g := NewGroup(context.Background())
+	for _, name := range []string{"checker", "exporter", "watcher"} {
+		g.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+		})
+	}
+	if got := g.Running(); len(got) != 3 {
+		t.Fatalf("Expected 3 running goroutines, got %v", got)
+	}
+
+	if err := g.Stop(time.Second); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if got := g.Running(); len(got) != 0 {
+		t.Errorf("Expected no running goroutines, got %v", got)
+	}
+}
+
+// TestGroupStopTimeout tests Stop names goroutines that ignore cancellation
+func TestGroupStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	g := NewGroup(context.Background())
+	g.Go("well_behaved", func(ctx context.Context) { <-ctx.Done() })
+	g.Go("stuck", func(ctx context.Context) { <-release })
+
+	err := g.Stop(50 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "stuck") || strings.Contains(err.Error(), "well_behaved") {
+		t.Errorf("Expected an error naming only the stuck goroutine, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package logging
 
 import (
 	"testing"
+	"time"
 )
 
 // TestLoggerCreation verifies logger can be created with prefix
@@ -42,3 +43,23 @@ func TestLoggerMultipleKeyValues(t *testing.T) {
 	// Should not panic with multiple key-value pairs
 	logger.Info("request processed", "id", "abc123", "status", 200, "duration", "45ms")
 }
+
+// TestRateLimiter verifies one line per interval with a suppressed count
+func TestRateLimiter(t *testing.T) {
+	rl := NewRateLimiter(50 * time.Millisecond)
+
+	if ok, _ := rl.Allow(); !ok {
+		t.Fatal("First line should be logged")
+	}
+	for i := 0; i < 5; i++ {
+		if ok, _ := rl.Allow(); ok {
+			t.Fatal("Lines within the interval should be suppressed")
+		}
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	ok, suppressed := rl.Allow()
+	if !ok || suppressed != 5 {
+		t.Errorf("Expected line logged with 5 suppressed, got ok=%v suppressed=%d", ok, suppressed)
+	}
+}
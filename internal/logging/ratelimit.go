@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter lets a repeated log line through at most once per interval and
+// counts how many were suppressed in between
+type RateLimiter struct {
+	interval   time.Duration
+	last       time.Time // When a line was last let through (zero = never)
+	suppressed int64     // Lines dropped since then
+	mux        sync.Mutex
+}
+
+// NewRateLimiter creates a rate limiter allowing one line per interval
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{interval: interval}
+}
+
+// Allow reports whether the line should be logged now. When it returns true it
+// also returns how many lines were suppressed since the last one logged.
+func (rl *RateLimiter) Allow() (bool, int64) {
+	rl.mux.Lock()
+	defer rl.mux.Unlock()
+
+	now := time.Now()
+	if !rl.last.IsZero() && now.Sub(rl.last) < rl.interval {
+		rl.suppressed++
+		return false, 0
+	}
+	suppressed := rl.suppressed
+	rl.last = now
+	rl.suppressed = 0
+	return true, suppressed
+}
@@ -10,43 +10,134 @@ type Collector struct {
 	// Request metrics
 	RequestsTotal       *prometheus.CounterVec
 	RequestDuration     *prometheus.HistogramVec
+	RequestTTFB         *prometheus.HistogramVec
 	ActiveRequests      *prometheus.GaugeVec
+	InFlightRequests    prometheus.Gauge
+	RequestAttempts     prometheus.Histogram
+	RequestsShedTotal   prometheus.Counter
+	NoBackendTotal      prometheus.Counter
+	ClientCanceledTotal prometheus.Counter
 
 	// Backend metrics
+	BackendsTotal       *prometheus.GaugeVec
+	BackendsHealthy     *prometheus.GaugeVec
 	BackendState        *prometheus.GaugeVec
+	BackendStateSince   *prometheus.GaugeVec
+	BackendLastCheck    *prometheus.GaugeVec
 	BackendConnections  *prometheus.GaugeVec
+	BackendErrorRate    *prometheus.GaugeVec
 	CircuitBreakerState *prometheus.GaugeVec
+	CircuitBreakerTrips *prometheus.CounterVec
+	BackendSelections   *prometheus.CounterVec
+	OversizedHeaders    *prometheus.CounterVec
 
 	// Health check metrics
 	HealthCheckTotal    *prometheus.CounterVec
 	HealthCheckDuration *prometheus.HistogramVec
+	HealthCheckFailures *prometheus.CounterVec
 
 	// Retry metrics
 	RetriesTotal        *prometheus.CounterVec
 	RetryBudgetTokens   prometheus.Gauge
+
+	// Cache metrics
+	CacheHitsTotal      prometheus.Counter
+
+	// Lifecycle metrics
+	Draining            prometheus.Gauge
+
+	dropMethod bool // Request metrics have no method label
 }
 
-// NewCollector creates and registers all metrics
+// CollectorOptions tunes the label schema and buckets of the collector's metrics
+type CollectorOptions struct {
+	DropMethodLabel bool      // Aggregate request metrics across methods to limit cardinality
+	DurationBuckets []float64 // Upper bounds in seconds for request duration and TTFB (nil = prometheus.DefBuckets)
+}
+
+// NewCollector creates and registers all metrics with the default registry
 func NewCollector() *Collector {
+	return NewCollectorWithOptions(prometheus.DefaultRegisterer, CollectorOptions{})
+}
+
+// NewCollectorWithOptions creates all metrics with the given label schema and registers them with reg
+func NewCollectorWithOptions(reg prometheus.Registerer, opts CollectorOptions) *Collector {
+	factory := promauto.With(reg)
+	requestLabels := []string{"backend", "method"}
+	if opts.DropMethodLabel {
+		requestLabels = []string{"backend"}
+	}
+	durationBuckets := opts.DurationBuckets
+	if len(durationBuckets) == 0 {
+		durationBuckets = prometheus.DefBuckets
+	}
+
 	return &Collector{
-		RequestsTotal: promauto.NewCounterVec(
+		dropMethod: opts.DropMethodLabel,
+
+		RequestsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gobalance_requests_total",
 				Help: "Total number of requests",
 			},
-			[]string{"backend", "method", "status"},
+			append(requestLabels, "status"),
 		),
 
-		RequestDuration: promauto.NewHistogramVec(
+		RequestDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "gobalance_request_duration_seconds",
 				Help:    "Request duration in seconds",
-				Buckets: prometheus.DefBuckets,
+				Buckets: durationBuckets,
+			},
+			requestLabels,
+		),
+
+		RequestTTFB: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gobalance_request_ttfb_seconds",
+				Help:    "Time from request start to the backend's first response byte in seconds",
+				Buckets: durationBuckets,
+			},
+			requestLabels,
+		),
+
+		InFlightRequests: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gobalance_inflight_requests",
+				Help: "Requests currently being served, including those queued, retrying or awaiting a backend",
+			},
+		),
+
+		RequestAttempts: factory.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "gobalance_request_attempts",
+				Help:    "Number of backend attempts taken per request",
+				Buckets: []float64{1, 2, 3, 4, 5},
 			},
-			[]string{"backend", "method"},
 		),
 
-		ActiveRequests: promauto.NewGaugeVec(
+		RequestsShedTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "gobalance_requests_shed_total",
+				Help: "Total number of requests rejected by the admission queue",
+			},
+		),
+
+		ClientCanceledTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "gobalance_client_canceled_total",
+				Help: "Total number of requests abandoned by the client before a response",
+			},
+		),
+
+		NoBackendTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "gobalance_no_healthy_backend_total",
+				Help: "Total number of requests that found no healthy backend",
+			},
+		),
+
+		ActiveRequests: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "gobalance_active_requests",
 				Help: "Number of active requests per backend",
@@ -54,7 +145,7 @@ func NewCollector() *Collector {
 			[]string{"backend"},
 		),
 
-		BackendState: promauto.NewGaugeVec(
+		BackendState: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "gobalance_backend_state",
 				Help: "Backend health state (0=DOWN, 1=UNHEALTHY, 2=DRAINING, 3=HEALTHY)",
@@ -62,7 +153,23 @@ func NewCollector() *Collector {
 			[]string{"backend"},
 		),
 
-		BackendConnections: promauto.NewGaugeVec(
+		BackendStateSince: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gobalance_backend_state_since_seconds",
+				Help: "Seconds the backend has been in its current health state",
+			},
+			[]string{"backend"},
+		),
+
+		BackendLastCheck: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gobalance_backend_last_check_timestamp",
+				Help: "Unix time of the backend's last health check",
+			},
+			[]string{"backend"},
+		),
+
+		BackendConnections: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "gobalance_backend_connections",
 				Help: "Active connections per backend",
@@ -70,7 +177,31 @@ func NewCollector() *Collector {
 			[]string{"backend"},
 		),
 
-		CircuitBreakerState: promauto.NewGaugeVec(
+		BackendsTotal: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gobalance_backends_total",
+				Help: "Number of backends in the pool",
+			},
+			[]string{"pool"},
+		),
+
+		BackendsHealthy: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gobalance_backends_healthy",
+				Help: "Number of healthy backends in the pool",
+			},
+			[]string{"pool"},
+		),
+
+		BackendErrorRate: factory.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gobalance_backend_error_rate",
+				Help: "Fraction of proxied requests that failed over the last minute",
+			},
+			[]string{"backend"},
+		),
+
+		CircuitBreakerState: factory.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "gobalance_circuit_breaker_state",
 				Help: "Circuit breaker state (0=CLOSED, 1=HALF_OPEN, 2=OPEN)",
@@ -78,7 +209,31 @@ func NewCollector() *Collector {
 			[]string{"backend"},
 		),
 
-		HealthCheckTotal: promauto.NewCounterVec(
+		CircuitBreakerTrips: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gobalance_circuit_breaker_trips_total",
+				Help: "Total number of times a backend's circuit breaker opened",
+			},
+			[]string{"backend"},
+		),
+
+		BackendSelections: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gobalance_backend_selections_total",
+				Help: "Total number of times a backend was selected for an attempt, counted before the outcome is known",
+			},
+			[]string{"backend"},
+		),
+
+		OversizedHeaders: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gobalance_response_headers_too_large_total",
+				Help: "Total number of backend responses rejected with 502 for exceeding the response header size limit",
+			},
+			[]string{"backend"},
+		),
+
+		HealthCheckTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gobalance_health_checks_total",
 				Help: "Total number of health checks",
@@ -86,7 +241,7 @@ func NewCollector() *Collector {
 			[]string{"backend", "result"},
 		),
 
-		HealthCheckDuration: promauto.NewHistogramVec(
+		HealthCheckDuration: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "gobalance_health_check_duration_seconds",
 				Help:    "Health check duration in seconds",
@@ -95,19 +250,61 @@ func NewCollector() *Collector {
 			[]string{"backend"},
 		),
 
-		RetriesTotal: promauto.NewCounterVec(
+		HealthCheckFailures: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gobalance_health_check_failures_total",
+				Help: "Failed health checks by reason (timeout, connection_error, bad_status, body_mismatch)",
+			},
+			[]string{"backend", "reason"},
+		),
+
+		RetriesTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "gobalance_retries_total",
-				Help: "Total number of retries",
+				Help: "Total number of retries by reason (circuit_open, server_error, connection_error, timeout)",
 			},
 			[]string{"reason"},
 		),
 
-		RetryBudgetTokens: promauto.NewGauge(
+		RetryBudgetTokens: factory.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "gobalance_retry_budget_tokens",
 				Help: "Available retry budget tokens",
 			},
 		),
+
+		CacheHitsTotal: factory.NewCounter(
+			prometheus.CounterOpts{
+				Name: "gobalance_cache_hits_total",
+				Help: "Total number of requests served from the response cache",
+			},
+		),
+
+		Draining: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "gobalance_draining",
+				Help: "1 while the load balancer is shutting down and draining in-flight requests",
+			},
+		),
+	}
+}
+
+// RecordRequest counts a completed request and observes its duration
+func (c *Collector) RecordRequest(backend, method, status string, duration float64) {
+	labels := c.requestLabels(backend, method)
+	c.RequestsTotal.WithLabelValues(append(labels, status)...).Inc()
+	c.RequestDuration.WithLabelValues(labels...).Observe(duration)
+}
+
+// RecordTTFB observes the time to a request's first response byte
+func (c *Collector) RecordTTFB(backend, method string, seconds float64) {
+	c.RequestTTFB.WithLabelValues(c.requestLabels(backend, method)...).Observe(seconds)
+}
+
+// requestLabels returns the request metric label values for the collector's schema
+func (c *Collector) requestLabels(backend, method string) []string {
+	if c.dropMethod {
+		return []string{backend}
 	}
+	return []string{backend, method}
 }
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestSeries returns how many request count series exist and their total
+func requestSeries(t *testing.T, reg *prometheus.Registry) (int, float64) {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "gobalance_requests_total" {
+			continue
+		}
+		total := 0.0
+		for _, m := range family.GetMetric() {
+			total += m.GetCounter().GetValue()
+		}
+		return len(family.GetMetric()), total
+	}
+	return 0, 0
+}
+
+// TestCollectorMethodLabel tests requests of different methods get their own
+// series by default and share one once the method label is dropped
+func TestCollectorMethodLabel(t *testing.T) {
+	tests := []struct {
+		name       string
+		dropMethod bool
+		wantSeries int
+	}{
+		{"method label kept", false, 3},
+		{"method label dropped", true, 1},
+	}
+
+	for _, tt := range tests {
+		reg := prometheus.NewRegistry()
+		collector := NewCollectorWithOptions(reg, CollectorOptions{DropMethodLabel: tt.dropMethod})
+		for _, method := range []string{"GET", "POST", "DELETE", "GET"} {
+			collector.RecordRequest("backend:8081", method, "200", 0.01)
+			collector.RecordTTFB("backend:8081", method, 0.005)
+		}
+
+		series, total := requestSeries(t, reg)
+		if series != tt.wantSeries || total != 4 {
+			t.Errorf("%s: expected %d series counting 4 requests, got %d series counting %v", tt.name, tt.wantSeries, series, total)
+		}
+	}
+}
+
+// TestCollectorDurationBuckets tests request durations land in the configured
+// histogram buckets instead of the Prometheus defaults
+func TestCollectorDurationBuckets(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	buckets := []float64{0.0005, 0.001, 0.01, 10}
+	collector := NewCollectorWithOptions(reg, CollectorOptions{DurationBuckets: buckets})
+	for _, d := range []float64{0.0002, 0.0008, 0.0009, 0.005, 7, 30} {
+		collector.RecordRequest("backend:8081", "GET", "200", d)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "gobalance_request_duration_seconds" {
+			continue
+		}
+		histogram := family.GetMetric()[0].GetHistogram()
+		want := []uint64{1, 3, 4, 5} // Cumulative counts per upper bound
+		got := histogram.GetBucket()
+		if len(got) != len(buckets) {
+			t.Fatalf("Expected %d buckets, got %d", len(buckets), len(got))
+		}
+		for i, b := range got {
+			if b.GetUpperBound() != buckets[i] || b.GetCumulativeCount() != want[i] {
+				t.Errorf("Bucket %d: expected le=%v count %d, got le=%v count %d",
+					i, buckets[i], want[i], b.GetUpperBound(), b.GetCumulativeCount())
+			}
+		}
+		if histogram.GetSampleCount() != 6 {
+			t.Errorf("Expected 6 observations, got %d", histogram.GetSampleCount())
+		}
+		return
+	}
+	t.Fatal("Request duration histogram not found")
+}
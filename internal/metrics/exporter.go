@@ -8,10 +8,14 @@ import (
 	"github.com/Nash0810/gobalance/internal/retry"
 )
 
+// DefaultPoolName labels pool metrics for the main backend pool
+const DefaultPoolName = "default"
+
 // Exporter periodically updates metrics from system state
 type Exporter struct {
 	collector    *Collector
-	pool         *backend.Pool
+	pool         *backend.Pool // nil = retry budget only
+	poolName     string // Label for pool-level gauges
 	retryBudget  *retry.Budget
 }
 
@@ -20,10 +24,16 @@ func NewExporter(collector *Collector, pool *backend.Pool, retryBudget *retry.Bu
 	return &Exporter{
 		collector:   collector,
 		pool:        pool,
+		poolName:    DefaultPoolName,
 		retryBudget: retryBudget,
 	}
 }
 
+// SetPoolName sets the pool label (e.g. a virtual-host group name)
+func (e *Exporter) SetPoolName(name string) {
+	e.poolName = name
+}
+
 // Start begins the metrics export loop
 func (e *Exporter) Start(ctx context.Context) {
 	ticker := time.NewTicker(5 * time.Second)
@@ -41,23 +51,41 @@ func (e *Exporter) Start(ctx context.Context) {
 
 // export updates all gauge metrics
 func (e *Exporter) export() {
+	if e.pool != nil {
+		e.exportPool()
+	}
+
+	// Retry budget
+	if e.retryBudget != nil {
+		tokens := float64(e.retryBudget.GetAvailable())
+		e.collector.RetryBudgetTokens.Set(tokens)
+	}
+}
+
+// exportPool updates the pool and per-backend gauges
+func (e *Exporter) exportPool() {
 	backends := e.pool.GetBackends()
 
+	// Pool size
+	e.collector.BackendsTotal.WithLabelValues(e.poolName).Set(float64(len(backends)))
+	e.collector.BackendsHealthy.WithLabelValues(e.poolName).Set(float64(len(e.pool.GetHealthyBackends())))
+
 	for _, b := range backends {
 		backendHost := b.URL.Host
 
 		// Backend state
 		state := float64(b.GetState())
 		e.collector.BackendState.WithLabelValues(backendHost).Set(state)
+		e.collector.BackendStateSince.WithLabelValues(backendHost).Set(time.Since(b.StateChangedAt()).Seconds())
+		if lastCheck := b.GetHealthMetrics().LastCheck; !lastCheck.IsZero() {
+			e.collector.BackendLastCheck.WithLabelValues(backendHost).Set(float64(lastCheck.UnixNano()) / 1e9)
+		}
 
 		// Active connections
 		connections := float64(b.GetActiveRequests())
 		e.collector.BackendConnections.WithLabelValues(backendHost).Set(connections)
-	}
 
-	// Retry budget
-	if e.retryBudget != nil {
-		tokens := float64(e.retryBudget.GetAvailable())
-		e.collector.RetryBudgetTokens.Set(tokens)
+		// Recent error rate
+		e.collector.BackendErrorRate.WithLabelValues(backendHost).Set(b.GetErrorRate(backend.DefaultErrorRateWindow))
 	}
 }
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/backend"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	testCollectorOnce sync.Once
+	testCollector     *Collector
+)
+
+// getTestCollector returns a collector shared by tests (metrics register globally once)
+func getTestCollector() *Collector {
+	testCollectorOnce.Do(func() {
+		testCollector = NewCollector()
+	})
+	return testCollector
+}
+
+// gaugeValue reads the current value of a gauge
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatalf("Failed to read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+// TestExporterStateSince tests the state-since gauge reflects time in the current state
+func TestExporterStateSince(t *testing.T) {
+	collector := getTestCollector()
+
+	u, _ := url.Parse("http://localhost:8081")
+	b := backend.NewBackend(u)
+	b.SetState(backend.Unhealthy)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+
+	time.Sleep(50 * time.Millisecond)
+	NewExporter(collector, pool, nil).export()
+
+	if got := gaugeValue(t, collector.BackendStateSince.WithLabelValues(u.Host)); got < 0.05 || got > 5 {
+		t.Errorf("Expected roughly 0.05s in state, got %v", got)
+	}
+}
+
+// TestExporterLastCheck tests the last-check gauge holds the time of the latest health check
+func TestExporterLastCheck(t *testing.T) {
+	collector := getTestCollector()
+
+	u, _ := url.Parse("http://localhost:8084")
+	b := backend.NewBackend(u)
+	pool := backend.NewPool()
+	pool.AddBackend(b)
+	exporter := NewExporter(collector, pool, nil)
+
+	b.RecordHealthCheckSuccess()
+	exporter.export()
+	first := gaugeValue(t, collector.BackendLastCheck.WithLabelValues(u.Host))
+	if want := float64(b.GetHealthMetrics().LastCheck.UnixNano()) / 1e9; first != want {
+		t.Fatalf("Expected timestamp %v, got %v", want, first)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	b.RecordHealthCheckFailure()
+	exporter.export()
+	if second := gaugeValue(t, collector.BackendLastCheck.WithLabelValues(u.Host)); second <= first {
+		t.Errorf("Expected the timestamp to advance past %v, got %v", first, second)
+	}
+}
+
+// TestExporterPoolSize tests the pool size and healthy count gauges per pool
+func TestExporterPoolSize(t *testing.T) {
+	collector := getTestCollector()
+
+	pool := backend.NewPool()
+	var backends []*backend.Backend
+	for _, raw := range []string{"http://localhost:8081", "http://localhost:8082", "http://localhost:8083"} {
+		u, _ := url.Parse(raw)
+		b := backend.NewBackend(u)
+		pool.AddBackend(b)
+		backends = append(backends, b)
+	}
+	backends[2].SetState(backend.Unhealthy)
+
+	exporter := NewExporter(collector, pool, nil)
+	exporter.SetPoolName("api")
+	exporter.export()
+
+	if got := gaugeValue(t, collector.BackendsTotal.WithLabelValues("api")); got != 3 {
+		t.Errorf("Expected 3 backends, got %v", got)
+	}
+	if got := gaugeValue(t, collector.BackendsHealthy.WithLabelValues("api")); got != 2 {
+		t.Errorf("Expected 2 healthy backends, got %v", got)
+	}
+
+	backends[0].SetState(backend.Unhealthy)
+	exporter.export()
+	if got := gaugeValue(t, collector.BackendsHealthy.WithLabelValues("api")); got != 1 {
+		t.Errorf("Expected 1 healthy backend after marking one unhealthy, got %v", got)
+	}
+	if got := gaugeValue(t, collector.BackendsTotal.WithLabelValues("api")); got != 3 {
+		t.Errorf("Total should stay 3, got %v", got)
+	}
+}
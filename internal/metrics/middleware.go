@@ -37,8 +37,7 @@ func (m *Middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(start).Seconds()
 	statusStr := strconv.Itoa(crw.statusCode)
 
-	m.collector.RequestsTotal.WithLabelValues("all", r.Method, statusStr).Inc()
-	m.collector.RequestDuration.WithLabelValues("all", r.Method).Observe(duration)
+	m.collector.RecordRequest("all", r.Method, statusStr, duration)
 }
 
 // CaptureResponseWriter captures HTTP status code
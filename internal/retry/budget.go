@@ -2,7 +2,8 @@ package retry
 
 import (
 	"sync/atomic"
-	"time"
+
+	"github.com/Nash0810/gobalance/internal/clock"
 )
 
 // Budget limits the number of retries globally using token bucket algorithm
@@ -14,6 +15,11 @@ type Budget struct {
 	refillRate     int64 // Tokens added per second
 	lastRefill     int64 // Unix timestamp of last refill
 	requestCounter int64 // Track actual request rate
+	measuredRate   int64 // Requests per second seen over the last refill window
+	allowed        int64 // Retries granted a token
+	denied         int64 // Retries refused because the budget was exhausted
+
+	clock clock.Clock // Time source (a fake in tests)
 }
 
 // NewBudget creates a new retry budget
@@ -34,11 +40,19 @@ func NewBudget(percent int) *Budget {
 		maxTokens:      maxTokens,
 		percent:        percent,
 		refillRate:     maxTokens,
-		lastRefill:     time.Now().Unix(),
+		lastRefill:     clock.Real.Now().Unix(),
 		requestCounter: 0,
+		clock:          clock.Real,
 	}
 }
 
+// SetClock replaces the budget's time source and restarts the refill window
+// at its current time. Call it before the budget is shared.
+func (b *Budget) SetClock(c clock.Clock) {
+	b.clock = c
+	atomic.StoreInt64(&b.lastRefill, c.Now().Unix())
+}
+
 // TryConsume attempts to consume a token for retry
 // Returns true if retry is allowed, false if budget exhausted
 func (b *Budget) TryConsume() bool {
@@ -47,10 +61,12 @@ func (b *Budget) TryConsume() bool {
 	for {
 		current := atomic.LoadInt64(&b.tokens)
 		if current <= 0 {
+			atomic.AddInt64(&b.denied, 1)
 			return false // Budget exhausted
 		}
 
 		if atomic.CompareAndSwapInt64(&b.tokens, current, current-1) {
+			atomic.AddInt64(&b.allowed, 1)
 			return true // Token consumed
 		}
 	}
@@ -63,7 +79,7 @@ func (b *Budget) TrackRequest() {
 
 // refill adds tokens based on elapsed time and adapts to actual request rate
 func (b *Budget) refill() {
-	now := time.Now().Unix()
+	now := b.clock.Now().Unix()
 	last := atomic.LoadInt64(&b.lastRefill)
 
 	if now <= last {
@@ -76,6 +92,7 @@ func (b *Budget) refill() {
 
 	// FIX #9: Calculate actual request rate and adjust refill
 	actualRate := atomic.SwapInt64(&b.requestCounter, 0)
+	atomic.StoreInt64(&b.measuredRate, actualRate/(now-last))
 	if actualRate > 0 {
 		// Adjust refill rate based on actual traffic
 		b.refillRate = actualRate * int64(b.percent) / 100
@@ -111,3 +128,30 @@ func (b *Budget) GetAvailable() int64 {
 	b.refill()
 	return atomic.LoadInt64(&b.tokens)
 }
+
+// MaxTokens returns the current token capacity, adapted to the measured request rate
+func (b *Budget) MaxTokens() int64 {
+	b.refill()
+	return atomic.LoadInt64(&b.maxTokens)
+}
+
+// MeasuredRate returns the requests per second seen over the last refill window
+func (b *Budget) MeasuredRate() int64 {
+	b.refill()
+	return atomic.LoadInt64(&b.measuredRate)
+}
+
+// Percent returns the percentage of requests that may be retries
+func (b *Budget) Percent() int {
+	return b.percent
+}
+
+// RetriesAllowed returns how many retries have been granted a token
+func (b *Budget) RetriesAllowed() int64 {
+	return atomic.LoadInt64(&b.allowed)
+}
+
+// RetriesDenied returns how many retries were refused because the budget was exhausted
+func (b *Budget) RetriesDenied() int64 {
+	return atomic.LoadInt64(&b.denied)
+}
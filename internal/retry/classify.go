@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// Error classes a policy can retry on
+const (
+	ClassTimeout           = "timeout"            // Dial, read or request deadline exceeded
+	ClassConnectionRefused = "connection_refused" // Nothing listening on the backend port
+	ClassConnectionReset   = "connection_reset"   // Connection reset or broken pipe mid-request
+	ClassUnreachable       = "unreachable"        // No route to the backend's host or network
+	ClassEOF               = "eof"                // Backend closed the connection without a response
+	ClassServerError       = "server_error"       // Backend answered with a 5xx status
+)
+
+// ErrorClasses lists every error class, all of which are retried by default
+var ErrorClasses = []string{
+	ClassTimeout,
+	ClassConnectionRefused,
+	ClassConnectionReset,
+	ClassUnreachable,
+	ClassEOF,
+	ClassServerError,
+}
+
+// StatusError reports a failed attempt: the status sent to the client and,
+// for transport failures, the error behind it
+type StatusError struct {
+	StatusCode int
+	Err        error // Transport error (nil if the backend answered with StatusCode)
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("status %d: %v", e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+// Unwrap returns the transport error
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// fallbackPatterns map error message fragments to classes for errors that
+// carry no type information, e.g. ones flattened to strings by a library
+var fallbackPatterns = []struct {
+	fragment string
+	class    string
+}{
+	{"connection refused", ClassConnectionRefused},
+	{"connection reset", ClassConnectionReset},
+	{"broken pipe", ClassConnectionReset},
+	{"no route to host", ClassUnreachable},
+	{"network is unreachable", ClassUnreachable},
+	{"i/o timeout", ClassTimeout},
+	{"deadline exceeded", ClassTimeout},
+	{"eof", ClassEOF},
+	{"status 5", ClassServerError},
+}
+
+// classify returns the class of a failed attempt's error, or "" if it is not
+// one that a retry can fix. Typed checks come first; message matching is only
+// a last resort for errors without a recognisable type.
+func classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	if class := networkClass(err); class != "" {
+		return class
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 500 {
+			return ClassServerError
+		}
+		if statusErr.Err == nil {
+			return "" // A non-5xx status counted as a failure
+		}
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, p := range fallbackPatterns {
+		if strings.Contains(msg, p.fragment) {
+			return p.class
+		}
+	}
+	return ""
+}
+
+// networkClass classifies transport errors by type
+func networkClass(err error) string {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ClassConnectionReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ClassUnreachable
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ClassEOF
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ClassTimeout
+	}
+	return ""
+}
@@ -5,13 +5,14 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"strings"
 )
 
 // Policy determines whether a request should be retried
 type Policy struct {
-	maxAttempts int
-	budget      *Budget
+	maxAttempts   int
+	maxPerBackend int             // Attempts allowed against any one backend per request (<1 = 1)
+	retryOn       map[string]bool // Error classes retried (nil = all)
+	budget        *Budget
 }
 
 // NewPolicy creates a new retry policy
@@ -22,9 +23,62 @@ func NewPolicy(maxAttempts int, budgetPercent int) *Policy {
 	}
 }
 
+// MaxAttempts returns the total attempts (original + retries) a request may make
+func (p *Policy) MaxAttempts() int {
+	return p.maxAttempts
+}
+
+// SetMaxPerBackend caps how many of a request's attempts may go to the same backend
+func (p *Policy) SetMaxPerBackend(n int) {
+	p.maxPerBackend = n
+}
+
+// MaxPerBackend returns how many attempts a request may make against one backend
+func (p *Policy) MaxPerBackend() int {
+	if p.maxPerBackend < 1 {
+		return 1 // Retries go to a different backend by default
+	}
+	return p.maxPerBackend
+}
+
+// SetRetryOn limits retries to the given error classes (see ErrorClasses);
+// an empty list retries every class
+func (p *Policy) SetRetryOn(classes []string) {
+	if len(classes) == 0 {
+		p.retryOn = nil
+		return
+	}
+	p.retryOn = make(map[string]bool, len(classes))
+	for _, class := range classes {
+		p.retryOn[class] = true
+	}
+}
+
+// retries reports whether errors of the class may be retried
+func (p *Policy) retries(class string) bool {
+	return class != "" && (p.retryOn == nil || p.retryOn[class])
+}
+
 // ShouldRetry determines if a request should be retried
 // FIX #4: Added context cancellation check
 func (p *Policy) ShouldRetry(req *http.Request, err error, attempt int) bool {
+	return p.ShouldRetryWithin(req, err, attempt, 0, false)
+}
+
+// ShouldRetryAnyMethod is ShouldRetry for backends marked always retryable:
+// non-idempotent methods are retried too
+func (p *Policy) ShouldRetryAnyMethod(req *http.Request, err error, attempt int) bool {
+	return p.ShouldRetryWithin(req, err, attempt, 0, true)
+}
+
+// ShouldRetryWithin is ShouldRetry with the request's own attempt limit in
+// place of the policy's (0 = the policy's), skipping the idempotency check if
+// anyMethod
+func (p *Policy) ShouldRetryWithin(req *http.Request, err error, attempt, maxAttempts int, anyMethod bool) bool {
+	if maxAttempts <= 0 {
+		maxAttempts = p.maxAttempts
+	}
+
 	// FIX #4: Check if client canceled (context propagation)
 	if req.Context().Err() != nil {
 		log.Printf("[RETRY] Request context canceled, skipping retry")
@@ -32,13 +86,13 @@ func (p *Policy) ShouldRetry(req *http.Request, err error, attempt int) bool {
 	}
 
 	// Check attempt limit
-	if attempt >= p.maxAttempts {
-		log.Printf("[RETRY] Max attempts (%d) reached", p.maxAttempts)
+	if attempt >= maxAttempts {
+		log.Printf("[RETRY] Max attempts (%d) reached", maxAttempts)
 		return false
 	}
 
 	// Check if method is idempotent
-	if !isIdempotent(req.Method) {
+	if !anyMethod && !isIdempotent(req.Method) {
 		log.Printf("[RETRY] Method %s is not idempotent, skipping retry", req.Method)
 		return false
 	}
@@ -48,8 +102,8 @@ func (p *Policy) ShouldRetry(req *http.Request, err error, attempt int) bool {
 		return false
 	}
 
-	if !isRetryableError(err) {
-		log.Printf("[RETRY] Error is not retryable: %v", err)
+	if class := classify(err); !p.retries(class) {
+		log.Printf("[RETRY] Error is not retryable (class %q): %v", class, err)
 		return false
 	}
 
@@ -84,35 +138,6 @@ func isIdempotent(method string) bool {
 	}
 }
 
-// isRetryableError returns true if error indicates connection failure
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-
-	errStr := err.Error()
-
-	// Connection errors (retryable)
-	retryableErrors := []string{
-		"connection refused",
-		"connection reset",
-		"broken pipe",
-		"no route to host",
-		"i/o timeout",
-		"EOF",
-		"deadline exceeded",
-		"status 5", // 5xx errors
-	}
-
-	for _, retryable := range retryableErrors {
-		if strings.Contains(strings.ToLower(errStr), retryable) {
-			return true
-		}
-	}
-
-	return false
-}
-
 // BufferRequestBody reads and buffers the request body for potential retries
 // FIX #2: Implemented request body buffering for retries
 func BufferRequestBody(req *http.Request) ([]byte, error) {
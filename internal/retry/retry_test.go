@@ -2,9 +2,18 @@ package retry
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"syscall"
 	"testing"
+	"time"
+
+	"github.com/Nash0810/gobalance/internal/clock"
 )
 
 // TestBufferRequestBody tests body buffering for retries
@@ -101,15 +110,238 @@ func TestRetryPolicyShouldRetry(t *testing.T) {
 	}
 }
 
-// TestRetryBudgetAdaptive tests adaptive budget rate
+// TestRetryPolicyShouldRetryWithin tests a request's own attempt limit
+// replaces the policy's in either direction
+func TestRetryPolicyShouldRetryWithin(t *testing.T) {
+	policy := NewPolicy(2, 100)
+	req, _ := http.NewRequest("GET", "http://localhost:8080", nil)
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+
+	if !policy.ShouldRetryWithin(req, refused, 4, 5, false) {
+		t.Error("Expected a retry within the request's higher limit")
+	}
+	if policy.ShouldRetryWithin(req, refused, 5, 5, false) {
+		t.Error("Should not retry at the request's limit")
+	}
+	if policy.ShouldRetryWithin(req, refused, 1, 1, false) {
+		t.Error("Should not retry past a lower request limit")
+	}
+	if !policy.ShouldRetryWithin(req, refused, 1, 0, false) || policy.ShouldRetryWithin(req, refused, 2, 0, false) {
+		t.Error("Expected the policy's limit without a request limit")
+	}
+}
+
+// TestRetryPolicyMaxPerBackend tests retries default to one attempt per backend
+func TestRetryPolicyMaxPerBackend(t *testing.T) {
+	policy := NewPolicy(3, 50)
+	if got := policy.MaxPerBackend(); got != 1 {
+		t.Errorf("Expected default of 1 attempt per backend, got %d", got)
+	}
+	policy.SetMaxPerBackend(2)
+	if got := policy.MaxPerBackend(); got != 2 {
+		t.Errorf("Expected 2 attempts per backend, got %d", got)
+	}
+}
+
+// TestRetryBudgetAdaptive tests the budget refills each second and adapts its
+// capacity to the measured request rate
 func TestRetryBudgetAdaptive(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
 	budget := NewBudget(20) // 20% budget
+	budget.SetClock(fake)
+
+	// Baseline capacity: 20% of 1000 req/s
+	for i := 0; i < 200; i++ {
+		if !budget.TryConsume() {
+			t.Fatalf("Retry %d should fit the initial budget of 200", i+1)
+		}
+	}
+	if budget.TryConsume() {
+		t.Fatal("Budget should be exhausted")
+	}
+
+	// No time passes: no refill
+	if got := budget.GetAvailable(); got != 0 {
+		t.Errorf("Expected no refill without elapsed time, got %d", got)
+	}
 
-	// Simulate high traffic (5000 req/s)
-	for i := 0; i < 5000; i++ {
+	// One quiet second refills up to the capacity
+	fake.Advance(time.Second)
+	if got := budget.GetAvailable(); got != 200 {
+		t.Errorf("Expected a full refill to 200, got %d", got)
+	}
+
+	// 50 req/s of traffic shrinks the capacity to 20% of it
+	for i := 0; i < 50; i++ {
 		budget.TrackRequest()
 	}
+	fake.Advance(time.Second)
+	if got := budget.MaxTokens(); got != 10 {
+		t.Errorf("Expected capacity 10 at 50 req/s, got %d", got)
+	}
+	if got := budget.GetAvailable(); got != 10 {
+		t.Errorf("Expected tokens capped at 10, got %d", got)
+	}
+}
+
+// TestRetryBudgetMeasuredRate tests the budget reports the request rate it adapted to
+func TestRetryBudgetMeasuredRate(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+	budget := NewBudget(10)
+	budget.SetClock(fake)
+	for i := 0; i < 400; i++ {
+		budget.TrackRequest()
+	}
+	fake.Advance(2 * time.Second) // Two seconds' worth of requests
+
+	if got := budget.MeasuredRate(); got != 200 {
+		t.Errorf("Expected a measured rate of 200 req/s, got %d", got)
+	}
+}
+
+// closedAddr returns the address of a TCP listener that has been closed, so
+// dialing it is refused
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// readFromPeer dials a listener whose accepted connections are handled by
+// serve, then returns the error of reading from it
+func readFromPeer(t *testing.T, serve func(c net.Conn), prepare func(c net.Conn)) error {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			serve(c)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	prepare(conn)
+	_, err = conn.Read(make([]byte, 1))
+	return err
+}
+
+// TestClassifyTypedErrors tests real network errors are classified by type
+func TestClassifyTypedErrors(t *testing.T) {
+	_, refused := net.Dial("tcp", closedAddr(t))
+
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	_, dialTimeout := (&net.Dialer{}).DialContext(expired, "tcp", closedAddr(t))
+
+	block := make(chan struct{})
+	defer close(block)
+	readTimeout := readFromPeer(t, func(c net.Conn) { <-block; c.Close() }, func(c net.Conn) {
+		c.SetReadDeadline(time.Now())
+	})
+	eof := readFromPeer(t, func(c net.Conn) { c.Close() }, func(net.Conn) {})
+	// A peer reset is built the way the net package reports one, as an RST
+	// can race the connect on loopback
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"connection refused", refused, ClassConnectionRefused},
+		{"dial deadline", dialTimeout, ClassTimeout},
+		{"read deadline", readTimeout, ClassTimeout},
+		{"peer closed", eof, ClassEOF},
+		{"peer reset", reset, ClassConnectionReset},
+		{"wrapped deadline", fmt.Errorf("proxy: %w", context.DeadlineExceeded), ClassTimeout},
+		{"unexpected EOF", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), ClassEOF},
+		{"5xx status", &StatusError{StatusCode: 503}, ClassServerError},
+		{"proxy error behind 502", &StatusError{StatusCode: 502, Err: refused}, ClassConnectionRefused},
+		{"non-5xx failure status", &StatusError{StatusCode: 429}, ""},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "backend.invalid", IsNotFound: true}, ""},
+		{"client canceled", context.Canceled, ""},
+	}
+	for _, tt := range tests {
+		if tt.err == nil {
+			t.Fatalf("%s: expected an error to classify", tt.name)
+		}
+		if got := classify(tt.err); got != tt.want {
+			t.Errorf("%s: expected class %q for %v, got %q", tt.name, tt.want, tt.err, got)
+		}
+	}
+}
+
+// TestClassifyFallback tests errors flattened to strings are still classified by message
+func TestClassifyFallback(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"read tcp 10.0.0.1:80: connection reset by peer", ClassConnectionReset},
+		{"write: broken pipe", ClassConnectionReset},
+		{"unexpected EOF", ClassEOF},
+		{"status 503", ClassServerError},
+		{"tls: bad certificate", ""},
+	}
+	for _, tt := range tests {
+		if got := classify(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("%q: expected class %q, got %q", tt.msg, tt.want, got)
+		}
+	}
+}
+
+// TestRetryPolicyRetryOn tests retries can be limited to chosen error classes
+func TestRetryPolicyRetryOn(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://localhost:8080", nil)
+	_, refused := net.Dial("tcp", closedAddr(t))
+	timeout := fmt.Errorf("proxy: %w", context.DeadlineExceeded)
+
+	policy := NewPolicy(3, 100)
+	if !policy.ShouldRetry(req, refused, 1) || !policy.ShouldRetry(req, timeout, 1) {
+		t.Fatal("Expected every class retried by default")
+	}
+
+	policy.SetRetryOn([]string{ClassTimeout})
+	if policy.ShouldRetry(req, refused, 1) {
+		t.Error("Connection refused should not be retried when only timeouts are")
+	}
+	if !policy.ShouldRetry(req, timeout, 1) {
+		t.Error("Timeouts should still be retried")
+	}
+	if policy.ShouldRetry(req, &net.DNSError{Err: "no such host", IsNotFound: true}, 1) {
+		t.Error("Unclassified errors are never retried")
+	}
+}
+
+// TestRetryPolicyAnyMethod tests always-retryable backends skip only the idempotency check
+func TestRetryPolicyAnyMethod(t *testing.T) {
+	policy := NewPolicy(3, 100)
+	postReq, _ := http.NewRequest("POST", "http://localhost:8080", bytes.NewBufferString("body"))
+	serverErr := &StatusError{StatusCode: http.StatusBadGateway}
 
-	// Budget should adapt to traffic rate
-	// With adaptive algorithm, tokens should increase based on actual request rate
+	if policy.ShouldRetry(postReq, serverErr, 1) {
+		t.Error("POST should not retry under the default policy")
+	}
+	if !policy.ShouldRetryAnyMethod(postReq, serverErr, 1) {
+		t.Error("POST should retry when any method may be retried")
+	}
+	if policy.ShouldRetryAnyMethod(postReq, serverErr, 3) {
+		t.Error("Max attempts still apply")
+	}
+	if policy.ShouldRetryAnyMethod(postReq, &StatusError{StatusCode: http.StatusTooManyRequests}, 1) {
+		t.Error("Non-retryable errors still aren't retried")
+	}
 }